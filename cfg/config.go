@@ -23,17 +23,43 @@ import (
 	"github.com/spf13/viper"
 )
 
+type CircuitBreakerGcsConnectionConfig struct {
+	Enable bool `yaml:"enable"`
+
+	ErrorRateThreshold float64 `yaml:"error-rate-threshold"`
+
+	MinRequests int64 `yaml:"min-requests"`
+
+	OpenDuration time.Duration `yaml:"open-duration"`
+
+	ReadOnlyWhenOpen bool `yaml:"read-only-when-open"`
+
+	WindowSize int64 `yaml:"window-size"`
+}
+
+type ClientSideEncryptionConfig struct {
+	KeyFile ResolvedPath `yaml:"key-file"`
+}
+
 type Config struct {
 	AppName string `yaml:"app-name"`
 
 	CacheDir ResolvedPath `yaml:"cache-dir"`
 
+	ClientSideEncryption ClientSideEncryptionConfig `yaml:"client-side-encryption"`
+
+	CostAccounting CostAccountingConfig `yaml:"cost-accounting"`
+
 	Debug DebugConfig `yaml:"debug"`
 
 	EnableAtomicRenameObject bool `yaml:"enable-atomic-rename-object"`
 
 	EnableHns bool `yaml:"enable-hns"`
 
+	FailoverBucket string `yaml:"failover-bucket"`
+
+	FailoverTimeout time.Duration `yaml:"failover-timeout"`
+
 	FileCache FileCacheConfig `yaml:"file-cache"`
 
 	FileSystem FileSystemConfig `yaml:"file-system"`
@@ -60,17 +86,53 @@ type Config struct {
 
 	OnlyDir string `yaml:"only-dir"`
 
+	S3 S3Config `yaml:"s3"`
+
+	SnapshotTime string `yaml:"snapshot-time"`
+
+	Union UnionConfig `yaml:"union"`
+
 	Write WriteConfig `yaml:"write"`
 }
 
+type CostAccountingConfig struct {
+	ClassAPricePer10K float64 `yaml:"class-a-price-per-10k"`
+
+	ClassBPricePer10K float64 `yaml:"class-b-price-per-10k"`
+
+	EgressPricePerGib float64 `yaml:"egress-price-per-gib"`
+
+	Enable bool `yaml:"enable"`
+
+	LogInterval time.Duration `yaml:"log-interval"`
+
+	MaxPrefixes int64 `yaml:"max-prefixes"`
+
+	MountLabel string `yaml:"mount-label"`
+
+	PrefixDepth int64 `yaml:"prefix-depth"`
+}
+
 type DebugConfig struct {
 	ExitOnInvariantViolation bool `yaml:"exit-on-invariant-violation"`
 
+	FaultInjectionFile ResolvedPath `yaml:"fault-injection-file"`
+
 	Fuse bool `yaml:"fuse"`
 
 	Gcs bool `yaml:"gcs"`
 
 	LogMutex bool `yaml:"log-mutex"`
+
+	RecordPayloads bool `yaml:"record-payloads"`
+
+	RecordTrace ResolvedPath `yaml:"record-trace"`
+
+	ReplayMatchByRequest bool `yaml:"replay-match-by-request"`
+
+	ReplayTimings bool `yaml:"replay-timings"`
+
+	ReplayTrace ResolvedPath `yaml:"replay-trace"`
 }
 
 type FileCacheConfig struct {
@@ -80,10 +142,14 @@ type FileCacheConfig struct {
 
 	EnableCrc bool `yaml:"enable-crc"`
 
+	EnableEncryption bool `yaml:"enable-encryption"`
+
 	EnableODirect bool `yaml:"enable-o-direct"`
 
 	EnableParallelDownloads bool `yaml:"enable-parallel-downloads"`
 
+	EncryptionKeyFile ResolvedPath `yaml:"encryption-key-file"`
+
 	MaxParallelDownloads int64 `yaml:"max-parallel-downloads"`
 
 	MaxSizeMb int64 `yaml:"max-size-mb"`
@@ -98,6 +164,8 @@ type FileSystemConfig struct {
 
 	DisableParallelDirops bool `yaml:"disable-parallel-dirops"`
 
+	EnableVersionsDir bool `yaml:"enable-versions-dir"`
+
 	FileMode Octal `yaml:"file-mode"`
 
 	FuseOptions []string `yaml:"fuse-options"`
@@ -122,6 +190,8 @@ type FileSystemConfig struct {
 type GcsAuthConfig struct {
 	AnonymousAccess bool `yaml:"anonymous-access"`
 
+	CustomerSuppliedEncryptionKeyFile ResolvedPath `yaml:"customer-supplied-encryption-key-file"`
+
 	KeyFile ResolvedPath `yaml:"key-file"`
 
 	ReuseTokenFromUrl bool `yaml:"reuse-token-from-url"`
@@ -132,6 +202,8 @@ type GcsAuthConfig struct {
 type GcsConnectionConfig struct {
 	BillingProject string `yaml:"billing-project"`
 
+	CircuitBreaker CircuitBreakerGcsConnectionConfig `yaml:"circuit-breaker"`
+
 	ClientProtocol Protocol `yaml:"client-protocol"`
 
 	CustomEndpoint string `yaml:"custom-endpoint"`
@@ -140,6 +212,8 @@ type GcsConnectionConfig struct {
 
 	GrpcConnPoolSize int64 `yaml:"grpc-conn-pool-size"`
 
+	HedgedReads HedgedReadsGcsConnectionConfig `yaml:"hedged-reads"`
+
 	HttpClientTimeout time.Duration `yaml:"http-client-timeout"`
 
 	LimitBytesPerSec float64 `yaml:"limit-bytes-per-sec"`
@@ -165,6 +239,18 @@ type GcsRetriesConfig struct {
 	ReadStall ReadStallGcsRetriesConfig `yaml:"read-stall"`
 }
 
+type HedgedReadsGcsConnectionConfig struct {
+	Enable bool `yaml:"enable"`
+
+	MaxDelay time.Duration `yaml:"max-delay"`
+
+	MaxHedgesPerSec float64 `yaml:"max-hedges-per-sec"`
+
+	MinDelay time.Duration `yaml:"min-delay"`
+
+	TargetPercentile float64 `yaml:"target-percentile"`
+}
+
 type ListConfig struct {
 	EnableEmptyManagedFolders bool `yaml:"enable-empty-managed-folders"`
 }
@@ -239,16 +325,52 @@ type ReadStallGcsRetriesConfig struct {
 	ReqTargetPercentile float64 `yaml:"req-target-percentile"`
 }
 
+type S3Config struct {
+	Endpoint string `yaml:"endpoint"`
+
+	Region string `yaml:"region"`
+}
+
+type UnionConfig struct {
+	LowerLayers []string `yaml:"lower-layers"`
+}
+
 type WriteConfig struct {
 	BlockSizeMb int64 `yaml:"block-size-mb"`
 
+	ConflictPolicy string `yaml:"conflict-policy"`
+
 	CreateEmptyFile bool `yaml:"create-empty-file"`
 
 	EnableStreamingWrites bool `yaml:"enable-streaming-writes"`
 
 	GlobalMaxBlocks int64 `yaml:"global-max-blocks"`
 
+	GzipObjects []string `yaml:"gzip-objects"`
+
+	JournalDir ResolvedPath `yaml:"journal-dir"`
+
 	MaxBlocksPerFile int64 `yaml:"max-blocks-per-file"`
+
+	MaxPendingBlocksPerFile int64 `yaml:"max-pending-blocks-per-file"`
+
+	MemoryBudgetMb int64 `yaml:"memory-budget-mb"`
+
+	ObjectAttributesFile ResolvedPath `yaml:"object-attributes-file"`
+
+	ParallelUploadConcurrency int64 `yaml:"parallel-upload-concurrency"`
+
+	ParallelUploadPartSizeMb int64 `yaml:"parallel-upload-part-size-mb"`
+
+	ParallelUploadThresholdMb int64 `yaml:"parallel-upload-threshold-mb"`
+
+	SniffContentType bool `yaml:"sniff-content-type"`
+
+	SpillDir ResolvedPath `yaml:"spill-dir"`
+
+	WriteBackConcurrency int64 `yaml:"write-back-concurrency"`
+
+	WriteBackStagingBudgetMb int64 `yaml:"write-back-staging-budget-mb"`
 }
 
 func BuildFlagSet(flagSet *pflag.FlagSet) error {
@@ -267,13 +389,97 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	flagSet.Float64P("circuit-breaker-error-rate-threshold", "", 0.5, "Fraction of recent requests of a class that must have failed for its circuit breaker to open, in (0, 1].")
+
+	if err := flagSet.MarkHidden("circuit-breaker-error-rate-threshold"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("circuit-breaker-min-requests", "", 20, "Number of recent requests of a class needed before its circuit breaker can open.")
+
+	if err := flagSet.MarkHidden("circuit-breaker-min-requests"); err != nil {
+		return err
+	}
+
+	flagSet.DurationP("circuit-breaker-open-duration", "", 30000000000*time.Nanosecond, "How long an open circuit breaker fails requests fast before letting a single probe request through.")
+
+	if err := flagSet.MarkHidden("circuit-breaker-open-duration"); err != nil {
+		return err
+	}
+
+	flagSet.BoolP("circuit-breaker-read-only-when-open", "", false, "While any circuit breaker is open, fail all modifications with EROFS and serve reads only from the file and metadata caches.")
+
+	if err := flagSet.MarkHidden("circuit-breaker-read-only-when-open"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("circuit-breaker-window-size", "", 100, "Number of recent requests of a class whose error rate is tracked.")
+
+	if err := flagSet.MarkHidden("circuit-breaker-window-size"); err != nil {
+		return err
+	}
+
 	flagSet.StringP("client-protocol", "", "http1", "The protocol used for communicating with the GCS backend. Value can be 'http1' (HTTP/1.1), 'http2' (HTTP/2) or 'grpc'.")
 
+	flagSet.StringP("client-side-encryption-key-file", "", "", "Path to the file containing the 32-byte key (raw or hex-encoded) wrapping the per-object data keys with which the contents of objects are encrypted before upload. Objects written without encryption are still readable. Disabled if empty.")
+
+	if err := flagSet.MarkHidden("client-side-encryption-key-file"); err != nil {
+		return err
+	}
+
 	flagSet.IntP("cloud-metrics-export-interval-secs", "", 0, "Specifies the interval at which the metrics are uploaded to cloud monitoring")
 
+	flagSet.Float64P("cost-accounting-class-a-price-per-10k", "", 0.05, "Price in dollars of 10,000 class A operations (writes, listings, copies, composes and updates), for estimating the cost of the mount.")
+
+	if err := flagSet.MarkHidden("cost-accounting-class-a-price-per-10k"); err != nil {
+		return err
+	}
+
+	flagSet.Float64P("cost-accounting-class-b-price-per-10k", "", 0.004, "Price in dollars of 10,000 class B operations (reads and stats), for estimating the cost of the mount.")
+
+	if err := flagSet.MarkHidden("cost-accounting-class-b-price-per-10k"); err != nil {
+		return err
+	}
+
+	flagSet.Float64P("cost-accounting-egress-price-per-gib", "", 0.12, "Price in dollars of a GiB read from GCS, for estimating the cost of the mount. Set to 0 for reads within the region of the bucket.")
+
+	if err := flagSet.MarkHidden("cost-accounting-egress-price-per-gib"); err != nil {
+		return err
+	}
+
+	flagSet.DurationP("cost-accounting-log-interval", "", 3600000000000*time.Nanosecond, "How often the estimated cost of the mount is logged, never if 0.")
+
+	if err := flagSet.MarkHidden("cost-accounting-log-interval"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("cost-accounting-max-prefixes", "", 100, "Maximum number of distinct object prefixes GCS operations are attributed to in cost accounting. Operations on further prefixes are attributed to \"other\".")
+
+	if err := flagSet.MarkHidden("cost-accounting-max-prefixes"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("cost-accounting-mount-label", "", "", "Label GCS operations are attributed to in cost accounting. Defaults to the bucket name.")
+
+	if err := flagSet.MarkHidden("cost-accounting-mount-label"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("cost-accounting-prefix-depth", "", 0, "Number of leading directories of object names GCS operations are also attributed to in cost accounting, none if 0.")
+
+	if err := flagSet.MarkHidden("cost-accounting-prefix-depth"); err != nil {
+		return err
+	}
+
 	flagSet.BoolP("create-empty-file", "", false, "For a new file, it creates an empty file in Cloud Storage bucket as a hold.")
 
-	flagSet.StringP("custom-endpoint", "", "", "Specifies an alternative custom endpoint for fetching data. Should only be used for testing.  The custom endpoint must support the equivalent resources and operations as the GCS  JSON endpoint, https://storage.googleapis.com/storage/v1. If a custom endpoint is not specified,  GCSFuse uses the global GCS JSON API endpoint, https://storage.googleapis.com/storage/v1.")
+	flagSet.StringP("custom-endpoint", "", "", "Specifies an alternative custom endpoint for fetching data. Should only be used for testing.  The custom endpoint must support the equivalent resources and operations as the GCS  JSON endpoint, https://storage.googleapis.com/storage/v1. If a custom endpoint is not specified,  GCSFuse uses the global GCS JSON API endpoint, https://storage.googleapis.com/storage/v1. A file:///path endpoint serves the subdirectories of the local directory path as buckets instead.")
+
+	flagSet.StringP("customer-supplied-encryption-key-file", "", "", "Path to the file containing the 32-byte AES-256 key (raw or hex-encoded) supplied to GCS to encrypt and decrypt objects (CSEK). Objects encrypted with another key, or with none, can't be read, and unfinalized objects in zonal buckets can't be appended to. Disabled if empty.")
+
+	if err := flagSet.MarkHidden("customer-supplied-encryption-key-file"); err != nil {
+		return err
+	}
 
 	flagSet.BoolP("debug_fs", "", false, "This flag is unused.")
 
@@ -323,12 +529,30 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	flagSet.BoolP("enable-circuit-breaker", "", false, "Fail GCS requests fast with EIO while too many recent requests of the same class (read, metadata or write) failed, instead of retrying each of them to exhaustion.")
+
+	if err := flagSet.MarkHidden("enable-circuit-breaker"); err != nil {
+		return err
+	}
+
+	flagSet.BoolP("enable-cost-accounting", "", false, "Count GCS operations by pricing class and bytes transferred by direction, exported as metrics labelled with the mount label and object prefix, and log the estimated cost of the mount periodically. Requests sent to both the bucket and the failover bucket are counted once.")
+
+	if err := flagSet.MarkHidden("enable-cost-accounting"); err != nil {
+		return err
+	}
+
 	flagSet.BoolP("enable-empty-managed-folders", "", false, "This handles the corner case in listing managed folders. There are two corner cases (a) empty managed folder (b) nested managed folder which doesn't contain any descendent as object. This flag always works in conjunction with --implicit-dirs flag. (a) If only ImplicitDirectories is true, all managed folders are listed other than above two mentioned cases. (b) If both ImplicitDirectories and EnableEmptyManagedFolders are true, then all the managed folders are listed including the above-mentioned corner case. (c) If ImplicitDirectories is false then no managed folders are listed irrespective of enable-empty-managed-folders flag.")
 
 	if err := flagSet.MarkHidden("enable-empty-managed-folders"); err != nil {
 		return err
 	}
 
+	flagSet.BoolP("enable-hedged-reads", "", false, "Issue a duplicate request for reads of objects whose first byte takes longer than most recent reads took, and use whichever responds first.")
+
+	if err := flagSet.MarkHidden("enable-hedged-reads"); err != nil {
+		return err
+	}
+
 	flagSet.BoolP("enable-hns", "", true, "Enables support for HNS buckets")
 
 	if err := flagSet.MarkHidden("enable-hns"); err != nil {
@@ -351,6 +575,12 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 
 	flagSet.BoolP("enable-streaming-writes", "", false, "Enables streaming uploads during write file operation.")
 
+	flagSet.BoolP("enable-versions-dir", "", false, "Expose all the generations of the objects, including the noncurrent ones in buckets with object versioning enabled, under the hidden read-only directory .versions in the root of the bucket, as .versions/<path>/<generation>. A generation can be restored by copying it back to <path>.")
+
+	if err := flagSet.MarkHidden("enable-versions-dir"); err != nil {
+		return err
+	}
+
 	flagSet.BoolP("experimental-enable-json-read", "", false, "By default, GCSFuse uses the GCS XML API to get and read objects. When this flag is specified, GCSFuse uses the GCS JSON API instead.\"")
 
 	if err := flagSet.MarkDeprecated("experimental-enable-json-read", "Experimental flag: could be dropped even in a minor release."); err != nil {
@@ -387,6 +617,24 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	flagSet.StringP("failover-bucket", "", "", "Mount the bucket read-only with this replica of it as a secondary: reads, stats and listings fall back to it when the bucket fails with not found, server or timeout errors. Objects served from the secondary get generations distinct from those in the bucket.")
+
+	if err := flagSet.MarkHidden("failover-bucket"); err != nil {
+		return err
+	}
+
+	flagSet.DurationP("failover-timeout", "", 10000000000*time.Nanosecond, "How long a request to the bucket may take to respond before it falls back to the failover bucket. 0 means no limit.")
+
+	if err := flagSet.MarkHidden("failover-timeout"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("fault-injection-file", "", "", "YAML file with a list of rules injecting latencies, errors, truncated or stalled reads and dropped finalize responses into the matching requests to the bucket, for testing. Injected errors aren't retried by the storage client. The file is read again on SIGHUP, which is how rules are changed while mounted. See gcsx.FaultRule for the format.")
+
+	if err := flagSet.MarkHidden("fault-injection-file"); err != nil {
+		return err
+	}
+
 	flagSet.BoolP("file-cache-cache-file-for-range-read", "", false, "Whether to cache file for range reads.")
 
 	flagSet.IntP("file-cache-download-chunk-size-mb", "", 50, "Size of chunks in MiB that each concurrent request downloads.")
@@ -397,6 +645,8 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	flagSet.BoolP("file-cache-enable-encryption", "", false, "Encrypts the contents of files in file-cache. The key is generated per mount unless file-cache-encryption-key-file is specified.")
+
 	flagSet.BoolP("file-cache-enable-o-direct", "", false, "Whether to use O_DIRECT while writing to file-cache in case of parallel downloads.")
 
 	if err := flagSet.MarkHidden("file-cache-enable-o-direct"); err != nil {
@@ -405,6 +655,8 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 
 	flagSet.BoolP("file-cache-enable-parallel-downloads", "", false, "Enable parallel downloads.")
 
+	flagSet.StringP("file-cache-encryption-key-file", "", "", "Path to the file containing the 32-byte key (raw or hex-encoded) used to encrypt the contents of files in file-cache. Requires file-cache-enable-encryption.")
+
 	flagSet.IntP("file-cache-max-parallel-downloads", "", DefaultMaxParallelDownloads(), "Sets an uber limit of number of concurrent file download requests that are made across all files.")
 
 	flagSet.IntP("file-cache-max-size-mb", "", -1, "Maximum size of the file-cache in MiBs")
@@ -429,6 +681,30 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	flagSet.DurationP("hedged-reads-max-delay", "", 1000000000*time.Nanosecond, "Upper bound of the delay after which a read is hedged, also used until enough reads have been timed.")
+
+	if err := flagSet.MarkHidden("hedged-reads-max-delay"); err != nil {
+		return err
+	}
+
+	flagSet.Float64P("hedged-reads-max-hedges-per-sec", "", 10, "Maximum number of duplicate read requests issued per second.")
+
+	if err := flagSet.MarkHidden("hedged-reads-max-hedges-per-sec"); err != nil {
+		return err
+	}
+
+	flagSet.DurationP("hedged-reads-min-delay", "", 10000000*time.Nanosecond, "Lower bound of the delay after which a read is hedged.")
+
+	if err := flagSet.MarkHidden("hedged-reads-min-delay"); err != nil {
+		return err
+	}
+
+	flagSet.Float64P("hedged-reads-target-percentile", "", 0.95, "Hedge reads whose first byte takes longer than p(targetPercentile * 100) of recent reads.")
+
+	if err := flagSet.MarkHidden("hedged-reads-target-percentile"); err != nil {
+		return err
+	}
+
 	flagSet.DurationP("http-client-timeout", "", 0*time.Nanosecond, "The time duration that http client will wait to get response from the server. The default value 0 indicates no timeout.")
 
 	flagSet.BoolP("ignore-interrupts", "", true, "Instructs gcsfuse to ignore system interrupt signals (like SIGINT, triggered by Ctrl+C). This prevents those signals from immediately terminating gcsfuse inflight operations. (default: true)")
@@ -523,14 +799,62 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	flagSet.BoolP("record-payloads", "", false, "Includes the contents read and written in the trace recorded with --record-trace.")
+
+	if err := flagSet.MarkHidden("record-payloads"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("record-trace", "", "", "Records every request to the bucket, with its response, timing and a hash of the contents read or written, to this file, to be replayed with --replay-trace.")
+
+	if err := flagSet.MarkHidden("record-trace"); err != nil {
+		return err
+	}
+
 	flagSet.IntP("rename-dir-limit", "", 0, "Allow rename a directory containing fewer descendants than this limit.")
 
+	flagSet.BoolP("replay-match-by-request", "", false, "Serves each request replayed with --replay-trace the response recorded for the same request, rather than the next recorded response.")
+
+	if err := flagSet.MarkHidden("replay-match-by-request"); err != nil {
+		return err
+	}
+
+	flagSet.BoolP("replay-timings", "", true, "Delays each response replayed with --replay-trace by its recorded latency.")
+
+	if err := flagSet.MarkHidden("replay-timings"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("replay-trace", "", "", "Serves the bucket from this trace recorded with --record-trace instead of GCS, so that a workload can be reproduced offline.")
+
+	if err := flagSet.MarkHidden("replay-trace"); err != nil {
+		return err
+	}
+
 	flagSet.Float64P("retry-multiplier", "", 2, "Param for exponential backoff algorithm, which is used to increase waiting time b/w two consecutive retries.")
 
 	flagSet.BoolP("reuse-token-from-url", "", true, "If false, the token acquired from token-url is not reused.")
 
+	flagSet.StringP("s3-endpoint", "", "", "URL of an S3-compatible object store whose buckets are mounted instead of GCS buckets, e.g. http://localhost:9000. Credentials are taken from the AWS environment variables or shared credentials file. Disabled if empty.")
+
+	if err := flagSet.MarkHidden("s3-endpoint"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("s3-region", "", "us-east-1", "Region of the S3-compatible object store set with --s3-endpoint.")
+
+	if err := flagSet.MarkHidden("s3-region"); err != nil {
+		return err
+	}
+
 	flagSet.IntP("sequential-read-size-mb", "", 200, "File chunk size to read from GCS in one call. Need to specify the value in MB. ChunkSize less than 1MB is not supported")
 
+	flagSet.StringP("snapshot-time", "", "", "Mount the bucket read-only as of the given time, in RFC 3339 format. Each object resolves to its newest generation created at or before that time, which requires object versioning to be enabled on the bucket. Since the view never changes, the metadata and kernel list caches never expire.")
+
+	if err := flagSet.MarkHidden("snapshot-time"); err != nil {
+		return err
+	}
+
 	flagSet.DurationP("stackdriver-export-interval", "", 0*time.Nanosecond, "Export metrics to stackdriver with this interval. The default value 0 indicates no exporting.")
 
 	if err := flagSet.MarkDeprecated("stackdriver-export-interval", "Please use --cloud-metrics-export-interval-secs instead."); err != nil {
@@ -565,24 +889,108 @@ func BuildFlagSet(flagSet *pflag.FlagSet) error {
 
 	flagSet.IntP("uid", "", -1, "UID owner of all inodes.")
 
+	flagSet.StringSliceP("union-lower-layers", "", []string{}, "Overlay these layers, in decreasing order of precedence, with the mounted bucket, which takes precedence over them and receives all modifications. Deletions of objects of the layers are recorded as whiteouts in the mounted bucket. A layer is a bucket name, optionally followed by \"/\" and a directory of the bucket to use as the layer. Multiple layers can be passed as comma separated. Can't be used with --record-trace or --replay-trace.")
+
+	if err := flagSet.MarkHidden("union-lower-layers"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("write-back-concurrency", "", 4, "Specifies the maximum number of files uploaded concurrently in the background in write-back mode. The value should be >= 1.")
+
+	if err := flagSet.MarkHidden("write-back-concurrency"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("write-back-staging-budget-mb", "", 0, "Enables write-back mode, in which closing a file returns once its contents are staged locally and the upload to GCS happens in the background. Closing blocks once the contents pending upload exceed this size in MiB. fsync still uploads synchronously. 0 disables write-back mode.")
+
+	if err := flagSet.MarkHidden("write-back-staging-budget-mb"); err != nil {
+		return err
+	}
+
 	flagSet.IntP("write-block-size-mb", "", 32, "Specifies the block size for streaming writes. The value should be more  than 0.")
 
 	if err := flagSet.MarkHidden("write-block-size-mb"); err != nil {
 		return err
 	}
 
+	flagSet.StringP("write-conflict-policy", "", "fail", "Specifies what to do on syncing a file whose object was modified or deleted by someone else since it was opened. Supported values: \"fail\" (return an error), \"last-writer-wins\" (overwrite the object with the local contents) and \"conflict-copy\" (write the local contents to a sibling object named <name>.conflict-<host>-<timestamp>, keeping the object). Files written with streaming writes always fail.")
+
+	if err := flagSet.MarkHidden("write-conflict-policy"); err != nil {
+		return err
+	}
+
 	flagSet.IntP("write-global-max-blocks", "", -1, "Specifies the maximum number of blocks to be used by all files for streaming writes. The value should be >= 0 (1 block per file is not counted  towards this limit) or -1 (for infinite blocks).")
 
 	if err := flagSet.MarkHidden("write-global-max-blocks"); err != nil {
 		return err
 	}
 
+	flagSet.StringSliceP("write-gzip-objects", "", []string{}, "Patterns of the names of objects whose contents are compressed with gzip on upload and decompressed on read. A pattern \"*.ext\" matches the names ending in \".ext\", and any other pattern matches the names it prefixes. Multiple patterns can be passed as comma separated. Compressed contents are staged in temp-dir while uploaded. Reads of compressed objects decompress them from the start, so random reads of large ones are slow.")
+
+	if err := flagSet.MarkHidden("write-gzip-objects"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("write-journal-dir", "", "", "Directory in which the contents of dirty files are kept until they are synced to GCS. Unsynced writes left behind by a crash are uploaded, or quarantined on conflict, when the bucket is mounted again. Disabled if empty.")
+
+	if err := flagSet.MarkHidden("write-journal-dir"); err != nil {
+		return err
+	}
+
 	flagSet.IntP("write-max-blocks-per-file", "", 1, "Specifies the maximum number of blocks to be used by a single file for  streaming writes. The value should be >= 1 or -1 (for infinite blocks).")
 
 	if err := flagSet.MarkHidden("write-max-blocks-per-file"); err != nil {
 		return err
 	}
 
+	flagSet.IntP("write-max-pending-blocks-per-file", "", 1, "Specifies the maximum number of full blocks of a file held back from upload for streaming writes, so that writes to them are served rather than falling back to a temporary file. Only writes to these blocks and the block being written can be out of order. The value should be >= 0, and is capped at write-max-blocks-per-file minus 1.")
+
+	if err := flagSet.MarkHidden("write-max-pending-blocks-per-file"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("write-memory-budget-mb", "", -1, "Specifies the maximum memory in MiB used by the blocks of all files for streaming writes when write-spill-dir is set. Blocks beyond this budget are backed by files in write-spill-dir. The value should be >= 0 or -1 (for no limit).")
+
+	if err := flagSet.MarkHidden("write-memory-budget-mb"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("write-object-attributes-file", "", "", "YAML file with a list of templates of attributes, such as storage-class and cache-control, set on the objects created with names matching their prefix or glob pattern. See gcsx.ObjectAttributesTemplate for the format.")
+
+	if err := flagSet.MarkHidden("write-object-attributes-file"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("write-parallel-upload-concurrency", "", 16, "Specifies the maximum number of parts of a file uploaded in parallel by a parallel composite upload. The value should be >= 1.")
+
+	if err := flagSet.MarkHidden("write-parallel-upload-concurrency"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("write-parallel-upload-part-size-mb", "", 64, "Specifies the size in MiB of each part of a parallel composite upload. The value should be >= 1.")
+
+	if err := flagSet.MarkHidden("write-parallel-upload-part-size-mb"); err != nil {
+		return err
+	}
+
+	flagSet.IntP("write-parallel-upload-threshold-mb", "", 0, "Files of at least this size in MiB are synced to GCS by uploading parts in parallel and composing them into the final object. 0 disables parallel composite uploads.")
+
+	if err := flagSet.MarkHidden("write-parallel-upload-threshold-mb"); err != nil {
+		return err
+	}
+
+	flagSet.BoolP("write-sniff-content-type", "", false, "Guesses the Content-Type of new objects from the first bytes of their contents, falling back to the file extension, instead of only from the extension.")
+
+	if err := flagSet.MarkHidden("write-sniff-content-type"); err != nil {
+		return err
+	}
+
+	flagSet.StringP("write-spill-dir", "", "", "Directory in which the streaming write blocks beyond write-memory-budget-mb are created as files. Requires write-memory-budget-mb to be set.")
+
+	if err := flagSet.MarkHidden("write-spill-dir"); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -608,14 +1016,66 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("gcs-connection.circuit-breaker.error-rate-threshold", flagSet.Lookup("circuit-breaker-error-rate-threshold")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("gcs-connection.circuit-breaker.min-requests", flagSet.Lookup("circuit-breaker-min-requests")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("gcs-connection.circuit-breaker.open-duration", flagSet.Lookup("circuit-breaker-open-duration")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("gcs-connection.circuit-breaker.read-only-when-open", flagSet.Lookup("circuit-breaker-read-only-when-open")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("gcs-connection.circuit-breaker.window-size", flagSet.Lookup("circuit-breaker-window-size")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("gcs-connection.client-protocol", flagSet.Lookup("client-protocol")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("client-side-encryption.key-file", flagSet.Lookup("client-side-encryption-key-file")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("metrics.cloud-metrics-export-interval-secs", flagSet.Lookup("cloud-metrics-export-interval-secs")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("cost-accounting.class-a-price-per-10k", flagSet.Lookup("cost-accounting-class-a-price-per-10k")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("cost-accounting.class-b-price-per-10k", flagSet.Lookup("cost-accounting-class-b-price-per-10k")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("cost-accounting.egress-price-per-gib", flagSet.Lookup("cost-accounting-egress-price-per-gib")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("cost-accounting.log-interval", flagSet.Lookup("cost-accounting-log-interval")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("cost-accounting.max-prefixes", flagSet.Lookup("cost-accounting-max-prefixes")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("cost-accounting.mount-label", flagSet.Lookup("cost-accounting-mount-label")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("cost-accounting.prefix-depth", flagSet.Lookup("cost-accounting-prefix-depth")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("write.create-empty-file", flagSet.Lookup("create-empty-file")); err != nil {
 		return err
 	}
@@ -624,6 +1084,10 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("gcs-auth.customer-supplied-encryption-key-file", flagSet.Lookup("customer-supplied-encryption-key-file")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("debug.fuse", flagSet.Lookup("debug_fuse")); err != nil {
 		return err
 	}
@@ -652,10 +1116,22 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("gcs-connection.circuit-breaker.enable", flagSet.Lookup("enable-circuit-breaker")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("cost-accounting.enable", flagSet.Lookup("enable-cost-accounting")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("list.enable-empty-managed-folders", flagSet.Lookup("enable-empty-managed-folders")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("gcs-connection.hedged-reads.enable", flagSet.Lookup("enable-hedged-reads")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("enable-hns", flagSet.Lookup("enable-hns")); err != nil {
 		return err
 	}
@@ -676,6 +1152,10 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("file-system.enable-versions-dir", flagSet.Lookup("enable-versions-dir")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("gcs-connection.experimental-enable-json-read", flagSet.Lookup("experimental-enable-json-read")); err != nil {
 		return err
 	}
@@ -700,6 +1180,18 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("failover-bucket", flagSet.Lookup("failover-bucket")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("failover-timeout", flagSet.Lookup("failover-timeout")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("debug.fault-injection-file", flagSet.Lookup("fault-injection-file")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("file-cache.cache-file-for-range-read", flagSet.Lookup("file-cache-cache-file-for-range-read")); err != nil {
 		return err
 	}
@@ -712,6 +1204,10 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("file-cache.enable-encryption", flagSet.Lookup("file-cache-enable-encryption")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("file-cache.enable-o-direct", flagSet.Lookup("file-cache-enable-o-direct")); err != nil {
 		return err
 	}
@@ -720,6 +1216,10 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("file-cache.encryption-key-file", flagSet.Lookup("file-cache-encryption-key-file")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("file-cache.max-parallel-downloads", flagSet.Lookup("file-cache-max-parallel-downloads")); err != nil {
 		return err
 	}
@@ -752,6 +1252,22 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("gcs-connection.hedged-reads.max-delay", flagSet.Lookup("hedged-reads-max-delay")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("gcs-connection.hedged-reads.max-hedges-per-sec", flagSet.Lookup("hedged-reads-max-hedges-per-sec")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("gcs-connection.hedged-reads.min-delay", flagSet.Lookup("hedged-reads-min-delay")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("gcs-connection.hedged-reads.target-percentile", flagSet.Lookup("hedged-reads-target-percentile")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("gcs-connection.http-client-timeout", flagSet.Lookup("http-client-timeout")); err != nil {
 		return err
 	}
@@ -864,10 +1380,30 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("debug.record-payloads", flagSet.Lookup("record-payloads")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("debug.record-trace", flagSet.Lookup("record-trace")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("file-system.rename-dir-limit", flagSet.Lookup("rename-dir-limit")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("debug.replay-match-by-request", flagSet.Lookup("replay-match-by-request")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("debug.replay-timings", flagSet.Lookup("replay-timings")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("debug.replay-trace", flagSet.Lookup("replay-trace")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("gcs-retries.multiplier", flagSet.Lookup("retry-multiplier")); err != nil {
 		return err
 	}
@@ -876,10 +1412,22 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("s3.endpoint", flagSet.Lookup("s3-endpoint")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("s3.region", flagSet.Lookup("s3-region")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("gcs-connection.sequential-read-size-mb", flagSet.Lookup("sequential-read-size-mb")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("snapshot-time", flagSet.Lookup("snapshot-time")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("metrics.stackdriver-export-interval", flagSet.Lookup("stackdriver-export-interval")); err != nil {
 		return err
 	}
@@ -916,17 +1464,73 @@ func BindFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
 		return err
 	}
 
+	if err := v.BindPFlag("union.lower-layers", flagSet.Lookup("union-lower-layers")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.write-back-concurrency", flagSet.Lookup("write-back-concurrency")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.write-back-staging-budget-mb", flagSet.Lookup("write-back-staging-budget-mb")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("write.block-size-mb", flagSet.Lookup("write-block-size-mb")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("write.conflict-policy", flagSet.Lookup("write-conflict-policy")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("write.global-max-blocks", flagSet.Lookup("write-global-max-blocks")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("write.gzip-objects", flagSet.Lookup("write-gzip-objects")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.journal-dir", flagSet.Lookup("write-journal-dir")); err != nil {
+		return err
+	}
+
 	if err := v.BindPFlag("write.max-blocks-per-file", flagSet.Lookup("write-max-blocks-per-file")); err != nil {
 		return err
 	}
 
+	if err := v.BindPFlag("write.max-pending-blocks-per-file", flagSet.Lookup("write-max-pending-blocks-per-file")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.memory-budget-mb", flagSet.Lookup("write-memory-budget-mb")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.object-attributes-file", flagSet.Lookup("write-object-attributes-file")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.parallel-upload-concurrency", flagSet.Lookup("write-parallel-upload-concurrency")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.parallel-upload-part-size-mb", flagSet.Lookup("write-parallel-upload-part-size-mb")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.parallel-upload-threshold-mb", flagSet.Lookup("write-parallel-upload-threshold-mb")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.sniff-content-type", flagSet.Lookup("write-sniff-content-type")); err != nil {
+		return err
+	}
+
+	if err := v.BindPFlag("write.spill-dir", flagSet.Lookup("write-spill-dir")); err != nil {
+		return err
+	}
+
 	return nil
 }
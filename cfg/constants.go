@@ -41,6 +41,18 @@ const (
 	ExperimentalMetadataPrefetchOnMountAsynchronous = "async"
 )
 
+const (
+	// ConflictPolicyFail fails the sync of a file whose object was modified
+	// concurrently.
+	ConflictPolicyFail = "fail"
+	// ConflictPolicyLastWriterWins overwrites the concurrently modified object
+	// with the local contents.
+	ConflictPolicyLastWriterWins = "last-writer-wins"
+	// ConflictPolicyConflictCopy writes the local contents to a sibling object,
+	// leaving the concurrently modified object as is.
+	ConflictPolicyConflictCopy = "conflict-copy"
+)
+
 const (
 	// maxSequentialReadSizeMb is the max value supported by sequential-read-size-mb flag.
 	maxSequentialReadSizeMB = 1024
@@ -97,6 +97,18 @@ func resolveCloudMetricsUploadIntervalSecs(m *MetricsConfig) {
 	}
 }
 
+// resolveSnapshotConfig makes the metadata and kernel list caches never expire
+// for snapshot mounts, as their view of the bucket never changes.
+func resolveSnapshotConfig(c *Config) {
+	if c.SnapshotTime == "" {
+		return
+	}
+
+	c.MetadataCache.TtlSecs = maxSupportedTTLInSeconds
+	c.MetadataCache.NegativeTtlSecs = maxSupportedTTLInSeconds
+	c.FileSystem.KernelListCacheTtlSecs = -1
+}
+
 // Rationalize updates the config fields based on the values of other fields.
 func Rationalize(v isSet, c *Config) error {
 	var err error
@@ -116,6 +128,7 @@ func Rationalize(v isSet, c *Config) error {
 	resolveMetadataCacheTTL(v, &c.MetadataCache)
 	resolveStatCacheMaxSizeMB(v, &c.MetadataCache)
 	resolveCloudMetricsUploadIntervalSecs(&c.Metrics)
+	resolveSnapshotConfig(c)
 
 	return nil
 }
@@ -430,3 +430,47 @@ func TestRationalizeMetricsConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestRationalizeSnapshotConfig(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name                  string
+		config                *Config
+		expectedTtlSecs       int64
+		expectedNegativeTtl   int64
+		expectedKernelListTtl int64
+	}{
+		{
+			name: "snapshot_unset",
+			config: &Config{
+				MetadataCache: MetadataCacheConfig{NegativeTtlSecs: 5},
+			},
+			expectedTtlSecs:       0,
+			expectedNegativeTtl:   5,
+			expectedKernelListTtl: 0,
+		},
+		{
+			name: "snapshot_set",
+			config: &Config{
+				MetadataCache: MetadataCacheConfig{NegativeTtlSecs: 5},
+				SnapshotTime:  "2025-01-02T03:04:05Z",
+			},
+			expectedTtlSecs:       maxSupportedTTLInSeconds,
+			expectedNegativeTtl:   maxSupportedTTLInSeconds,
+			expectedKernelListTtl: -1,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			if assert.NoError(t, Rationalize(&mockIsSet{}, tc.config)) {
+				assert.Equal(t, tc.expectedTtlSecs, tc.config.MetadataCache.TtlSecs)
+				assert.Equal(t, tc.expectedNegativeTtl, tc.config.MetadataCache.NegativeTtlSecs)
+				assert.Equal(t, tc.expectedKernelListTtl, tc.config.FileSystem.KernelListCacheTtlSecs)
+			}
+		})
+	}
+}
@@ -18,6 +18,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
+	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
 )
@@ -28,6 +30,7 @@ const (
 	ParallelDownloadsPerFileInvalidValueError = "the value of parallel-downloads-per-file for file-cache can't be less than 1"
 	DownloadChunkSizeMBInvalidValueError      = "the value of download-chunk-size-mb for file-cache can't be less than 1"
 	MaxParallelDownloadsCantBeZeroError       = "the value of max-parallel-downloads for file-cache must not be 0 when enable-parallel-downloads is true"
+	EncryptionKeyFileWithoutEncryptionError   = "encryption-key-file for file-cache can't be set when enable-encryption is false"
 )
 
 func isValidLogRotateConfig(config *LogRotateLoggingConfig) error {
@@ -77,6 +80,9 @@ func isValidFileCacheConfig(config *FileCacheConfig) error {
 	if config.DownloadChunkSizeMb < 1 {
 		return errors.New(DownloadChunkSizeMBInvalidValueError)
 	}
+	if config.EncryptionKeyFile != "" && !config.EnableEncryption {
+		return errors.New(EncryptionKeyFileWithoutEncryptionError)
+	}
 
 	return nil
 }
@@ -92,6 +98,41 @@ func IsValidExperimentalMetadataPrefetchOnMount(mode string) error {
 	}
 }
 
+func isValidConflictPolicy(policy string) error {
+	switch policy {
+	case ConflictPolicyFail,
+		ConflictPolicyLastWriterWins,
+		ConflictPolicyConflictCopy:
+		return nil
+	default:
+		return fmt.Errorf("unsupported write-conflict-policy: \"%s\"; supported values: fail, last-writer-wins, conflict-copy", policy)
+	}
+}
+
+func isValidSnapshotTime(snapshotTime string) error {
+	if snapshotTime == "" {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, snapshotTime); err != nil {
+		return fmt.Errorf("snapshot-time should be in RFC 3339 format: %w", err)
+	}
+	return nil
+}
+
+func isValidFailoverTimeout(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("invalid value of failover-timeout: %v, can't be negative", timeout)
+	}
+	return nil
+}
+
+func isValidTraceConfig(config *DebugConfig) error {
+	if config.RecordTrace != "" && config.ReplayTrace != "" {
+		return fmt.Errorf("record-trace and replay-trace can't both be set")
+	}
+	return nil
+}
+
 func isValidSequentialReadSizeMB(size int64) error {
 	if size < 1 || size > maxSequentialReadSizeMB {
 		return fmt.Errorf("sequential-read-size-mb should be between 1 and %d", maxSequentialReadSizeMB)
@@ -172,9 +213,67 @@ func isValidWriteStreamingConfig(wc *WriteConfig) error {
 	if !(wc.MaxBlocksPerFile == -1 || wc.MaxBlocksPerFile >= 1) {
 		return fmt.Errorf("invalid value of write-max-blocks-per-file: %d; should be >=1 or -1 (for infinite)", wc.MaxBlocksPerFile)
 	}
+	if wc.MaxPendingBlocksPerFile < 0 {
+		return fmt.Errorf("invalid value of write-max-pending-blocks-per-file: %d; should be >=0", wc.MaxPendingBlocksPerFile)
+	}
 	if wc.GlobalMaxBlocks < -1 {
 		return fmt.Errorf("invalid value of write-global-max-blocks: %d; should be >=0 or -1 (for infinite)", wc.GlobalMaxBlocks)
 	}
+	if wc.MemoryBudgetMb < -1 {
+		return fmt.Errorf("invalid value of write-memory-budget-mb: %d; should be >=0 or -1 (for no limit)", wc.MemoryBudgetMb)
+	}
+	if wc.SpillDir != "" && wc.MemoryBudgetMb == -1 {
+		return errors.New("write-memory-budget-mb must be set when write-spill-dir is set")
+	}
+	return nil
+}
+
+func isValidParallelUploadConfig(wc *WriteConfig) error {
+	if wc.ParallelUploadThresholdMb == 0 {
+		return nil
+	}
+
+	if wc.ParallelUploadThresholdMb < 0 || wc.ParallelUploadThresholdMb > util.MaxMiBsInInt64 {
+		return fmt.Errorf("invalid value of write-parallel-upload-threshold-mb: %d; should be >=0 and <= %d", wc.ParallelUploadThresholdMb, util.MaxMiBsInInt64)
+	}
+	if wc.ParallelUploadPartSizeMb < 1 || wc.ParallelUploadPartSizeMb > util.MaxMiBsInInt64 {
+		return fmt.Errorf("invalid value of write-parallel-upload-part-size-mb: %d; should be >=1 and <= %d", wc.ParallelUploadPartSizeMb, util.MaxMiBsInInt64)
+	}
+	if wc.ParallelUploadConcurrency < 1 {
+		return fmt.Errorf("invalid value of write-parallel-upload-concurrency: %d; should be >=1", wc.ParallelUploadConcurrency)
+	}
+	return nil
+}
+
+func isValidGzipObjects(patterns []string) error {
+	for _, p := range patterns {
+		if p == "" || p == "*" {
+			return fmt.Errorf("invalid value of write-gzip-objects: %q; patterns should be a prefix or \"*.ext\"", p)
+		}
+	}
+	return nil
+}
+
+func isValidUnionLowerLayers(layers []string) error {
+	for _, l := range layers {
+		if bucket, _, _ := strings.Cut(l, "/"); bucket == "" {
+			return fmt.Errorf("invalid value of union-lower-layers: %q; layers should be a bucket name optionally followed by \"/\" and a directory", l)
+		}
+	}
+	return nil
+}
+
+func isValidWriteBackConfig(wc *WriteConfig) error {
+	if wc.WriteBackStagingBudgetMb == 0 {
+		return nil
+	}
+
+	if wc.WriteBackStagingBudgetMb < 0 || wc.WriteBackStagingBudgetMb > util.MaxMiBsInInt64 {
+		return fmt.Errorf("invalid value of write-back-staging-budget-mb: %d; should be >=0 and <= %d", wc.WriteBackStagingBudgetMb, util.MaxMiBsInInt64)
+	}
+	if wc.WriteBackConcurrency < 1 {
+		return fmt.Errorf("invalid value of write-back-concurrency: %d; should be >=1", wc.WriteBackConcurrency)
+	}
 	return nil
 }
 
@@ -193,6 +292,57 @@ func isValidReadStallGcsRetriesConfig(rsrc *ReadStallGcsRetriesConfig) error {
 	return nil
 }
 
+func isValidHedgedReadsConfig(hrc *HedgedReadsGcsConnectionConfig) error {
+	if !hrc.Enable {
+		return nil
+	}
+	if hrc.TargetPercentile <= 0 || hrc.TargetPercentile >= 1 {
+		return fmt.Errorf("invalid value of target-percentile: %f, should be in the range (0, 1)", hrc.TargetPercentile)
+	}
+	if hrc.MinDelay < 0 || hrc.MaxDelay < hrc.MinDelay {
+		return fmt.Errorf("invalid delays: min-delay %v and max-delay %v should satisfy 0 <= min-delay <= max-delay", hrc.MinDelay, hrc.MaxDelay)
+	}
+	if hrc.MaxHedgesPerSec <= 0 {
+		return fmt.Errorf("invalid value of max-hedges-per-sec: %f, can't be 0 or negative", hrc.MaxHedgesPerSec)
+	}
+	return nil
+}
+
+func isValidCircuitBreakerConfig(cbc *CircuitBreakerGcsConnectionConfig) error {
+	if !cbc.Enable {
+		return nil
+	}
+	if cbc.ErrorRateThreshold <= 0 || cbc.ErrorRateThreshold > 1 {
+		return fmt.Errorf("invalid value of error-rate-threshold: %f, should be in the range (0, 1]", cbc.ErrorRateThreshold)
+	}
+	if cbc.MinRequests < 1 || cbc.WindowSize < cbc.MinRequests {
+		return fmt.Errorf("invalid sizes: min-requests %d and window-size %d should satisfy 1 <= min-requests <= window-size", cbc.MinRequests, cbc.WindowSize)
+	}
+	if cbc.OpenDuration <= 0 {
+		return fmt.Errorf("invalid value of open-duration: %v, can't be 0 or negative", cbc.OpenDuration)
+	}
+	return nil
+}
+
+func isValidCostAccountingConfig(c *CostAccountingConfig) error {
+	if !c.Enable {
+		return nil
+	}
+	if c.ClassAPricePer10K < 0 || c.ClassBPricePer10K < 0 || c.EgressPricePerGib < 0 {
+		return fmt.Errorf("prices can't be negative")
+	}
+	if c.PrefixDepth < 0 {
+		return fmt.Errorf("invalid value of prefix-depth: %d, can't be negative", c.PrefixDepth)
+	}
+	if c.MaxPrefixes < 0 {
+		return fmt.Errorf("invalid value of max-prefixes: %d, can't be negative", c.MaxPrefixes)
+	}
+	if c.LogInterval < 0 {
+		return fmt.Errorf("invalid value of log-interval: %v, can't be negative", c.LogInterval)
+	}
+	return nil
+}
+
 func isValidMetricsConfig(m *MetricsConfig) error {
 	if m.StackdriverExportInterval != 0 && m.CloudMetricsExportIntervalSecs != 0 {
 		return fmt.Errorf("exactly one of stackdriver-export-interval and cloud-metrics-export-interval-secs must be specified")
@@ -223,6 +373,10 @@ func ValidateConfig(v isSet, config *Config) error {
 		return fmt.Errorf("error parsing custom-endpoint config: %w", err)
 	}
 
+	if err = isValidURL(config.S3.Endpoint); err != nil {
+		return fmt.Errorf("error parsing s3 config: %w", err)
+	}
+
 	if err = isValidFileCacheConfig(&config.FileCache); err != nil {
 		return fmt.Errorf("error parsing file cache config: %w", err)
 	}
@@ -251,14 +405,46 @@ func ValidateConfig(v isSet, config *Config) error {
 		return fmt.Errorf("error parsing write config: %w", err)
 	}
 
+	if err = isValidParallelUploadConfig(&config.Write); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
+	if err = isValidWriteBackConfig(&config.Write); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
+	if err = isValidUnionLowerLayers(config.Union.LowerLayers); err != nil {
+		return fmt.Errorf("error parsing union-lower-layers config: %w", err)
+	}
+
+	if err = isValidGzipObjects(config.Write.GzipObjects); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
+	if err = isValidConflictPolicy(config.Write.ConflictPolicy); err != nil {
+		return fmt.Errorf("error parsing write config: %w", err)
+	}
+
 	if err = isValidReadStallGcsRetriesConfig(&config.GcsRetries.ReadStall); err != nil {
 		return fmt.Errorf("error parsing read-stall-gcs-retries config: %w", err)
 	}
 
+	if err = isValidHedgedReadsConfig(&config.GcsConnection.HedgedReads); err != nil {
+		return fmt.Errorf("error parsing hedged-reads config: %w", err)
+	}
+
+	if err = isValidCircuitBreakerConfig(&config.GcsConnection.CircuitBreaker); err != nil {
+		return fmt.Errorf("error parsing circuit-breaker config: %w", err)
+	}
+
 	if err = isValidChunkTransferTimeoutForRetriesConfig(config.GcsRetries.ChunkTransferTimeoutSecs); err != nil {
 		return fmt.Errorf("error parsing chunk-transfer-timeout-secs config: %w", err)
 	}
 
+	if err = isValidCostAccountingConfig(&config.CostAccounting); err != nil {
+		return fmt.Errorf("error parsing cost-accounting config: %w", err)
+	}
+
 	if err = isValidMetricsConfig(&config.Metrics); err != nil {
 		return fmt.Errorf("error parsing metrics config: %w", err)
 	}
@@ -267,5 +453,17 @@ func ValidateConfig(v isSet, config *Config) error {
 		return fmt.Errorf("error parsing parallel download config: %w", err)
 	}
 
+	if err = isValidSnapshotTime(config.SnapshotTime); err != nil {
+		return fmt.Errorf("error parsing snapshot-time config: %w", err)
+	}
+
+	if err = isValidFailoverTimeout(config.FailoverTimeout); err != nil {
+		return fmt.Errorf("error parsing failover-timeout config: %w", err)
+	}
+
+	if err = isValidTraceConfig(&config.Debug); err != nil {
+		return fmt.Errorf("error parsing debug config: %w", err)
+	}
+
 	return nil
 }
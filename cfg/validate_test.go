@@ -30,6 +30,12 @@ func validLogRotateConfig() LogRotateLoggingConfig {
 	}
 }
 
+func validWriteConfig() WriteConfig {
+	return WriteConfig{
+		ConflictPolicy: ConflictPolicyFail,
+	}
+}
+
 func validFileCacheConfig(t *testing.T) FileCacheConfig {
 	t.Helper()
 	return FileCacheConfig{
@@ -54,6 +60,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "Valid Config where input and expected custom endpoint match.",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					CustomEndpoint:       "https://bing.com/search?q=dotnet",
@@ -68,6 +75,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "Valid Config where input and expected custom endpoint differ.",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					CustomEndpoint:       "https://j@ne:password@google.com",
@@ -82,6 +90,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "experimental-metadata-prefetch-on-mount disabled",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "disabled",
@@ -95,6 +104,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "experimental-metadata-prefetch-on-mount async",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "async",
@@ -108,6 +118,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "experimental-metadata-prefetch-on-mount sync",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -121,6 +132,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "Valid Sequential read size MB",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 10,
@@ -134,6 +146,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "Valid Sequential read size MB",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 10,
@@ -147,6 +160,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "valid_kernel_list_cache_TTL",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 10,
@@ -161,6 +175,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "valid_parallel_download_config_with_file_cache_enabled",
 			config: &Config{
 				Logging:  LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:    validWriteConfig(),
 				CacheDir: "/some/valid/path",
 				FileCache: FileCacheConfig{
 					DownloadChunkSizeMb:      50,
@@ -183,6 +198,7 @@ func TestValidateConfigSuccessful(t *testing.T) {
 			name: "valid_chunk_transfer_timeout_secs",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 10,
@@ -214,6 +230,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "Invalid Config due to invalid custom endpoint",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					CustomEndpoint:       "a_b://abc",
@@ -228,6 +245,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "Invalid experimental-metadata-prefetch-on-mount",
 			config: &Config{
 				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:   validWriteConfig(),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "a",
 				},
@@ -240,6 +258,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "Invalid Config due to invalid token URL",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsAuth: GcsAuthConfig{
 					TokenUrl: "a_b://abc",
@@ -256,6 +275,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "Sequential read size MB more than 1024 (max permissible value)",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 2048,
@@ -269,6 +289,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "Sequential read size MB less than 1 (min permissible value)",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				GcsConnection: GcsConnectionConfig{
 					SequentialReadSizeMb: 0,
@@ -282,6 +303,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "kernel_list_cache_TTL_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -293,6 +315,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "kernel_list_cache_TTL_too_large",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -304,6 +327,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "read_stall_req_increase_rate_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -320,6 +344,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "read_stall_req_increase_rate_zero",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -336,6 +361,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "read_stall_req_target_percentile_large",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -352,6 +378,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "read_stall_req_target_percentile_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -368,6 +395,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "parallel_download_config_without_file_cache_enabled",
 			config: &Config{
 				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:   validWriteConfig(),
 				FileCache: FileCacheConfig{
 					DownloadChunkSizeMb:      50,
 					EnableParallelDownloads:  true,
@@ -388,6 +416,7 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 			name: "chunk_transfer_timeout_in_negative",
 			config: &Config{
 				Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:     validWriteConfig(),
 				FileCache: validFileCacheConfig(t),
 				MetadataCache: MetadataCacheConfig{
 					ExperimentalMetadataPrefetchOnMount: "sync",
@@ -397,6 +426,26 @@ func TestValidateConfig_ErrorScenarios(t *testing.T) {
 				},
 			},
 		},
+		{
+			name: "file_cache_encryption_key_file_without_encryption",
+			config: &Config{
+				Logging: LoggingConfig{LogRotate: validLogRotateConfig()},
+				Write:   validWriteConfig(),
+				FileCache: FileCacheConfig{
+					DownloadChunkSizeMb:      50,
+					MaxParallelDownloads:     4,
+					MaxSizeMb:                -1,
+					ParallelDownloadsPerFile: 16,
+					EncryptionKeyFile:        "/tmp/key",
+				},
+				MetadataCache: MetadataCacheConfig{
+					ExperimentalMetadataPrefetchOnMount: "disabled",
+				},
+				GcsConnection: GcsConnectionConfig{
+					SequentialReadSizeMb: 200,
+				},
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -487,6 +536,29 @@ func Test_isValidWriteStreamingConfig_ErrorScenarios(t *testing.T) {
 			GlobalMaxBlocks:       20,
 			MaxBlocksPerFile:      0,
 		}},
+		{"-1_max_pending_blocks_per_file", WriteConfig{
+			BlockSizeMb:             10,
+			EnableStreamingWrites:   true,
+			GlobalMaxBlocks:         20,
+			MaxBlocksPerFile:        -1,
+			MaxPendingBlocksPerFile: -1,
+		}},
+		{"-2_memory_budget", WriteConfig{
+			BlockSizeMb:           10,
+			EnableStreamingWrites: true,
+			GlobalMaxBlocks:       20,
+			MaxBlocksPerFile:      -1,
+			MemoryBudgetMb:        -2,
+			SpillDir:              "/tmp",
+		}},
+		{"spill_dir_without_memory_budget", WriteConfig{
+			BlockSizeMb:           10,
+			EnableStreamingWrites: true,
+			GlobalMaxBlocks:       20,
+			MaxBlocksPerFile:      -1,
+			MemoryBudgetMb:        -1,
+			SpillDir:              "/tmp",
+		}},
 	}
 
 	for _, tc := range testCases {
@@ -557,6 +629,14 @@ func Test_isValidWriteStreamingConfig_SuccessScenarios(t *testing.T) {
 			GlobalMaxBlocks:       20,
 			MaxBlocksPerFile:      1,
 		}},
+		{"memory_budget_with_spill_dir", WriteConfig{
+			BlockSizeMb:           10,
+			EnableStreamingWrites: true,
+			GlobalMaxBlocks:       20,
+			MaxBlocksPerFile:      -1,
+			MemoryBudgetMb:        100,
+			SpillDir:              "/tmp",
+		}},
 	}
 
 	for _, tc := range testCases {
@@ -566,9 +646,302 @@ func Test_isValidWriteStreamingConfig_SuccessScenarios(t *testing.T) {
 	}
 }
 
+func Test_isValidParallelUploadConfig(t *testing.T) {
+	var testCases = []struct {
+		testName    string
+		writeConfig WriteConfig
+		wantErr     bool
+	}{
+		{"disabled", WriteConfig{ParallelUploadThresholdMb: 0}, false},
+		{"valid", WriteConfig{ParallelUploadThresholdMb: 1024, ParallelUploadPartSizeMb: 64, ParallelUploadConcurrency: 16}, false},
+		{"negative_threshold", WriteConfig{ParallelUploadThresholdMb: -1, ParallelUploadPartSizeMb: 64, ParallelUploadConcurrency: 16}, true},
+		{"zero_part_size", WriteConfig{ParallelUploadThresholdMb: 1024, ParallelUploadPartSizeMb: 0, ParallelUploadConcurrency: 16}, true},
+		{"zero_concurrency", WriteConfig{ParallelUploadThresholdMb: 1024, ParallelUploadPartSizeMb: 64, ParallelUploadConcurrency: 0}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := isValidParallelUploadConfig(&tc.writeConfig)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidConflictPolicy(t *testing.T) {
+	var testCases = []struct {
+		policy  string
+		wantErr bool
+	}{
+		{ConflictPolicyFail, false},
+		{ConflictPolicyLastWriterWins, false},
+		{ConflictPolicyConflictCopy, false},
+		{"", true},
+		{"overwrite", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.policy, func(t *testing.T) {
+			err := isValidConflictPolicy(tc.policy)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidGzipObjects(t *testing.T) {
+	var testCases = []struct {
+		testName string
+		patterns []string
+		wantErr  bool
+	}{
+		{"disabled", nil, false},
+		{"valid", []string{"*.log", "logs/"}, false},
+		{"empty_pattern", []string{"*.log", ""}, true},
+		{"match_all", []string{"*"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := isValidGzipObjects(tc.patterns)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidUnionLowerLayers(t *testing.T) {
+	var testCases = []struct {
+		testName string
+		layers   []string
+		wantErr  bool
+	}{
+		{"disabled", nil, false},
+		{"valid", []string{"base-dataset", "other-bucket/v3/train"}, false},
+		{"empty_layer", []string{"base-dataset", ""}, true},
+		{"missing_bucket", []string{"/v3/train"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := isValidUnionLowerLayers(tc.layers)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidWriteBackConfig(t *testing.T) {
+	var testCases = []struct {
+		testName    string
+		writeConfig WriteConfig
+		wantErr     bool
+	}{
+		{"disabled", WriteConfig{WriteBackStagingBudgetMb: 0}, false},
+		{"valid", WriteConfig{WriteBackStagingBudgetMb: 1024, WriteBackConcurrency: 4}, false},
+		{"negative_budget", WriteConfig{WriteBackStagingBudgetMb: -1, WriteBackConcurrency: 4}, true},
+		{"zero_concurrency", WriteConfig{WriteBackStagingBudgetMb: 1024, WriteBackConcurrency: 0}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			err := isValidWriteBackConfig(&tc.writeConfig)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidHedgedReadsConfig(t *testing.T) {
+	valid := HedgedReadsGcsConnectionConfig{
+		Enable:           true,
+		MaxDelay:         time.Second,
+		MaxHedgesPerSec:  10,
+		MinDelay:         10 * time.Millisecond,
+		TargetPercentile: 0.95,
+	}
+	var testCases = []struct {
+		testName string
+		modify   func(*HedgedReadsGcsConnectionConfig)
+		wantErr  bool
+	}{
+		{"disabled", func(c *HedgedReadsGcsConnectionConfig) { *c = HedgedReadsGcsConnectionConfig{} }, false},
+		{"valid", func(*HedgedReadsGcsConnectionConfig) {}, false},
+		{"target_percentile_one", func(c *HedgedReadsGcsConnectionConfig) { c.TargetPercentile = 1 }, true},
+		{"target_percentile_zero", func(c *HedgedReadsGcsConnectionConfig) { c.TargetPercentile = 0 }, true},
+		{"negative_min_delay", func(c *HedgedReadsGcsConnectionConfig) { c.MinDelay = -time.Second }, true},
+		{"max_delay_below_min_delay", func(c *HedgedReadsGcsConnectionConfig) { c.MaxDelay = time.Millisecond }, true},
+		{"zero_max_hedges_per_sec", func(c *HedgedReadsGcsConnectionConfig) { c.MaxHedgesPerSec = 0 }, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			c := valid
+			tc.modify(&c)
+
+			err := isValidHedgedReadsConfig(&c)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidCircuitBreakerConfig(t *testing.T) {
+	valid := CircuitBreakerGcsConnectionConfig{
+		Enable:             true,
+		ErrorRateThreshold: 0.5,
+		MinRequests:        20,
+		OpenDuration:       30 * time.Second,
+		WindowSize:         100,
+	}
+	var testCases = []struct {
+		testName string
+		modify   func(*CircuitBreakerGcsConnectionConfig)
+		wantErr  bool
+	}{
+		{"disabled", func(c *CircuitBreakerGcsConnectionConfig) { *c = CircuitBreakerGcsConnectionConfig{} }, false},
+		{"valid", func(*CircuitBreakerGcsConnectionConfig) {}, false},
+		{"error_rate_threshold_one", func(c *CircuitBreakerGcsConnectionConfig) { c.ErrorRateThreshold = 1 }, false},
+		{"error_rate_threshold_zero", func(c *CircuitBreakerGcsConnectionConfig) { c.ErrorRateThreshold = 0 }, true},
+		{"error_rate_threshold_above_one", func(c *CircuitBreakerGcsConnectionConfig) { c.ErrorRateThreshold = 1.5 }, true},
+		{"zero_min_requests", func(c *CircuitBreakerGcsConnectionConfig) { c.MinRequests = 0 }, true},
+		{"window_size_below_min_requests", func(c *CircuitBreakerGcsConnectionConfig) { c.WindowSize = 10 }, true},
+		{"zero_open_duration", func(c *CircuitBreakerGcsConnectionConfig) { c.OpenDuration = 0 }, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			c := valid
+			tc.modify(&c)
+
+			err := isValidCircuitBreakerConfig(&c)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidCostAccountingConfig(t *testing.T) {
+	valid := CostAccountingConfig{
+		ClassAPricePer10K: 0.05,
+		ClassBPricePer10K: 0.004,
+		EgressPricePerGib: 0.12,
+		Enable:            true,
+		LogInterval:       time.Hour,
+		MaxPrefixes:       100,
+		PrefixDepth:       1,
+	}
+	var testCases = []struct {
+		testName string
+		modify   func(*CostAccountingConfig)
+		wantErr  bool
+	}{
+		{"disabled", func(c *CostAccountingConfig) { *c = CostAccountingConfig{PrefixDepth: -1} }, false},
+		{"valid", func(*CostAccountingConfig) {}, false},
+		{"free", func(c *CostAccountingConfig) { c.ClassAPricePer10K, c.ClassBPricePer10K, c.EgressPricePerGib = 0, 0, 0 }, false},
+		{"negative_price", func(c *CostAccountingConfig) { c.EgressPricePerGib = -0.12 }, true},
+		{"negative_prefix_depth", func(c *CostAccountingConfig) { c.PrefixDepth = -1 }, true},
+		{"negative_max_prefixes", func(c *CostAccountingConfig) { c.MaxPrefixes = -1 }, true},
+		{"no_logs", func(c *CostAccountingConfig) { c.LogInterval = 0 }, false},
+		{"negative_log_interval", func(c *CostAccountingConfig) { c.LogInterval = -time.Hour }, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.testName, func(t *testing.T) {
+			c := valid
+			tc.modify(&c)
+
+			err := isValidCostAccountingConfig(&c)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidFailoverTimeout(t *testing.T) {
+	var testCases = []struct {
+		timeout time.Duration
+		wantErr bool
+	}{
+		{0, false},
+		{10 * time.Second, false},
+		{-time.Second, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.timeout.String(), func(t *testing.T) {
+			err := isValidFailoverTimeout(tc.timeout)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func Test_isValidTraceConfig(t *testing.T) {
+	var testCases = []struct {
+		name    string
+		config  DebugConfig
+		wantErr bool
+	}{
+		{"none", DebugConfig{}, false},
+		{"record", DebugConfig{RecordTrace: "/tmp/trace"}, false},
+		{"replay", DebugConfig{ReplayTrace: "/tmp/trace"}, false},
+		{"both", DebugConfig{RecordTrace: "/tmp/a", ReplayTrace: "/tmp/b"}, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := isValidTraceConfig(&tc.config)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
 func validConfig(t *testing.T) Config {
 	return Config{
 		Logging:   LoggingConfig{LogRotate: validLogRotateConfig()},
+		Write:     validWriteConfig(),
 		FileCache: validFileCacheConfig(t),
 		GcsConnection: GcsConnectionConfig{
 			CustomEndpoint:       "https://bing.com/search?q=dotnet",
@@ -663,3 +1036,28 @@ func TestValidateMetrics(t *testing.T) {
 		})
 	}
 }
+
+func Test_isValidSnapshotTime(t *testing.T) {
+	var testCases = []struct {
+		snapshotTime string
+		wantErr      bool
+	}{
+		{"", false},
+		{"2025-01-02T03:04:05Z", false},
+		{"2025-01-02T03:04:05.123+05:30", false},
+		{"2025-01-02", true},
+		{"yesterday", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.snapshotTime, func(t *testing.T) {
+			err := isValidSnapshotTime(tc.snapshotTime)
+
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
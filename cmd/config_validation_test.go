@@ -190,11 +190,19 @@ func TestValidateConfigFile_WriteConfig(t *testing.T) {
 			configFile: "testdata/empty_file.yaml",
 			expectedConfig: &cfg.Config{
 				Write: cfg.WriteConfig{
-					CreateEmptyFile:       false,
-					BlockSizeMb:           32 * util.MiB,
-					EnableStreamingWrites: false,
-					GlobalMaxBlocks:       math.MaxInt64,
-					MaxBlocksPerFile:      1},
+					ConflictPolicy:            "fail",
+					CreateEmptyFile:           false,
+					BlockSizeMb:               32 * util.MiB,
+					EnableStreamingWrites:     false,
+					GlobalMaxBlocks:           math.MaxInt64,
+					GzipObjects:               []string{},
+					MaxBlocksPerFile:          1,
+					MaxPendingBlocksPerFile:   1,
+					MemoryBudgetMb:            -1,
+					ParallelUploadConcurrency: 16,
+					ParallelUploadPartSizeMb:  64,
+					WriteBackConcurrency:      4,
+				},
 			},
 		},
 		{
@@ -202,11 +210,18 @@ func TestValidateConfigFile_WriteConfig(t *testing.T) {
 			configFile: "testdata/valid_config.yaml",
 			expectedConfig: &cfg.Config{
 				Write: cfg.WriteConfig{
-					CreateEmptyFile:       false, // changed due to enabled streaming writes.
-					BlockSizeMb:           10 * util.MiB,
-					EnableStreamingWrites: true,
-					GlobalMaxBlocks:       20,
-					MaxBlocksPerFile:      2,
+					ConflictPolicy:            "fail",
+					CreateEmptyFile:           false, // changed due to enabled streaming writes.
+					BlockSizeMb:               10 * util.MiB,
+					EnableStreamingWrites:     true,
+					GlobalMaxBlocks:           20,
+					GzipObjects:               []string{},
+					MaxBlocksPerFile:          2,
+					MaxPendingBlocksPerFile:   1,
+					MemoryBudgetMb:            -1,
+					ParallelUploadConcurrency: 16,
+					ParallelUploadPartSizeMb:  64,
+					WriteBackConcurrency:      4,
 				},
 			},
 		},
@@ -446,17 +461,29 @@ func TestValidateConfigFile_GCSConnectionConfigSuccessful(t *testing.T) {
 			configFile: "testdata/empty_file.yaml",
 			expectedConfig: &cfg.Config{
 				GcsConnection: cfg.GcsConnectionConfig{
-					BillingProject:             "",
+					BillingProject: "",
+					CircuitBreaker: cfg.CircuitBreakerGcsConnectionConfig{
+						ErrorRateThreshold: 0.5,
+						MinRequests:        20,
+						OpenDuration:       30 * time.Second,
+						WindowSize:         100,
+					},
 					ClientProtocol:             "http1",
 					CustomEndpoint:             "",
 					ExperimentalEnableJsonRead: false,
 					GrpcConnPoolSize:           1,
-					HttpClientTimeout:          0,
-					LimitBytesPerSec:           -1,
-					LimitOpsPerSec:             -1,
-					MaxConnsPerHost:            0,
-					MaxIdleConnsPerHost:        100,
-					SequentialReadSizeMb:       200,
+					HedgedReads: cfg.HedgedReadsGcsConnectionConfig{
+						MaxDelay:         time.Second,
+						MaxHedgesPerSec:  10,
+						MinDelay:         10 * time.Millisecond,
+						TargetPercentile: 0.95,
+					},
+					HttpClientTimeout:    0,
+					LimitBytesPerSec:     -1,
+					LimitOpsPerSec:       -1,
+					MaxConnsPerHost:      0,
+					MaxIdleConnsPerHost:  100,
+					SequentialReadSizeMb: 200,
 				},
 			},
 		},
@@ -465,17 +492,29 @@ func TestValidateConfigFile_GCSConnectionConfigSuccessful(t *testing.T) {
 			configFile: "testdata/valid_config.yaml",
 			expectedConfig: &cfg.Config{
 				GcsConnection: cfg.GcsConnectionConfig{
-					BillingProject:             "abc",
+					BillingProject: "abc",
+					CircuitBreaker: cfg.CircuitBreakerGcsConnectionConfig{
+						ErrorRateThreshold: 0.5,
+						MinRequests:        20,
+						OpenDuration:       30 * time.Second,
+						WindowSize:         100,
+					},
 					ClientProtocol:             "http2",
 					CustomEndpoint:             "www.abc.com",
 					ExperimentalEnableJsonRead: true,
 					GrpcConnPoolSize:           200,
-					HttpClientTimeout:          400 * time.Second,
-					LimitBytesPerSec:           20,
-					LimitOpsPerSec:             30,
-					MaxConnsPerHost:            400,
-					MaxIdleConnsPerHost:        20,
-					SequentialReadSizeMb:       450,
+					HedgedReads: cfg.HedgedReadsGcsConnectionConfig{
+						MaxDelay:         time.Second,
+						MaxHedgesPerSec:  10,
+						MinDelay:         10 * time.Millisecond,
+						TargetPercentile: 0.95,
+					},
+					HttpClientTimeout:    400 * time.Second,
+					LimitBytesPerSec:     20,
+					LimitOpsPerSec:       30,
+					MaxConnsPerHost:      400,
+					MaxIdleConnsPerHost:  20,
+					SequentialReadSizeMb: 450,
 				},
 			},
 		},
@@ -32,12 +32,14 @@ import (
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/encryption"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/canned"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/monitor"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/mount"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/local"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
 	"github.com/jacobsa/daemonize"
@@ -138,6 +140,15 @@ func createStorageHandle(newConfig *cfg.Config, userAgent string) (storageHandle
 		GrpcConnPoolSize:           int(newConfig.GcsConnection.GrpcConnPoolSize),
 		EnableHNS:                  newConfig.EnableHns,
 		ReadStallRetryConfig:       newConfig.GcsRetries.ReadStall,
+		S3Endpoint:                 newConfig.S3.Endpoint,
+		S3Region:                   newConfig.S3.Region,
+	}
+	if newConfig.GcsAuth.CustomerSuppliedEncryptionKeyFile != "" {
+		storageClientConfig.EncryptionKey, err = encryption.ReadKeyFile(string(newConfig.GcsAuth.CustomerSuppliedEncryptionKeyFile))
+		if err != nil {
+			err = fmt.Errorf("customer-supplied encryption key: %w", err)
+			return
+		}
 	}
 	logger.Infof("UserAgent = %s\n", storageClientConfig.UserAgent)
 	storageHandle, err = storage.NewStorageHandle(context.Background(), storageClientConfig)
@@ -160,10 +171,12 @@ func mountWithArgs(bucketName string, mountPoint string, newConfig *cfg.Config,
 
 	// Grab the connection.
 	//
-	// Special case: if we're mounting the fake bucket, we don't need an actual
-	// connection.
+	// Special case: if we're mounting the fake bucket, local directories or a
+	// replayed trace, we don't need an actual connection to GCS. Buckets of
+	// S3-compatible stores are served by their own storage handle.
 	var storageHandle storage.StorageHandle
-	if bucketName != canned.FakeBucketName {
+	_, isLocal := local.DirFromEndpoint(newConfig.GcsConnection.CustomEndpoint)
+	if bucketName != canned.FakeBucketName && !isLocal && newConfig.Debug.ReplayTrace == "" {
 		userAgent := getUserAgent(newConfig.AppName, getConfigForUserAgent(newConfig))
 		logger.Info("Creating Storage handle...")
 		storageHandle, err = createStorageHandle(newConfig, userAgent)
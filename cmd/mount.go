@@ -17,18 +17,25 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/mount"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/local"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/replay"
 	"golang.org/x/net/context"
 
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/encryption"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/monitor"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/perms"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
 	"github.com/jacobsa/fuse"
 	"github.com/jacobsa/fuse/fsutil"
 	"github.com/jacobsa/timeutil"
@@ -98,6 +105,92 @@ be interacting with the file system.`)
 		AppendThreshold:                    1 << 21, // 2 MiB, a total guess.
 		ChunkTransferTimeoutSecs:           newConfig.GcsRetries.ChunkTransferTimeoutSecs,
 		TmpObjectPrefix:                    ".gcsfuse_tmp/",
+		SniffContentType:                   newConfig.Write.SniffContentType,
+		GzipPatterns:                       newConfig.Write.GzipObjects,
+		TempDir:                            string(newConfig.FileSystem.TempDir),
+	}
+	if newConfig.Write.ParallelUploadThresholdMb > 0 {
+		bucketCfg.ParallelUpload = &gcsx.ParallelUploadConfig{
+			Threshold:   newConfig.Write.ParallelUploadThresholdMb * util.MiB,
+			PartSize:    newConfig.Write.ParallelUploadPartSizeMb * util.MiB,
+			Concurrency: int(newConfig.Write.ParallelUploadConcurrency),
+		}
+	}
+	if hr := newConfig.GcsConnection.HedgedReads; hr.Enable {
+		bucketCfg.HedgedReads = &gcsx.HedgedReadsConfig{
+			TargetPercentile: hr.TargetPercentile,
+			MinDelay:         hr.MinDelay,
+			MaxDelay:         hr.MaxDelay,
+			MaxHedgesPerSec:  hr.MaxHedgesPerSec,
+		}
+	}
+	if cb := newConfig.GcsConnection.CircuitBreaker; cb.Enable {
+		bucketCfg.CircuitBreaker = &gcsx.CircuitBreakerConfig{
+			ErrorRateThreshold: cb.ErrorRateThreshold,
+			WindowSize:         int(cb.WindowSize),
+			MinRequests:        int(cb.MinRequests),
+			OpenDuration:       cb.OpenDuration,
+			ReadOnlyWhenOpen:   cb.ReadOnlyWhenOpen,
+		}
+	}
+	if ca := newConfig.CostAccounting; ca.Enable {
+		bucketCfg.CostAccounting = &monitor.CostConfig{
+			MountLabel:        ca.MountLabel,
+			PrefixDepth:       int(ca.PrefixDepth),
+			MaxPrefixes:       int(ca.MaxPrefixes),
+			ClassAPrice:       ca.ClassAPricePer10K,
+			ClassBPrice:       ca.ClassBPricePer10K,
+			EgressPricePerGiB: ca.EgressPricePerGib,
+			LogInterval:       ca.LogInterval,
+		}
+	}
+	if len(newConfig.Union.LowerLayers) > 0 {
+		bucketCfg.UnionLowerLayers = newConfig.Union.LowerLayers
+	}
+	if newConfig.FailoverBucket != "" {
+		bucketCfg.FailoverBucket = newConfig.FailoverBucket
+		bucketCfg.FailoverTimeout = newConfig.FailoverTimeout
+	}
+	if newConfig.Debug.RecordTrace != "" {
+		bucketCfg.RecordTrace = string(newConfig.Debug.RecordTrace)
+		bucketCfg.RecordPayloads = newConfig.Debug.RecordPayloads
+	}
+	if newConfig.Debug.ReplayTrace != "" {
+		bucketCfg.ReplayTrace = string(newConfig.Debug.ReplayTrace)
+		bucketCfg.Replay = replay.ReplayConfig{
+			MatchByRequest:  newConfig.Debug.ReplayMatchByRequest,
+			Timings:         newConfig.Debug.ReplayTimings,
+			TmpObjectPrefix: bucketCfg.TmpObjectPrefix,
+		}
+	}
+	if newConfig.SnapshotTime != "" {
+		// Already validated along with the config.
+		bucketCfg.SnapshotTime, _ = time.Parse(time.RFC3339, newConfig.SnapshotTime)
+	}
+	if newConfig.Write.ObjectAttributesFile != "" {
+		bucketCfg.ObjectAttributesTemplates, err = gcsx.LoadObjectAttributesTemplates(string(newConfig.Write.ObjectAttributesFile))
+		if err != nil {
+			err = fmt.Errorf("LoadObjectAttributesTemplates: %w", err)
+			return
+		}
+	}
+	if newConfig.Debug.FaultInjectionFile != "" {
+		bucketCfg.FaultInjector, err = gcsx.NewFaultInjector(string(newConfig.Debug.FaultInjectionFile))
+		if err != nil {
+			err = fmt.Errorf("NewFaultInjector: %w", err)
+			return
+		}
+		go reloadFaultRulesOnSIGHUP(bucketCfg.FaultInjector)
+	}
+	if dir, ok := local.DirFromEndpoint(newConfig.GcsConnection.CustomEndpoint); ok {
+		bucketCfg.LocalDir = dir
+	}
+	if newConfig.ClientSideEncryption.KeyFile != "" {
+		bucketCfg.EncryptionKey, err = encryption.ReadKeyFile(string(newConfig.ClientSideEncryption.KeyFile))
+		if err != nil {
+			err = fmt.Errorf("client-side encryption: %w", err)
+			return
+		}
 	}
 	bm := gcsx.NewBucketManager(bucketCfg, storageHandle)
 
@@ -171,9 +264,25 @@ func getFuseMountConfig(fsName string, newConfig *cfg.Config) *fuse.MountConfig
 		EnableParallelDirOps: !(newConfig.FileSystem.DisableParallelDirops),
 		// We disable write-back cache when streaming writes are enabled.
 		DisableWritebackCaching: newConfig.Write.EnableStreamingWrites,
+		// Snapshots of buckets and failover mounts can't be modified.
+		ReadOnly: newConfig.SnapshotTime != "" || newConfig.FailoverBucket != "",
 	}
 
 	mountCfg.ErrorLogger = logger.NewLegacyLogger(logger.LevelError, "fuse: ")
 	mountCfg.DebugLogger = logger.NewLegacyLogger(logger.LevelTrace, "fuse_debug: ")
 	return mountCfg
 }
+
+// Read the fault injection rules again on each SIGHUP, which is how they are
+// changed while mounted, in place of an admin socket.
+func reloadFaultRulesOnSIGHUP(fi *gcsx.FaultInjector) {
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, syscall.SIGHUP)
+	for range c {
+		if err := fi.Reload(); err != nil {
+			logger.Errorf("Reloading fault injection rules, keeping the current ones: %v", err)
+			continue
+		}
+		logger.Infof("Reloaded fault injection rules.")
+	}
+}
@@ -540,17 +540,29 @@ func TestArgsParsing_GCSConnectionFlags(t *testing.T) {
 			args: []string{"gcsfuse", "--billing-project=abc", "--client-protocol=http2", "--custom-endpoint=www.abc.com", "--experimental-enable-json-read", "--experimental-grpc-conn-pool-size=20", "--http-client-timeout=20s", "--limit-bytes-per-sec=30", "--limit-ops-per-sec=10", "--max-conns-per-host=1000", "--max-idle-conns-per-host=20", "--sequential-read-size-mb=70", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				GcsConnection: cfg.GcsConnectionConfig{
-					BillingProject:             "abc",
+					BillingProject: "abc",
+					CircuitBreaker: cfg.CircuitBreakerGcsConnectionConfig{
+						ErrorRateThreshold: 0.5,
+						MinRequests:        20,
+						OpenDuration:       30 * time.Second,
+						WindowSize:         100,
+					},
 					ClientProtocol:             "http2",
 					CustomEndpoint:             "www.abc.com",
 					ExperimentalEnableJsonRead: true,
 					GrpcConnPoolSize:           20,
-					HttpClientTimeout:          20 * time.Second,
-					LimitBytesPerSec:           30,
-					LimitOpsPerSec:             10,
-					MaxConnsPerHost:            1000,
-					MaxIdleConnsPerHost:        20,
-					SequentialReadSizeMb:       70,
+					HedgedReads: cfg.HedgedReadsGcsConnectionConfig{
+						MaxDelay:         time.Second,
+						MaxHedgesPerSec:  10,
+						MinDelay:         10 * time.Millisecond,
+						TargetPercentile: 0.95,
+					},
+					HttpClientTimeout:    20 * time.Second,
+					LimitBytesPerSec:     30,
+					LimitOpsPerSec:       10,
+					MaxConnsPerHost:      1000,
+					MaxIdleConnsPerHost:  20,
+					SequentialReadSizeMb: 70,
 				},
 			},
 		},
@@ -559,17 +571,29 @@ func TestArgsParsing_GCSConnectionFlags(t *testing.T) {
 			args: []string{"gcsfuse", "abc", "pqr"},
 			expectedConfig: &cfg.Config{
 				GcsConnection: cfg.GcsConnectionConfig{
-					BillingProject:             "",
+					BillingProject: "",
+					CircuitBreaker: cfg.CircuitBreakerGcsConnectionConfig{
+						ErrorRateThreshold: 0.5,
+						MinRequests:        20,
+						OpenDuration:       30 * time.Second,
+						WindowSize:         100,
+					},
 					ClientProtocol:             "http1",
 					CustomEndpoint:             "",
 					ExperimentalEnableJsonRead: false,
 					GrpcConnPoolSize:           1,
-					HttpClientTimeout:          0,
-					LimitBytesPerSec:           -1,
-					LimitOpsPerSec:             -1,
-					MaxConnsPerHost:            0,
-					MaxIdleConnsPerHost:        100,
-					SequentialReadSizeMb:       200,
+					HedgedReads: cfg.HedgedReadsGcsConnectionConfig{
+						MaxDelay:         time.Second,
+						MaxHedgesPerSec:  10,
+						MinDelay:         10 * time.Millisecond,
+						TargetPercentile: 0.95,
+					},
+					HttpClientTimeout:    0,
+					LimitBytesPerSec:     -1,
+					LimitOpsPerSec:       -1,
+					MaxConnsPerHost:      0,
+					MaxIdleConnsPerHost:  100,
+					SequentialReadSizeMb: 200,
 				},
 			},
 		},
@@ -23,12 +23,16 @@ func NewNoopMetrics() MetricHandle {
 
 type noopMetrics struct{}
 
-func (*noopMetrics) GCSReadBytesCount(_ context.Context, _ int64, _ []MetricAttr)       {}
-func (*noopMetrics) GCSReaderCount(_ context.Context, _ int64, _ []MetricAttr)          {}
-func (*noopMetrics) GCSRequestCount(_ context.Context, _ int64, _ []MetricAttr)         {}
-func (*noopMetrics) GCSRequestLatency(_ context.Context, value float64, _ []MetricAttr) {}
-func (*noopMetrics) GCSReadCount(_ context.Context, _ int64, _ []MetricAttr)            {}
-func (*noopMetrics) GCSDownloadBytesCount(_ context.Context, _ int64, _ []MetricAttr)   {}
+func (*noopMetrics) GCSReadBytesCount(_ context.Context, _ int64, _ []MetricAttr)                {}
+func (*noopMetrics) GCSReaderCount(_ context.Context, _ int64, _ []MetricAttr)                   {}
+func (*noopMetrics) GCSRequestCount(_ context.Context, _ int64, _ []MetricAttr)                  {}
+func (*noopMetrics) GCSRequestLatency(_ context.Context, value float64, _ []MetricAttr)          {}
+func (*noopMetrics) GCSReadCount(_ context.Context, _ int64, _ []MetricAttr)                     {}
+func (*noopMetrics) GCSDownloadBytesCount(_ context.Context, _ int64, _ []MetricAttr)            {}
+func (*noopMetrics) GCSHedgedReadCount(_ context.Context, _ int64, _ []MetricAttr)               {}
+func (*noopMetrics) GCSCircuitBreakerTransitionCount(_ context.Context, _ int64, _ []MetricAttr) {}
+func (*noopMetrics) GCSOperationCount(_ context.Context, _ int64, _ []MetricAttr)                {}
+func (*noopMetrics) GCSTransferBytesCount(_ context.Context, _ int64, _ []MetricAttr)            {}
 
 func (*noopMetrics) OpsCount(_ context.Context, _ int64, _ []MetricAttr)         {}
 func (*noopMetrics) OpsLatency(_ context.Context, value float64, _ []MetricAttr) {}
@@ -37,3 +41,8 @@ func (*noopMetrics) OpsErrorCount(_ context.Context, _ int64, _ []MetricAttr)
 func (*noopMetrics) FileCacheReadCount(_ context.Context, _ int64, _ []MetricAttr)         {}
 func (*noopMetrics) FileCacheReadBytesCount(_ context.Context, _ int64, _ []MetricAttr)    {}
 func (*noopMetrics) FileCacheReadLatency(_ context.Context, value float64, _ []MetricAttr) {}
+
+func (*noopMetrics) WriteBackPendingUploads(_ context.Context, _ int64, _ []MetricAttr) {}
+func (*noopMetrics) WriteBackPendingBytes(_ context.Context, _ int64, _ []MetricAttr)   {}
+
+func (*noopMetrics) WriteConflictCount(_ context.Context, _ int64, _ []MetricAttr) {}
@@ -45,16 +45,51 @@ const (
 
 	// CacheHit annotates the read operation from file cache with true or false.
 	CacheHit = "cache_hit"
+
+	// ConflictPolicy annotates the write conflict with the policy applied.
+	ConflictPolicy = "conflict_policy"
+
+	// HedgeOutcome annotates the hedged read with whether the hedge won, lost
+	// or was throttled.
+	HedgeOutcome = "hedge_outcome"
+
+	// OpClass annotates the circuit breaker transition with the class of
+	// operations it guards - read/metadata/write.
+	OpClass = "op_class"
+
+	// CircuitBreakerState annotates the circuit breaker transition with the
+	// state entered - open/half_open/closed.
+	CircuitBreakerState = "circuit_breaker_state"
+
+	// GCSOperationClass annotates the GCS operation with its pricing class -
+	// class_a/class_b/free.
+	GCSOperationClass = "gcs_operation_class"
+
+	// TransferDirection annotates the bytes transferred with their direction -
+	// egress/ingress.
+	TransferDirection = "transfer_direction"
+
+	// MountLabel annotates the GCS operations and bytes transferred with the
+	// label of the mount.
+	MountLabel = "mount_label"
+
+	// ObjectPrefix annotates the GCS operations and bytes transferred with the
+	// prefix of the object names they are attributed to.
+	ObjectPrefix = "object_prefix"
 )
 
 type ocMetrics struct {
 	// GCS measures
-	gcsReadBytesCount     *stats.Int64Measure
-	gcsReaderCount        *stats.Int64Measure
-	gcsRequestCount       *stats.Int64Measure
-	gcsRequestLatency     *stats.Float64Measure
-	gcsReadCount          *stats.Int64Measure
-	gcsDownloadBytesCount *stats.Int64Measure
+	gcsReadBytesCount                *stats.Int64Measure
+	gcsReaderCount                   *stats.Int64Measure
+	gcsRequestCount                  *stats.Int64Measure
+	gcsRequestLatency                *stats.Float64Measure
+	gcsReadCount                     *stats.Int64Measure
+	gcsDownloadBytesCount            *stats.Int64Measure
+	gcsHedgedReadCount               *stats.Int64Measure
+	gcsCircuitBreakerTransitionCount *stats.Int64Measure
+	gcsOperationCount                *stats.Int64Measure
+	gcsTransferBytesCount            *stats.Int64Measure
 
 	// Ops measures
 	opsCount      *stats.Int64Measure
@@ -65,6 +100,13 @@ type ocMetrics struct {
 	fileCacheReadCount      *stats.Int64Measure
 	fileCacheReadBytesCount *stats.Int64Measure
 	fileCacheReadLatency    *stats.Float64Measure
+
+	// Write-back measures
+	writeBackPendingUploads *stats.Int64Measure
+	writeBackPendingBytes   *stats.Int64Measure
+
+	// Write measures
+	writeConflictCount *stats.Int64Measure
 }
 
 func attrsToTags(attrs []MetricAttr) []tag.Mutator {
@@ -113,6 +155,22 @@ func (o *ocMetrics) GCSDownloadBytesCount(ctx context.Context, inc int64, attrs
 	recordOCMetric(ctx, o.gcsDownloadBytesCount, inc, attrs, "GCS download bytes count")
 }
 
+func (o *ocMetrics) GCSHedgedReadCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsHedgedReadCount, inc, attrs, "GCS hedged read count")
+}
+
+func (o *ocMetrics) GCSCircuitBreakerTransitionCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsCircuitBreakerTransitionCount, inc, attrs, "GCS circuit breaker transition count")
+}
+
+func (o *ocMetrics) GCSOperationCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsOperationCount, inc, attrs, "GCS operation count")
+}
+
+func (o *ocMetrics) GCSTransferBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.gcsTransferBytesCount, inc, attrs, "GCS transfer bytes count")
+}
+
 func (o *ocMetrics) OpsCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	recordOCMetric(ctx, o.opsCount, inc, attrs, "file system op count")
 }
@@ -133,6 +191,17 @@ func (o *ocMetrics) FileCacheReadLatency(ctx context.Context, value float64, att
 	recordOCLatencyMetric(ctx, o.fileCacheReadLatency, value, attrs, "file cache read latency")
 }
 
+func (o *ocMetrics) WriteBackPendingUploads(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.writeBackPendingUploads, inc, attrs, "write-back pending uploads")
+}
+func (o *ocMetrics) WriteBackPendingBytes(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.writeBackPendingBytes, inc, attrs, "write-back pending bytes")
+}
+
+func (o *ocMetrics) WriteConflictCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	recordOCMetric(ctx, o.writeConflictCount, inc, attrs, "write conflict count")
+}
+
 func recordOCMetric(ctx context.Context, m *stats.Int64Measure, inc int64, attrs []MetricAttr, metricStr string) {
 	if err := stats.RecordWithTags(
 		ctx,
@@ -160,6 +229,10 @@ func NewOCMetrics() (MetricHandle, error) {
 	gcsRequestLatency := stats.Float64("gcs/request_latency", "The latency of a GCS request.", stats.UnitMilliseconds)
 	gcsReadCount := stats.Int64("gcs/read_count", "Specifies the number of gcs reads made along with type - Sequential/Random", stats.UnitDimensionless)
 	gcsDownloadBytesCount := stats.Int64("gcs/download_bytes_count", "The cumulative number of bytes downloaded from GCS along with type - Sequential/Random", stats.UnitBytes)
+	gcsCircuitBreakerTransitionCount := stats.Int64("gcs/circuit_breaker_transition_count", "The number of state transitions of the circuit breakers around GCS along with operation class and state entered", stats.UnitDimensionless)
+	gcsOperationCount := stats.Int64("gcs/operation_count", "The number of GCS operations along with pricing class, mount label and object prefix", stats.UnitDimensionless)
+	gcsTransferBytesCount := stats.Int64("gcs/transfer_bytes_count", "The number of bytes transferred from and to GCS along with direction, mount label and object prefix", stats.UnitBytes)
+	gcsHedgedReadCount := stats.Int64("gcs/hedged_read_count", "The number of reads whose first byte was late enough to be hedged along with outcome - won/lost/throttled", stats.UnitDimensionless)
 
 	opsCount := stats.Int64("fs/ops_count", "The number of ops processed by the file system.", stats.UnitDimensionless)
 	opsLatency := stats.Float64("fs/ops_latency", "The latency of a file system operation.", "us")
@@ -168,6 +241,11 @@ func NewOCMetrics() (MetricHandle, error) {
 	fileCacheReadCount := stats.Int64("file_cache/read_count", "Specifies the number of read requests made via file cache along with type - Sequential/Random and cache hit - true/false", stats.UnitDimensionless)
 	fileCacheReadBytesCount := stats.Int64("file_cache/read_bytes_count", "The cumulative number of bytes read from file cache along with read type - Sequential/Random", stats.UnitBytes)
 	fileCacheReadLatency := stats.Float64("file_cache/read_latency", "Latency of read from file cache along with cache hit - true/false", "us")
+
+	writeBackPendingUploads := stats.Int64("write_back/pending_uploads", "The change in number of closed files whose upload to GCS is pending in write-back mode.", stats.UnitDimensionless)
+	writeBackPendingBytes := stats.Int64("write_back/pending_bytes", "The change in number of bytes staged locally for pending uploads in write-back mode.", stats.UnitBytes)
+
+	writeConflictCount := stats.Int64("write/conflict_count", "The number of files found modified concurrently on sync along with the conflict policy applied.", stats.UnitDimensionless)
 	// OpenCensus views (aggregated measures)
 	if err := view.Register(
 		&view.View{
@@ -211,6 +289,34 @@ func NewOCMetrics() (MetricHandle, error) {
 			Aggregation: view.Sum(),
 			TagKeys:     []tag.Key{tag.MustNewKey(ReadType)},
 		},
+		&view.View{
+			Name:        "gcs/hedged_read_count",
+			Measure:     gcsHedgedReadCount,
+			Description: "The cumulative number of reads whose first byte was late enough to be hedged along with outcome - won/lost/throttled",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(HedgeOutcome)},
+		},
+		&view.View{
+			Name:        "gcs/circuit_breaker_transition_count",
+			Measure:     gcsCircuitBreakerTransitionCount,
+			Description: "The cumulative number of state transitions of the circuit breakers around GCS along with operation class and state entered",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(OpClass), tag.MustNewKey(CircuitBreakerState)},
+		},
+		&view.View{
+			Name:        "gcs/operation_count",
+			Measure:     gcsOperationCount,
+			Description: "The cumulative number of GCS operations along with pricing class, mount label and object prefix",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(GCSOperationClass), tag.MustNewKey(MountLabel), tag.MustNewKey(ObjectPrefix)},
+		},
+		&view.View{
+			Name:        "gcs/transfer_bytes_count",
+			Measure:     gcsTransferBytesCount,
+			Description: "The cumulative number of bytes transferred from and to GCS along with direction, mount label and object prefix",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(TransferDirection), tag.MustNewKey(MountLabel), tag.MustNewKey(ObjectPrefix)},
+		},
 		&view.View{
 			Name:        "fs/ops_count",
 			Measure:     opsCount,
@@ -253,16 +359,40 @@ func NewOCMetrics() (MetricHandle, error) {
 			Description: "The cumulative distribution of the file cache read latencies along with cache hit - true/false",
 			Aggregation: ochttp.DefaultLatencyDistribution,
 			TagKeys:     []tag.Key{tag.MustNewKey(CacheHit)},
+		},
+		// Write-back related metrics
+		&view.View{
+			Name:        "write_back/pending_uploads",
+			Measure:     writeBackPendingUploads,
+			Description: "The number of closed files whose upload to GCS is pending in write-back mode.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "write_back/pending_bytes",
+			Measure:     writeBackPendingBytes,
+			Description: "The number of bytes staged locally for pending uploads in write-back mode.",
+			Aggregation: view.Sum(),
+		},
+		&view.View{
+			Name:        "write/conflict_count",
+			Measure:     writeConflictCount,
+			Description: "The cumulative number of files found modified concurrently on sync along with the conflict policy applied.",
+			Aggregation: view.Sum(),
+			TagKeys:     []tag.Key{tag.MustNewKey(ConflictPolicy)},
 		}); err != nil {
 		return nil, fmt.Errorf("failed to register OpenCensus metrics for GCS client library: %w", err)
 	}
 	return &ocMetrics{
-		gcsReadBytesCount:     gcsReadBytesCount,
-		gcsReaderCount:        gcsReaderCount,
-		gcsRequestCount:       gcsRequestCount,
-		gcsRequestLatency:     gcsRequestLatency,
-		gcsReadCount:          gcsReadCount,
-		gcsDownloadBytesCount: gcsDownloadBytesCount,
+		gcsReadBytesCount:                gcsReadBytesCount,
+		gcsReaderCount:                   gcsReaderCount,
+		gcsRequestCount:                  gcsRequestCount,
+		gcsRequestLatency:                gcsRequestLatency,
+		gcsReadCount:                     gcsReadCount,
+		gcsDownloadBytesCount:            gcsDownloadBytesCount,
+		gcsHedgedReadCount:               gcsHedgedReadCount,
+		gcsCircuitBreakerTransitionCount: gcsCircuitBreakerTransitionCount,
+		gcsOperationCount:                gcsOperationCount,
+		gcsTransferBytesCount:            gcsTransferBytesCount,
 
 		opsCount:      opsCount,
 		opsErrorCount: opsErrorCount,
@@ -271,5 +401,10 @@ func NewOCMetrics() (MetricHandle, error) {
 		fileCacheReadCount:      fileCacheReadCount,
 		fileCacheReadBytesCount: fileCacheReadBytesCount,
 		fileCacheReadLatency:    fileCacheReadLatency,
+
+		writeBackPendingUploads: writeBackPendingUploads,
+		writeBackPendingBytes:   writeBackPendingBytes,
+
+		writeConflictCount: writeConflictCount,
 	}, nil
 }
@@ -26,6 +26,8 @@ var (
 	fsOpsMeter     = otel.Meter("fs_op")
 	gcsMeter       = otel.Meter("gcs")
 	fileCacheMeter = otel.Meter("file_cache")
+	writeBackMeter = otel.Meter("write_back")
+	writeMeter     = otel.Meter("write")
 )
 
 // otelMetrics maintains the list of all metrics computed in GCSFuse.
@@ -34,16 +36,25 @@ type otelMetrics struct {
 	fsOpsErrorCount metric.Int64Counter
 	fsOpsLatency    metric.Float64Histogram
 
-	gcsReadCount          metric.Int64Counter
-	gcsReadBytesCount     metric.Int64Counter
-	gcsReaderCount        metric.Int64Counter
-	gcsRequestCount       metric.Int64Counter
-	gcsRequestLatency     metric.Float64Histogram
-	gcsDownloadBytesCount metric.Int64Counter
+	gcsReadCount                     metric.Int64Counter
+	gcsReadBytesCount                metric.Int64Counter
+	gcsReaderCount                   metric.Int64Counter
+	gcsRequestCount                  metric.Int64Counter
+	gcsRequestLatency                metric.Float64Histogram
+	gcsDownloadBytesCount            metric.Int64Counter
+	gcsHedgedReadCount               metric.Int64Counter
+	gcsCircuitBreakerTransitionCount metric.Int64Counter
+	gcsOperationCount                metric.Int64Counter
+	gcsTransferBytesCount            metric.Int64Counter
 
 	fileCacheReadCount      metric.Int64Counter
 	fileCacheReadBytesCount metric.Int64Counter
 	fileCacheReadLatency    metric.Float64Histogram
+
+	writeBackPendingUploads metric.Int64UpDownCounter
+	writeBackPendingBytes   metric.Int64UpDownCounter
+
+	writeConflictCount metric.Int64Counter
 }
 
 func (o *otelMetrics) GCSReadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
@@ -70,6 +81,22 @@ func (o *otelMetrics) GCSDownloadBytesCount(ctx context.Context, inc int64, attr
 	o.gcsDownloadBytesCount.Add(ctx, inc, attrsToAddOption(attrs)...)
 }
 
+func (o *otelMetrics) GCSHedgedReadCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsHedgedReadCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSCircuitBreakerTransitionCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsCircuitBreakerTransitionCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSOperationCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsOperationCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) GCSTransferBytesCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.gcsTransferBytesCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
 func (o *otelMetrics) OpsCount(ctx context.Context, inc int64, attrs []MetricAttr) {
 	o.fsOpsCount.Add(ctx, inc, attrsToAddOption(attrs)...)
 }
@@ -94,6 +121,18 @@ func (o *otelMetrics) FileCacheReadLatency(ctx context.Context, value float64, a
 	o.fileCacheReadLatency.Record(ctx, value, attrsToRecordOption(attrs)...)
 }
 
+func (o *otelMetrics) WriteBackPendingUploads(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.writeBackPendingUploads.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) WriteBackPendingBytes(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.writeBackPendingBytes.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
+func (o *otelMetrics) WriteConflictCount(ctx context.Context, inc int64, attrs []MetricAttr) {
+	o.writeConflictCount.Add(ctx, inc, attrsToAddOption(attrs)...)
+}
+
 func NewOTelMetrics() (MetricHandle, error) {
 	fsOpsCount, err1 := fsOpsMeter.Int64Counter("fs/ops_count", metric.WithDescription("The cumulative number of ops processed by the file system."))
 	fsOpsLatency, err2 := fsOpsMeter.Float64Histogram("fs/ops_latency", metric.WithDescription("The cumulative distribution of file system operation latencies"), metric.WithUnit("us"),
@@ -119,21 +158,50 @@ func NewOTelMetrics() (MetricHandle, error) {
 		metric.WithUnit("us"),
 		defaultLatencyDistribution)
 
-	if err := errors.Join(err1, err2, err3, err4, err5, err6, err7, err8, err9, err10, err11, err12); err != nil {
+	writeBackPendingUploads, err13 := writeBackMeter.Int64UpDownCounter("write_back/pending_uploads",
+		metric.WithDescription("The number of closed files whose upload to GCS is pending in write-back mode."))
+	writeBackPendingBytes, err14 := writeBackMeter.Int64UpDownCounter("write_back/pending_bytes",
+		metric.WithDescription("The number of bytes staged locally for pending uploads in write-back mode."),
+		metric.WithUnit("By"))
+
+	writeConflictCount, err15 := writeMeter.Int64Counter("write/conflict_count",
+		metric.WithDescription("The cumulative number of files found modified concurrently on sync along with the conflict policy applied."))
+
+	gcsHedgedReadCount, err16 := gcsMeter.Int64Counter("gcs/hedged_read_count",
+		metric.WithDescription("The cumulative number of reads whose first byte was late enough to be hedged along with outcome - won/lost/throttled"))
+
+	gcsCircuitBreakerTransitionCount, err17 := gcsMeter.Int64Counter("gcs/circuit_breaker_transition_count",
+		metric.WithDescription("The cumulative number of state transitions of the circuit breakers around GCS along with operation class and state entered"))
+
+	gcsOperationCount, err18 := gcsMeter.Int64Counter("gcs/operation_count",
+		metric.WithDescription("The cumulative number of GCS operations along with pricing class, mount label and object prefix"))
+
+	gcsTransferBytesCount, err19 := gcsMeter.Int64Counter("gcs/transfer_bytes_count",
+		metric.WithDescription("The cumulative number of bytes transferred from and to GCS along with direction, mount label and object prefix"),
+		metric.WithUnit("By"))
+
+	if err := errors.Join(err1, err2, err3, err4, err5, err6, err7, err8, err9, err10, err11, err12, err13, err14, err15, err16, err17, err18, err19); err != nil {
 		return nil, err
 	}
 	return &otelMetrics{
-		fsOpsCount:              fsOpsCount,
-		fsOpsErrorCount:         fsOpsErrorCount,
-		fsOpsLatency:            fsOpsLatency,
-		gcsReadCount:            gcsReadCount,
-		gcsReadBytesCount:       gcsReadBytesCount,
-		gcsReaderCount:          gcsReaderCount,
-		gcsRequestCount:         gcsRequestCount,
-		gcsRequestLatency:       gcsRequestLatency,
-		gcsDownloadBytesCount:   gcsDownloadBytesCount,
-		fileCacheReadCount:      fileCacheReadCount,
-		fileCacheReadBytesCount: fileCacheReadBytesCount,
-		fileCacheReadLatency:    fileCacheReadLatency,
+		fsOpsCount:                       fsOpsCount,
+		fsOpsErrorCount:                  fsOpsErrorCount,
+		fsOpsLatency:                     fsOpsLatency,
+		gcsReadCount:                     gcsReadCount,
+		gcsReadBytesCount:                gcsReadBytesCount,
+		gcsReaderCount:                   gcsReaderCount,
+		gcsRequestCount:                  gcsRequestCount,
+		gcsRequestLatency:                gcsRequestLatency,
+		gcsDownloadBytesCount:            gcsDownloadBytesCount,
+		gcsHedgedReadCount:               gcsHedgedReadCount,
+		gcsCircuitBreakerTransitionCount: gcsCircuitBreakerTransitionCount,
+		gcsOperationCount:                gcsOperationCount,
+		gcsTransferBytesCount:            gcsTransferBytesCount,
+		fileCacheReadCount:               fileCacheReadCount,
+		fileCacheReadBytesCount:          fileCacheReadBytesCount,
+		fileCacheReadLatency:             fileCacheReadLatency,
+		writeBackPendingUploads:          writeBackPendingUploads,
+		writeBackPendingBytes:            writeBackPendingBytes,
+		writeConflictCount:               writeConflictCount,
 	}, nil
 }
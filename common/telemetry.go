@@ -58,6 +58,10 @@ type GCSMetricHandle interface {
 	GCSRequestLatency(ctx context.Context, value float64, attrs []MetricAttr)
 	GCSReadCount(ctx context.Context, inc int64, attrs []MetricAttr)
 	GCSDownloadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSHedgedReadCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSCircuitBreakerTransitionCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSOperationCount(ctx context.Context, inc int64, attrs []MetricAttr)
+	GCSTransferBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
 }
 
 type OpsMetricHandle interface {
@@ -71,10 +75,21 @@ type FileCacheMetricHandle interface {
 	FileCacheReadBytesCount(ctx context.Context, inc int64, attrs []MetricAttr)
 	FileCacheReadLatency(ctx context.Context, value float64, attrs []MetricAttr)
 }
+type WriteBackMetricHandle interface {
+	WriteBackPendingUploads(ctx context.Context, inc int64, attrs []MetricAttr)
+	WriteBackPendingBytes(ctx context.Context, inc int64, attrs []MetricAttr)
+}
+
+type WriteMetricHandle interface {
+	WriteConflictCount(ctx context.Context, inc int64, attrs []MetricAttr)
+}
+
 type MetricHandle interface {
 	GCSMetricHandle
 	OpsMetricHandle
 	FileCacheMetricHandle
+	WriteBackMetricHandle
+	WriteMetricHandle
 }
 
 func CaptureGCSReadMetrics(ctx context.Context, metricHandle MetricHandle, readType string, requestedDataSize int64) {
@@ -33,6 +33,11 @@ type Block interface {
 	// Write writes the given data to block.
 	Write(bytes []byte) error
 
+	// WriteAt writes the given data at the given offset relative to the start of
+	// the block. The offset must not be beyond Size(), so the data can overwrite
+	// and extend the existing data but can't leave a hole.
+	WriteAt(bytes []byte, offset int64) error
+
 	// Reader interface helps in copying the data directly to storage.writer
 	// while uploading to GCS.
 	Reader() io.Reader
@@ -76,6 +81,19 @@ func (m *memoryBlock) Write(bytes []byte) error {
 	return nil
 }
 
+func (m *memoryBlock) WriteAt(bytes []byte, offset int64) error {
+	if offset < 0 || offset > m.Size() {
+		return fmt.Errorf("invalid offset %d for block of size %d", offset, m.Size())
+	}
+	if offset+int64(len(bytes)) > int64(cap(m.buffer)) {
+		return fmt.Errorf("received data more than capacity of the block")
+	}
+
+	copy(m.buffer[m.offset.start+offset:], bytes)
+	m.offset.end = max(m.offset.end, m.offset.start+offset+int64(len(bytes)))
+	return nil
+}
+
 func (m *memoryBlock) Reader() io.Reader {
 	return bytes.NewReader(m.buffer[0:m.offset.end])
 }
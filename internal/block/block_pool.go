@@ -20,6 +20,17 @@ import (
 	"golang.org/x/sync/semaphore"
 )
 
+// SpillConfig configures the blocks to be created on local disk once the
+// memory budget shared by the block pools is exhausted.
+type SpillConfig struct {
+	// Dir is the directory in which the disk blocks are created.
+	Dir string
+
+	// MemoryBudgetSem limits the total bytes of memory blocks created across
+	// different files.
+	MemoryBudgetSem *semaphore.Weighted
+}
+
 // BlockPool handles the creation of blocks as per the user configuration.
 type BlockPool struct {
 	// Channel holding free blocks.
@@ -37,10 +48,14 @@ type BlockPool struct {
 	// Semaphore used to limit the total number of blocks created across
 	// different files.
 	globalMaxBlocksSem *semaphore.Weighted
+
+	// Optional config to create blocks on disk beyond the memory budget. All
+	// blocks are created in memory when nil.
+	spill *SpillConfig
 }
 
 // NewBlockPool creates the blockPool based on the user configuration.
-func NewBlockPool(blockSize int64, maxBlocks int64, globalMaxBlocksSem *semaphore.Weighted) (bp *BlockPool, err error) {
+func NewBlockPool(blockSize int64, maxBlocks int64, globalMaxBlocksSem *semaphore.Weighted, spill *SpillConfig) (bp *BlockPool, err error) {
 	if blockSize <= 0 || maxBlocks <= 0 {
 		err = fmt.Errorf("invalid configuration provided for blockPool, blocksize: %d, maxBlocks: %d", blockSize, maxBlocks)
 		return
@@ -52,6 +67,7 @@ func NewBlockPool(blockSize int64, maxBlocks int64, globalMaxBlocksSem *semaphor
 		maxBlocks:          maxBlocks,
 		totalBlocks:        0,
 		globalMaxBlocksSem: globalMaxBlocksSem,
+		spill:              spill,
 	}
 	return
 }
@@ -60,26 +76,52 @@ func NewBlockPool(blockSize int64, maxBlocks int64, globalMaxBlocksSem *semaphor
 // creates a new one if required.
 func (bp *BlockPool) Get() (Block, error) {
 	for {
-		select {
-		case b := <-bp.freeBlocksCh:
-			// Reset the block for reuse.
-			b.Reuse()
-			return b, nil
+		b, err := bp.TryGet()
+		if err != nil || b != nil {
+			return b, err
+		}
+	}
+}
 
-		default:
-			// No lock is required here since blockPool is per file and all write
-			// calls to a single file are serialized because of inode.lock().
-			if bp.canAllocateBlock() {
-				b, err := createBlock(bp.blockSize)
-				if err != nil {
-					return nil, err
-				}
-
-				bp.totalBlocks++
-				return b, nil
+// TryGet is the non-blocking version of Get. It returns nil without error when
+// no block is free for reuse and a new one can't be created.
+func (bp *BlockPool) TryGet() (Block, error) {
+	select {
+	case b := <-bp.freeBlocksCh:
+		// Reset the block for reuse.
+		b.Reuse()
+		return b, nil
+
+	default:
+		// No lock is required here since blockPool is per file and all write
+		// calls to a single file are serialized because of inode.lock().
+		if bp.canAllocateBlock() {
+			b, err := bp.createBlock()
+			if err != nil {
+				return nil, err
 			}
+
+			bp.totalBlocks++
+			return b, nil
 		}
+		return nil, nil
+	}
+}
+
+// createBlock creates a memory block if the memory budget allows, else a disk
+// block.
+func (bp *BlockPool) createBlock() (Block, error) {
+	if bp.spill == nil {
+		return createBlock(bp.blockSize)
+	}
+	if !bp.spill.MemoryBudgetSem.TryAcquire(bp.blockSize) {
+		return createDiskBlock(bp.spill.Dir, bp.blockSize)
 	}
+	b, err := createBlock(bp.blockSize)
+	if err != nil {
+		bp.spill.MemoryBudgetSem.Release(bp.blockSize)
+	}
+	return b, err
 }
 
 // canAllocateBlock checks if a new block can be allocated.
@@ -118,6 +160,9 @@ func (bp *BlockPool) ClearFreeBlockChannel() error {
 				// if we get here, there is likely memory corruption.
 				return fmt.Errorf("munmap error: %v", err)
 			}
+			if _, ok := b.(*memoryBlock); ok && bp.spill != nil {
+				bp.spill.MemoryBudgetSem.Release(bp.blockSize)
+			}
 			bp.totalBlocks--
 			if bp.totalBlocks != 0 {
 				bp.globalMaxBlocksSem.Release(1)
@@ -37,7 +37,7 @@ func TestBlockPoolTestSuite(t *testing.T) {
 }
 
 func (t *BlockPoolTest) TestInitBlockPool() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10), nil)
 
 	require.Nil(t.T(), err)
 	require.NotNil(t.T(), bp)
@@ -47,28 +47,28 @@ func (t *BlockPoolTest) TestInitBlockPool() {
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForZeroBlockSize() {
-	_, err := NewBlockPool(0, 10, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(0, 10, semaphore.NewWeighted(10), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, 0, 10), err)
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForNegativeBlockSize() {
-	_, err := NewBlockPool(-1, 10, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(-1, 10, semaphore.NewWeighted(10), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, -1, 10), err)
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForZeroMaxBlocks() {
-	_, err := NewBlockPool(10, 0, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(10, 0, semaphore.NewWeighted(10), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, 10, 0), err)
 }
 
 func (t *BlockPoolTest) TestInitBlockPoolForNegativeMaxBlocks() {
-	_, err := NewBlockPool(10, -1, semaphore.NewWeighted(10))
+	_, err := NewBlockPool(10, -1, semaphore.NewWeighted(10), nil)
 
 	require.NotNil(t.T(), err)
 	assert.Equal(t.T(), fmt.Errorf(invalidConfigError, 10, -1), err)
@@ -76,7 +76,7 @@ func (t *BlockPoolTest) TestInitBlockPoolForNegativeMaxBlocks() {
 
 // Represents when block is available on the freeBlocksCh.
 func (t *BlockPoolTest) TestGetWhenBlockIsAvailableForReuse() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10), nil)
 	require.Nil(t.T(), err)
 	// Creating a block with some data and send it to blockCh.
 	b, err := createBlock(2)
@@ -101,7 +101,7 @@ func (t *BlockPoolTest) TestGetWhenBlockIsAvailableForReuse() {
 }
 
 func (t *BlockPoolTest) TestGetWhenTotalBlocksIsLessThanThanMaxBlocks() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10), nil)
 	require.Nil(t.T(), err)
 
 	block, err := bp.Get()
@@ -113,7 +113,7 @@ func (t *BlockPoolTest) TestGetWhenTotalBlocksIsLessThanThanMaxBlocks() {
 
 func (t *BlockPoolTest) TestCreateBlockWithLargeSize() {
 	// Creating block of size 1TB
-	bp, err := NewBlockPool(1024*1024*1024*1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024*1024*1024*1024, 10, semaphore.NewWeighted(10), nil)
 	require.Nil(t.T(), err)
 
 	_, err = bp.Get()
@@ -123,14 +123,14 @@ func (t *BlockPoolTest) TestCreateBlockWithLargeSize() {
 }
 
 func (t *BlockPoolTest) TestBlockSize() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10), nil)
 
 	require.Nil(t.T(), err)
 	require.Equal(t.T(), int64(1024), bp.BlockSize())
 }
 
 func (t *BlockPoolTest) TestClearFreeBlockChannel() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(3))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(3), nil)
 	require.Nil(t.T(), err)
 	blocks := make([]Block, 4)
 	for i := 0; i < 4; i++ {
@@ -155,7 +155,7 @@ func (t *BlockPoolTest) TestClearFreeBlockChannel() {
 }
 
 func (t *BlockPoolTest) TestFirstBlockIsCreatedWithoutAcquiringGlobalSem() {
-	bp, err := NewBlockPool(1024, 3, semaphore.NewWeighted(0))
+	bp, err := NewBlockPool(1024, 3, semaphore.NewWeighted(0), nil)
 	require.Nil(t.T(), err)
 	b1, err := bp.Get()
 	require.Nil(t.T(), err)
@@ -173,9 +173,9 @@ func (t *BlockPoolTest) TestFirstBlockIsCreatedWithoutAcquiringGlobalSem() {
 
 func (t *BlockPoolTest) TestClearFreeBlockChannelWithMultipleBlockPools() {
 	globalMaxBlocksSem := semaphore.NewWeighted(1)
-	bp1, err := NewBlockPool(1024, 3, globalMaxBlocksSem)
+	bp1, err := NewBlockPool(1024, 3, globalMaxBlocksSem, nil)
 	require.Nil(t.T(), err)
-	bp2, err := NewBlockPool(1024, 3, globalMaxBlocksSem)
+	bp2, err := NewBlockPool(1024, 3, globalMaxBlocksSem, nil)
 	require.Nil(t.T(), err)
 	// Create 2 blocks in bp1.
 	b1 := t.validateGetBlockIsNotBlocked(bp1)
@@ -206,7 +206,7 @@ func (t *BlockPoolTest) TestClearFreeBlockChannelWithMultipleBlockPools() {
 }
 
 func (t *BlockPoolTest) TestGetWhenGlobalMaxBlocksIsZero() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(0))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(0), nil)
 	require.Nil(t.T(), err)
 
 	// First block is allowed even with globalMaxBlocks being zero.
@@ -218,7 +218,7 @@ func (t *BlockPoolTest) TestGetWhenGlobalMaxBlocksIsZero() {
 }
 
 func (t *BlockPoolTest) TestGetWhenLimitedByGlobalBlocks() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(2))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(2), nil)
 	require.Nil(t.T(), err)
 
 	// 3 blocks can be created.
@@ -231,13 +231,39 @@ func (t *BlockPoolTest) TestGetWhenLimitedByGlobalBlocks() {
 }
 
 func (t *BlockPoolTest) TestGetWhenTotalBlocksEqualToMaxBlocks() {
-	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(2))
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(2), nil)
 	require.Nil(t.T(), err)
 	bp.totalBlocks = 10
 
 	t.validateGetBlockIsBlocked(bp)
 }
 
+func (t *BlockPoolTest) TestTryGetWhenBlockCantBeAllocated() {
+	bp, err := NewBlockPool(1024, 1, semaphore.NewWeighted(0), nil)
+	require.Nil(t.T(), err)
+	b1, err := bp.TryGet()
+	require.Nil(t.T(), err)
+	require.NotNil(t.T(), b1)
+
+	b2, err := bp.TryGet()
+
+	assert.Nil(t.T(), err)
+	assert.Nil(t.T(), b2)
+}
+
+func (t *BlockPoolTest) TestTryGetWhenBlockIsAvailableForReuse() {
+	bp, err := NewBlockPool(1024, 1, semaphore.NewWeighted(0), nil)
+	require.Nil(t.T(), err)
+	b1, err := bp.TryGet()
+	require.Nil(t.T(), err)
+	bp.freeBlocksCh <- b1
+
+	b2, err := bp.TryGet()
+
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), b1, b2)
+}
+
 func (t *BlockPoolTest) validateGetBlockIsBlocked(bp *BlockPool) {
 	t.T().Helper()
 	done := make(chan bool, 1)
@@ -352,3 +378,36 @@ func (t *BlockPoolTest) TestCanAllocateBlock() {
 		})
 	}
 }
+
+func (t *BlockPoolTest) TestGetCreatesDiskBlocksBeyondMemoryBudget() {
+	spill := &SpillConfig{Dir: t.T().TempDir(), MemoryBudgetSem: semaphore.NewWeighted(2048)}
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10), spill)
+	require.Nil(t.T(), err)
+
+	b1 := t.validateGetBlockIsNotBlocked(bp)
+	b2 := t.validateGetBlockIsNotBlocked(bp)
+	b3 := t.validateGetBlockIsNotBlocked(bp)
+
+	require.IsType(t.T(), &memoryBlock{}, b1)
+	require.IsType(t.T(), &memoryBlock{}, b2)
+	require.IsType(t.T(), &diskBlock{}, b3)
+	require.Equal(t.T(), int64(3), bp.totalBlocks)
+}
+
+func (t *BlockPoolTest) TestClearFreeBlockChannelReleasesMemoryBudget() {
+	spill := &SpillConfig{Dir: t.T().TempDir(), MemoryBudgetSem: semaphore.NewWeighted(1024)}
+	bp, err := NewBlockPool(1024, 10, semaphore.NewWeighted(10), spill)
+	require.Nil(t.T(), err)
+	b1 := t.validateGetBlockIsNotBlocked(bp)
+	b2 := t.validateGetBlockIsNotBlocked(bp)
+	require.IsType(t.T(), &diskBlock{}, b2)
+	bp.freeBlocksCh <- b1
+	bp.freeBlocksCh <- b2
+
+	err = bp.ClearFreeBlockChannel()
+
+	require.Nil(t.T(), err)
+	require.Nil(t.T(), b2.(*diskBlock).file)
+	// Memory budget of the memory block is released.
+	require.True(t.T(), spill.MemoryBudgetSem.TryAcquire(1024))
+}
@@ -136,3 +136,56 @@ func (testSuite *MemoryBlockTest) TestMemoryBlockDeAllocate() {
 	require.Nil(testSuite.T(), err)
 	require.Nil(testSuite.T(), mb.(*memoryBlock).buffer)
 }
+
+func (testSuite *MemoryBlockTest) TestMemoryBlockWriteAtOverwritesData() {
+	mb, err := createBlock(12)
+	require.Nil(testSuite.T(), err)
+	err = mb.Write([]byte("hello"))
+	require.Nil(testSuite.T(), err)
+
+	err = mb.WriteAt([]byte("J"), 0)
+
+	assert.Nil(testSuite.T(), err)
+	output, err := io.ReadAll(mb.Reader())
+	assert.Nil(testSuite.T(), err)
+	assert.Equal(testSuite.T(), []byte("Jello"), output)
+	assert.Equal(testSuite.T(), int64(5), mb.Size())
+}
+
+func (testSuite *MemoryBlockTest) TestMemoryBlockWriteAtExtendsData() {
+	mb, err := createBlock(12)
+	require.Nil(testSuite.T(), err)
+	err = mb.Write([]byte("hello"))
+	require.Nil(testSuite.T(), err)
+
+	err = mb.WriteAt([]byte("p me"), 3)
+
+	assert.Nil(testSuite.T(), err)
+	output, err := io.ReadAll(mb.Reader())
+	assert.Nil(testSuite.T(), err)
+	assert.Equal(testSuite.T(), []byte("help me"), output)
+	assert.Equal(testSuite.T(), int64(7), mb.Size())
+}
+
+func (testSuite *MemoryBlockTest) TestMemoryBlockWriteAtBeyondSize() {
+	mb, err := createBlock(12)
+	require.Nil(testSuite.T(), err)
+	err = mb.Write([]byte("hi"))
+	require.Nil(testSuite.T(), err)
+
+	err = mb.WriteAt([]byte("hi"), 3)
+
+	assert.NotNil(testSuite.T(), err)
+	assert.Equal(testSuite.T(), int64(2), mb.Size())
+}
+
+func (testSuite *MemoryBlockTest) TestMemoryBlockWriteAtBeyondCapacity() {
+	mb, err := createBlock(4)
+	require.Nil(testSuite.T(), err)
+	err = mb.Write([]byte("hi"))
+	require.Nil(testSuite.T(), err)
+
+	err = mb.WriteAt([]byte("abc"), 2)
+
+	assert.EqualError(testSuite.T(), err, outOfCapacityError)
+}
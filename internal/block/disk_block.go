@@ -0,0 +1,89 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package block
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// diskBlock is a Block backed by an unlinked file on local disk. It is used
+// in place of memoryBlock once the memory budget for blocks is exhausted.
+type diskBlock struct {
+	file     *os.File
+	size     int64
+	capacity int64
+}
+
+func (d *diskBlock) Reuse() {
+	d.size = 0
+}
+
+func (d *diskBlock) Size() int64 {
+	return d.size
+}
+
+func (d *diskBlock) Write(bytes []byte) error {
+	return d.WriteAt(bytes, d.size)
+}
+
+func (d *diskBlock) WriteAt(bytes []byte, offset int64) error {
+	if offset < 0 || offset > d.size {
+		return fmt.Errorf("invalid offset %d for block of size %d", offset, d.size)
+	}
+	if offset+int64(len(bytes)) > d.capacity {
+		return fmt.Errorf("received data more than capacity of the block")
+	}
+
+	n, err := d.file.WriteAt(bytes, offset)
+	if err != nil {
+		return fmt.Errorf("error in writing the data to disk block: %w", err)
+	}
+	d.size = max(d.size, offset+int64(n))
+	return nil
+}
+
+func (d *diskBlock) Reader() io.Reader {
+	return io.NewSectionReader(d.file, 0, d.size)
+}
+
+func (d *diskBlock) Deallocate() error {
+	if d.file == nil {
+		return fmt.Errorf("invalid file")
+	}
+
+	err := d.file.Close()
+	d.file = nil
+	if err != nil {
+		return fmt.Errorf("error while closing disk block file: %w", err)
+	}
+	return nil
+}
+
+// createDiskBlock creates a new block backed by a file in the given directory.
+// The file is unlinked right away so that it doesn't outlive the process.
+func createDiskBlock(dir string, blockSize int64) (Block, error) {
+	f, err := os.CreateTemp(dir, "gcsfuse-block-")
+	if err != nil {
+		return nil, fmt.Errorf("error while creating disk block: %w", err)
+	}
+	if err = os.Remove(f.Name()); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error while unlinking disk block file: %w", err)
+	}
+
+	return &diskBlock{file: f, capacity: blockSize}, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package block
+
+import (
+	"io"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type DiskBlockTest struct {
+	suite.Suite
+	dir string
+}
+
+func TestDiskBlockTestSuite(t *testing.T) {
+	suite.Run(t, new(DiskBlockTest))
+}
+
+func (testSuite *DiskBlockTest) SetupTest() {
+	testSuite.dir = testSuite.T().TempDir()
+}
+
+func (testSuite *DiskBlockTest) TestDiskBlockFileIsUnlinked() {
+	_, err := createDiskBlock(testSuite.dir, 12)
+	require.Nil(testSuite.T(), err)
+
+	entries, err := os.ReadDir(testSuite.dir)
+	require.Nil(testSuite.T(), err)
+	assert.Empty(testSuite.T(), entries)
+}
+
+func (testSuite *DiskBlockTest) TestDiskBlockWriteWithMultipleWrites() {
+	db, err := createDiskBlock(testSuite.dir, 12)
+	require.Nil(testSuite.T(), err)
+	err = db.Write([]byte("hi"))
+	assert.Nil(testSuite.T(), err)
+	err = db.Write([]byte("hello"))
+	assert.Nil(testSuite.T(), err)
+
+	output, err := io.ReadAll(db.Reader())
+	assert.Nil(testSuite.T(), err)
+	assert.Equal(testSuite.T(), []byte("hihello"), output)
+	assert.Equal(testSuite.T(), int64(7), db.Size())
+}
+
+func (testSuite *DiskBlockTest) TestDiskBlockWriteWithDataGreaterThanCapacity() {
+	db, err := createDiskBlock(testSuite.dir, 1)
+	require.Nil(testSuite.T(), err)
+
+	err = db.Write([]byte("hi"))
+
+	assert.EqualError(testSuite.T(), err, outOfCapacityError)
+}
+
+func (testSuite *DiskBlockTest) TestDiskBlockWriteAtOverwritesAndExtendsData() {
+	db, err := createDiskBlock(testSuite.dir, 12)
+	require.Nil(testSuite.T(), err)
+	require.Nil(testSuite.T(), db.Write([]byte("hello")))
+
+	err = db.WriteAt([]byte("LLO world"), 2)
+
+	assert.Nil(testSuite.T(), err)
+	output, err := io.ReadAll(db.Reader())
+	assert.Nil(testSuite.T(), err)
+	assert.Equal(testSuite.T(), []byte("heLLO world"), output)
+	assert.Equal(testSuite.T(), int64(11), db.Size())
+}
+
+func (testSuite *DiskBlockTest) TestDiskBlockWriteAtBeyondSize() {
+	db, err := createDiskBlock(testSuite.dir, 12)
+	require.Nil(testSuite.T(), err)
+	require.Nil(testSuite.T(), db.Write([]byte("hi")))
+
+	err = db.WriteAt([]byte("hello"), 3)
+
+	assert.NotNil(testSuite.T(), err)
+	assert.Equal(testSuite.T(), int64(2), db.Size())
+}
+
+func (testSuite *DiskBlockTest) TestDiskBlockReuse() {
+	db, err := createDiskBlock(testSuite.dir, 12)
+	require.Nil(testSuite.T(), err)
+	require.Nil(testSuite.T(), db.Write([]byte("hello")))
+
+	db.Reuse()
+
+	assert.Equal(testSuite.T(), int64(0), db.Size())
+	output, err := io.ReadAll(db.Reader())
+	assert.Nil(testSuite.T(), err)
+	assert.Empty(testSuite.T(), output)
+}
+
+func (testSuite *DiskBlockTest) TestDiskBlockDeAllocate() {
+	db, err := createDiskBlock(testSuite.dir, 12)
+	require.Nil(testSuite.T(), err)
+
+	err = db.Deallocate()
+
+	assert.Nil(testSuite.T(), err)
+	assert.Nil(testSuite.T(), db.(*diskBlock).file)
+	assert.NotNil(testSuite.T(), db.Deallocate())
+}
@@ -31,10 +31,14 @@ import (
 
 type BufferedWriteHandler interface {
 	// Write writes the given data to the buffer. It writes to an existing buffer if
-	// the capacity is available otherwise writes to a new buffer.
+	// the capacity is available otherwise writes to a new buffer. Data which is
+	// still buffered (not yet handed over for upload) can be overwritten, any
+	// other write not at the end of the file fails with ErrOutOfOrderWrite.
 	Write(data []byte, offset int64) (err error)
 
-	// Sync uploads all the pending full buffers to GCS.
+	// Sync uploads all the pending full buffers to GCS. In zonal buckets it
+	// uploads the partial buffer too and flushes the upload, so that all the
+	// data written so far is persisted in the unfinalized object.
 	Sync() (err error)
 
 	// Flush finalizes the upload.
@@ -60,9 +64,15 @@ type BufferedWriteHandler interface {
 // bufferedWriteHandlerImpl is responsible for filling up the buffers with the data
 // as it receives and handing over to uploadHandler which uploads to GCS.
 type bufferedWriteHandlerImpl struct {
-	current       block.Block
-	blockPool     *block.BlockPool
-	uploadHandler *UploadHandler
+	current block.Block
+	// Full blocks which are held back from uploadHandler, so that out-of-order
+	// writes to recently written data can still be applied to them. Along with
+	// current, these blocks form the window of data which can be overwritten.
+	pendingBlocks []block.Block
+	// Maximum number of full blocks held back in pendingBlocks.
+	maxPendingBlocks int
+	blockPool        *block.BlockPool
+	uploadHandler    *UploadHandler
 	// Total size of data buffered so far. Some part of buffered data might have
 	// been uploaded to GCS as well. Depending on the state we are in, it might or
 	// might not include truncatedSize.
@@ -88,37 +98,60 @@ var ErrOutOfOrderWrite = errors.New("outOfOrder write detected")
 var ErrUploadFailure = errors.New("error while uploading object to GCS")
 
 type CreateBWHandlerRequest struct {
-	Object                   *gcs.Object
-	ObjectName               string
-	Bucket                   gcs.Bucket
-	BlockSize                int64
-	MaxBlocksPerFile         int64
+	Object           *gcs.Object
+	ObjectName       string
+	Bucket           gcs.Bucket
+	BlockSize        int64
+	MaxBlocksPerFile int64
+	// The maximum number of full blocks held back from upload to serve
+	// out-of-order writes. It is capped at MaxBlocksPerFile-1, so that at least
+	// one block is always available for new data.
+	MaxPendingBlocks         int64
 	GlobalMaxBlocksSem       *semaphore.Weighted
 	ChunkTransferTimeoutSecs int64
+	// Optional config to create blocks on disk beyond the memory budget.
+	SpillConfig *block.SpillConfig
+	// Append to Object, an unfinalized appendable object, instead of replacing
+	// it. The upload is taken over eagerly, so NewBWHandler fails if it can't
+	// be.
+	Append bool
 }
 
 // NewBWHandler creates the bufferedWriteHandler struct.
 func NewBWHandler(req *CreateBWHandlerRequest) (bwh BufferedWriteHandler, err error) {
-	bp, err := block.NewBlockPool(req.BlockSize, req.MaxBlocksPerFile, req.GlobalMaxBlocksSem)
+	bp, err := block.NewBlockPool(req.BlockSize, req.MaxBlocksPerFile, req.GlobalMaxBlocksSem, req.SpillConfig)
 	if err != nil {
 		return
 	}
 
+	uh := newUploadHandler(&CreateUploadHandlerRequest{
+		Object:                   req.Object,
+		ObjectName:               req.ObjectName,
+		Bucket:                   req.Bucket,
+		FreeBlocksCh:             bp.FreeBlocksChannel(),
+		MaxBlocksPerFile:         req.MaxBlocksPerFile,
+		BlockSize:                req.BlockSize,
+		ChunkTransferTimeoutSecs: req.ChunkTransferTimeoutSecs,
+		Append:                   req.Append,
+	})
+
+	var totalSize int64
+	if req.Append {
+		if err = uh.start(); err != nil {
+			_ = bp.ClearFreeBlockChannel()
+			return nil, fmt.Errorf("appending to object %s: %w", req.ObjectName, err)
+		}
+		totalSize = int64(req.Object.Size)
+	}
+
 	bwh = &bufferedWriteHandlerImpl{
-		current:   nil,
-		blockPool: bp,
-		uploadHandler: newUploadHandler(&CreateUploadHandlerRequest{
-			Object:                   req.Object,
-			ObjectName:               req.ObjectName,
-			Bucket:                   req.Bucket,
-			FreeBlocksCh:             bp.FreeBlocksChannel(),
-			MaxBlocksPerFile:         req.MaxBlocksPerFile,
-			BlockSize:                req.BlockSize,
-			ChunkTransferTimeoutSecs: req.ChunkTransferTimeoutSecs,
-		}),
-		totalSize:     0,
-		mtime:         time.Now(),
-		truncatedSize: -1,
+		current:          nil,
+		maxPendingBlocks: int(max(0, min(req.MaxPendingBlocks, req.MaxBlocksPerFile-1))),
+		blockPool:        bp,
+		uploadHandler:    uh,
+		totalSize:        totalSize,
+		mtime:            time.Now(),
+		truncatedSize:    -1,
 	}
 	return
 }
@@ -132,6 +165,21 @@ func (wh *bufferedWriteHandlerImpl) Write(data []byte, offset int64) (err error)
 		break
 	}
 
+	// Overwrite the data which has been buffered but not yet uploaded and append
+	// the rest.
+	if offset < wh.totalSize && offset >= wh.windowStart() {
+		overlap := min(int64(len(data)), wh.totalSize-offset)
+		err = wh.patchBuffers(data[:overlap], offset)
+		if err != nil {
+			return
+		}
+		data = data[overlap:]
+		offset += overlap
+		if len(data) == 0 {
+			return nil
+		}
+	}
+
 	if offset != wh.totalSize && offset != wh.truncatedSize {
 		logger.Errorf("BufferedWriteHandler.OutOfOrderError for object: %s, expectedOffset: %d, actualOffset: %d",
 			wh.uploadHandler.objectName, wh.totalSize, offset)
@@ -149,11 +197,83 @@ func (wh *bufferedWriteHandlerImpl) Write(data []byte, offset int64) (err error)
 	return wh.appendBuffer(data)
 }
 
+// windowStart returns the offset from which the data is still buffered in
+// pendingBlocks and current, i.e. not yet handed over to uploadHandler.
+func (wh *bufferedWriteHandlerImpl) windowStart() int64 {
+	start := wh.totalSize - int64(len(wh.pendingBlocks))*wh.blockPool.BlockSize()
+	if wh.current != nil {
+		start -= wh.current.Size()
+	}
+	return start
+}
+
+// patchBuffers overwrites the buffered data in the range
+// [offset, offset+len(data)), which must lie within the window of blocks not
+// yet handed over to uploadHandler.
+func (wh *bufferedWriteHandlerImpl) patchBuffers(data []byte, offset int64) error {
+	blocks := wh.pendingBlocks
+	if wh.current != nil {
+		blocks = append(blocks[:len(blocks):len(blocks)], wh.current)
+	}
+
+	blockStart := wh.windowStart()
+	for _, b := range blocks {
+		blockEnd := blockStart + b.Size()
+		if len(data) > 0 && offset < blockEnd {
+			n := min(int64(len(data)), blockEnd-offset)
+			if err := b.WriteAt(data[:n], offset-blockStart); err != nil {
+				return fmt.Errorf("failed to overwrite buffered data: %w", err)
+			}
+			data = data[n:]
+			offset += n
+		}
+		blockStart = blockEnd
+	}
+	return nil
+}
+
+// getBlock returns a block for writing new data. If the block pool is
+// exhausted, the held back blocks are uploaded so that they can be reused.
+func (wh *bufferedWriteHandlerImpl) getBlock() (block.Block, error) {
+	b, err := wh.blockPool.TryGet()
+	if err != nil || b != nil {
+		return b, err
+	}
+
+	err = wh.uploadPendingBlocks()
+	if err != nil {
+		return nil, err
+	}
+	return wh.blockPool.Get()
+}
+
+// uploadPendingBlocks hands over all the held back full blocks to
+// uploadHandler.
+func (wh *bufferedWriteHandlerImpl) uploadPendingBlocks() error {
+	for len(wh.pendingBlocks) > 0 {
+		err := wh.uploadHandler.Upload(wh.pendingBlocks[0])
+		if err != nil {
+			return err
+		}
+		wh.pendingBlocks = wh.pendingBlocks[1:]
+	}
+	return nil
+}
+
+// releasePendingBlocks returns the held back blocks to the block pool without
+// uploading them.
+func (wh *bufferedWriteHandlerImpl) releasePendingBlocks() {
+	for _, b := range wh.pendingBlocks {
+		wh.blockPool.FreeBlocksChannel() <- b
+	}
+	wh.pendingBlocks = nil
+}
+
 func (wh *bufferedWriteHandlerImpl) appendBuffer(data []byte) (err error) {
 	dataWritten := 0
 	for dataWritten < len(data) {
 		if wh.current == nil {
-			wh.current, err = wh.blockPool.Get()
+			wh.current, err = wh.getBlock()
 			if err != nil {
 				return fmt.Errorf("failed to get new block: %w", err)
 			}
@@ -170,11 +290,16 @@ func (wh *bufferedWriteHandlerImpl) appendBuffer(data []byte) (err error) {
 		dataWritten += bytesToCopy
 
 		if wh.current.Size() == wh.blockPool.BlockSize() {
-			err := wh.uploadHandler.Upload(wh.current)
-			if err != nil {
-				return err
-			}
+			wh.pendingBlocks = append(wh.pendingBlocks, wh.current)
 			wh.current = nil
+			// Hand over the oldest full block once the window is exceeded.
+			if len(wh.pendingBlocks) > wh.maxPendingBlocks {
+				err := wh.uploadHandler.Upload(wh.pendingBlocks[0])
+				if err != nil {
+					return err
+				}
+				wh.pendingBlocks = wh.pendingBlocks[1:]
+			}
 		}
 	}
 
@@ -184,6 +309,24 @@ func (wh *bufferedWriteHandlerImpl) appendBuffer(data []byte) (err error) {
 
 func (wh *bufferedWriteHandlerImpl) Sync() (err error) {
 	// Upload all the pending buffers and release the buffers.
+	err = wh.uploadPendingBlocks()
+	if err != nil {
+		return err
+	}
+
+	if wh.uploadHandler.bucket.BucketType().Zonal {
+		// Appendable uploads can be flushed at any offset, so the partial buffer
+		// need not wait for more data.
+		if wh.current != nil {
+			if err = wh.uploadHandler.Upload(wh.current); err != nil {
+				return err
+			}
+			wh.current = nil
+		}
+		if err = wh.uploadHandler.Flush(); err != nil {
+			return err
+		}
+	}
 	wh.uploadHandler.AwaitBlocksUpload()
 	err = wh.blockPool.ClearFreeBlockChannel()
 	if err != nil {
@@ -215,6 +358,11 @@ func (wh *bufferedWriteHandlerImpl) Flush() (*gcs.MinObject, error) {
 		return nil, err
 	}
 
+	err = wh.uploadPendingBlocks()
+	if err != nil {
+		return nil, err
+	}
+
 	if wh.current != nil {
 		err := wh.uploadHandler.Upload(wh.current)
 		if err != nil {
@@ -258,6 +406,7 @@ func (wh *bufferedWriteHandlerImpl) WriteFileInfo() WriteFileInfo {
 }
 
 func (wh *bufferedWriteHandlerImpl) Destroy() error {
+	wh.releasePendingBlocks()
 	wh.uploadHandler.Destroy()
 	return wh.blockPool.ClearFreeBlockChannel()
 }
@@ -285,6 +434,7 @@ func (wh *bufferedWriteHandlerImpl) writeDataForTruncatedSize() error {
 }
 
 func (wh *bufferedWriteHandlerImpl) Unlink() {
+	wh.releasePendingBlocks()
 	wh.uploadHandler.CancelUpload()
 	err := wh.blockPool.ClearFreeBlockChannel()
 	if err != nil {
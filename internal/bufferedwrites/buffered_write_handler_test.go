@@ -15,12 +15,14 @@
 package bufferedwrites
 
 import (
+	"context"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
 	"github.com/googlecloudplatform/gcsfuse/v2/tools/integration_tests/util/operations"
 	"github.com/jacobsa/timeutil"
 	"github.com/stretchr/testify/assert"
@@ -32,7 +34,8 @@ import (
 const chunkTransferTimeoutSecs int64 = 10
 
 type BufferedWriteTest struct {
-	bwh BufferedWriteHandler
+	bwh    BufferedWriteHandler
+	bucket gcs.Bucket
 	suite.Suite
 }
 
@@ -41,18 +44,31 @@ func TestBufferedWriteTestSuite(t *testing.T) {
 }
 
 func (testSuite *BufferedWriteTest) SetupTest() {
-	bucket := fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.BucketType{})
+	testSuite.bucket = fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.BucketType{})
+	testSuite.bwh = testSuite.createBWHandler(10, 1)
+}
+
+func (testSuite *BufferedWriteTest) createBWHandler(maxBlocksPerFile, maxPendingBlocks int64) BufferedWriteHandler {
 	bwh, err := NewBWHandler(&CreateBWHandlerRequest{
 		Object:                   nil,
 		ObjectName:               "testObject",
-		Bucket:                   bucket,
+		Bucket:                   testSuite.bucket,
 		BlockSize:                blockSize,
-		MaxBlocksPerFile:         10,
+		MaxBlocksPerFile:         maxBlocksPerFile,
+		MaxPendingBlocks:         maxPendingBlocks,
 		GlobalMaxBlocksSem:       semaphore.NewWeighted(10),
 		ChunkTransferTimeoutSecs: chunkTransferTimeoutSecs,
 	})
 	require.Nil(testSuite.T(), err)
-	testSuite.bwh = bwh
+	return bwh
+}
+
+func (testSuite *BufferedWriteTest) flushAndReadObject() []byte {
+	_, err := testSuite.bwh.Flush()
+	require.NoError(testSuite.T(), err)
+	content, err := storageutil.ReadObject(context.Background(), testSuite.bucket, "testObject")
+	require.NoError(testSuite.T(), err)
+	return content
 }
 
 func (testSuite *BufferedWriteTest) TestSetMTime() {
@@ -132,12 +148,81 @@ func (testSuite *BufferedWriteTest) TestWriteWhenNextOffsetIsLessThanExpected()
 	// Next offset should be 5, but we are calling with 2.
 	err = testSuite.bwh.Write([]byte("abcdefgh"), 2)
 
-	require.NotNil(testSuite.T(), err)
-	require.Equal(testSuite.T(), err, ErrOutOfOrderWrite)
+	require.Nil(testSuite.T(), err)
 	fileInfo := testSuite.bwh.WriteFileInfo()
 	bwhImpl := testSuite.bwh.(*bufferedWriteHandlerImpl)
 	assert.Equal(testSuite.T(), bwhImpl.mtime, fileInfo.Mtime)
-	assert.Equal(testSuite.T(), int64(5), fileInfo.TotalSize)
+	assert.Equal(testSuite.T(), int64(10), fileInfo.TotalSize)
+	assert.Equal(testSuite.T(), []byte("heabcdefgh"), testSuite.flushAndReadObject())
+}
+
+func (testSuite *BufferedWriteTest) TestWriteWithinCurrentBlock() {
+	err := testSuite.bwh.Write([]byte("hello world"), 0)
+	require.Nil(testSuite.T(), err)
+
+	err = testSuite.bwh.Write([]byte("W"), 6)
+
+	require.Nil(testSuite.T(), err)
+	assert.Equal(testSuite.T(), int64(11), testSuite.bwh.WriteFileInfo().TotalSize)
+	assert.Equal(testSuite.T(), []byte("hello World"), testSuite.flushAndReadObject())
+}
+
+func (testSuite *BufferedWriteTest) TestWriteSpanningPendingAndCurrentBlock() {
+	data := []byte(strings.Repeat("A", 3*blockSize+10))
+	err := testSuite.bwh.Write(data, 0)
+	require.Nil(testSuite.T(), err)
+	bwhImpl := testSuite.bwh.(*bufferedWriteHandlerImpl)
+	require.Equal(testSuite.T(), 1, len(bwhImpl.pendingBlocks))
+	require.Equal(testSuite.T(), int64(2*blockSize), bwhImpl.windowStart())
+
+	err = testSuite.bwh.Write([]byte(strings.Repeat("B", blockSize)), 2*blockSize+20)
+
+	require.Nil(testSuite.T(), err)
+	data = append(data[:2*blockSize+20], []byte(strings.Repeat("B", blockSize))...)
+	assert.Equal(testSuite.T(), int64(len(data)), testSuite.bwh.WriteFileInfo().TotalSize)
+	assert.Equal(testSuite.T(), data, testSuite.flushAndReadObject())
+}
+
+func (testSuite *BufferedWriteTest) TestWriteBeforeWindowStart() {
+	err := testSuite.bwh.Write([]byte(strings.Repeat("A", 3*blockSize)), 0)
+	require.Nil(testSuite.T(), err)
+
+	err = testSuite.bwh.Write([]byte("hi"), blockSize)
+
+	require.Equal(testSuite.T(), ErrOutOfOrderWrite, err)
+	assert.Equal(testSuite.T(), int64(3*blockSize), testSuite.bwh.WriteFileInfo().TotalSize)
+}
+
+func (testSuite *BufferedWriteTest) TestWriteWithinLargerWindow() {
+	testSuite.bwh = testSuite.createBWHandler(10, 3)
+	data := []byte(strings.Repeat("A", 3*blockSize+10))
+	err := testSuite.bwh.Write(data, 0)
+	require.Nil(testSuite.T(), err)
+	require.Equal(testSuite.T(), int64(0), testSuite.bwh.(*bufferedWriteHandlerImpl).windowStart())
+
+	err = testSuite.bwh.Write([]byte("hi"), 10)
+
+	require.Nil(testSuite.T(), err)
+	copy(data[10:], "hi")
+	assert.Equal(testSuite.T(), data, testSuite.flushAndReadObject())
+}
+
+func (testSuite *BufferedWriteTest) TestWindowIsCappedByMaxBlocksPerFile() {
+	testSuite.bwh = testSuite.createBWHandler(2, 5)
+
+	assert.Equal(testSuite.T(), 1, testSuite.bwh.(*bufferedWriteHandlerImpl).maxPendingBlocks)
+}
+
+func (testSuite *BufferedWriteTest) TestWriteToFullBlockWithSingleBlockPerFile() {
+	testSuite.bwh = testSuite.createBWHandler(1, 1)
+	err := testSuite.bwh.Write([]byte(strings.Repeat("A", blockSize)), 0)
+	require.Nil(testSuite.T(), err)
+
+	// The full block is handed over for upload as there is no other block to
+	// continue writing to.
+	err = testSuite.bwh.Write([]byte("hi"), 0)
+
+	require.Equal(testSuite.T(), ErrOutOfOrderWrite, err)
 }
 
 func (testSuite *BufferedWriteTest) TestMultipleWrites() {
@@ -304,6 +389,76 @@ func (testSuite *BufferedWriteTest) TestSyncBlocksWithError() {
 	assert.Equal(testSuite.T(), ErrUploadFailure, err)
 }
 
+func (testSuite *BufferedWriteTest) TestSyncInZonalBucketFlushesPartialBlock() {
+	testSuite.bucket = fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.BucketType{Zonal: true})
+	testSuite.bwh = testSuite.createBWHandler(10, 1)
+	err := testSuite.bwh.Write([]byte("hello"), 0)
+	require.NoError(testSuite.T(), err)
+
+	err = testSuite.bwh.Sync()
+
+	require.NoError(testSuite.T(), err)
+	content, err := storageutil.ReadObject(context.Background(), testSuite.bucket, "testObject")
+	require.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), "hello", string(content))
+	err = testSuite.bwh.Write([]byte(" world"), 5)
+	require.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), "hello world", string(testSuite.flushAndReadObject()))
+}
+
+// Create an unfinalized appendable object named testObject in a zonal bucket.
+func (testSuite *BufferedWriteTest) createUnfinalizedObject(contents string) *gcs.Object {
+	testSuite.bucket = fake.NewFakeBucket(timeutil.RealClock(), "FakeBucketName", gcs.BucketType{Zonal: true})
+	w, err := testSuite.bucket.CreateObjectChunkWriter(context.Background(), &gcs.CreateObjectRequest{Name: "testObject"}, 0, nil)
+	require.NoError(testSuite.T(), err)
+	_, err = w.Write([]byte(contents))
+	require.NoError(testSuite.T(), err)
+	_, err = w.Flush()
+	require.NoError(testSuite.T(), err)
+	m, _, err := testSuite.bucket.StatObject(context.Background(), &gcs.StatObjectRequest{Name: "testObject"})
+	require.NoError(testSuite.T(), err)
+	return storageutil.ConvertMinObjectToObject(m)
+}
+
+func (testSuite *BufferedWriteTest) TestAppendToUnfinalizedObject() {
+	o := testSuite.createUnfinalizedObject("taco")
+	bwh, err := NewBWHandler(&CreateBWHandlerRequest{
+		Object:             o,
+		ObjectName:         "testObject",
+		Bucket:             testSuite.bucket,
+		BlockSize:          blockSize,
+		MaxBlocksPerFile:   10,
+		GlobalMaxBlocksSem: semaphore.NewWeighted(10),
+		Append:             true,
+	})
+	require.NoError(testSuite.T(), err)
+	testSuite.bwh = bwh
+	assert.Equal(testSuite.T(), int64(4), bwh.WriteFileInfo().TotalSize)
+
+	err = bwh.Write([]byte("s"), 4)
+
+	require.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), "tacos", string(testSuite.flushAndReadObject()))
+}
+
+func (testSuite *BufferedWriteTest) TestAppendToObjectWhichHasGrownFails() {
+	o := testSuite.createUnfinalizedObject("taco")
+	o.Size = 3
+
+	_, err := NewBWHandler(&CreateBWHandlerRequest{
+		Object:             o,
+		ObjectName:         "testObject",
+		Bucket:             testSuite.bucket,
+		BlockSize:          blockSize,
+		MaxBlocksPerFile:   10,
+		GlobalMaxBlocksSem: semaphore.NewWeighted(10),
+		Append:             true,
+	})
+
+	var preconditionErr *gcs.PreconditionError
+	assert.ErrorAs(testSuite.T(), err, &preconditionErr)
+}
+
 func (testSuite *BufferedWriteTest) TestFlushWithNonZeroTruncatedLengthForEmptyObject() {
 	bwhImpl := testSuite.bwh.(*bufferedWriteHandlerImpl)
 	require.Nil(testSuite.T(), bwhImpl.current)
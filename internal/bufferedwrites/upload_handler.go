@@ -19,6 +19,7 @@ package bufferedwrites
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -56,6 +57,8 @@ type UploadHandler struct {
 	obj                  *gcs.Object
 	chunkTransferTimeout int64
 	blockSize            int64
+	// Whether the upload appends to obj, an unfinalized appendable object.
+	append bool
 }
 
 type CreateUploadHandlerRequest struct {
@@ -66,6 +69,7 @@ type CreateUploadHandlerRequest struct {
 	MaxBlocksPerFile         int64
 	BlockSize                int64
 	ChunkTransferTimeoutSecs int64
+	Append                   bool
 }
 
 // newUploadHandler creates the UploadHandler struct.
@@ -80,6 +84,7 @@ func newUploadHandler(req *CreateUploadHandlerRequest) *UploadHandler {
 		blockSize:            req.BlockSize,
 		signalUploadFailure:  make(chan error, 1),
 		chunkTransferTimeout: req.ChunkTransferTimeoutSecs,
+		append:               req.Append,
 	}
 	return uh
 }
@@ -90,28 +95,47 @@ func (uh *UploadHandler) Upload(block block.Block) error {
 
 	if uh.writer == nil {
 		// Lazily create the object writer.
-		err := uh.createObjectWriter()
+		err := uh.start()
 		if err != nil {
 			// createObjectWriter can only fail here due to throttling, so we will not
 			// handle this error explicitly or fall back to temp file flow.
 			return fmt.Errorf("createObjectWriter failed for object %s: %w", uh.objectName, err)
 		}
-		// Start the uploader goroutine.
-		go uh.uploader()
 	}
 
 	uh.uploadCh <- block
 	return nil
 }
 
-// createObjectWriter creates a GCS object writer.
+// start creates the object writer and starts the uploader goroutine.
+func (uh *UploadHandler) start() error {
+	err := uh.createObjectWriter()
+	if err != nil {
+		return err
+	}
+	go uh.uploader()
+	return nil
+}
+
+// createObjectWriter creates a GCS object writer, which takes over the upload
+// of the appendable object when appending.
 func (uh *UploadHandler) createObjectWriter() (err error) {
-	// TODO: b/381479965: Dynamically set chunkTransferTimeoutSecs based on chunk size. 0 here means no timeout.
-	req := gcs.NewCreateObjectRequest(uh.obj, uh.objectName, nil, 0)
 	// We need a new context here, since the first writeFile() call will be complete
 	// (and context will be cancelled) by the time complete upload is done.
 	var ctx context.Context
 	ctx, uh.cancelFunc = context.WithCancel(context.Background())
+	if uh.append {
+		uh.writer, err = uh.bucket.CreateAppendableObjectWriter(ctx, &gcs.AppendObjectRequest{
+			Name:                     uh.objectName,
+			Generation:               uh.obj.Generation,
+			Offset:                   int64(uh.obj.Size),
+			ChunkTransferTimeoutSecs: uh.chunkTransferTimeout,
+		}, int(uh.blockSize), nil)
+		return
+	}
+
+	// TODO: b/381479965: Dynamically set chunkTransferTimeoutSecs based on chunk size. 0 here means no timeout.
+	req := gcs.NewCreateObjectRequest(uh.obj, uh.objectName, nil, 0)
 	uh.writer, err = uh.bucket.CreateObjectChunkWriter(ctx, req, int(uh.blockSize), nil)
 	return
 }
@@ -156,6 +180,37 @@ func (uh *UploadHandler) Finalize() (*gcs.MinObject, error) {
 	return obj, nil
 }
 
+// Flush waits for the queued blocks to be uploaded and then persists all the
+// data uploaded so far without finalizing the object. Writers which can't be
+// flushed, e.g. those of transformed contents, are left as they are.
+func (uh *UploadHandler) Flush() error {
+	uh.wg.Wait()
+
+	if uh.writer == nil {
+		// Nothing was uploaded yet, create the object so that it is persisted.
+		if err := uh.start(); err != nil {
+			return fmt.Errorf("createObjectWriter failed for object %s: %w", uh.objectName, err)
+		}
+	}
+
+	select {
+	case <-uh.signalUploadFailure:
+		return ErrUploadFailure
+	default:
+	}
+
+	_, err := uh.writer.Flush()
+	if errors.Is(err, errors.ErrUnsupported) {
+		logger.Tracef("Not flushing the upload of object %s: %v", uh.objectName, err)
+		return nil
+	}
+	if err != nil {
+		uh.closeUploadFailureChannel()
+		return fmt.Errorf("flush failed for object %s: %w", uh.objectName, err)
+	}
+	return nil
+}
+
 func (uh *UploadHandler) CancelUpload() {
 	if uh.cancelFunc != nil {
 		// cancel the context to cancel the ongoing GCS upload.
@@ -0,0 +1,311 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package encryption provides encryption at rest for the files in file cache.
+//
+// A cache file is laid out as a fixed size header followed by a sequence of
+// independently sealed chunks:
+//
+//	[magic (8 bytes) | salt (24 bytes)] [chunk 0] [chunk 1] ... [chunk N]
+//
+// Every chunk except the last one holds exactly ChunkSize bytes of plaintext
+// sealed with AES-256-GCM, so the ciphertext of plaintext offset x can be
+// located without reading the preceding chunks. The chunk index is used as the
+// GCM nonce and the AES key is derived from the cipher key and the random
+// per-file salt, so nonces are never reused across files or re-downloads.
+package encryption
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const (
+	// KeySize is the size in bytes of the key used by Cipher.
+	KeySize = 32
+
+	// ChunkSize is the size of plaintext sealed in one chunk. Writers must start
+	// at a multiple of ChunkSize, which holds for all the download paths since
+	// they write in multiples of MiB.
+	ChunkSize = 64 * 1024
+
+	magicSize  = 8
+	saltSize   = 24
+	headerSize = magicSize + saltSize
+	tagSize    = 16
+	nonceSize  = 12
+
+	encryptedChunkSize = ChunkSize + tagSize
+)
+
+var magic = []byte("GCSFENC1")
+
+// Cipher encrypts and decrypts files in cache with a single key.
+type Cipher struct {
+	key []byte
+}
+
+// NewCipher returns a Cipher using the given key, which must be KeySize bytes
+// long.
+func NewCipher(key []byte) (*Cipher, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("invalid file cache encryption key size %d, expected %d", len(key), KeySize)
+	}
+	return &Cipher{key: bytes.Clone(key)}, nil
+}
+
+// NewEphemeralCipher returns a Cipher with a randomly generated key. The key
+// lives only in memory, so the files in cache become unreadable once the
+// process exits.
+func NewEphemeralCipher() (*Cipher, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("error while generating file cache encryption key: %w", err)
+	}
+	return NewCipher(key)
+}
+
+// NewCipherFromKeyFile returns a Cipher with the key read from the given file.
+// The file must contain either KeySize raw bytes or their hex encoding.
+func NewCipherFromKeyFile(path string) (*Cipher, error) {
+	key, err := ReadKeyFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("file cache: %w", err)
+	}
+	return NewCipher(key)
+}
+
+// ReadKeyFile returns the key in the given file, which must contain either
+// KeySize raw bytes or their hex encoding.
+func ReadKeyFile(path string) ([]byte, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error while reading encryption key file: %w", err)
+	}
+	if len(content) == KeySize {
+		return content, nil
+	}
+	key, err := hex.DecodeString(string(bytes.TrimSpace(content)))
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("encryption key file %s is neither %d raw bytes nor hex encoded", path, KeySize)
+	}
+	return key, nil
+}
+
+// EncryptedSize returns the size of the cache file holding plaintextSize bytes.
+func (c *Cipher) EncryptedSize(plaintextSize int64) int64 {
+	chunks := (plaintextSize + ChunkSize - 1) / ChunkSize
+	return headerSize + plaintextSize + chunks*tagSize
+}
+
+// fileAt is the subset of *os.File used by File.
+type fileAt interface {
+	io.ReaderAt
+	io.WriterAt
+}
+
+// File provides plaintext random access to an encrypted cache file.
+type File struct {
+	cipher *Cipher
+	file   fileAt
+
+	// mu guards the lazy initialization of aead.
+	mu   sync.Mutex
+	aead cipher.AEAD
+}
+
+// NewFile returns a File which decrypts the contents of the given file. The
+// header is read lazily on the first read, so the file may still be empty at
+// the time of calling.
+func (c *Cipher) NewFile(f fileAt) *File {
+	return &File{cipher: c, file: f}
+}
+
+// InitFile writes a new header to the given (empty) file and returns a File
+// that can be used to write encrypted content into it.
+func (c *Cipher) InitFile(f fileAt) (*File, error) {
+	header := make([]byte, headerSize)
+	copy(header, magic)
+	if _, err := rand.Read(header[magicSize:]); err != nil {
+		return nil, fmt.Errorf("InitFile: error while generating salt: %w", err)
+	}
+	if _, err := f.WriteAt(header, 0); err != nil {
+		return nil, fmt.Errorf("InitFile: error while writing header: %w", err)
+	}
+	aead, err := c.newAEAD(header[magicSize:])
+	if err != nil {
+		return nil, err
+	}
+	return &File{cipher: c, file: f, aead: aead}, nil
+}
+
+// newAEAD derives the per-file key from the given salt.
+func (c *Cipher) newAEAD(salt []byte) (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, c.key)
+	mac.Write(salt)
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, fmt.Errorf("error while creating block cipher: %w", err)
+	}
+	return cipher.NewGCM(block)
+}
+
+// getAEAD returns the AEAD of the file, reading the header if required.
+func (f *File) getAEAD() (cipher.AEAD, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.aead != nil {
+		return f.aead, nil
+	}
+
+	header := make([]byte, headerSize)
+	if _, err := f.file.ReadAt(header, 0); err != nil {
+		return nil, fmt.Errorf("error while reading encryption header: %w", err)
+	}
+	if !bytes.Equal(header[:magicSize], magic) {
+		return nil, errors.New("cache file is not encrypted with the expected format")
+	}
+	aead, err := f.cipher.newAEAD(header[magicSize:])
+	if err != nil {
+		return nil, err
+	}
+	f.aead = aead
+	return aead, nil
+}
+
+func chunkNonce(index int64) []byte {
+	nonce := make([]byte, nonceSize)
+	binary.BigEndian.PutUint64(nonce, uint64(index))
+	return nonce
+}
+
+func chunkOffset(index int64) int64 {
+	return headerSize + index*encryptedChunkSize
+}
+
+// ReadAt reads len(p) bytes of plaintext starting at plaintext offset off. Like
+// os.File.ReadAt, it returns io.EOF when fewer bytes are available.
+func (f *File) ReadAt(p []byte, off int64) (n int, err error) {
+	if off < 0 {
+		return 0, errors.New("encryption.File.ReadAt: negative offset")
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	aead, err := f.getAEAD()
+	if err != nil {
+		return 0, err
+	}
+
+	ciphertext := make([]byte, encryptedChunkSize)
+	var plaintext []byte
+	for n < len(p) {
+		index := (off + int64(n)) / ChunkSize
+		read, readErr := f.file.ReadAt(ciphertext, chunkOffset(index))
+		if readErr != nil && readErr != io.EOF {
+			return n, readErr
+		}
+		if read <= tagSize {
+			return n, io.EOF
+		}
+		plaintext, err = aead.Open(plaintext[:0], chunkNonce(index), ciphertext[:read], nil)
+		if err != nil {
+			return n, fmt.Errorf("error while decrypting chunk %d: %w", index, err)
+		}
+		inChunk := int((off + int64(n)) - index*ChunkSize)
+		if inChunk >= len(plaintext) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], plaintext[inChunk:])
+		if len(plaintext) < ChunkSize && n < len(p) {
+			// Partial chunk is always the last one.
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// NewOffsetWriter returns a Writer which encrypts and writes the plaintext
+// starting at the given plaintext offset, which must be a multiple of
+// ChunkSize.
+func (f *File) NewOffsetWriter(off int64) (*Writer, error) {
+	if off%ChunkSize != 0 {
+		return nil, fmt.Errorf("encrypted write offset %d is not a multiple of %d", off, ChunkSize)
+	}
+	aead, err := f.getAEAD()
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		file:  f.file,
+		aead:  aead,
+		index: off / ChunkSize,
+		buf:   make([]byte, 0, ChunkSize),
+	}, nil
+}
+
+// Writer seals plaintext in chunks and writes them to the file. Only complete
+// chunks are written by Write; Flush must be called once the writer reaches
+// the end of its range to write the remaining partial chunk.
+type Writer struct {
+	file   io.WriterAt
+	aead   cipher.AEAD
+	index  int64
+	buf    []byte
+	sealed []byte
+}
+
+func (w *Writer) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		copied := copy(w.buf[len(w.buf):ChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+copied]
+		p = p[copied:]
+		n += copied
+		if len(w.buf) == ChunkSize {
+			if err = w.writeChunk(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Flush writes the buffered partial chunk, if any.
+func (w *Writer) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	return w.writeChunk()
+}
+
+func (w *Writer) writeChunk() error {
+	w.sealed = w.aead.Seal(w.sealed[:0], chunkNonce(w.index), w.buf, nil)
+	if _, err := w.file.WriteAt(w.sealed, chunkOffset(w.index)); err != nil {
+		return fmt.Errorf("error while writing encrypted chunk %d: %w", w.index, err)
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
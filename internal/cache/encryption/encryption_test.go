@@ -0,0 +1,163 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package encryption
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	testutil "github.com/googlecloudplatform/gcsfuse/v2/internal/util"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type EncryptionTest struct {
+	suite.Suite
+	cipher *Cipher
+	file   *os.File
+}
+
+func TestEncryptionTestSuite(t *testing.T) {
+	suite.Run(t, new(EncryptionTest))
+}
+
+func (t *EncryptionTest) SetupTest() {
+	var err error
+	t.cipher, err = NewEphemeralCipher()
+	require.NoError(t.T(), err)
+	t.file, err = os.Create(path.Join(t.T().TempDir(), "cache_file"))
+	require.NoError(t.T(), err)
+}
+
+func (t *EncryptionTest) TearDownTest() {
+	_ = t.file.Close()
+}
+
+// writeEncrypted writes content to t.file in ranges of rangeSize bytes, the
+// way parallel downloads do.
+func (t *EncryptionTest) writeEncrypted(content []byte, rangeSize int) {
+	f, err := t.cipher.InitFile(t.file)
+	require.NoError(t.T(), err)
+	for start := 0; start < len(content); start += rangeSize {
+		end := min(start+rangeSize, len(content))
+		w, err := f.NewOffsetWriter(int64(start))
+		require.NoError(t.T(), err)
+		_, err = io.Copy(w, bytes.NewReader(content[start:end]))
+		require.NoError(t.T(), err)
+		require.NoError(t.T(), w.Flush())
+	}
+}
+
+func (t *EncryptionTest) TestReadAtReturnsPlaintextAtAnyOffset() {
+	content := testutil.GenerateRandomBytes(3*ChunkSize + 123)
+	t.writeEncrypted(content, 2*ChunkSize)
+	reader := t.cipher.NewFile(t.file)
+
+	testCases := []struct {
+		offset int64
+		size   int
+	}{
+		{0, len(content)},
+		{1, 10},
+		{ChunkSize - 5, 10},
+		{2*ChunkSize + 1, ChunkSize},
+		{int64(len(content)) - 3, 3},
+	}
+	for _, tc := range testCases {
+		buf := make([]byte, tc.size)
+		n, err := reader.ReadAt(buf, tc.offset)
+
+		assert.NoError(t.T(), err)
+		assert.Equal(t.T(), tc.size, n)
+		assert.Equal(t.T(), content[tc.offset:tc.offset+int64(tc.size)], buf)
+	}
+}
+
+func (t *EncryptionTest) TestReadAtBeyondEndReturnsEOF() {
+	content := testutil.GenerateRandomBytes(ChunkSize + 10)
+	t.writeEncrypted(content, len(content))
+	reader := t.cipher.NewFile(t.file)
+	buf := make([]byte, 20)
+
+	n, err := reader.ReadAt(buf, ChunkSize)
+
+	assert.ErrorIs(t.T(), err, io.EOF)
+	assert.Equal(t.T(), 10, n)
+	assert.Equal(t.T(), content[ChunkSize:], buf[:n])
+}
+
+func (t *EncryptionTest) TestFileContentIsEncrypted() {
+	content := bytes.Repeat([]byte("plaintext"), ChunkSize)
+	t.writeEncrypted(content, len(content))
+
+	raw, err := os.ReadFile(t.file.Name())
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), t.cipher.EncryptedSize(int64(len(content))), int64(len(raw)))
+	assert.False(t.T(), bytes.Contains(raw, []byte("plaintextplaintext")))
+}
+
+func (t *EncryptionTest) TestReadAtWithDifferentKeyFails() {
+	t.writeEncrypted(testutil.GenerateRandomBytes(100), 100)
+	other, err := NewEphemeralCipher()
+	require.NoError(t.T(), err)
+
+	_, err = other.NewFile(t.file).ReadAt(make([]byte, 10), 0)
+
+	assert.Error(t.T(), err)
+}
+
+func (t *EncryptionTest) TestNewOffsetWriterWithUnalignedOffset() {
+	f, err := t.cipher.InitFile(t.file)
+	require.NoError(t.T(), err)
+
+	_, err = f.NewOffsetWriter(ChunkSize + 1)
+
+	assert.Error(t.T(), err)
+}
+
+func (t *EncryptionTest) TestNewCipherFromKeyFile() {
+	key := testutil.GenerateRandomBytes(KeySize)
+	rawKeyFile := path.Join(t.T().TempDir(), "raw")
+	require.NoError(t.T(), os.WriteFile(rawKeyFile, key, 0600))
+	hexKeyFile := path.Join(t.T().TempDir(), "hex")
+	require.NoError(t.T(), os.WriteFile(hexKeyFile, []byte(hex.EncodeToString(key)+"\n"), 0600))
+
+	rawCipher, err := NewCipherFromKeyFile(rawKeyFile)
+	require.NoError(t.T(), err)
+	hexCipher, err := NewCipherFromKeyFile(hexKeyFile)
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), key, rawCipher.key)
+	assert.Equal(t.T(), key, hexCipher.key)
+}
+
+func (t *EncryptionTest) TestNewCipherWithInvalidKeySize() {
+	_, err := NewCipher(make([]byte, 16))
+
+	assert.Error(t.T(), err)
+}
+
+func (t *EncryptionTest) TestEncryptedSize() {
+	assert.Equal(t.T(), int64(headerSize), t.cipher.EncryptedSize(0))
+	assert.Equal(t.T(), int64(headerSize+1+tagSize), t.cipher.EncryptedSize(1))
+	assert.Equal(t.T(), int64(headerSize+ChunkSize+tagSize), t.cipher.EncryptedSize(ChunkSize))
+	assert.Equal(t.T(), int64(headerSize+ChunkSize+1+2*tagSize), t.cipher.EncryptedSize(ChunkSize+1))
+}
@@ -32,6 +32,10 @@ type CacheHandle struct {
 	// fileHandle to a local file which contains locally downloaded data.
 	fileHandle *os.File
 
+	// fileReader reads the plaintext content of fileHandle. It is nil when file
+	// cache encryption is disabled, in which case fileHandle is read directly.
+	fileReader io.ReaderAt
+
 	// fileDownloadJob is a reference to async download Job. It can be nil if
 	// job is already completed.
 	fileDownloadJob *downloader.Job
@@ -212,7 +216,7 @@ func (fch *CacheHandle) Read(ctx context.Context, bucket gcs.Bucket, object *gcs
 	}
 
 	// We are here means, we have the data downloaded which kernel has asked for.
-	n, err = fch.fileHandle.ReadAt(dst, offset)
+	n, err = fch.readAt(dst, offset)
 	requestedNumBytes := int(requiredOffset - offset)
 	// dst buffer has fixed size of 1 MiB even when the offset is such that
 	// offset + 1 MiB > object size. In that case, io.ErrUnexpectedEOF is thrown
@@ -243,6 +247,15 @@ func (fch *CacheHandle) Read(ctx context.Context, bucket gcs.Bucket, object *gcs
 	return
 }
 
+// readAt reads from the local file into dst, decrypting the content if file
+// cache encryption is enabled.
+func (fch *CacheHandle) readAt(dst []byte, offset int64) (int, error) {
+	if fch.fileReader != nil {
+		return fch.fileReader.ReadAt(dst, offset)
+	}
+	return fch.fileHandle.ReadAt(dst, offset)
+}
+
 // IsSequential returns true if the sequential read is being performed, false for
 // random read.
 func (fch *CacheHandle) IsSequential(currentOffset int64) bool {
@@ -269,6 +282,7 @@ func (fch *CacheHandle) Close() (err error) {
 			err = fmt.Errorf("cacheHandle.Close(): while closing read file handle: %w", err)
 		}
 		fch.fileHandle = nil
+		fch.fileReader = nil
 	}
 
 	return
@@ -157,6 +157,7 @@ func (cht *cacheHandleTest) SetupTest() {
 		fileCacheConfig,
 		semaphore.NewWeighted(math.MaxInt64),
 		common.NewNoopMetrics(),
+		nil,
 	)
 
 	cht.cacheHandle = NewCacheHandle(readLocalFileHandle, fileDownloadJob, cht.cache, false, 0)
@@ -865,6 +866,7 @@ func (cht *cacheHandleTest) Test_SequentialRead_Parallel_Download_True() {
 		fileCacheConfig,
 		semaphore.NewWeighted(math.MaxInt64),
 		common.NewNoopMetrics(),
+		nil,
 	)
 	cht.cacheHandle.fileDownloadJob = fileDownloadJob
 
@@ -900,6 +902,7 @@ func (cht *cacheHandleTest) Test_RandomRead_Parallel_Download_True() {
 		fileCacheConfig,
 		semaphore.NewWeighted(math.MaxInt64),
 		common.NewNoopMetrics(),
+		nil,
 	)
 	cht.cacheHandle.fileDownloadJob = fileDownloadJob
 
@@ -935,6 +938,7 @@ func (cht *cacheHandleTest) Test_RandomRead_CacheForRangeReadFalse_And_ParallelD
 		fileCacheConfig,
 		semaphore.NewWeighted(math.MaxInt64),
 		common.NewNoopMetrics(),
+		nil,
 	)
 
 	// Since, it's a random read, download job will not start.
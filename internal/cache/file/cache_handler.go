@@ -19,6 +19,7 @@ import (
 	"os"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/encryption"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file/downloader"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
@@ -49,17 +50,22 @@ type CacheHandler struct {
 	// dirPerm parameter specifies the permission of cache directory.
 	dirPerm os.FileMode
 
+	// cipher decrypts the files in cache. It is nil if file cache encryption is
+	// disabled.
+	cipher *encryption.Cipher
+
 	// mu guards the handling of insertion into and eviction from file cache.
 	mu locker.Locker
 }
 
-func NewCacheHandler(fileInfoCache *lru.Cache, jobManager *downloader.JobManager, cacheDir string, filePerm os.FileMode, dirPerm os.FileMode) *CacheHandler {
+func NewCacheHandler(fileInfoCache *lru.Cache, jobManager *downloader.JobManager, cacheDir string, filePerm os.FileMode, dirPerm os.FileMode, cipher *encryption.Cipher) *CacheHandler {
 	return &CacheHandler{
 		fileInfoCache: fileInfoCache,
 		jobManager:    jobManager,
 		cacheDir:      cacheDir,
 		filePerm:      filePerm,
 		dirPerm:       dirPerm,
+		cipher:        cipher,
 		mu:            locker.New("FileCacheHandler", func() {}),
 	}
 }
@@ -163,7 +169,7 @@ func (chr *CacheHandler) addFileInfoEntryAndCreateDownloadJob(object *gcs.MinObj
 			Key:              fileInfoKey,
 			ObjectGeneration: object.Generation,
 			Offset:           0,
-			FileSize:         object.Size,
+			FileSize:         chr.cacheFileSize(object),
 		}
 
 		evictedValues, err := chr.fileInfoCache.Insert(fileInfoKeyName, fileInfo)
@@ -187,6 +193,15 @@ func (chr *CacheHandler) addFileInfoEntryAndCreateDownloadJob(object *gcs.MinObj
 	return nil
 }
 
+// cacheFileSize returns the size of the file in cache holding the whole
+// object, which is what its entry is charged in fileInfoCache.
+func (chr *CacheHandler) cacheFileSize(object *gcs.MinObject) uint64 {
+	if chr.cipher != nil {
+		return uint64(chr.cipher.EncryptedSize(int64(object.Size)))
+	}
+	return object.Size
+}
+
 // GetCacheHandle creates an entry in fileInfoCache if it does not already exist. It
 // creates downloader.Job if not already exis and requiredt. Also, creates local
 // file into which the download job downloads the object content. Finally, it
@@ -231,7 +246,11 @@ func (chr *CacheHandler) GetCacheHandle(object *gcs.MinObject, bucket gcs.Bucket
 		return nil, fmt.Errorf("GetCacheHandle: while creating local-file read handle: %w", err)
 	}
 
-	return NewCacheHandle(localFileReadHandle, chr.jobManager.GetJob(object.Name, bucket.Name()), chr.fileInfoCache, cacheForRangeRead, initialOffset), nil
+	cacheHandle := NewCacheHandle(localFileReadHandle, chr.jobManager.GetJob(object.Name, bucket.Name()), chr.fileInfoCache, cacheForRangeRead, initialOffset)
+	if chr.cipher != nil {
+		cacheHandle.fileReader = chr.cipher.NewFile(localFileReadHandle)
+	}
+	return cacheHandle, nil
 }
 
 // InvalidateCache removes the file entry from the fileInfoCache and performs clean
@@ -21,6 +21,7 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/encryption"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
@@ -49,6 +50,9 @@ type JobManager struct {
 	sequentialReadSizeMb int32
 	fileInfoCache        *lru.Cache
 	fileCacheConfig      *cfg.FileCacheConfig
+	// cipher is passed to Job created by JobManager to encrypt the files in
+	// cache. It is nil if file cache encryption is disabled.
+	cipher *encryption.Cipher
 
 	/////////////////////////
 	// Mutable state
@@ -66,7 +70,7 @@ type JobManager struct {
 
 func NewJobManager(fileInfoCache *lru.Cache, filePerm os.FileMode, dirPerm os.FileMode,
 	cacheDir string, sequentialReadSizeMb int32, c *cfg.FileCacheConfig,
-	metricHandle common.MetricHandle) (jm *JobManager) {
+	metricHandle common.MetricHandle, cipher *encryption.Cipher) (jm *JobManager) {
 	maxParallelDownloads := int64(math.MaxInt64)
 	if c.MaxParallelDownloads > 0 {
 		maxParallelDownloads = c.MaxParallelDownloads
@@ -81,6 +85,7 @@ func NewJobManager(fileInfoCache *lru.Cache, filePerm os.FileMode, dirPerm os.Fi
 		// Shared between jobs - Limits the overall concurrency of downloads.
 		maxParallelismSem: semaphore.NewWeighted(maxParallelDownloads),
 		metricHandle:      metricHandle,
+		cipher:            cipher,
 	}
 	jm.mu = locker.New("JobManager", func() {})
 	jm.jobs = make(map[string]*Job)
@@ -118,7 +123,7 @@ func (jm *JobManager) CreateJobIfNotExists(object *gcs.MinObject, bucket gcs.Buc
 	removeJobCallback := func() {
 		jm.removeJob(object.Name, bucket.Name())
 	}
-	job = NewJob(object, bucket, jm.fileInfoCache, jm.sequentialReadSizeMb, fileSpec, removeJobCallback, jm.fileCacheConfig, jm.maxParallelismSem, jm.metricHandle)
+	job = NewJob(object, bucket, jm.fileInfoCache, jm.sequentialReadSizeMb, fileSpec, removeJobCallback, jm.fileCacheConfig, jm.maxParallelismSem, jm.metricHandle, jm.cipher)
 	jm.jobs[objectPath] = job
 	return job
 }
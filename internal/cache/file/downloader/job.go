@@ -28,6 +28,7 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/encryption"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	cacheutil "github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
@@ -63,6 +64,9 @@ type Job struct {
 	sequentialReadSizeMb int32
 	fileSpec             data.FileSpec
 	fileCacheConfig      *cfg.FileCacheConfig
+	// cipher encrypts the content written to file in cache. It is nil if file
+	// cache encryption is disabled.
+	cipher *encryption.Cipher
 
 	/////////////////////////
 	// Mutable state
@@ -98,6 +102,10 @@ type Job struct {
 	// downloaded when parallel download is enabled.
 	rangeChan chan data.ObjectRange
 
+	// encryptedFile is used to write encrypted content into the file in cache.
+	// It is set by createCacheFile when cipher is non-nil.
+	encryptedFile *encryption.File
+
 	metricsHandle common.MetricHandle
 }
 
@@ -125,6 +133,7 @@ func NewJob(
 	fileCacheConfig *cfg.FileCacheConfig,
 	maxParallelismSem *semaphore.Weighted,
 	metricHandle common.MetricHandle,
+	cipher *encryption.Cipher,
 ) (job *Job) {
 	job = &Job{
 		object:               object,
@@ -136,6 +145,7 @@ func NewJob(
 		fileCacheConfig:      fileCacheConfig,
 		maxParallelismSem:    maxParallelismSem,
 		metricsHandle:        metricHandle,
+		cipher:               cipher,
 	}
 	job.mu = locker.New("Job-"+fileSpec.Path, job.checkInvariants)
 	job.init()
@@ -276,7 +286,7 @@ func (job *Job) updateStatusOffset(downloadedOffset int64) (err error) {
 
 	updatedFileInfo := data.FileInfo{
 		Key: fileInfoKey, ObjectGeneration: job.object.Generation,
-		FileSize: job.object.Size, Offset: uint64(downloadedOffset),
+		FileSize: uint64(job.cacheFileSize()), Offset: uint64(downloadedOffset),
 	}
 
 	err = job.fileInfoCache.UpdateWithoutChangingOrder(fileInfoKeyName, updatedFileInfo)
@@ -320,6 +330,7 @@ func (job *Job) downloadObjectToFile(cacheFile *os.File) (err error) {
 					},
 					ReadCompressed: job.object.HasContentEncodingGzip(),
 					ReadHandle:     readHandle,
+					Object:         job.object,
 				})
 			if err != nil {
 				err = fmt.Errorf("downloadObjectToFile: error in creating NewReader with start %d and limit %d: %w", start, newReaderLimit, err)
@@ -334,8 +345,14 @@ func (job *Job) downloadObjectToFile(cacheFile *os.File) (err error) {
 		maxRead := min(ReadChunkSize, newReaderLimit-start)
 
 		// Copy the contents from NewReader to cache file.
-		offsetWriter := io.NewOffsetWriter(cacheFile, start)
+		offsetWriter, flush, err := job.newCacheFileWriter(cacheFile, start)
+		if err != nil {
+			return fmt.Errorf("downloadObjectToFile: %w", err)
+		}
 		_, err = io.CopyN(offsetWriter, newReader, maxRead)
+		if err == nil {
+			err = flush()
+		}
 		if err != nil {
 			err = fmt.Errorf("downloadObjectToFile: error at the time of copying content to cache file %w", err)
 			return err
@@ -382,8 +399,40 @@ func (job *Job) cleanUpDownloadAsyncJob() {
 	job.mu.Unlock()
 }
 
+// newCacheFileWriter returns a writer which writes the object content starting
+// at the given offset into cacheFile, along with a function which must be
+// called once the content is copied. The content is encrypted if file cache
+// encryption is enabled.
+func (job *Job) newCacheFileWriter(cacheFile *os.File, offset int64) (io.Writer, func() error, error) {
+	if job.encryptedFile == nil {
+		return io.NewOffsetWriter(cacheFile, offset), func() error { return nil }, nil
+	}
+	writer, err := job.encryptedFile.NewOffsetWriter(offset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error while creating encrypted writer: %w", err)
+	}
+	return writer, writer.Flush, nil
+}
+
+// useODirect returns true if the file in cache should be written with O_DIRECT.
+// Encrypted chunks are not aligned, hence O_DIRECT is never used with
+// encryption.
+func (job *Job) useODirect() bool {
+	return job.fileCacheConfig.EnableParallelDownloads && job.fileCacheConfig.EnableODirect && job.cipher == nil
+}
+
+// cacheFileSize returns the size of the file in cache once the object is
+// completely downloaded.
+func (job *Job) cacheFileSize() int64 {
+	if job.cipher != nil {
+		return job.cipher.EncryptedSize(int64(job.object.Size))
+	}
+	return int64(job.object.Size)
+}
+
 // createCacheFile is a helper function which creates file in cache using
-// appropriate open file flags.
+// appropriate open file flags. If file cache encryption is enabled, it also
+// writes the encryption header to the file.
 func (job *Job) createCacheFile() (*os.File, error) {
 	// Create, open and truncate cache file for writing object into it.
 	openFileFlags := os.O_TRUNC | os.O_WRONLY
@@ -391,7 +440,7 @@ func (job *Job) createCacheFile() (*os.File, error) {
 	var err error
 	// Try using O_DIRECT while opening file when parallel downloads are enabled
 	// and O_DIRECT use is not disabled.
-	if job.fileCacheConfig.EnableParallelDownloads && job.fileCacheConfig.EnableODirect {
+	if job.useODirect() {
 		cacheFile, err = cacheutil.CreateFile(job.fileSpec, openFileFlags|syscall.O_DIRECT)
 		if errors.Is(err, fs.ErrInvalid) || errors.Is(err, syscall.EINVAL) {
 			logger.Warnf("downloadObjectAsync: failure in opening file with O_DIRECT, falling back to without O_DIRECT")
@@ -401,6 +450,14 @@ func (job *Job) createCacheFile() (*os.File, error) {
 		cacheFile, err = cacheutil.CreateFile(job.fileSpec, openFileFlags)
 	}
 
+	if err == nil && job.cipher != nil {
+		job.encryptedFile, err = job.cipher.InitFile(cacheFile)
+		if err != nil {
+			_ = cacheFile.Close()
+			return nil, err
+		}
+	}
+
 	return cacheFile, err
 }
 
@@ -451,7 +508,7 @@ func (job *Job) downloadObjectAsync() {
 	// Truncate as the parallel downloads can create file with size little higher
 	// than the actual object size because writing with O_DIRECT happens in size
 	// multiple of cfg.MinimumAlignSizeForWriting.
-	err = cacheFile.Truncate(int64(job.object.Size))
+	err = cacheFile.Truncate(job.cacheFileSize())
 	if err != nil {
 		err = fmt.Errorf("downloadObjectAsync: error while truncating cache file: %w", err)
 		job.handleError(err)
@@ -526,11 +583,13 @@ func (job *Job) GetStatus() JobStatus {
 // Compares CRC32 of the downloaded file with the CRC32 from GCS object metadata.
 // In case of mismatch deletes the file and corresponding entry from file cache.
 func (job *Job) validateCRC() (err error) {
-	if !job.fileCacheConfig.EnableCrc {
+	// Objects presented with contents other than those stored, such as
+	// encrypted ones, have no checksum to compare with.
+	if !job.fileCacheConfig.EnableCrc || job.object.CRC32C == nil {
 		return
 	}
 
-	crc32Val, err := cacheutil.CalculateFileCRC32(job.cancelCtx, job.fileSpec.Path)
+	crc32Val, err := job.calculateCacheFileCRC32()
 	if err != nil {
 		return
 	}
@@ -563,6 +622,23 @@ func (job *Job) validateCRC() (err error) {
 	return
 }
 
+// calculateCacheFileCRC32 returns the CRC-32 checksum of the plaintext content
+// of the file in cache.
+func (job *Job) calculateCacheFileCRC32() (uint32, error) {
+	if job.cipher == nil {
+		return cacheutil.CalculateFileCRC32(job.cancelCtx, job.fileSpec.Path)
+	}
+
+	file, err := os.Open(job.fileSpec.Path)
+	if err != nil {
+		return 0, fmt.Errorf("error opening file: %w", err)
+	}
+	defer file.Close()
+
+	reader := io.NewSectionReader(job.cipher.NewFile(file), 0, int64(job.object.Size))
+	return cacheutil.CalculateCRC32(job.cancelCtx, reader)
+}
+
 // Performs different actions based on the type of error.
 // For context.Canceled it marks the job as invalid and notifies subscribers.
 // For other errors, marks the job as failed and notifies subscribers.
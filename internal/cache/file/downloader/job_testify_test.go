@@ -15,6 +15,8 @@
 package downloader
 
 import (
+	"bytes"
+	"hash/crc32"
 	"io"
 	"math"
 	"os"
@@ -29,6 +31,7 @@ import (
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/data"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/encryption"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/util"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
@@ -36,6 +39,7 @@ import (
 	testutil "github.com/googlecloudplatform/gcsfuse/v2/internal/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
 
@@ -65,15 +69,21 @@ func (t *JobTestifyTest) initReadCacheTestifyTest(objectName string, objectConte
 		DirPerm:  util.DefaultDirPerm,
 	}
 	t.cache = lru.NewCache(lruCacheSize)
-	t.job = NewJob(&t.object, t.mockBucket, t.cache, sequentialReadSize, t.fileSpec, removeCallback, t.defaultFileCacheConfig, semaphore.NewWeighted(math.MaxInt64), common.NewNoopMetrics())
+	t.job = NewJob(&t.object, t.mockBucket, t.cache, sequentialReadSize, t.fileSpec, removeCallback, t.defaultFileCacheConfig, semaphore.NewWeighted(math.MaxInt64), common.NewNoopMetrics(), nil)
+	t.insertFileInfo()
+}
+
+// insertFileInfo inserts the entry of the job's object in the cache, charged
+// at the size of its file in cache, as the cache handler does.
+func (t *JobTestifyTest) insertFileInfo() {
 	fileInfoKey := data.FileInfoKey{
 		BucketName: storage.TestBucketName,
-		ObjectName: objectName,
+		ObjectName: t.object.Name,
 	}
 	fileInfo := data.FileInfo{
 		Key:              fileInfoKey,
 		ObjectGeneration: t.object.Generation,
-		FileSize:         t.object.Size,
+		FileSize:         uint64(t.job.cacheFileSize()),
 		Offset:           0,
 	}
 	fileInfoKeyName, err := fileInfoKey.Key()
@@ -105,9 +115,9 @@ func (t *JobTestifyTest) Test_downloadObjectToFile_WithReadHandle() {
 	rc := io.NopCloser(strings.NewReader(string(objectContent)))
 	rd := &fake.FakeReader{ReadCloser: rc, Handle: []byte("opaque-handle")}
 	t.mockBucket.On("Name").Return(storage.TestBucketName)
-	readObjectReq := gcs.ReadObjectRequest{Name: objectName, Generation: 0, Range: &gcs.ByteRange{Start: 0, Limit: 5 * util.MiB}, ReadCompressed: false, ReadHandle: nil}
+	readObjectReq := gcs.ReadObjectRequest{Name: objectName, Generation: 0, Range: &gcs.ByteRange{Start: 0, Limit: 5 * util.MiB}, ReadCompressed: false, ReadHandle: nil, Object: t.job.object}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, &readObjectReq).Return(rd, nil)
-	readObjectReq2 := gcs.ReadObjectRequest{Name: objectName, Generation: 0, Range: &gcs.ByteRange{Start: 5 * util.MiB, Limit: 10 * util.MiB}, ReadCompressed: false, ReadHandle: []byte("opaque-handle")}
+	readObjectReq2 := gcs.ReadObjectRequest{Name: objectName, Generation: 0, Range: &gcs.ByteRange{Start: 5 * util.MiB, Limit: 10 * util.MiB}, ReadCompressed: false, ReadHandle: []byte("opaque-handle"), Object: t.job.object}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, &readObjectReq2).Return(rd, nil)
 
 	// Start download
@@ -126,3 +136,42 @@ func (t *JobTestifyTest) Test_downloadObjectToFile_WithReadHandle() {
 	// Verify fileInfoCache update
 	verifyFileInfoEntry(t.T(), t.mockBucket, t.object, t.cache, uint64(objectSize))
 }
+
+func (t *JobTestifyTest) Test_Download_WithEncryption() {
+	objectName := "path/in/gcs/encrypted.txt"
+	objectSize := 3*util.MiB + 7
+	objectContent := testutil.GenerateRandomBytes(objectSize)
+	t.defaultFileCacheConfig = &cfg.FileCacheConfig{EnableCrc: true}
+	t.initReadCacheTestifyTest(objectName, objectContent, 200, uint64(2*objectSize), func() {})
+	crc := crc32.Checksum(objectContent, crc32.MakeTable(crc32.Castagnoli))
+	t.object.CRC32C = &crc
+	cipher, err := encryption.NewEphemeralCipher()
+	require.NoError(t.T(), err)
+	t.job.cipher = cipher
+	t.insertFileInfo()
+	rd := &fake.FakeReader{ReadCloser: io.NopCloser(bytes.NewReader(objectContent))}
+	t.mockBucket.On("Name").Return(storage.TestBucketName)
+	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, mock.Anything).Return(rd, nil)
+
+	_, err = t.job.Download(t.ctx, int64(objectSize), true)
+	<-t.job.doneCh
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), Completed, t.job.GetStatus().Name)
+	fileInfoKeyName, err := data.FileInfoKey{BucketName: storage.TestBucketName, ObjectName: objectName}.Key()
+	require.NoError(t.T(), err)
+	fileInfo := t.cache.LookUpWithoutChangingOrder(fileInfoKeyName)
+	require.NotNil(t.T(), fileInfo)
+	assert.Equal(t.T(), uint64(cipher.EncryptedSize(int64(objectSize))), fileInfo.Size())
+	raw, err := os.ReadFile(t.fileSpec.Path)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), cipher.EncryptedSize(int64(objectSize)), int64(len(raw)))
+	assert.False(t.T(), bytes.Contains(raw, objectContent[:1024]))
+	file, err := os.Open(t.fileSpec.Path)
+	require.NoError(t.T(), err)
+	defer file.Close()
+	plaintext := make([]byte, objectSize)
+	_, err = cipher.NewFile(file).ReadAt(plaintext, 0)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), objectContent, plaintext)
+}
@@ -46,6 +46,7 @@ func (job *Job) downloadRange(ctx context.Context, dstWriter io.Writer, start, e
 			},
 			ReadCompressed: job.object.HasContentEncodingGzip(),
 			ReadHandle:     readHandle,
+			Object:         job.object,
 		})
 	if err != nil {
 		err = fmt.Errorf("downloadRange: error in creating NewReader with start %d and limit %d: %w", start, end, err)
@@ -65,7 +66,7 @@ func (job *Job) downloadRange(ctx context.Context, dstWriter io.Writer, start, e
 
 	// Use standard copy function if O_DIRECT is disabled and memory aligned
 	// buffer otherwise.
-	if !job.fileCacheConfig.EnableODirect {
+	if !job.useODirect() {
 		_, err = io.CopyN(dstWriter, newReader, end-start)
 	} else {
 		_, err = cacheutil.CopyUsingMemoryAlignedBuffer(ctx, newReader, dstWriter, end-start,
@@ -138,7 +139,6 @@ func (job *Job) downloadOffsets(ctx context.Context, goroutineIndex int64, cache
 			defer job.maxParallelismSem.Release(1)
 		}
 		var readHandle []byte
-		var err error
 
 		for {
 			// Read the offset to be downloaded from the channel.
@@ -148,11 +148,17 @@ func (job *Job) downloadOffsets(ctx context.Context, goroutineIndex int64, cache
 				return nil
 			}
 
-			offsetWriter := io.NewOffsetWriter(cacheFile, int64(objectRange.Start))
+			offsetWriter, flush, err := job.newCacheFileWriter(cacheFile, objectRange.Start)
+			if err != nil {
+				return err
+			}
 			readHandle, err = job.downloadRange(ctx, offsetWriter, objectRange.Start, objectRange.End, readHandle)
 			if err != nil {
 				return err
 			}
+			if err = flush(); err != nil {
+				return err
+			}
 
 			err = job.updateRangeMap(rangeMap, objectRange.Start, objectRange.End)
 			if err != nil {
@@ -81,13 +81,13 @@ func (t *ParallelDownloaderJobTestifyTest) Test_ParallelDownloadObjectToFile_New
 	rc4 := io.NopCloser(strings.NewReader(string(objectContent[9*util.MiB : 10*util.MiB])))
 	rd4 := &fake.FakeReader{ReadCloser: rc4, Handle: handle}
 	t.mockBucket.On("Name").Return(storage.TestBucketName)
-	readObjectReq := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 0, Limit: 3 * util.MiB}, ReadHandle: nil}
+	readObjectReq := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 0, Limit: 3 * util.MiB}, ReadHandle: nil, Object: t.job.object}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, &readObjectReq).Return(rd1, nil).Times(1)
-	readObjectReq2 := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 3 * util.MiB, Limit: 6 * util.MiB}, ReadHandle: nil}
+	readObjectReq2 := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 3 * util.MiB, Limit: 6 * util.MiB}, ReadHandle: nil, Object: t.job.object}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, &readObjectReq2).Return(rd2, nil).Times(1)
-	readObjectReq3 := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 6 * util.MiB, Limit: 9 * util.MiB}, ReadHandle: nil}
+	readObjectReq3 := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 6 * util.MiB, Limit: 9 * util.MiB}, ReadHandle: nil, Object: t.job.object}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, &readObjectReq3).Return(rd3, nil).Times(1)
-	readObjectReq4 := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 9 * util.MiB, Limit: 10 * util.MiB}, ReadHandle: handle}
+	readObjectReq4 := gcs.ReadObjectRequest{Name: objectName, Range: &gcs.ByteRange{Start: 9 * util.MiB, Limit: 10 * util.MiB}, ReadHandle: handle, Object: t.job.object}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, &readObjectReq4).Return(rd4, nil).Times(1)
 
 	// Start download
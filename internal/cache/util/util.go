@@ -134,7 +134,9 @@ func CreateCacheDirectoryIfNotPresentAt(dirPath string, dirPerm os.FileMode) err
 	return nil
 }
 
-func calculateCRC32(ctx context.Context, reader io.Reader) (uint32, error) {
+// CalculateCRC32 calculates and returns the CRC-32 checksum of the content of
+// the given reader.
+func CalculateCRC32(ctx context.Context, reader io.Reader) (uint32, error) {
 	table := crc32.MakeTable(crc32.Castagnoli)
 	checksum := crc32.Checksum([]byte(""), table)
 	buf := make([]byte, BufferSizeForCRC)
@@ -164,7 +166,7 @@ func CalculateFileCRC32(ctx context.Context, filePath string) (uint32, error) {
 	}
 	defer file.Close() // Ensure file closure
 
-	return calculateCRC32(ctx, file)
+	return CalculateCRC32(ctx, file)
 }
 
 // TruncateAndRemoveFile first truncates the file to 0 and then remove (delete)
@@ -32,6 +32,8 @@ import (
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/encryption"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/file/downloader"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
@@ -40,6 +42,7 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/handle"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/inode"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/journal"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/locker"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
@@ -165,11 +168,24 @@ func NewFileSystem(ctx context.Context, serverCfg *ServerConfig) (fuseutil.FileS
 		}
 	}
 
+	// Keep the contents of dirty files in the write journal if enabled, and
+	// recover the writes left behind in it as the buckets are set up.
+	bucketManager := serverCfg.BucketManager
+	var writeJournal *journal.Journal
+	if serverCfg.NewConfig.Write.JournalDir != "" {
+		var err error
+		writeJournal, err = journal.New(string(serverCfg.NewConfig.Write.JournalDir), mtimeClock)
+		if err != nil {
+			return nil, fmt.Errorf("journal.New: %w", err)
+		}
+		bucketManager = writeJournal.WrapBucketManager(bucketManager)
+	}
+
 	// Set up the basic struct.
 	fs := &fileSystem{
 		mtimeClock:                 mtimeClock,
 		cacheClock:                 serverCfg.CacheClock,
-		bucketManager:              serverCfg.BucketManager,
+		bucketManager:              bucketManager,
 		localFileCache:             serverCfg.LocalFileCache,
 		contentCache:               contentCache,
 		implicitDirs:               serverCfg.ImplicitDirectories,
@@ -190,12 +206,16 @@ func NewFileSystem(ctx context.Context, serverCfg *ServerConfig) (fuseutil.FileS
 		folderInodes:               make(map[inode.Name]inode.DirInode),
 		localFileInodes:            make(map[inode.Name]inode.Inode),
 		handles:                    make(map[fuseops.HandleID]interface{}),
+		versionsBuckets:            make(map[string]*gcsx.SyncerBucket),
 		newConfig:                  serverCfg.NewConfig,
 		fileCacheHandler:           fileCacheHandler,
 		cacheFileForRangeRead:      serverCfg.NewConfig.FileCache.CacheFileForRangeRead,
 		metricHandle:               serverCfg.MetricHandle,
 		enableAtomicRenameObject:   serverCfg.NewConfig.EnableAtomicRenameObject,
 		globalMaxWriteBlocksSem:    semaphore.NewWeighted(serverCfg.NewConfig.Write.GlobalMaxBlocks),
+		writeBlockSpillConfig:      createWriteBlockSpillConfig(&serverCfg.NewConfig.Write),
+		writeJournal:               writeJournal,
+		writeBack:                  newWriteBackUploader(&serverCfg.NewConfig.Write, serverCfg.MetricHandle),
 	}
 
 	// Set up root bucket
@@ -248,8 +268,20 @@ func createFileCacheHandler(serverCfg *ServerConfig) (fileCacheHandler *file.Cac
 		return nil, fmt.Errorf("createFileCacheHandler: while creating file cache directory: %w", cacheDirErr)
 	}
 
-	jobManager := downloader.NewJobManager(fileInfoCache, filePerm, dirPerm, cacheDir, serverCfg.SequentialReadSizeMb, &serverCfg.NewConfig.FileCache, serverCfg.MetricHandle)
-	fileCacheHandler = file.NewCacheHandler(fileInfoCache, jobManager, cacheDir, filePerm, dirPerm)
+	var cipher *encryption.Cipher
+	if serverCfg.NewConfig.FileCache.EnableEncryption {
+		if keyFile := string(serverCfg.NewConfig.FileCache.EncryptionKeyFile); keyFile != "" {
+			cipher, err = encryption.NewCipherFromKeyFile(keyFile)
+		} else {
+			cipher, err = encryption.NewEphemeralCipher()
+		}
+		if err != nil {
+			return nil, fmt.Errorf("createFileCacheHandler: while creating file cache cipher: %w", err)
+		}
+	}
+
+	jobManager := downloader.NewJobManager(fileInfoCache, filePerm, dirPerm, cacheDir, serverCfg.SequentialReadSizeMb, &serverCfg.NewConfig.FileCache, serverCfg.MetricHandle, cipher)
+	fileCacheHandler = file.NewCacheHandler(fileInfoCache, jobManager, cacheDir, filePerm, dirPerm, cipher)
 	return
 }
 
@@ -474,6 +506,13 @@ type fileSystem struct {
 	// GUARDED_BY(mu)
 	nextHandleID fuseops.HandleID
 
+	// The read-only views exposing the generations of the objects of each
+	// bucket, keyed by bucket name, created on the first lookup of their
+	// versions directories.
+	//
+	// GUARDED_BY(mu)
+	versionsBuckets map[string]*gcsx.SyncerBucket
+
 	// newConfig specified by the user using config-file flag and CLI flags.
 	newConfig *cfg.Config
 
@@ -492,12 +531,36 @@ type fileSystem struct {
 	// Limits the max number of blocks that can be created across file system when
 	// streaming writes are enabled.
 	globalMaxWriteBlocksSem *semaphore.Weighted
+
+	// Config to create the streaming write blocks on disk beyond the memory
+	// budget. Nil if blocks are always created in memory.
+	writeBlockSpillConfig *block.SpillConfig
+
+	// Journal keeping the contents of dirty files until they are synced. Nil if
+	// disabled.
+	writeJournal *journal.Journal
+
+	// Uploads the files closed in write-back mode in the background. Nil if
+	// write-back mode is disabled.
+	writeBack *writeBackUploader
 }
 
 ////////////////////////////////////////////////////////////////////////
 // Helpers
 ////////////////////////////////////////////////////////////////////////
 
+// createWriteBlockSpillConfig returns the config to spill the streaming write
+// blocks to disk, or nil if spill-dir isn't configured.
+func createWriteBlockSpillConfig(wc *cfg.WriteConfig) *block.SpillConfig {
+	if wc.SpillDir == "" {
+		return nil
+	}
+	return &block.SpillConfig{
+		Dir:             string(wc.SpillDir),
+		MemoryBudgetSem: semaphore.NewWeighted(wc.MemoryBudgetMb * util.MiB),
+	}
+}
+
 func (fs *fileSystem) checkInvariantsForLocalFileInodes() {
 	// INVARIANT: For each k/v, v.Name() == k
 	for k, v := range fs.localFileInodes {
@@ -813,7 +876,10 @@ func (fs *fileSystem) mintInode(ic inode.Core) (in inode.Inode) {
 			fs.mtimeClock,
 			ic.Local,
 			fs.newConfig,
-			fs.globalMaxWriteBlocksSem)
+			fs.globalMaxWriteBlocksSem,
+			fs.writeBlockSpillConfig,
+			fs.writeJournal,
+			fs.metricHandle)
 	}
 
 	// Place it in our map of IDs to inodes.
@@ -994,6 +1060,10 @@ func (fs *fileSystem) lookUpOrCreateChildInode(
 	// Set up a function that will find a lookup result for the child with the
 	// given name. Expects no locks to be held.
 	getLookupResult := func() (*inode.Core, error) {
+		if core := fs.versionsDirCore(parent, childName); core != nil {
+			return core, nil
+		}
+
 		if fs.newConfig.FileSystem.DisableParallelDirops {
 			parent.Lock()
 			defer parent.Unlock()
@@ -1033,6 +1103,45 @@ func (fs *fileSystem) lookUpOrCreateChildInode(
 	return
 }
 
+// The name of the hidden directory in the root of a bucket which exposes all
+// the generations of its objects, if enabled.
+const versionsDirName = ".versions"
+
+// Return the core of the versions directory if it is the given child of
+// parent, or nil otherwise. The directory is the root of a read-only view on
+// the bucket of parent, shared by all the lookups of the mount.
+//
+// LOCKS_EXCLUDED(fs.mu)
+func (fs *fileSystem) versionsDirCore(parent inode.DirInode, childName string) *inode.Core {
+	if !fs.newConfig.FileSystem.EnableVersionsDir ||
+		childName != versionsDirName ||
+		!parent.Name().IsBucketRoot() {
+		return nil
+	}
+
+	// The root of all buckets doesn't belong to a bucket.
+	owned, ok := parent.(inode.BucketOwnedInode)
+	if !ok {
+		return nil
+	}
+
+	name := inode.NewDirName(parent.Name(), versionsDirName)
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+	bucket, ok := fs.versionsBuckets[owned.Bucket().Name()]
+	if !ok {
+		// Writes to the view fail, so the syncer config doesn't matter.
+		b := gcsx.NewSyncerBucket(1, 0, "", nil, gcsx.NewVersionsBucket(owned.Bucket().Bucket, name.GcsObjectName()))
+		bucket = &b
+		fs.versionsBuckets[owned.Bucket().Name()] = bucket
+	}
+
+	return &inode.Core{
+		FullName: name,
+		Bucket:   bucket,
+	}
+}
+
 // Look up the localFileInodes to check if a file with given name exists.
 // Return inode if it exists, else return nil.
 // LOCKS_EXCLUDED(fs.mu)
@@ -1181,6 +1290,113 @@ func (fs *fileSystem) flushFile(
 	return nil
 }
 
+// Schedules the flush of the supplied file inode in the background if its
+// contents are staged locally and fit in the write-back staging budget,
+// blocking while the budget is full. scheduled is false if the inode must be
+// flushed synchronously instead, in which case f is still locked on return.
+//
+// LOCKS_EXCLUDED(fs.mu)
+// LOCKS_REQUIRED(f)
+// UNLOCK_FUNCTION(f) if scheduled or err != nil
+func (fs *fileSystem) flushFileInBackground(
+	ctx context.Context,
+	f *inode.FileInode) (scheduled bool, err error) {
+	if f.IsUnlinked() {
+		return
+	}
+	size, ok, err := f.StagedContentSize()
+	if err != nil {
+		err = fmt.Errorf("StagedContentSize: %w", err)
+		f.Unlock()
+		return
+	}
+	if !ok || !fs.writeBack.CanStage(size) {
+		return
+	}
+	// The contents are only staged once close returns.
+	if err = f.PersistStagedContent(); err != nil {
+		f.Unlock()
+		return
+	}
+
+	// Keep the inode, and hence its contents, alive until the upload is done.
+	f.IncrementLookupCount()
+	f.Unlock()
+	scheduled, err = fs.writeBack.Schedule(ctx, f.ID(), size, func() {
+		f.Lock()
+		fs.writeBack.Start(f.ID())
+		err := fs.writeBackFile(context.Background(), f)
+		if err != nil {
+			logger.Errorf("Write-back of %q failed, keeping its contents staged until the next sync: %v", f.Name(), err)
+		}
+		fs.unlockAndDecrementLookupCount(f, 1+fs.recordWriteBack(f, err))
+	})
+	if !scheduled {
+		// Either an upload is already pending, which will pick up the contents,
+		// or scheduling failed.
+		f.Lock()
+		fs.unlockAndDecrementLookupCount(f, 1)
+		scheduled = err == nil
+	}
+	return
+}
+
+// Uploads the contents of the supplied file inode closed in write-back mode,
+// updating the index as appropriate. Unlike flushFile, the inode stays in the
+// index if that fails, so that its staged contents can still be found.
+//
+// LOCKS_EXCLUDED(fs.mu)
+// LOCKS_REQUIRED(f)
+func (fs *fileSystem) writeBackFile(
+	ctx context.Context,
+	f *inode.FileInode) error {
+	if f.IsUnlinked() {
+		return nil
+	}
+
+	if err := f.Flush(ctx); err != nil {
+		return fmt.Errorf("FileInode.Flush: %w", err)
+	}
+	fs.promoteToGenerationBacked(f)
+	return nil
+}
+
+// Records the result of an upload of the supplied file inode in write-back
+// mode. While its last upload failed, the inode, and hence its staged
+// contents, is kept alive by a lookup count, released once an upload
+// succeeds. Returns the number of lookup counts to release.
+//
+// LOCKS_REQUIRED(f)
+func (fs *fileSystem) recordWriteBack(f *inode.FileInode, err error) (release uint64) {
+	if err != nil {
+		if fs.writeBack.Fail(f.ID(), err) {
+			f.IncrementLookupCount()
+		}
+		return 0
+	}
+	if fs.writeBack.Succeed(f.ID()) != nil {
+		return 1
+	}
+	return 0
+}
+
+// Uploads the contents of the supplied file inode, whose last upload in
+// write-back mode failed, synchronously so that a failure is reported.
+//
+// LOCKS_EXCLUDED(fs.mu)
+// LOCKS_REQUIRED(f)
+// UNLOCK_FUNCTION(f)
+func (fs *fileSystem) retryWriteBack(
+	ctx context.Context,
+	f *inode.FileInode) error {
+	err := fs.writeBackFile(ctx, f)
+	if err != nil {
+		err = fmt.Errorf("write-back: %w", err)
+	}
+	fs.unlockAndDecrementLookupCount(f, fs.recordWriteBack(f, err))
+	return err
+}
+
 // Synchronizes the supplied file inode to GCS, updating the index as
 // appropriate.
 //
@@ -1405,7 +1621,25 @@ func (fs *fileSystem) invalidateChildFileCacheIfExist(parentInode inode.DirInode
 ////////////////////////////////////////////////////////////////////////
 
 func (fs *fileSystem) Destroy() {
+	if fs.writeBack != nil {
+		logger.Info("Waiting for the pending write-back uploads")
+		fs.writeBack.Wait()
+		for _, id := range fs.writeBack.Failed() {
+			fs.mu.Lock()
+			in := fs.inodes[id].(*inode.FileInode)
+			fs.mu.Unlock()
+			in.Lock()
+			if err := fs.retryWriteBack(context.Background(), in); err != nil {
+				logger.Errorf("Failed to upload %q on unmount, its writes are lost: %v", in.Name(), err)
+			}
+		}
+	}
 	fs.bucketManager.ShutDown()
+	if fs.writeJournal != nil {
+		if err := fs.writeJournal.Close(); err != nil {
+			logger.Warnf("Failed to release the write journal: %v", err)
+		}
+	}
 	if fs.fileCacheHandler != nil {
 		_ = fs.fileCacheHandler.Destroy()
 	}
@@ -2076,7 +2310,7 @@ func (fs *fileSystem) flushPendingWrites(ctx context.Context, child *inode.Core)
 	// We will return modified minObject if flush is done, otherwise the original
 	// minObject is returned. Original minObject is the one passed in the request.
 	minObject = child.MinObject
-	if !fs.newConfig.Write.EnableStreamingWrites {
+	if !fs.newConfig.Write.EnableStreamingWrites && fs.writeBack == nil {
 		return
 	}
 
@@ -2651,6 +2885,9 @@ func (fs *fileSystem) SyncFile(
 	}
 
 	file.Lock()
+	if fs.writeBack != nil && fs.writeBack.Err(file.ID()) != nil {
+		return fs.retryWriteBack(ctx, file)
+	}
 	defer file.Unlock()
 
 	// Sync it.
@@ -2678,6 +2915,15 @@ func (fs *fileSystem) FlushFile(
 	fs.mu.Unlock()
 
 	in.Lock()
+	if fs.writeBack != nil {
+		if fs.writeBack.Err(in.ID()) != nil {
+			return fs.retryWriteBack(ctx, in)
+		}
+		scheduled, err := fs.flushFileInBackground(ctx, in)
+		if scheduled || err != nil {
+			return err
+		}
+	}
 	defer in.Unlock()
 
 	// Sync it.
@@ -378,6 +378,7 @@ func (bm *fakeBucketManager) SetUpBucket(
 			bm.appendThreshold,
 			bm.chunkTransferTimeoutSecs,
 			bm.tmpObjectPrefix,
+			nil,
 			gcsx.NewContentTypeBucket(bucket),
 		)
 		return
@@ -64,12 +64,14 @@ func (t *BaseDirTest) SetUp(ti *TestInfo) {
 		1, // Append threshold
 		ChunkTransferTimeoutSecs,
 		".gcsfuse_tmp/",
+		nil,
 		fake.NewFakeBucket(&t.clock, "bucketA", gcs.BucketType{}),
 	)
 	t.bm.buckets["bucketB"] = gcsx.NewSyncerBucket(
 		1, // Append threshold
 		ChunkTransferTimeoutSecs,
 		".gcsfuse_tmp/",
+		nil,
 		fake.NewFakeBucket(&t.clock, "bucketB", gcs.BucketType{}),
 	)
 
@@ -49,7 +49,7 @@ func init() { RegisterTestSuite(&CoreTest{}) }
 func (t *CoreTest) SetUp(ti *TestInfo) {
 	t.ctx = ti.Ctx
 	t.bucket = gcsx.NewSyncerBucket(
-		1, 10, ".gcsfuse_tmp/", fake.NewFakeBucket(&t.clock, "some_bucket", gcs.BucketType{}))
+		1, 10, ".gcsfuse_tmp/", nil, fake.NewFakeBucket(&t.clock, "some_bucket", gcs.BucketType{}))
 	t.clock.SetTime(time.Date(2012, 8, 15, 22, 56, 0, 0, time.Local))
 }
 
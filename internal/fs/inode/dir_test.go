@@ -24,6 +24,7 @@ import (
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/metadata"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
@@ -74,6 +75,7 @@ func (t *DirTest) SetUp(ti *TestInfo) {
 		1, // Append threshold
 		ChunkTransferTimeoutSecs,
 		".gcsfuse_tmp/",
+		nil,
 		bucket)
 	// Create the inode. No implicit dirs by default.
 	t.resetInode(false, false, true)
@@ -208,7 +210,10 @@ func (t *DirTest) createLocalFileInode(parent Name, name string, id fuseops.Inod
 		&t.clock,
 		true, //localFile
 		&cfg.Config{},
-		semaphore.NewWeighted(math.MaxInt64))
+		semaphore.NewWeighted(math.MaxInt64),
+		nil,
+		nil,
+		common.NewNoopMetrics())
 	return
 }
 
@@ -18,15 +18,19 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/block"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufferedwrites"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/journal"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
@@ -116,6 +120,15 @@ type FileInode struct {
 	// Limits the max number of blocks that can be created across file system when
 	// streaming writes are enabled.
 	globalMaxWriteBlocksSem *semaphore.Weighted
+
+	// Config to create the streaming write blocks on disk beyond the memory
+	// budget. Nil if blocks are always created in memory.
+	writeBlockSpillConfig *block.SpillConfig
+
+	// Journal keeping the content until it is synced. Nil if disabled.
+	writeJournal *journal.Journal
+
+	metricHandle common.MetricHandle
 }
 
 var _ Inode = &FileInode{}
@@ -139,7 +152,10 @@ func NewFileInode(
 	mtimeClock timeutil.Clock,
 	localFile bool,
 	cfg *cfg.Config,
-	globalMaxBlocksSem *semaphore.Weighted) (f *FileInode) {
+	globalMaxBlocksSem *semaphore.Weighted,
+	writeBlockSpillConfig *block.SpillConfig,
+	writeJournal *journal.Journal,
+	metricHandle common.MetricHandle) (f *FileInode) {
 	// Set up the basic struct.
 	var minObj gcs.MinObject
 	if m != nil {
@@ -158,6 +174,9 @@ func NewFileInode(
 		unlinked:                false,
 		config:                  cfg,
 		globalMaxWriteBlocksSem: globalMaxBlocksSem,
+		writeBlockSpillConfig:   writeBlockSpillConfig,
+		writeJournal:            writeJournal,
+		metricHandle:            metricHandle,
 	}
 	var err error
 	f.MRDWrapper, err = gcsx.NewMultiRangeDownloaderWrapper(bucket, &f.src)
@@ -253,6 +272,7 @@ func (f *FileInode) openReader(ctx context.Context) (io.ReadCloser, error) {
 			Name:           f.src.Name,
 			Generation:     f.src.Generation,
 			ReadCompressed: f.src.HasContentEncodingGzip(),
+			Object:         &f.src,
 		})
 	// If the object with requested generation doesn't exist in GCS, it indicates
 	// a file clobbering scenario. This likely occurred because the file was
@@ -311,7 +331,7 @@ func (f *FileInode) ensureContent(ctx context.Context) (err error) {
 			return err
 		}
 
-		tf, err := f.contentCache.NewTempFile(rc)
+		tf, err := f.newTempFile(rc)
 		if err != nil {
 			err = fmt.Errorf("NewTempFile: %w", err)
 			return err
@@ -323,6 +343,22 @@ func (f *FileInode) ensureContent(ctx context.Context) (err error) {
 	return
 }
 
+// newTempFile creates the temp file holding the content, in the write journal
+// if enabled.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) newTempFile(rc io.ReadCloser) (gcsx.TempFile, error) {
+	if f.writeJournal == nil {
+		return f.contentCache.NewTempFile(rc)
+	}
+	return f.writeJournal.NewTempFile(rc, journal.Manifest{
+		BucketName:     f.bucket.Name(),
+		ObjectName:     f.name.GcsObjectName(),
+		Generation:     f.src.Generation,
+		MetaGeneration: f.src.MetaGeneration,
+	})
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Public interface
 ////////////////////////////////////////////////////////////////////////
@@ -526,9 +562,10 @@ func (f *FileInode) Read(
 	ctx context.Context,
 	dst []byte,
 	offset int64) (n int, err error) {
-	// It is not nil when streaming writes are enabled in 2 scenarios:
+	// It is not nil when streaming writes are enabled in 3 scenarios:
 	// 1. Local file
 	// 2. Empty GCS files and writes are triggered via buffered flow.
+	// 3. Unfinalized objects of zonal buckets being appended to.
 	if f.bwh != nil {
 		err = fmt.Errorf("cannot read a file when upload in progress")
 		return
@@ -568,6 +605,10 @@ func (f *FileInode) Write(
 		return err
 	}
 
+	if f.bwh == nil && offset == int64(f.src.Size) {
+		f.initAppendingBufferedWriteHandlerIfEligible(ctx)
+	}
+
 	if f.bwh != nil {
 		return f.writeUsingBufferedWrites(ctx, data, offset)
 	}
@@ -782,10 +823,18 @@ func (f *FileInode) Sync(ctx context.Context) (gcsSynced bool, err error) {
 //
 // LOCKS_REQUIRED(f.mu)
 func (f *FileInode) syncUsingContent(ctx context.Context) (err error) {
-	latestGcsObj, err := f.fetchLatestGcsObject(ctx)
+	// Make sure the contents can be recovered if the sync doesn't succeed.
+	if err = f.PersistStagedContent(); err != nil {
+		return
+	}
+
+	latestGcsObj, isClobbered, err := f.clobbered(ctx, true, true)
 	if err != nil {
 		return
 	}
+	if isClobbered {
+		return f.syncClobbered(ctx, latestGcsObj, errors.New("file was clobbered"))
+	}
 
 	// Write out the contents if they are dirty.
 	// Object properties are also synced as part of content sync. Hence, passing
@@ -794,10 +843,17 @@ func (f *FileInode) syncUsingContent(ctx context.Context) (err error) {
 
 	var preconditionErr *gcs.PreconditionError
 	if errors.As(err, &preconditionErr) {
-		err = &gcsfuse_errors.FileClobberedError{
-			Err: fmt.Errorf("SyncObject: %w", err),
+		// The object was modified after we fetched it.
+		err = fmt.Errorf("SyncObject: %w", err)
+		if f.config.Write.ConflictPolicy == cfg.ConflictPolicyFail {
+			return f.syncClobbered(ctx, nil, err)
 		}
-		return
+		var clobberedErr error
+		latestGcsObj, _, clobberedErr = f.clobbered(ctx, true, true)
+		if clobberedErr != nil {
+			return clobberedErr
+		}
+		return f.syncClobbered(ctx, latestGcsObj, err)
 	}
 
 	// Propagate other errors.
@@ -811,6 +867,88 @@ func (f *FileInode) syncUsingContent(ctx context.Context) (err error) {
 	return
 }
 
+// syncClobbered syncs the content of an inode whose object was modified or
+// deleted concurrently, as per the configured conflict policy. latestGcsObj is
+// the current object, or nil if it was deleted. cause describes the conflict.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) syncClobbered(ctx context.Context, latestGcsObj *gcs.Object, cause error) (err error) {
+	policy := f.config.Write.ConflictPolicy
+	objectName := f.Name().GcsObjectName()
+	f.metricHandle.WriteConflictCount(ctx, 1, []common.MetricAttr{{Key: common.ConflictPolicy, Value: policy}})
+
+	switch policy {
+	case cfg.ConflictPolicyLastWriterWins:
+		logger.Warnf("Object %q was modified concurrently, overwriting it with the local contents: %v", objectName, cause)
+		var o *gcs.Object
+		o, err = f.createObjectFromContent(ctx, objectName, latestGcsObj)
+		var preconditionErr *gcs.PreconditionError
+		if errors.As(err, &preconditionErr) {
+			err = &gcsfuse_errors.FileClobberedError{
+				Err: fmt.Errorf("CreateObject: %w", err),
+			}
+			return
+		}
+		if err != nil {
+			err = fmt.Errorf("CreateObject: %w", err)
+			return
+		}
+		f.updateInodeStateAfterSync(storageutil.ConvertObjToMinObject(o))
+
+	case cfg.ConflictPolicyConflictCopy:
+		copyName := conflictCopyName(objectName, f.mtimeClock.Now())
+		logger.Warnf("Object %q was modified concurrently, writing the local contents to %q: %v", objectName, copyName, cause)
+		if _, err = f.createObjectFromContent(ctx, copyName, nil); err != nil {
+			err = fmt.Errorf("CreateObject(%q): %w", copyName, err)
+			return
+		}
+		// The local contents are safe in the copy, so the object is authoritative
+		// again. If it was deleted, so is the file, whose contents stay readable
+		// through the open handles but are no longer synced.
+		if latestGcsObj != nil {
+			f.updateInodeStateAfterSync(storageutil.ConvertObjToMinObject(latestGcsObj))
+		} else {
+			f.Unlink()
+		}
+
+	default:
+		logger.Warnf("Object %q was modified concurrently, failing the sync: %v", objectName, cause)
+		err = &gcsfuse_errors.FileClobberedError{
+			Err: cause,
+		}
+	}
+	return
+}
+
+// createObjectFromContent writes out the entire content to the object with the
+// given name, with srcObject as the precondition and the source of the object
+// properties. srcObject is nil to create a new object.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) createObjectFromContent(ctx context.Context, name string, srcObject *gcs.Object) (*gcs.Object, error) {
+	sr, err := f.content.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("stat: %w", err)
+	}
+	if _, err = f.content.Seek(0, 0); err != nil {
+		return nil, fmt.Errorf("seek: %w", err)
+	}
+
+	req := gcs.NewCreateObjectRequest(srcObject, name, sr.Mtime, f.config.GcsRetries.ChunkTransferTimeoutSecs)
+	req.Contents = f.content
+	return f.bucket.CreateObject(ctx, req)
+}
+
+// conflictCopyName returns the name of the object holding the local contents of
+// the given object on a conflict at the given time.
+func conflictCopyName(objectName string, t time.Time) string {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return fmt.Sprintf("%s.conflict-%s-%s", objectName, host, t.UTC().Format("20060102T150405.000Z"))
+}
+
 // Flush writes out contents to GCS. If this fails due to the generation
 // having been clobbered, failure is propagated back to the calling
 // function as an error.
@@ -834,6 +972,39 @@ func (f *FileInode) Flush(ctx context.Context) (err error) {
 	return f.syncUsingContent(ctx)
 }
 
+// PersistStagedContent makes the contents staged in the write journal, if
+// enabled, durable so that they survive a restart of the machine.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) PersistStagedContent() error {
+	if f.writeJournal == nil || f.content == nil {
+		return nil
+	}
+	if err := f.writeJournal.Sync(f.content); err != nil {
+		return fmt.Errorf("journal.Sync: %w", err)
+	}
+	return nil
+}
+
+// StagedContentSize returns the size of the dirty contents held in a local
+// temp file awaiting upload, i.e. those which can be flushed at a later time
+// without losing writes. ok is false if there are no such contents, including
+// when the writes are streamed to GCS.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) StagedContentSize() (size int64, ok bool, err error) {
+	if f.content == nil || f.bwh != nil {
+		return
+	}
+
+	sr, err := f.content.Stat()
+	if err != nil {
+		err = fmt.Errorf("stat: %w", err)
+		return
+	}
+	return sr.Size, true, nil
+}
+
 func (f *FileInode) updateInodeStateAfterSync(minObj *gcs.MinObject) {
 	if minObj != nil && !f.localFileCache {
 		f.src = *minObj
@@ -914,7 +1085,7 @@ func (f *FileInode) CreateBufferedOrTempWriter(ctx context.Context) (err error)
 
 	// Creating a file with no contents. The contents will be updated with
 	// writeFile operations.
-	f.content, err = f.contentCache.NewTempFile(io.NopCloser(strings.NewReader("")))
+	f.content, err = f.newTempFile(io.NopCloser(strings.NewReader("")))
 	// Setting the initial mtime to creation time.
 	f.content.SetMtime(f.mtimeClock.Now())
 	return
@@ -927,6 +1098,8 @@ func (f *FileInode) initBufferedWriteHandlerIfEligible(ctx context.Context) erro
 	}
 
 	tempFileInUse := f.content != nil
+	// Appends to non-empty objects are handled by
+	// initAppendingBufferedWriteHandlerIfEligible.
 	if f.src.Size != 0 || !f.config.Write.EnableStreamingWrites || tempFileInUse {
 		// bwh should not be initialized under these conditions.
 		return nil
@@ -948,8 +1121,10 @@ func (f *FileInode) initBufferedWriteHandlerIfEligible(ctx context.Context) erro
 			Bucket:                   f.bucket,
 			BlockSize:                f.config.Write.BlockSizeMb,
 			MaxBlocksPerFile:         f.config.Write.MaxBlocksPerFile,
+			MaxPendingBlocks:         f.config.Write.MaxPendingBlocksPerFile,
 			GlobalMaxBlocksSem:       f.globalMaxWriteBlocksSem,
 			ChunkTransferTimeoutSecs: f.config.GcsRetries.ChunkTransferTimeoutSecs,
+			SpillConfig:              f.writeBlockSpillConfig,
 		})
 		if err != nil {
 			return fmt.Errorf("failed to create bufferedWriteHandler: %w", err)
@@ -959,3 +1134,43 @@ func (f *FileInode) initBufferedWriteHandlerIfEligible(ctx context.Context) erro
 
 	return nil
 }
+
+// In zonal buckets, appends to an unfinalized object are streamed by taking
+// over its upload from its current size, rather than downloading it to a temp
+// file. If the object can't be taken over, e.g. because it was finalized or
+// has grown, the temp file is used as usual.
+//
+// LOCKS_REQUIRED(f.mu)
+func (f *FileInode) initAppendingBufferedWriteHandlerIfEligible(ctx context.Context) {
+	if f.src.Size == 0 || f.local || f.content != nil ||
+		!f.config.Write.EnableStreamingWrites || !f.bucket.BucketType().Zonal {
+		return
+	}
+
+	latestGcsObj, err := f.fetchLatestGcsObject(ctx)
+	// Growing an unfinalized object changes neither its generation nor its
+	// metageneration, so it isn't seen as clobbered.
+	if err == nil && latestGcsObj.Size != f.src.Size {
+		err = fmt.Errorf("object has grown from %d to %d bytes", f.src.Size, latestGcsObj.Size)
+	}
+	if err == nil {
+		f.bwh, err = bufferedwrites.NewBWHandler(&bufferedwrites.CreateBWHandlerRequest{
+			Object:                   latestGcsObj,
+			ObjectName:               f.name.GcsObjectName(),
+			Bucket:                   f.bucket,
+			BlockSize:                f.config.Write.BlockSizeMb,
+			MaxBlocksPerFile:         f.config.Write.MaxBlocksPerFile,
+			MaxPendingBlocks:         f.config.Write.MaxPendingBlocksPerFile,
+			GlobalMaxBlocksSem:       f.globalMaxWriteBlocksSem,
+			ChunkTransferTimeoutSecs: f.config.GcsRetries.ChunkTransferTimeoutSecs,
+			SpillConfig:              f.writeBlockSpillConfig,
+			Append:                   true,
+		})
+	}
+	if err != nil {
+		f.bwh = nil
+		logger.Infof("Appending to %q using a temporary file: %v", f.name.GcsObjectName(), err)
+		return
+	}
+	f.bwh.SetMtime(f.mtimeClock.Now())
+}
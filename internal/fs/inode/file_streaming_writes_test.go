@@ -22,6 +22,7 @@ import (
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/bufferedwrites"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
@@ -87,6 +88,7 @@ func (t *FileStreamingWritesTest) createInode(fileName string, fileType string)
 		1, // Append threshold
 		ChunkTransferTimeoutSecs,
 		".gcsfuse_tmp/",
+		nil,
 		t.bucket)
 
 	isLocal := false
@@ -121,14 +123,18 @@ func (t *FileStreamingWritesTest) createInode(fileName string, fileType string)
 		&t.clock,
 		isLocal,
 		&cfg.Config{},
-		semaphore.NewWeighted(math.MaxInt64))
+		semaphore.NewWeighted(math.MaxInt64),
+		nil,
+		nil,
+		common.NewNoopMetrics())
 
 	// Set buffered write config for created inode.
 	t.in.config = &cfg.Config{Write: cfg.WriteConfig{
-		MaxBlocksPerFile:      5,
-		BlockSizeMb:           1,
-		EnableStreamingWrites: true,
-		GlobalMaxBlocks:       10,
+		MaxBlocksPerFile:        5,
+		MaxPendingBlocksPerFile: 1,
+		BlockSizeMb:             1,
+		EnableStreamingWrites:   true,
+		GlobalMaxBlocks:         10,
 	}}
 
 	// Create write handler for the local inode created above.
@@ -205,6 +211,30 @@ func (t *FileStreamingWritesTest) TestOutOfOrderWritesToLocalFileFallBackToTempF
 	}
 }
 
+func (t *FileStreamingWritesTest) TestOutOfOrderWriteWithinBufferedDataDoesNotFallBackToTempFile() {
+	// Recreate the bwh with blocks large enough to hold all the data written.
+	t.in.bwh = nil
+	t.in.config.Write.BlockSizeMb = 1024
+	err := t.in.CreateBufferedOrTempWriter(t.ctx)
+	require.Nil(t.T(), err)
+	err = t.in.Write(t.ctx, []byte("taco"), 0)
+	require.Nil(t.T(), err)
+
+	err = t.in.Write(t.ctx, []byte("hello"), 2)
+
+	require.Nil(t.T(), err)
+	assert.NotNil(t.T(), t.in.bwh)
+	assert.Nil(t.T(), t.in.content)
+	attrs, err := t.in.Attributes(t.ctx)
+	require.Nil(t.T(), err)
+	assert.Equal(t.T(), uint64(len("tahello")), attrs.Size)
+	err = t.in.Flush(t.ctx)
+	require.Nil(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	assert.Nil(t.T(), err)
+	assert.Equal(t.T(), "tahello", string(contents))
+}
+
 func (t *FileStreamingWritesTest) TestOutOfOrderWriteFollowedByOrderedWrite() {
 	assert.True(t.T(), t.in.IsLocal())
 	createTime := t.in.mtimeClock.Now()
@@ -639,3 +669,130 @@ func (t *FileStreamingWritesTest) TestWriteUsingBufferedWritesFails() {
 	require.Error(t.T(), err)
 	assert.Regexp(t.T(), writeErr.Error(), err.Error())
 }
+
+// Replace the inode with one of an object with the given contents in a zonal
+// bucket, left unfinalized unless finalize is set.
+func (t *FileStreamingWritesTest) createZonalInode(contents string, finalize bool) {
+	t.in.Unlock()
+	t.bucket = fake.NewFakeBucket(&t.clock, "some_bucket", gcs.BucketType{Zonal: true})
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: fileName}, 0, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte(contents))
+	require.NoError(t.T(), err)
+	if finalize {
+		t.backingObj, err = t.bucket.FinalizeUpload(t.ctx, w)
+		require.NoError(t.T(), err)
+	} else {
+		_, err = w.Flush()
+		require.NoError(t.T(), err)
+		t.backingObj, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: fileName})
+		require.NoError(t.T(), err)
+	}
+
+	syncerBucket := gcsx.NewSyncerBucket(1, ChunkTransferTimeoutSecs, ".gcsfuse_tmp/", nil, t.bucket)
+	t.in = NewFileInode(
+		fileInodeID,
+		NewFileName(NewRootName(""), fileName),
+		t.backingObj,
+		fuseops.InodeAttributes{Uid: uid, Gid: gid, Mode: fileMode},
+		&syncerBucket,
+		false, // localFileCache
+		contentcache.New("", &t.clock),
+		&t.clock,
+		false, // localFile
+		&cfg.Config{Write: cfg.WriteConfig{
+			MaxBlocksPerFile:        5,
+			MaxPendingBlocksPerFile: 1,
+			BlockSizeMb:             1,
+			EnableStreamingWrites:   true,
+			GlobalMaxBlocks:         10,
+		}},
+		semaphore.NewWeighted(math.MaxInt64),
+		nil,
+		nil,
+		common.NewNoopMetrics())
+	t.in.Lock()
+}
+
+func (t *FileStreamingWritesTest) TestAppendToUnfinalizedObjectInZonalBucket() {
+	t.createZonalInode("taco", false)
+	generation := t.backingObj.Generation
+
+	err := t.in.Write(t.ctx, []byte("burrito"), 4)
+
+	require.NoError(t.T(), err)
+	assert.NotNil(t.T(), t.in.bwh)
+	assert.Nil(t.T(), t.in.content)
+	attrs, err := t.in.Attributes(t.ctx)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), uint64(len("tacoburrito")), attrs.Size)
+
+	// Sync persists the appended contents without finalizing the object.
+	gcsSynced, err := t.in.Sync(t.ctx)
+	require.NoError(t.T(), err)
+	assert.False(t.T(), gcsSynced)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, fileName)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "tacoburrito", string(contents))
+
+	err = t.in.Write(t.ctx, []byte("s"), 11)
+	require.NoError(t.T(), err)
+	err = t.in.Flush(t.ctx)
+	require.NoError(t.T(), err)
+	assert.Nil(t.T(), t.in.bwh)
+	assert.Equal(t.T(), generation, t.in.SourceGeneration().Object)
+	contents, err = storageutil.ReadObject(t.ctx, t.bucket, fileName)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "tacoburritos", string(contents))
+	// The object was finalized, so it can't be taken over anymore.
+	_, err = t.bucket.CreateAppendableObjectWriter(t.ctx, &gcs.AppendObjectRequest{
+		Name:       fileName,
+		Generation: generation,
+		Offset:     int64(len("tacoburritos")),
+	}, 0, nil)
+	var preconditionErr *gcs.PreconditionError
+	assert.ErrorAs(t.T(), err, &preconditionErr)
+}
+
+func (t *FileStreamingWritesTest) TestAppendToGrownObjectInZonalBucketUsesTempFile() {
+	t.createZonalInode("taco", false)
+	// Another writer appends to the object after it was looked up.
+	w, err := t.bucket.CreateAppendableObjectWriter(t.ctx, &gcs.AppendObjectRequest{
+		Name:       fileName,
+		Generation: t.backingObj.Generation,
+		Offset:     int64(len("taco")),
+	}, 0, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte("salsa"))
+	require.NoError(t.T(), err)
+	_, err = w.Flush()
+	require.NoError(t.T(), err)
+
+	err = t.in.Write(t.ctx, []byte("burrito"), 4)
+
+	require.NoError(t.T(), err)
+	assert.Nil(t.T(), t.in.bwh)
+	assert.NotNil(t.T(), t.in.content)
+	// The object of the other writer wasn't finalized.
+	_, err = t.bucket.CreateAppendableObjectWriter(t.ctx, &gcs.AppendObjectRequest{
+		Name:       fileName,
+		Generation: t.backingObj.Generation,
+		Offset:     int64(len("tacosalsa")),
+	}, 0, nil)
+	assert.NoError(t.T(), err)
+}
+
+func (t *FileStreamingWritesTest) TestAppendToFinalizedObjectInZonalBucketUsesTempFile() {
+	t.createZonalInode("taco", true)
+
+	err := t.in.Write(t.ctx, []byte("burrito"), 4)
+
+	require.NoError(t.T(), err)
+	assert.Nil(t.T(), t.in.bwh)
+	assert.NotNil(t.T(), t.in.content)
+	err = t.in.Flush(t.ctx)
+	require.NoError(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, fileName)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "tacoburrito", string(contents))
+}
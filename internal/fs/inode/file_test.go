@@ -20,15 +20,18 @@ import (
 	"io"
 	"math"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"testing"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/contentcache"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/journal"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
@@ -130,6 +133,7 @@ func (t *FileTest) createInodeWithLocalParam(fileName string, local bool) {
 		1, // Append threshold
 		ChunkTransferTimeoutSecs,
 		".gcsfuse_tmp/",
+		nil,
 		t.bucket)
 
 	if local {
@@ -150,7 +154,10 @@ func (t *FileTest) createInodeWithLocalParam(fileName string, local bool) {
 		&t.clock,
 		local,
 		&cfg.Config{},
-		semaphore.NewWeighted(math.MaxInt64))
+		semaphore.NewWeighted(math.MaxInt64),
+		nil,
+		nil,
+		common.NewNoopMetrics())
 
 	t.in.Lock()
 }
@@ -1621,3 +1628,105 @@ func getWriteConfig() *cfg.WriteConfig {
 		EnableStreamingWrites: true,
 	}
 }
+
+func (t *FileTest) TestWriteWithJournalKeepsContentInJournalUntilSync() {
+	dir := t.T().TempDir()
+	var err error
+	t.in.writeJournal, err = journal.New(dir, &t.clock)
+	require.NoError(t.T(), err)
+
+	err = t.in.Write(t.ctx, []byte("burrito"), 0)
+
+	require.NoError(t.T(), err)
+	entries, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t.T(), err)
+	assert.Len(t.T(), entries, 1)
+	gcsSynced, err := t.in.Sync(t.ctx)
+	require.NoError(t.T(), err)
+	assert.True(t.T(), gcsSynced)
+	entries, err = filepath.Glob(filepath.Join(dir, "*.json"))
+	require.NoError(t.T(), err)
+	assert.Empty(t.T(), entries)
+}
+
+func (t *FileTest) TestStagedContentSize() {
+	_, ok, err := t.in.StagedContentSize()
+	require.NoError(t.T(), err)
+	assert.False(t.T(), ok)
+
+	err = t.in.Write(t.ctx, []byte("burrito"), 0)
+	require.NoError(t.T(), err)
+	size, ok, err := t.in.StagedContentSize()
+
+	require.NoError(t.T(), err)
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), int64(len("burrito")), size)
+	err = t.in.Flush(t.ctx)
+	require.NoError(t.T(), err)
+	_, ok, err = t.in.StagedContentSize()
+	require.NoError(t.T(), err)
+	assert.False(t.T(), ok)
+}
+
+func (t *FileTest) TestFlushClobberedWithLastWriterWinsOverwritesObject() {
+	t.in.config.Write.ConflictPolicy = cfg.ConflictPolicyLastWriterWins
+	err := t.in.Write(t.ctx, []byte("burrito"), 0)
+	require.NoError(t.T(), err)
+	newObj, err := storageutil.CreateObject(t.ctx, t.bucket, t.in.Name().GcsObjectName(), []byte("enchilada"))
+	require.NoError(t.T(), err)
+
+	err = t.in.Flush(t.ctx)
+
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), t.in.SourceGeneration().Object, newObj.Generation)
+	assert.Nil(t.T(), t.in.content)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+}
+
+func (t *FileTest) TestFlushClobberedWithConflictCopyWritesSiblingObject() {
+	t.in.config.Write.ConflictPolicy = cfg.ConflictPolicyConflictCopy
+	err := t.in.Write(t.ctx, []byte("burrito"), 0)
+	require.NoError(t.T(), err)
+	newObj, err := storageutil.CreateObject(t.ctx, t.bucket, t.in.Name().GcsObjectName(), []byte("enchilada"))
+	require.NoError(t.T(), err)
+
+	err = t.in.Flush(t.ctx)
+
+	require.NoError(t.T(), err)
+	// The inode now reflects the concurrently written object.
+	assert.Equal(t.T(), newObj.Generation, t.in.SourceGeneration().Object)
+	assert.Nil(t.T(), t.in.content)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, t.in.Name().GcsObjectName())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "enchilada", string(contents))
+	copies, _, err := storageutil.ListAll(t.ctx, t.bucket, &gcs.ListObjectsRequest{Prefix: t.in.Name().GcsObjectName() + ".conflict-"})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), copies, 1)
+	contents, err = storageutil.ReadObject(t.ctx, t.bucket, copies[0].Name)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+}
+
+func (t *FileTest) TestFlushDeletedObjectWithConflictCopyWritesSiblingObject() {
+	t.in.config.Write.ConflictPolicy = cfg.ConflictPolicyConflictCopy
+	err := t.in.Write(t.ctx, []byte("burrito"), 0)
+	require.NoError(t.T(), err)
+	err = t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: t.in.Name().GcsObjectName()})
+	require.NoError(t.T(), err)
+
+	err = t.in.Flush(t.ctx)
+
+	require.NoError(t.T(), err)
+	copies, _, err := storageutil.ListAll(t.ctx, t.bucket, &gcs.ListObjectsRequest{Prefix: t.in.Name().GcsObjectName() + ".conflict-"})
+	require.NoError(t.T(), err)
+	assert.Len(t.T(), copies, 1)
+	// The file is gone along with the object, rather than being clobbered on
+	// each sync, but its contents can still be read.
+	assert.True(t.T(), t.in.IsUnlinked())
+	buf := make([]byte, 7)
+	n, err := t.in.Read(t.ctx, buf, 0)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(buf[:n]))
+}
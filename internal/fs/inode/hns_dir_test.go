@@ -71,6 +71,7 @@ func (t *hnsDirTest) setupTestSuite(hierarchical bool) {
 		1,
 		ChunkTransferTimeoutSecs,
 		".gcsfuse_tmp/",
+		nil,
 		t.mockBucket)
 	t.resetDirInode(false, false, true)
 }
@@ -0,0 +1,108 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/replay"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ReplayFileSystemTest struct {
+	suite.Suite
+	ctx   context.Context
+	trace bytes.Buffer
+}
+
+func TestReplayFileSystemTestSuite(t *testing.T) {
+	suite.Run(t, new(ReplayFileSystemTest))
+}
+
+func (t *ReplayFileSystemTest) SetupTest() {
+	t.ctx = context.Background()
+	t.trace.Reset()
+}
+
+func (t *ReplayFileSystemTest) newFileSystem(bucket gcs.Bucket) *fileSystem {
+	server, err := NewFileSystem(t.ctx, &ServerConfig{
+		CacheClock:           timeutil.RealClock(),
+		BucketManager:        &singleBucketManager{bucket: bucket},
+		BucketName:           bucket.Name(),
+		TempDir:              t.T().TempDir(),
+		FilePerms:            0644,
+		DirPerms:             0755,
+		SequentialReadSizeMb: 200,
+		NewConfig:            &cfg.Config{},
+		MetricHandle:         common.NewNoopMetrics(),
+	})
+	require.NoError(t.T(), err)
+	return server.(*fileSystem)
+}
+
+// Create a file and write it in two parts, each synced, the second one being
+// appended to the object by composing it with a temporary object.
+func (t *ReplayFileSystemTest) workload(fs *fileSystem) error {
+	createOp := &fuseops.CreateFileOp{Parent: fuseops.RootInodeID, Name: "foo", Mode: 0644}
+	if err := fs.CreateFile(t.ctx, createOp); err != nil {
+		return err
+	}
+	id := createOp.Entry.Child
+	if err := fs.WriteFile(t.ctx, &fuseops.WriteFileOp{Inode: id, Handle: createOp.Handle, Data: []byte("taco")}); err != nil {
+		return err
+	}
+	if err := fs.SyncFile(t.ctx, &fuseops.SyncFileOp{Inode: id, Handle: createOp.Handle}); err != nil {
+		return err
+	}
+	// Let the mtime of the second sync differ from run to run.
+	time.Sleep(time.Millisecond)
+	if err := fs.WriteFile(t.ctx, &fuseops.WriteFileOp{Inode: id, Handle: createOp.Handle, Offset: 4, Data: []byte("burrito")}); err != nil {
+		return err
+	}
+	if err := fs.FlushFile(t.ctx, &fuseops.FlushFileOp{Inode: id, Handle: createOp.Handle}); err != nil {
+		return err
+	}
+	return fs.ReleaseFileHandle(t.ctx, &fuseops.ReleaseFileHandleOp{Handle: createOp.Handle})
+}
+
+func (t *ReplayFileSystemTest) TestWritesAreReplayed() {
+	bucket := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	recording, err := replay.NewRecordingBucket(bucket, &t.trace, false)
+	require.NoError(t.T(), err)
+	require.NoError(t.T(), t.workload(t.newFileSystem(recording)))
+	contents, err := storageutil.ReadObject(t.ctx, bucket, "foo")
+	require.NoError(t.T(), err)
+	require.Equal(t.T(), "tacoburrito", string(contents))
+
+	replaying, err := replay.NewReplayBucket(bytes.NewReader(t.trace.Bytes()), replay.ReplayConfig{TmpObjectPrefix: ".gcsfuse_tmp/"})
+	require.NoError(t.T(), err)
+	err = t.workload(t.newFileSystem(replaying))
+
+	// The requests match those recorded, though the mtimes of the file and the
+	// names of the temporary objects differ.
+	assert.NoError(t.T(), err)
+}
@@ -0,0 +1,154 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/fuse"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/fuse/fuseutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// singleBucketManager sets up the one bucket it holds.
+type singleBucketManager struct {
+	bucket gcs.Bucket
+}
+
+func (bm *singleBucketManager) SetUpBucket(
+	ctx context.Context,
+	name string, isMultibucketMount bool, _ common.MetricHandle) (gcsx.SyncerBucket, error) {
+	return gcsx.NewSyncerBucket(0, 10, ".gcsfuse_tmp/", nil, bm.bucket), nil
+}
+
+func (bm *singleBucketManager) ShutDown() {}
+
+type VersionsDirTest struct {
+	suite.Suite
+	ctx    context.Context
+	bucket gcs.Bucket
+	config *cfg.Config
+}
+
+func TestVersionsDirTestSuite(t *testing.T) {
+	suite.Run(t, new(VersionsDirTest))
+}
+
+func (t *VersionsDirTest) SetupTest() {
+	t.ctx = context.Background()
+	t.bucket = fake.NewFakeBucketWithVersioning(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	t.config = &cfg.Config{FileSystem: cfg.FileSystemConfig{EnableVersionsDir: true}}
+}
+
+func (t *VersionsDirTest) newFileSystem() fuseutil.FileSystem {
+	fs, err := NewFileSystem(t.ctx, &ServerConfig{
+		CacheClock:           timeutil.RealClock(),
+		BucketManager:        &singleBucketManager{bucket: t.bucket},
+		BucketName:           t.bucket.Name(),
+		TempDir:              t.T().TempDir(),
+		FilePerms:            0644,
+		DirPerms:             0755,
+		SequentialReadSizeMb: 200,
+		NewConfig:            t.config,
+		MetricHandle:         common.NewNoopMetrics(),
+	})
+	require.NoError(t.T(), err)
+	return fs
+}
+
+func (t *VersionsDirTest) lookUp(fs fuseutil.FileSystem, parent fuseops.InodeID, name string) (fuseops.InodeID, error) {
+	op := &fuseops.LookUpInodeOp{Parent: parent, Name: name}
+	err := fs.LookUpInode(t.ctx, op)
+	return op.Entry.Child, err
+}
+
+func (t *VersionsDirTest) readFile(fs fuseutil.FileSystem, id fuseops.InodeID) string {
+	openOp := &fuseops.OpenFileOp{Inode: id}
+	require.NoError(t.T(), fs.OpenFile(t.ctx, openOp))
+	readOp := &fuseops.ReadFileOp{Inode: id, Handle: openOp.Handle, Dst: make([]byte, 100)}
+	require.NoError(t.T(), fs.ReadFile(t.ctx, readOp))
+	return string(readOp.Dst[:readOp.BytesRead])
+}
+
+func (t *VersionsDirTest) TestReadsNoncurrentGeneration() {
+	old, err := storageutil.CreateObject(t.ctx, t.bucket, "dir/foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "dir/foo", []byte("burrito"))
+	require.NoError(t.T(), err)
+	fs := t.newFileSystem()
+	defer fs.Destroy()
+
+	var id fuseops.InodeID = fuseops.RootInodeID
+	for _, name := range []string{versionsDirName, "dir", "foo", strconv.FormatInt(old.Generation, 10)} {
+		id, err = t.lookUp(fs, id, name)
+		require.NoError(t.T(), err, name)
+	}
+
+	assert.Equal(t.T(), "taco", t.readFile(fs, id))
+}
+
+func (t *VersionsDirTest) TestVersionsDirIsHiddenWhenDisabled() {
+	t.config.FileSystem.EnableVersionsDir = false
+	fs := t.newFileSystem()
+	defer fs.Destroy()
+
+	_, err := t.lookUp(fs, fuseops.RootInodeID, versionsDirName)
+
+	assert.ErrorIs(t.T(), err, fuse.ENOENT)
+}
+
+func (t *VersionsDirTest) TestVersionsDirIsReadOnly() {
+	fs := t.newFileSystem()
+	defer fs.Destroy()
+	id, err := t.lookUp(fs, fuseops.RootInodeID, versionsDirName)
+	require.NoError(t.T(), err)
+
+	err = fs.MkDir(t.ctx, &fuseops.MkDirOp{Parent: id, Name: "dir", Mode: 0755})
+
+	assert.Error(t.T(), err)
+	_, err = storageutil.ReadObject(t.ctx, t.bucket, "dir/")
+	assert.Error(t.T(), err)
+}
+
+func (t *VersionsDirTest) TestLookUpsShareVersionsDir() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	fs := t.newFileSystem()
+	defer fs.Destroy()
+
+	first, err := t.lookUp(fs, fuseops.RootInodeID, versionsDirName)
+	require.NoError(t.T(), err)
+	_, err = t.lookUp(fs, first, "foo")
+	require.NoError(t.T(), err)
+	second, err := t.lookUp(fs, fuseops.RootInodeID, versionsDirName)
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), first, second)
+	impl := fs.(*fileSystem)
+	assert.Len(t.T(), impl.versionsBuckets, 1)
+	assert.Equal(t.T(), versionsDirName+"/", impl.inodes[first].Name().GcsObjectName())
+}
@@ -24,6 +24,7 @@ import (
 	"cloud.google.com/go/storage"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/jacobsa/fuse/fuseops"
 	"github.com/jacobsa/fuse/fuseutil"
 	"google.golang.org/api/googleapi"
@@ -74,6 +75,12 @@ func errno(err error, preconditionErrCfg bool) error {
 		return syscall.EACCES
 	}
 
+	// The customer-supplied encryption key doesn't match the object.
+	var encryptionKeyErr *gcs.EncryptionKeyError
+	if errors.As(err, &encryptionKeyErr) {
+		return syscall.EACCES
+	}
+
 	if grpcStatus, ok := status.FromError(err); ok {
 		switch grpcStatus.Code() {
 		case codes.Canceled:
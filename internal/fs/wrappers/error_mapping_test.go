@@ -22,6 +22,7 @@ import (
 
 	"github.com/googleapis/gax-go/v2/apierror"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/fs/gcsfuse_errors"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
 	"google.golang.org/api/googleapi"
@@ -92,6 +93,14 @@ func (testSuite *ErrorMapping) TestUnAuthenticatedHttpGoogleApiError() {
 	assert.Equal(testSuite.T(), syscall.EACCES, fsErr)
 }
 
+func (testSuite *ErrorMapping) TestEncryptionKeyError() {
+	keyErr := fmt.Errorf("reading: %w", &gcs.EncryptionKeyError{Err: fmt.Errorf("the provided encryption key is incorrect")})
+
+	fsErr := errno(keyErr, testSuite.preconditionErrCfg)
+
+	assert.Equal(testSuite.T(), syscall.EACCES, fsErr)
+}
+
 func (testSuite *ErrorMapping) TestFileClobberedErrorWithPreconditionErrCfg() {
 	clobberedErr := &gcsfuse_errors.FileClobberedError{
 		Err: fmt.Errorf("some error"),
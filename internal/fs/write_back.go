@@ -0,0 +1,215 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
+	"github.com/jacobsa/fuse/fuseops"
+	"golang.org/x/sync/semaphore"
+)
+
+// writeBackUploader runs the uploads of files closed in write-back mode in the
+// background. The contents of such files stay staged in their local temp files
+// until uploaded, and the total size of the staged contents is bounded by a
+// budget: scheduling an upload blocks until enough of the budget is available.
+// The contents of files whose upload failed stay staged, and keep their part
+// of the budget, until a later upload succeeds or they are discarded, and the
+// failure is reported by the next sync or flush.
+//
+// writeBackUploader is safe for concurrent access.
+type writeBackUploader struct {
+	budgetBytes int64
+	budget      *semaphore.Weighted
+
+	// Limits the number of uploads running concurrently.
+	uploadSem *semaphore.Weighted
+
+	metricHandle common.MetricHandle
+
+	mu sync.Mutex
+
+	// The inodes with a scheduled upload which hasn't started yet.
+	//
+	// GUARDED_BY(mu)
+	pending map[fuseops.InodeID]struct{}
+
+	// The inodes whose last upload failed.
+	//
+	// GUARDED_BY(mu)
+	failed map[fuseops.InodeID]failedUpload
+
+	// Tracks all the scheduled uploads, including the running ones.
+	wg sync.WaitGroup
+}
+
+// The failure of the last upload of an inode.
+type failedUpload struct {
+	err error
+
+	// The part of the budget still reserved for the staged contents.
+	reserved int64
+}
+
+// newWriteBackUploader returns a writeBackUploader for the given config, or nil
+// if write-back mode is disabled.
+func newWriteBackUploader(wc *cfg.WriteConfig, metricHandle common.MetricHandle) *writeBackUploader {
+	if wc.WriteBackStagingBudgetMb == 0 {
+		return nil
+	}
+
+	budgetBytes := wc.WriteBackStagingBudgetMb * util.MiB
+	return &writeBackUploader{
+		budgetBytes:  budgetBytes,
+		budget:       semaphore.NewWeighted(budgetBytes),
+		uploadSem:    semaphore.NewWeighted(wc.WriteBackConcurrency),
+		metricHandle: metricHandle,
+		pending:      make(map[fuseops.InodeID]struct{}),
+		failed:       make(map[fuseops.InodeID]failedUpload),
+	}
+}
+
+// CanStage returns true if contents of the given size fit in the staging
+// budget at all. Larger contents must be uploaded synchronously.
+func (u *writeBackUploader) CanStage(size int64) bool {
+	return size <= u.budgetBytes
+}
+
+// IsPending returns true if an upload of the given inode is scheduled but
+// hasn't started yet, in which case it will pick up any later writes.
+func (u *writeBackUploader) IsPending(id fuseops.InodeID) bool {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	_, ok := u.pending[id]
+	return ok
+}
+
+// Schedule reserves size bytes of the staging budget, blocking until they are
+// available or ctx is done, and runs upload in the background. upload must
+// call Start before uploading the contents of the inode, and Fail or Succeed
+// after. The reservation is released once upload returns, unless it failed, in
+// which case it is kept until Succeed. scheduled is false if an upload of the
+// inode is already pending, in which case upload isn't run.
+func (u *writeBackUploader) Schedule(ctx context.Context, id fuseops.InodeID, size int64, upload func()) (scheduled bool, err error) {
+	if u.IsPending(id) {
+		return false, nil
+	}
+	if err = u.budget.Acquire(ctx, size); err != nil {
+		return false, err
+	}
+
+	u.mu.Lock()
+	if _, ok := u.pending[id]; ok {
+		u.mu.Unlock()
+		u.budget.Release(size)
+		return false, nil
+	}
+	u.pending[id] = struct{}{}
+	u.wg.Add(1)
+	u.mu.Unlock()
+
+	u.metricHandle.WriteBackPendingUploads(ctx, 1, nil)
+	u.metricHandle.WriteBackPendingBytes(ctx, size, nil)
+	go func() {
+		defer u.wg.Done()
+		// Acquire never fails without a deadline.
+		_ = u.uploadSem.Acquire(context.Background(), 1)
+		upload()
+		u.uploadSem.Release(1)
+
+		u.budget.Release(u.keepIfFailed(id, size))
+		u.metricHandle.WriteBackPendingUploads(context.Background(), -1, nil)
+		u.metricHandle.WriteBackPendingBytes(context.Background(), -size, nil)
+	}()
+	return true, nil
+}
+
+// Keep the reservation of size bytes for the staged contents of the given
+// inode if its last upload failed, in place of the one kept for an earlier
+// failure. Returns the size of the reservation to release.
+func (u *writeBackUploader) keepIfFailed(id fuseops.InodeID, size int64) (release int64) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	f, ok := u.failed[id]
+	if !ok {
+		return size
+	}
+	release, f.reserved = f.reserved, size
+	u.failed[id] = f
+	return
+}
+
+// Start marks the pending upload of the given inode as started, so that the
+// writes after this point are uploaded by a newly scheduled upload.
+//
+// LOCKS_REQUIRED(inode with the given ID)
+func (u *writeBackUploader) Start(id fuseops.InodeID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	delete(u.pending, id)
+}
+
+// Wait blocks until all the scheduled uploads are done.
+func (u *writeBackUploader) Wait() {
+	u.wg.Wait()
+}
+
+// Fail records the failed upload of the given inode. first is true if its
+// previous upload didn't fail.
+//
+// LOCKS_REQUIRED(inode with the given ID)
+func (u *writeBackUploader) Fail(id fuseops.InodeID, err error) (first bool) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	f, failed := u.failed[id]
+	f.err = err
+	u.failed[id] = f
+	return !failed
+}
+
+// Succeed records the successful upload of the given inode, or the discarding
+// of its staged contents, releasing the budget kept for them. Returns the error
+// of its previous upload, if it failed.
+//
+// LOCKS_REQUIRED(inode with the given ID)
+func (u *writeBackUploader) Succeed(id fuseops.InodeID) (prevErr error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	f := u.failed[id]
+	delete(u.failed, id)
+	u.budget.Release(f.reserved)
+	return f.err
+}
+
+// Err returns the error of the last upload of the given inode, if it failed.
+func (u *writeBackUploader) Err(id fuseops.InodeID) error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.failed[id].err
+}
+
+// Failed returns the inodes whose last upload failed.
+func (u *writeBackUploader) Failed() (ids []fuseops.InodeID) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for id := range u.failed {
+		ids = append(ids, id)
+	}
+	return
+}
@@ -0,0 +1,242 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
+	"github.com/jacobsa/fuse/fuseops"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type WriteBackUploaderTest struct {
+	suite.Suite
+	ctx      context.Context
+	uploader *writeBackUploader
+}
+
+func TestWriteBackUploaderTestSuite(t *testing.T) {
+	suite.Run(t, new(WriteBackUploaderTest))
+}
+
+func (t *WriteBackUploaderTest) SetupTest() {
+	t.ctx = context.Background()
+	t.uploader = newWriteBackUploader(
+		&cfg.WriteConfig{WriteBackStagingBudgetMb: 1, WriteBackConcurrency: 1},
+		common.NewNoopMetrics())
+}
+
+func (t *WriteBackUploaderTest) TestNewWriteBackUploaderReturnsNilWhenDisabled() {
+	assert.Nil(t.T(), newWriteBackUploader(&cfg.WriteConfig{}, common.NewNoopMetrics()))
+}
+
+func (t *WriteBackUploaderTest) TestCanStage() {
+	assert.True(t.T(), t.uploader.CanStage(util.MiB))
+	assert.False(t.T(), t.uploader.CanStage(util.MiB+1))
+}
+
+func (t *WriteBackUploaderTest) TestWaitWaitsForScheduledUploads() {
+	uploaded := false
+
+	scheduled, err := t.uploader.Schedule(t.ctx, 1, 10, func() {
+		t.uploader.Start(1)
+		time.Sleep(10 * time.Millisecond)
+		uploaded = true
+	})
+	t.uploader.Wait()
+
+	require.NoError(t.T(), err)
+	assert.True(t.T(), scheduled)
+	assert.True(t.T(), uploaded)
+	assert.False(t.T(), t.uploader.IsPending(1))
+}
+
+func (t *WriteBackUploaderTest) TestScheduleSkipsInodeWithPendingUpload() {
+	release := make(chan struct{})
+	// Occupy the only upload slot, so that the next upload stays pending.
+	_, err := t.uploader.Schedule(t.ctx, 1, 10, func() { <-release })
+	require.NoError(t.T(), err)
+	_, err = t.uploader.Schedule(t.ctx, 2, 10, func() { t.uploader.Start(2) })
+	require.NoError(t.T(), err)
+	called := false
+
+	scheduled, err := t.uploader.Schedule(t.ctx, 2, 10, func() { called = true })
+
+	require.NoError(t.T(), err)
+	assert.False(t.T(), scheduled)
+	assert.True(t.T(), t.uploader.IsPending(2))
+	close(release)
+	t.uploader.Wait()
+	assert.False(t.T(), called)
+}
+
+func (t *WriteBackUploaderTest) TestScheduleBlocksWhileStagingBudgetIsFull() {
+	release := make(chan struct{})
+	_, err := t.uploader.Schedule(t.ctx, 1, util.MiB, func() { <-release })
+	require.NoError(t.T(), err)
+	ctx, cancel := context.WithTimeout(t.ctx, 10*time.Millisecond)
+	defer cancel()
+
+	scheduled, err := t.uploader.Schedule(ctx, 2, 1, func() {})
+
+	assert.ErrorIs(t.T(), err, context.DeadlineExceeded)
+	assert.False(t.T(), scheduled)
+	assert.False(t.T(), t.uploader.IsPending(2))
+	// The budget is available again once the upload is done.
+	close(release)
+	scheduled, err = t.uploader.Schedule(t.ctx, 2, util.MiB, func() {})
+	require.NoError(t.T(), err)
+	assert.True(t.T(), scheduled)
+	t.uploader.Wait()
+}
+
+func (t *WriteBackUploaderTest) TestFailedUploadKeepsItsBudgetUntilSucceeded() {
+	failedUpload := func() {
+		t.uploader.Start(1)
+		t.uploader.Fail(1, errors.New("upload failed"))
+	}
+	_, err := t.uploader.Schedule(t.ctx, 1, util.MiB/2, failedUpload)
+	require.NoError(t.T(), err)
+	t.uploader.Wait()
+	// A later failure keeps the reservation of the later contents instead.
+	_, err = t.uploader.Schedule(t.ctx, 1, util.MiB/2, failedUpload)
+	require.NoError(t.T(), err)
+	t.uploader.Wait()
+	ctx, cancel := context.WithTimeout(t.ctx, 10*time.Millisecond)
+	defer cancel()
+
+	_, err = t.uploader.Schedule(ctx, 2, util.MiB/2+1, func() {})
+
+	assert.ErrorIs(t.T(), err, context.DeadlineExceeded)
+	assert.Error(t.T(), t.uploader.Succeed(1))
+	scheduled, err := t.uploader.Schedule(t.ctx, 2, util.MiB, func() {})
+	require.NoError(t.T(), err)
+	assert.True(t.T(), scheduled)
+	t.uploader.Wait()
+}
+
+// failingUploadsBucket fails the creation of objects while fail is set.
+type failingUploadsBucket struct {
+	gcs.Bucket
+	fail atomic.Bool
+}
+
+func (b *failingUploadsBucket) CreateObject(ctx context.Context, req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	if b.fail.Load() {
+		return nil, errors.New("upload failed")
+	}
+	return b.Bucket.CreateObject(ctx, req)
+}
+
+type WriteBackFileSystemTest struct {
+	suite.Suite
+	ctx    context.Context
+	bucket *failingUploadsBucket
+	fs     *fileSystem
+}
+
+func TestWriteBackFileSystemTestSuite(t *testing.T) {
+	suite.Run(t, new(WriteBackFileSystemTest))
+}
+
+func (t *WriteBackFileSystemTest) SetupTest() {
+	t.ctx = context.Background()
+	t.bucket = &failingUploadsBucket{Bucket: fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})}
+	server, err := NewFileSystem(t.ctx, &ServerConfig{
+		CacheClock:           timeutil.RealClock(),
+		BucketManager:        &singleBucketManager{bucket: t.bucket},
+		BucketName:           t.bucket.Name(),
+		TempDir:              t.T().TempDir(),
+		FilePerms:            0644,
+		DirPerms:             0755,
+		SequentialReadSizeMb: 200,
+		NewConfig: &cfg.Config{Write: cfg.WriteConfig{
+			WriteBackStagingBudgetMb: 1,
+			WriteBackConcurrency:     1,
+		}},
+		MetricHandle: common.NewNoopMetrics(),
+	})
+	require.NoError(t.T(), err)
+	t.fs = server.(*fileSystem)
+}
+
+// writeAndClose creates a file with the given contents and closes it, which
+// schedules its upload, returning its inode.
+func (t *WriteBackFileSystemTest) writeAndClose(name, contents string) fuseops.InodeID {
+	createOp := &fuseops.CreateFileOp{Parent: fuseops.RootInodeID, Name: name, Mode: 0644}
+	require.NoError(t.T(), t.fs.CreateFile(t.ctx, createOp))
+	id := createOp.Entry.Child
+	require.NoError(t.T(), t.fs.WriteFile(t.ctx, &fuseops.WriteFileOp{Inode: id, Handle: createOp.Handle, Data: []byte(contents)}))
+	require.NoError(t.T(), t.fs.FlushFile(t.ctx, &fuseops.FlushFileOp{Inode: id, Handle: createOp.Handle}))
+	require.NoError(t.T(), t.fs.ReleaseFileHandle(t.ctx, &fuseops.ReleaseFileHandleOp{Handle: createOp.Handle}))
+	return id
+}
+
+func (t *WriteBackFileSystemTest) readObject(name string) (string, error) {
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, name)
+	return string(contents), err
+}
+
+func (t *WriteBackFileSystemTest) TestFailedUploadIsReportedAndRetriedBySync() {
+	t.bucket.fail.Store(true)
+	id := t.writeAndClose("foo", "taco")
+	t.fs.writeBack.Wait()
+	_, err := t.readObject("foo")
+	require.Error(t.T(), err)
+
+	// The contents outlive the kernel forgetting the inode.
+	require.NoError(t.T(), t.fs.ForgetInode(t.ctx, &fuseops.ForgetInodeOp{Inode: id, N: 1}))
+	// The failure is reported while uploads keep failing.
+	err = t.fs.SyncFile(t.ctx, &fuseops.SyncFileOp{Inode: id})
+	assert.ErrorContains(t.T(), err, "upload failed")
+
+	t.bucket.fail.Store(false)
+	err = t.fs.SyncFile(t.ctx, &fuseops.SyncFileOp{Inode: id})
+
+	require.NoError(t.T(), err)
+	contents, err := t.readObject("foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", contents)
+	assert.Empty(t.T(), t.fs.writeBack.Failed())
+	t.fs.mu.Lock()
+	defer t.fs.mu.Unlock()
+	assert.NotContains(t.T(), t.fs.inodes, id)
+}
+
+func (t *WriteBackFileSystemTest) TestFailedUploadIsRetriedOnUnmount() {
+	t.bucket.fail.Store(true)
+	t.writeAndClose("foo", "taco")
+	t.fs.writeBack.Wait()
+
+	t.bucket.fail.Store(false)
+	t.fs.Destroy()
+
+	contents, err := t.readObject("foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", contents)
+}
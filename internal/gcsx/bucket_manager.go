@@ -18,7 +18,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
@@ -30,6 +33,8 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/caching"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/local"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/replay"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/util"
 	"github.com/jacobsa/timeutil"
 )
@@ -46,6 +51,11 @@ type BucketConfig struct {
 	NegativeStatCacheTTL time.Duration
 	EnableMonitoring     bool
 
+	// If set, the GCS operations of each bucket are accounted for by pricing
+	// class, labelled with the bucket name unless a mount label is set. See
+	// monitor.NewCostAccountant.
+	CostAccounting *monitor.CostConfig
+
 	// Files backed by on object of length at least AppendThreshold that have
 	// only been appended to (i.e. none of the object's contents have been
 	// dirtied) will be written out by "appending" to the object in GCS with this
@@ -65,6 +75,70 @@ type BucketConfig struct {
 	AppendThreshold          int64
 	ChunkTransferTimeoutSecs int64
 	TmpObjectPrefix          string
+
+	// If non-nil, large files are written out by uploading parts in parallel
+	// and composing them. The parts are also named with TmpObjectPrefix.
+	ParallelUpload *ParallelUploadConfig
+
+	// If set, reads whose first byte is late are hedged with duplicate
+	// requests. See NewHedgingBucket.
+	HedgedReads *HedgedReadsConfig
+
+	// If set, requests fail fast while too many recent ones failed. See
+	// NewCircuitBreakerBucket.
+	CircuitBreaker *CircuitBreakerConfig
+
+	// If set, the bucket is mounted read-only, with reads falling back to this
+	// replica of it. See NewFailoverBucket.
+	FailoverBucket  string
+	FailoverTimeout time.Duration
+
+	// If set, faults are injected into the requests to the bucket. See
+	// NewFaultInjectionBucket.
+	FaultInjector *FaultInjector
+
+	// If set, the requests to the bucket are recorded to this trace file, with
+	// the contents read and written if RecordPayloads is set. See
+	// replay.NewRecordingBucket.
+	RecordTrace    string
+	RecordPayloads bool
+
+	// If set, the bucket is served from this trace file instead. See
+	// replay.NewReplayBucket.
+	ReplayTrace string
+	Replay      replay.ReplayConfig
+
+	// If set, the bucket is overlaid onto these layers, each a bucket name
+	// optionally followed by "/" and a directory. See NewUnionBucket.
+	UnionLowerLayers []string
+
+	// If non-zero, buckets are mounted read-only as of this time. See
+	// NewSnapshotBucket.
+	SnapshotTime time.Time
+
+	// Attributes set on newly created objects whose names match them. See
+	// NewObjectAttributesBucket.
+	ObjectAttributesTemplates []ObjectAttributesTemplate
+
+	// If true, content types of new objects are guessed from their contents.
+	// See NewSniffingContentTypeBucket.
+	SniffContentType bool
+
+	// Patterns of the names of objects compressed with gzip. See
+	// NewGzipBucket.
+	GzipPatterns []string
+
+	// The directory in which temporary files are created, the system default
+	// if empty.
+	TempDir string
+
+	// If set, the key-encryption key with which the contents of objects are
+	// encrypted client-side. See NewEncryptionBucket.
+	EncryptionKey []byte
+
+	// If set, buckets are the subdirectories of this local directory instead
+	// of GCS buckets. See local.NewBucket.
+	LocalDir string
 }
 
 // BucketManager manages the lifecycle of buckets.
@@ -156,32 +230,197 @@ func setUpRateLimiting(
 	return
 }
 
+// Set up the appropriate backing bucket with the given name.
+func (bm *bucketManager) backingBucket(ctx context.Context, name string) (b gcs.Bucket, err error) {
+	if name == canned.FakeBucketName {
+		b = canned.MakeFakeBucket(ctx)
+	} else if bm.config.LocalDir != "" {
+		b, err = local.NewBucket(filepath.Join(bm.config.LocalDir, name), name)
+		if err != nil {
+			err = fmt.Errorf("local.NewBucket: %w", err)
+			return
+		}
+	} else if bm.storageHandle == nil {
+		// As when replaying a trace.
+		err = fmt.Errorf("bucket %q: no connection to GCS", name)
+		return
+	} else {
+		b, err = bm.storageHandle.BucketHandle(ctx, name, bm.config.BillingProject)
+		if err != nil {
+			err = fmt.Errorf("BucketHandle: %w", err)
+			return
+		}
+	}
+	return
+}
+
+// Serve the named bucket from the given trace.
+func replayBucket(name string, trace string, config replay.ReplayConfig) (gcs.Bucket, error) {
+	f, err := os.Open(trace)
+	if err != nil {
+		return nil, fmt.Errorf("opening trace: %w", err)
+	}
+	defer f.Close()
+
+	b, err := replay.NewReplayBucket(f, config)
+	if err != nil {
+		return nil, fmt.Errorf("NewReplayBucket: %w", err)
+	}
+	if b.Name() != name {
+		return nil, fmt.Errorf("trace %q is of bucket %q, not %q", trace, b.Name(), name)
+	}
+	return b, nil
+}
+
+// Record the requests to the given bucket to a trace file, which is left open
+// for the lifetime of the mount.
+func recordingBucket(b gcs.Bucket, trace string, payloads bool) (gcs.Bucket, error) {
+	f, err := os.OpenFile(trace, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("creating trace: %w", err)
+	}
+
+	rb, err := replay.NewRecordingBucket(b, f, payloads)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("NewRecordingBucket: %w", err)
+	}
+	return rb, nil
+}
+
+// Set up the given lower layer of a union mount: a bucket name, optionally
+// followed by a directory of the bucket to which the layer is limited. The
+// layer is wrapped like the bucket it lies under, short of the wrappers which
+// only concern the bucket itself.
+func (bm *bucketManager) lowerLayer(
+	ctx context.Context,
+	layer string,
+	metricHandle common.MetricHandle,
+	costs *monitor.CostAccountant) (b gcs.Bucket, err error) {
+	name, dir, _ := strings.Cut(layer, "/")
+	if b, err = bm.backingBucket(ctx, name); err != nil {
+		return
+	}
+
+	if bm.config.FaultInjector != nil {
+		b = NewFaultInjectionBucket(bm.config.FaultInjector, b)
+	}
+	if bm.config.EnableMonitoring || costs != nil {
+		b = monitor.NewMonitoringBucket(b, metricHandle, costs)
+	}
+	b = storage.NewDebugBucket(b)
+	if bm.config.HedgedReads != nil {
+		b = NewHedgingBucket(*bm.config.HedgedReads, metricHandle, b)
+	}
+	if bm.config.CircuitBreaker != nil {
+		b = NewCircuitBreakerBucket(*bm.config.CircuitBreaker, metricHandle, timeutil.RealClock(), b)
+	}
+
+	if dir != "" {
+		b, err = NewPrefixBucket(path.Clean(dir)+"/", b)
+		if err != nil {
+			err = fmt.Errorf("NewPrefixBucket: %w", err)
+		}
+	}
+	return
+}
+
 func (bm *bucketManager) SetUpBucket(
 	ctx context.Context,
 	name string,
 	isMultibucketMount bool,
 	metricHandle common.MetricHandle,
 ) (sb SyncerBucket, err error) {
+	if isMultibucketMount && (bm.config.RecordTrace != "" || bm.config.ReplayTrace != "") {
+		err = fmt.Errorf("traces can't be recorded or replayed with dynamic mounts")
+		return
+	}
+
+	// A trace holds the requests to a single bucket.
+	if len(bm.config.UnionLowerLayers) > 0 && (bm.config.RecordTrace != "" || bm.config.ReplayTrace != "") {
+		err = fmt.Errorf("traces can't be recorded or replayed with union lower layers")
+		return
+	}
+
 	var b gcs.Bucket
-	// Set up the appropriate backing bucket.
-	if name == canned.FakeBucketName {
-		b = canned.MakeFakeBucket(ctx)
+	if bm.config.ReplayTrace != "" {
+		b, err = replayBucket(name, bm.config.ReplayTrace, bm.config.Replay)
 	} else {
-		b, err = bm.storageHandle.BucketHandle(ctx, name, bm.config.BillingProject)
+		b, err = bm.backingBucket(ctx, name)
+	}
+	if err != nil {
+		return
+	}
+
+	// Inject faults as if they came from GCS, beneath the recording so that
+	// replays reproduce them.
+	if bm.config.FaultInjector != nil {
+		b = NewFaultInjectionBucket(bm.config.FaultInjector, b)
+	}
+
+	// Record the requests to the bucket as is, so that replaying them
+	// reproduces everything above.
+	if bm.config.RecordTrace != "" {
+		b, err = recordingBucket(b, bm.config.RecordTrace, bm.config.RecordPayloads)
 		if err != nil {
-			err = fmt.Errorf("BucketHandle: %w", err)
 			return
 		}
 	}
 
+	// Fall back to the replica, if any, beneath monitoring and logs so that
+	// they show the requests made by the file system.
+	if bm.config.FailoverBucket != "" {
+		if isMultibucketMount {
+			err = fmt.Errorf("a failover bucket can't be used with dynamic mounts")
+			return
+		}
+
+		var secondary gcs.Bucket
+		secondary, err = bm.backingBucket(ctx, bm.config.FailoverBucket)
+		if err != nil {
+			err = fmt.Errorf("failover bucket: %w", err)
+			return
+		}
+		b = NewFailoverBucket(b, secondary, bm.config.FailoverTimeout)
+	}
+
+	// Account for the cost of the requests to GCS, including those to the
+	// lower layers of union mounts.
+	var costs *monitor.CostAccountant
+	if bm.config.CostAccounting != nil {
+		config := *bm.config.CostAccounting
+		if config.MountLabel == "" {
+			config.MountLabel = name
+		}
+		costs = monitor.NewCostAccountant(config, metricHandle)
+		go costs.LogPeriodically(bm.gcCtx)
+	}
+
 	// Enable monitoring.
-	if bm.config.EnableMonitoring {
-		b = monitor.NewMonitoringBucket(b, metricHandle)
+	if bm.config.EnableMonitoring || costs != nil {
+		b = monitor.NewMonitoringBucket(b, metricHandle, costs)
 	}
 
 	// Enable gcs logs.
 	b = storage.NewDebugBucket(b)
 
+	// Hedge late reads, above monitoring and logs so that each attempt,
+	// hedges included, shows up in them as a request of its own.
+	if bm.config.HedgedReads != nil {
+		b = NewHedgingBucket(*bm.config.HedgedReads, metricHandle, b)
+	}
+
+	// Fail fast during outages, above hedging so that a hedged read counts
+	// once, and beneath the caches so that hits are still served.
+	if bm.config.CircuitBreaker != nil {
+		b = NewCircuitBreakerBucket(*bm.config.CircuitBreaker, metricHandle, timeutil.RealClock(), b)
+	}
+
+	// View the bucket as of the requested time, if any.
+	if !bm.config.SnapshotTime.IsZero() {
+		b = NewSnapshotBucket(bm.config.SnapshotTime, b)
+	}
+
 	// Limit to a requested prefix of the bucket, if any.
 	if bm.config.OnlyDir != "" {
 		b, err = NewPrefixBucket(path.Clean(bm.config.OnlyDir)+"/", b)
@@ -191,6 +430,26 @@ func (bm *bucketManager) SetUpBucket(
 		}
 	}
 
+	// Overlay the lower layers, if any, with the bucket.
+	if len(bm.config.UnionLowerLayers) > 0 {
+		if isMultibucketMount {
+			err = fmt.Errorf("union lower layers can't be used with dynamic mounts")
+			return
+		}
+
+		lowers := make([]gcs.Bucket, len(bm.config.UnionLowerLayers))
+		for i, layer := range bm.config.UnionLowerLayers {
+			if lowers[i], err = bm.lowerLayer(ctx, layer, metricHandle, costs); err != nil {
+				err = fmt.Errorf("union lower layer %q: %w", layer, err)
+				return
+			}
+		}
+		if b, err = NewUnionBucket(b, lowers); err != nil {
+			err = fmt.Errorf("NewUnionBucket: %w", err)
+			return
+		}
+	}
+
 	// Enable rate limiting, if requested.
 	b, err = setUpRateLimiting(
 		b,
@@ -220,8 +479,32 @@ func (bm *bucketManager) SetUpBucket(
 			bm.config.NegativeStatCacheTTL)
 	}
 
+	// Encrypt the contents of objects, beneath compression, which would gain
+	// nothing from encrypted contents.
+	if len(bm.config.EncryptionKey) > 0 {
+		b, err = NewEncryptionBucket(bm.config.EncryptionKey, b)
+		if err != nil {
+			err = fmt.Errorf("NewEncryptionBucket: %w", err)
+			return
+		}
+	}
+
+	// Compress the contents of the objects matching the gzip patterns.
+	if len(bm.config.GzipPatterns) > 0 {
+		b = NewGzipBucket(bm.config.GzipPatterns, bm.config.TempDir, b)
+	}
+
 	// Enable content type awareness
-	b = NewContentTypeBucket(b)
+	if bm.config.SniffContentType {
+		b = NewSniffingContentTypeBucket(b)
+	} else {
+		b = NewContentTypeBucket(b)
+	}
+
+	// Set the attributes of the templates matching new objects.
+	if len(bm.config.ObjectAttributesTemplates) > 0 {
+		b = NewObjectAttributesBucket(bm.config.ObjectAttributesTemplates, b)
+	}
 
 	// Enable Syncer
 	if bm.config.TmpObjectPrefix == "" {
@@ -232,6 +515,7 @@ func (bm *bucketManager) SetUpBucket(
 		bm.config.AppendThreshold,
 		bm.config.ChunkTransferTimeoutSecs,
 		bm.config.TmpObjectPrefix,
+		bm.config.ParallelUpload,
 		b)
 
 	// Fetch bucket type from storage layout api and set bucket type.
@@ -246,8 +530,11 @@ func (bm *bucketManager) SetUpBucket(
 		}
 	}
 
-	// Periodically garbage collect temporary objects
-	go garbageCollect(bm.gcCtx, bm.config.TmpObjectPrefix, sb)
+	// Periodically garbage collect temporary objects, which snapshots can't
+	// delete.
+	if bm.config.SnapshotTime.IsZero() {
+		go garbageCollect(bm.gcCtx, bm.config.TmpObjectPrefix, sb)
+	}
 
 	return
 }
@@ -24,6 +24,7 @@ import (
 	"github.com/googlecloudplatform/gcsfuse/v2/common"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	. "github.com/jacobsa/oglematchers"
 	. "github.com/jacobsa/ogletest"
 	"github.com/stretchr/testify/mock"
 )
@@ -156,7 +157,7 @@ func (t *BucketManagerTest) TestSetUpBucketMethodWhenBucketDoesNotExist() {
 
 	bucket, err := bm.SetUpBucket(context.Background(), invalidBucketName, false, common.NewNoopMetrics())
 
-	ExpectEq("error in iterating through objects: storage: bucket doesn't exist", err.Error())
+	ExpectThat(err, Error(HasSubstr("error in iterating through objects: storage: bucket doesn't exist")))
 	ExpectNe(nil, bucket.Syncer)
 }
 
@@ -180,6 +181,23 @@ func (t *BucketManagerTest) TestSetUpBucketMethodWhenBucketDoesNotExist_IsMultiB
 
 	bucket, err := bm.SetUpBucket(context.Background(), invalidBucketName, true, common.NewNoopMetrics())
 
-	ExpectEq("error in iterating through objects: storage: bucket doesn't exist", err.Error())
+	ExpectThat(err, Error(HasSubstr("error in iterating through objects: storage: bucket doesn't exist")))
 	ExpectNe(nil, bucket.Syncer)
 }
+
+func (t *BucketManagerTest) TestSetUpBucketMethodWithUnionLowerLayersAndTrace() {
+	var bm bucketManager
+	bucketConfig := BucketConfig{
+		TmpObjectPrefix:  "TmpObjectPrefix",
+		UnionLowerLayers: []string{TestBucketName + "/base"},
+		ReplayTrace:      "/tmp/trace",
+	}
+	ctx := context.Background()
+	bm.storageHandle = t.storageHandle
+	bm.config = bucketConfig
+	bm.gcCtx = ctx
+
+	_, err := bm.SetUpBucket(context.Background(), TestBucketName, false, common.NewNoopMetrics())
+
+	ExpectThat(err, Error(HasSubstr("union lower layers")))
+}
@@ -0,0 +1,447 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/jacobsa/timeutil"
+	"golang.org/x/net/context"
+)
+
+// The classes of operations guarded by separate circuit breakers.
+const (
+	opClassRead     = "read"
+	opClassMetadata = "metadata"
+	opClassWrite    = "write"
+)
+
+// The states of a circuit breaker.
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// CircuitBreakerConfig configures the circuit breakers around a bucket. See
+// NewCircuitBreakerBucket.
+type CircuitBreakerConfig struct {
+	// A breaker opens once this fraction of the recent requests it tracks
+	// failed, in (0, 1].
+	ErrorRateThreshold float64
+
+	// The number of recent requests tracked, and the number needed before the
+	// breaker may open.
+	WindowSize  int
+	MinRequests int
+
+	// How long a breaker stays open before letting a probe request through.
+	OpenDuration time.Duration
+
+	// Whether all requests but probes fail fast while any breaker isn't
+	// closed, modifications with EROFS.
+	ReadOnlyWhenOpen bool
+}
+
+// NewCircuitBreakerBucket creates a wrapper bucket with a circuit breaker for
+// each class of operations: reads of contents, reads of metadata and
+// modifications. A breaker opens once too many of the recent requests of its
+// class failed, after which requests of the class fail fast with EIO instead
+// of reaching the wrapped bucket. Once the open duration has elapsed, a single
+// probe request is let through, closing the breaker if it succeeds and
+// reopening it otherwise.
+//
+// Errors that say nothing about the health of GCS, such as not found and
+// precondition errors, count as successes, and cancelled requests aren't
+// counted at all. State transitions are logged and recorded with the metric
+// handle.
+//
+// Placed beneath the caching layers, reads that hit the stat, type and file
+// caches are still served while breakers are open.
+func NewCircuitBreakerBucket(
+	config CircuitBreakerConfig,
+	metricHandle common.MetricHandle,
+	clock timeutil.Clock,
+	wrapped gcs.Bucket) gcs.Bucket {
+	b := &circuitBreakerBucket{
+		Bucket:   wrapped,
+		config:   config,
+		breakers: make(map[string]*circuitBreaker),
+	}
+	for _, class := range []string{opClassRead, opClassMetadata, opClassWrite} {
+		b.breakers[class] = &circuitBreaker{
+			class:        class,
+			config:       &b.config,
+			metricHandle: metricHandle,
+			clock:        clock,
+			state:        breakerClosed,
+		}
+	}
+	return b
+}
+
+type circuitBreakerBucket struct {
+	gcs.Bucket
+	config CircuitBreakerConfig
+
+	// The breakers of each class of operations, keyed by class.
+	breakers map[string]*circuitBreaker
+}
+
+// circuitBreaker tracks the outcomes of the requests of a class of operations.
+type circuitBreaker struct {
+	class        string
+	config       *CircuitBreakerConfig
+	metricHandle common.MetricHandle
+	clock        timeutil.Clock
+
+	mu sync.Mutex
+
+	state string // GUARDED_BY(mu)
+
+	// The outcomes of the most recent requests while closed, true for
+	// failures, as a ring buffer whose oldest entry is at index next once
+	// full.
+	outcomes []bool // GUARDED_BY(mu)
+	next     int    // GUARDED_BY(mu)
+	failures int    // GUARDED_BY(mu)
+
+	// When the breaker last opened.
+	openedAt time.Time // GUARDED_BY(mu)
+
+	// Whether a probe request is in flight while half open.
+	probing bool // GUARDED_BY(mu)
+}
+
+// Enter the given state, logging and recording the transition.
+//
+// LOCKS_REQUIRED(cb.mu)
+func (cb *circuitBreaker) transition(ctx context.Context, state string, cause error) {
+	cb.state = state
+	cb.outcomes = cb.outcomes[:0]
+	cb.next = 0
+	cb.failures = 0
+
+	switch state {
+	case breakerOpen:
+		cb.openedAt = cb.clock.Now()
+		logger.Warnf("Circuit breaker for %s operations opened for %v, after: %v", cb.class, cb.config.OpenDuration, cause)
+	case breakerHalfOpen:
+		logger.Infof("Circuit breaker for %s operations half open, probing", cb.class)
+	case breakerClosed:
+		logger.Infof("Circuit breaker for %s operations closed", cb.class)
+	}
+	cb.metricHandle.GCSCircuitBreakerTransitionCount(ctx, 1, []common.MetricAttr{
+		{Key: common.OpClass, Value: cb.class},
+		{Key: common.CircuitBreakerState, Value: state},
+	})
+}
+
+// Return whether a request may be sent, and whether it's the probe of a half
+// open breaker.
+//
+// LOCKS_EXCLUDED(cb.mu)
+func (cb *circuitBreaker) allow(ctx context.Context) (ok bool, probe bool) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	switch cb.state {
+	case breakerOpen:
+		if cb.clock.Now().Sub(cb.openedAt) < cb.config.OpenDuration {
+			return false, false
+		}
+		cb.transition(ctx, breakerHalfOpen, nil)
+		fallthrough
+
+	case breakerHalfOpen:
+		if cb.probing {
+			return false, false
+		}
+		cb.probing = true
+		return true, true
+	}
+
+	return true, false
+}
+
+// LOCKS_EXCLUDED(cb.mu)
+func (cb *circuitBreaker) closed() bool {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	return cb.state == breakerClosed
+}
+
+// Record the outcome of a request allowed by allow.
+//
+// LOCKS_EXCLUDED(cb.mu)
+func (cb *circuitBreaker) record(ctx context.Context, probe bool, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	counted, failed := classifyOutcome(err)
+	if probe {
+		cb.probing = false
+		switch {
+		case !counted:
+			// Let the next request probe instead.
+		case failed:
+			cb.transition(ctx, breakerOpen, err)
+		default:
+			cb.transition(ctx, breakerClosed, nil)
+		}
+		return
+	}
+
+	// Requests sent before the breaker opened say nothing new.
+	if !counted || cb.state != breakerClosed {
+		return
+	}
+
+	if len(cb.outcomes) < cb.config.WindowSize {
+		cb.outcomes = append(cb.outcomes, failed)
+	} else {
+		if cb.outcomes[cb.next] {
+			cb.failures--
+		}
+		cb.outcomes[cb.next] = failed
+		cb.next = (cb.next + 1) % cb.config.WindowSize
+	}
+	if failed {
+		cb.failures++
+	}
+
+	if len(cb.outcomes) >= cb.config.MinRequests &&
+		float64(cb.failures) >= cb.config.ErrorRateThreshold*float64(len(cb.outcomes)) {
+		cb.transition(ctx, breakerOpen, err)
+	}
+}
+
+// Return whether the outcome of a request counts towards the error rate, and
+// whether it's a failure.
+func classifyOutcome(err error) (counted bool, failed bool) {
+	if err == nil {
+		return true, false
+	}
+	if errors.Is(err, context.Canceled) {
+		return false, false
+	}
+
+	var notFoundErr *gcs.NotFoundError
+	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &notFoundErr) || errors.As(err, &preconditionErr) {
+		return true, false
+	}
+	return true, true
+}
+
+// Return nil if a request of the given class may be sent, along with whether
+// it's a probe, or the error to fail it with otherwise.
+func (b *circuitBreakerBucket) admit(ctx context.Context, class string) (probe bool, err error) {
+	errno := syscall.EIO
+	if class == opClassWrite && b.config.ReadOnlyWhenOpen {
+		errno = syscall.EROFS
+	}
+
+	ok, probe := b.breakers[class].allow(ctx)
+	if !ok {
+		return false, fmt.Errorf("circuit breaker for %s operations is open: %w", class, errno)
+	}
+	if probe || !b.config.ReadOnlyWhenOpen {
+		return probe, nil
+	}
+
+	// Probes get through regardless, so that breakers open together may each
+	// close.
+	for other, cb := range b.breakers {
+		if !cb.closed() {
+			return false, fmt.Errorf("circuit breaker for %s operations is open: %w", other, errno)
+		}
+	}
+	return false, nil
+}
+
+// Send a request of the given class through the breakers.
+func guard[T any](
+	ctx context.Context,
+	b *circuitBreakerBucket,
+	class string,
+	f func() (T, error)) (T, error) {
+	probe, err := b.admit(ctx, class)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	v, err := f()
+	b.breakers[class].record(ctx, probe, err)
+	return v, err
+}
+
+func (b *circuitBreakerBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return guard(ctx, b, opClassRead, func() (io.ReadCloser, error) {
+		return b.Bucket.NewReader(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	return guard(ctx, b, opClassRead, func() (gcs.StorageReader, error) {
+		return b.Bucket.NewReaderWithReadHandle(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	return guard(ctx, b, opClassRead, func() (gcs.MultiRangeDownloader, error) {
+		return b.Bucket.NewMultiRangeDownloader(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.Object, error) {
+		return b.Bucket.CreateObject(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return guard(ctx, b, opClassWrite, func() (gcs.Writer, error) {
+		return b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+	})
+}
+
+func (b *circuitBreakerBucket) CreateAppendableObjectWriter(
+	ctx context.Context,
+	req *gcs.AppendObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return guard(ctx, b, opClassWrite, func() (gcs.Writer, error) {
+		return b.Bucket.CreateAppendableObjectWriter(ctx, req, chunkSize, callBack)
+	})
+}
+
+func (b *circuitBreakerBucket) FinalizeUpload(
+	ctx context.Context,
+	writer gcs.Writer) (*gcs.MinObject, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.MinObject, error) {
+		return b.Bucket.FinalizeUpload(ctx, writer)
+	})
+}
+
+func (b *circuitBreakerBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.Object, error) {
+		return b.Bucket.CopyObject(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.Object, error) {
+		return b.Bucket.ComposeObjects(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	var e *gcs.ExtendedObjectAttributes
+	m, err := guard(ctx, b, opClassMetadata, func() (m *gcs.MinObject, err error) {
+		m, e, err = b.Bucket.StatObject(ctx, req)
+		return
+	})
+	return m, e, err
+}
+
+func (b *circuitBreakerBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	return guard(ctx, b, opClassMetadata, func() (*gcs.Listing, error) {
+		return b.Bucket.ListObjects(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.Object, error) {
+		return b.Bucket.UpdateObject(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	_, err := guard(ctx, b, opClassWrite, func() (struct{}, error) {
+		return struct{}{}, b.Bucket.DeleteObject(ctx, req)
+	})
+	return err
+}
+
+func (b *circuitBreakerBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.Object, error) {
+		return b.Bucket.MoveObject(ctx, req)
+	})
+}
+
+func (b *circuitBreakerBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	_, err := guard(ctx, b, opClassWrite, func() (struct{}, error) {
+		return struct{}{}, b.Bucket.DeleteFolder(ctx, folderName)
+	})
+	return err
+}
+
+func (b *circuitBreakerBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return guard(ctx, b, opClassMetadata, func() (*gcs.Folder, error) {
+		return b.Bucket.GetFolder(ctx, folderName)
+	})
+}
+
+func (b *circuitBreakerBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.Folder, error) {
+		return b.Bucket.CreateFolder(ctx, folderName)
+	})
+}
+
+func (b *circuitBreakerBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (*gcs.Folder, error) {
+	return guard(ctx, b, opClassWrite, func() (*gcs.Folder, error) {
+		return b.Bucket.RenameFolder(ctx, folderName, destinationFolderId)
+	})
+}
@@ -0,0 +1,204 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+var errUnavailable = errors.New("service unavailable")
+
+// failingStatsBucket fails stats with its error, if any, counting the stats
+// that reached it.
+type failingStatsBucket struct {
+	gcs.Bucket
+	err   error
+	stats int
+}
+
+func (b *failingStatsBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	b.stats++
+	if b.err != nil {
+		return nil, nil, b.err
+	}
+	return b.Bucket.StatObject(ctx, req)
+}
+
+type breakerMetricHandle struct {
+	common.MetricHandle
+	transitions []string
+}
+
+func (h *breakerMetricHandle) GCSCircuitBreakerTransitionCount(_ context.Context, _ int64, attrs []common.MetricAttr) {
+	h.transitions = append(h.transitions, attrs[0].Value+":"+attrs[1].Value)
+}
+
+type CircuitBreakerBucketTest struct {
+	suite.Suite
+	ctx     context.Context
+	clock   timeutil.SimulatedClock
+	wrapped *failingStatsBucket
+	metrics *breakerMetricHandle
+	config  CircuitBreakerConfig
+}
+
+func TestCircuitBreakerBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(CircuitBreakerBucketTest))
+}
+
+func (t *CircuitBreakerBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.clock.SetTime(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	t.wrapped = &failingStatsBucket{
+		Bucket: fake.NewFakeBucket(&t.clock, "some_bucket", gcs.BucketType{}),
+	}
+	t.metrics = &breakerMetricHandle{MetricHandle: common.NewNoopMetrics()}
+	t.config = CircuitBreakerConfig{
+		ErrorRateThreshold: 0.5,
+		WindowSize:         10,
+		MinRequests:        4,
+		OpenDuration:       time.Minute,
+	}
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped.Bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+}
+
+func (t *CircuitBreakerBucketTest) newBucket() gcs.Bucket {
+	return NewCircuitBreakerBucket(t.config, t.metrics, &t.clock, t.wrapped)
+}
+
+func (t *CircuitBreakerBucketTest) stat(bucket gcs.Bucket, name string) error {
+	_, _, err := bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+	return err
+}
+
+// Open the breaker of metadata operations.
+func (t *CircuitBreakerBucketTest) open(bucket gcs.Bucket) {
+	t.wrapped.err = errUnavailable
+	for range t.config.MinRequests {
+		require.ErrorIs(t.T(), t.stat(bucket, "foo"), errUnavailable)
+	}
+	t.wrapped.err = nil
+}
+
+func (t *CircuitBreakerBucketTest) TestOpensOnceErrorRateIsReached() {
+	bucket := t.newBucket()
+	t.wrapped.err = errUnavailable
+	for range t.config.MinRequests - 1 {
+		assert.ErrorIs(t.T(), t.stat(bucket, "foo"), errUnavailable)
+	}
+	assert.Empty(t.T(), t.metrics.transitions)
+
+	assert.ErrorIs(t.T(), t.stat(bucket, "foo"), errUnavailable)
+	t.wrapped.err = nil
+
+	assert.ErrorIs(t.T(), t.stat(bucket, "foo"), syscall.EIO)
+	assert.Equal(t.T(), t.config.MinRequests, t.wrapped.stats)
+	assert.Equal(t.T(), []string{"metadata:open"}, t.metrics.transitions)
+}
+
+func (t *CircuitBreakerBucketTest) TestSuccessesKeepErrorRateDown() {
+	bucket := t.newBucket()
+	for range 2 * t.config.MinRequests {
+		t.wrapped.err = errUnavailable
+		assert.Error(t.T(), t.stat(bucket, "foo"))
+		t.wrapped.err = nil
+		assert.NoError(t.T(), t.stat(bucket, "foo"))
+		assert.NoError(t.T(), t.stat(bucket, "foo"))
+		// Not found errors are successes too.
+		assert.Error(t.T(), t.stat(bucket, "bar"))
+	}
+
+	assert.Empty(t.T(), t.metrics.transitions)
+}
+
+func (t *CircuitBreakerBucketTest) TestClassesHaveSeparateBreakers() {
+	bucket := t.newBucket()
+	t.open(bucket)
+
+	contents, err := storageutil.ReadObject(t.ctx, bucket, "foo")
+	assert.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+	_, err = storageutil.CreateObject(t.ctx, bucket, "bar", []byte("burrito"))
+	assert.NoError(t.T(), err)
+}
+
+func (t *CircuitBreakerBucketTest) TestSuccessfulProbeCloses() {
+	bucket := t.newBucket()
+	t.open(bucket)
+	t.clock.AdvanceTime(t.config.OpenDuration - time.Second)
+	assert.ErrorIs(t.T(), t.stat(bucket, "foo"), syscall.EIO)
+
+	t.clock.AdvanceTime(time.Second)
+	assert.NoError(t.T(), t.stat(bucket, "foo"))
+	assert.NoError(t.T(), t.stat(bucket, "foo"))
+
+	assert.Equal(t.T(), []string{"metadata:open", "metadata:half_open", "metadata:closed"}, t.metrics.transitions)
+}
+
+func (t *CircuitBreakerBucketTest) TestFailedProbeReopens() {
+	bucket := t.newBucket()
+	t.open(bucket)
+	t.clock.AdvanceTime(t.config.OpenDuration)
+
+	t.wrapped.err = errUnavailable
+	assert.ErrorIs(t.T(), t.stat(bucket, "foo"), errUnavailable)
+	t.wrapped.err = nil
+	assert.ErrorIs(t.T(), t.stat(bucket, "foo"), syscall.EIO)
+
+	assert.Equal(t.T(), []string{"metadata:open", "metadata:half_open", "metadata:open"}, t.metrics.transitions)
+}
+
+func (t *CircuitBreakerBucketTest) TestCancelledRequestsArentCounted() {
+	bucket := t.newBucket()
+	t.wrapped.err = context.Canceled
+	for range 2 * t.config.MinRequests {
+		assert.ErrorIs(t.T(), t.stat(bucket, "foo"), context.Canceled)
+	}
+
+	assert.Empty(t.T(), t.metrics.transitions)
+}
+
+func (t *CircuitBreakerBucketTest) TestReadOnlyWhenOpen() {
+	t.config.ReadOnlyWhenOpen = true
+	bucket := t.newBucket()
+	t.open(bucket)
+
+	_, err := storageutil.ReadObject(t.ctx, bucket, "foo")
+	assert.ErrorIs(t.T(), err, syscall.EIO)
+	_, err = storageutil.CreateObject(t.ctx, bucket, "bar", []byte("burrito"))
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+
+	// Once the probe succeeds, everything is let through again.
+	t.clock.AdvanceTime(t.config.OpenDuration)
+	assert.NoError(t.T(), t.stat(bucket, "foo"))
+	_, err = storageutil.CreateObject(t.ctx, bucket, "bar", []byte("burrito"))
+	assert.NoError(t.T(), err)
+}
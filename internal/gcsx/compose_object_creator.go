@@ -55,6 +55,12 @@ type composeObjectCreator struct {
 }
 
 func (oc *composeObjectCreator) chooseName() (name string, err error) {
+	return chooseTmpObjectName(oc.prefix)
+}
+
+// chooseTmpObjectName returns a random name for a temporary object beginning
+// with the given prefix.
+func chooseTmpObjectName(prefix string) (name string, err error) {
 	// Generate a good 64-bit random number.
 	var buf [8]byte
 	_, err = io.ReadFull(rand.Reader, buf[:])
@@ -73,7 +79,7 @@ func (oc *composeObjectCreator) chooseName() (name string, err error) {
 		uint64(buf[7])<<56
 
 	// Turn it into a name.
-	name = fmt.Sprintf("%s%016x", oc.prefix, x)
+	name = fmt.Sprintf("%s%016x", prefix, x)
 
 	return
 }
@@ -15,21 +15,60 @@
 package gcsx
 
 import (
+	"bytes"
+	"io"
 	"mime"
+	"net/http"
 	"path"
+	"strings"
 
+	"cloud.google.com/go/storage"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"golang.org/x/net/context"
 )
 
+// The number of leading bytes of content considered by http.DetectContentType.
+const sniffLen = 512
+
 // NewContentTypeBucket creates a wrapper bucket that guesses MIME types for
 // newly created or composed objects when an explicit type is not already set.
 func NewContentTypeBucket(b gcs.Bucket) gcs.Bucket {
-	return contentTypeBucket{b}
+	return contentTypeBucket{Bucket: b}
+}
+
+// NewSniffingContentTypeBucket is like NewContentTypeBucket, but guesses the
+// MIME types of objects created through CreateObject or
+// CreateObjectChunkWriter from the first bytes of their contents, falling back
+// to the extension for contents which aren't recognized.
+//
+// The chunk writers don't start the upload until they have been given enough
+// contents to guess from, or are finalized.
+func NewSniffingContentTypeBucket(b gcs.Bucket) gcs.Bucket {
+	return contentTypeBucket{Bucket: b, sniff: true}
 }
 
 type contentTypeBucket struct {
 	gcs.Bucket
+	sniff bool
+}
+
+// Guess the MIME type of an object with the given name and leading bytes of
+// contents.
+func guessContentType(name string, head []byte) string {
+	byExtension := mime.TypeByExtension(path.Ext(name))
+	if len(head) == 0 {
+		return byExtension
+	}
+
+	// The generic types only say that the contents weren't recognized beyond
+	// being text or not, which the extension may refine.
+	sniffed := http.DetectContentType(head)
+	if byExtension != "" &&
+		(sniffed == "application/octet-stream" || strings.HasPrefix(sniffed, "text/plain")) {
+		return byExtension
+	}
+
+	return sniffed
 }
 
 func (b contentTypeBucket) CreateObject(
@@ -37,7 +76,19 @@ func (b contentTypeBucket) CreateObject(
 	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
 	// Guess a content type if necessary.
 	if req.ContentType == "" {
-		req.ContentType = mime.TypeByExtension(path.Ext(req.Name))
+		if b.sniff {
+			head := make([]byte, sniffLen)
+			var n int
+			n, err = io.ReadFull(req.Contents, head)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return
+			}
+			head = head[:n]
+			req.Contents = io.MultiReader(bytes.NewReader(head), req.Contents)
+			req.ContentType = guessContentType(req.Name, head)
+		} else {
+			req.ContentType = mime.TypeByExtension(path.Ext(req.Name))
+		}
 	}
 
 	// Pass on the request.
@@ -59,11 +110,112 @@ func (b contentTypeBucket) ComposeObjects(
 }
 
 func (b contentTypeBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
-	// Guess a content type if necessary.
+	// Guess a content type if necessary, once the first bytes are written when
+	// sniffing.
 	if req.ContentType == "" {
+		if b.sniff {
+			return &sniffingWriter{
+				ctx:       ctx,
+				bucket:    b.Bucket,
+				req:       req,
+				chunkSize: chunkSize,
+				callBack:  callBack,
+			}, nil
+		}
 		req.ContentType = mime.TypeByExtension(path.Ext(req.Name))
 	}
 
 	// Pass on the request.
 	return b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
 }
+
+func (b contentTypeBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	if sw, ok := w.(*sniffingWriter); ok {
+		// Start the upload of contents too short to have started it.
+		if err := sw.start(nil); err != nil {
+			return nil, err
+		}
+		w = sw.wrapped
+	}
+
+	return b.Bucket.FinalizeUpload(ctx, w)
+}
+
+// sniffingWriter holds back the first bytes written until it can guess the
+// content type from them, and then creates the chunk writer of the wrapped
+// bucket for the rest.
+type sniffingWriter struct {
+	ctx       context.Context
+	bucket    gcs.Bucket
+	req       *gcs.CreateObjectRequest
+	chunkSize int
+	callBack  func(bytesUploadedSoFar int64)
+
+	// The bytes written before the wrapped writer was created.
+	head []byte
+
+	// Nil until the upload is started.
+	wrapped gcs.Writer
+}
+
+// Create the wrapped writer, if not already created, guessing the content
+// type from the bytes held back followed by the given ones.
+func (w *sniffingWriter) start(p []byte) (err error) {
+	if w.wrapped != nil {
+		return
+	}
+
+	head := w.head
+	if missing := sniffLen - len(head); missing > 0 {
+		head = append(head, p[:min(missing, len(p))]...)
+	}
+	w.req.ContentType = guessContentType(w.req.Name, head)
+
+	if w.wrapped, err = w.bucket.CreateObjectChunkWriter(w.ctx, w.req, w.chunkSize, w.callBack); err != nil {
+		return
+	}
+	if len(w.head) > 0 {
+		_, err = w.wrapped.Write(w.head)
+	}
+	w.head = nil
+	return
+}
+
+func (w *sniffingWriter) Write(p []byte) (n int, err error) {
+	if w.wrapped == nil {
+		if len(w.head)+len(p) < sniffLen {
+			w.head = append(w.head, p...)
+			return len(p), nil
+		}
+		if err = w.start(p); err != nil {
+			return
+		}
+	}
+
+	return w.wrapped.Write(p)
+}
+
+func (w *sniffingWriter) Close() error {
+	if err := w.start(nil); err != nil {
+		return err
+	}
+	return w.wrapped.Close()
+}
+
+func (w *sniffingWriter) Flush() (int64, error) {
+	if err := w.start(nil); err != nil {
+		return 0, err
+	}
+	return w.wrapped.Flush()
+}
+
+func (w *sniffingWriter) ObjectName() string {
+	return w.req.Name
+}
+
+func (w *sniffingWriter) Attrs() *storage.ObjectAttrs {
+	if w.wrapped == nil {
+		return nil
+	}
+	return w.wrapped.Attrs()
+}
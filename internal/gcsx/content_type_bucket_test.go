@@ -167,3 +167,127 @@ func TestContentTypeBucket_ComposeObjects(t *testing.T) {
 		}
 	}
 }
+
+var sniffingContentTypeBucketTestCases = []struct {
+	name     string
+	contents string
+	expected string // Expected final type
+}{
+	// Contents recognized without an extension.
+	0: {
+		name:     "foo/report",
+		contents: "<!DOCTYPE html><html><body>taco</body></html>",
+		expected: "text/html; charset=utf-8",
+	},
+
+	// Contents recognized despite a wrong extension.
+	1: {
+		name:     "foo/bar.jpg",
+		contents: "%PDF-1.7",
+		expected: "application/pdf",
+	},
+
+	// Plain text refined by the extension.
+	2: {
+		name:     "foo/bar.css",
+		contents: "body { color: red; }",
+		expected: "text/css; charset=utf-8",
+	},
+
+	// Plain text without an extension.
+	3: {
+		name:     "foo/bar",
+		contents: "taco",
+		expected: "text/plain; charset=utf-8",
+	},
+
+	// Empty contents fall back to the extension.
+	4: {
+		name:     "foo/bar.jpg",
+		contents: "",
+		expected: "image/jpeg",
+	},
+
+	// Contents longer than the sniffed prefix.
+	5: {
+		name:     "foo/bar",
+		contents: "<html>" + strings.Repeat("taco", 1000),
+		expected: "text/html; charset=utf-8",
+	},
+}
+
+func contentTypeOf(t *testing.T, bucket gcs.Bucket, name string) string {
+	_, e, err := bucket.StatObject(context.Background(), &gcs.StatObjectRequest{
+		Name:                           name,
+		ForceFetchFromGcs:              true,
+		ReturnExtendedObjectAttributes: true,
+	})
+	if err != nil {
+		t.Fatalf("StatObject: %v", err)
+	}
+	return e.ContentType
+}
+
+func TestSniffingContentTypeBucket_CreateObject(t *testing.T) {
+	for i, tc := range sniffingContentTypeBucketTestCases {
+		// Set up a bucket.
+		wrapped := fake.NewFakeBucket(timeutil.RealClock(), "", gcs.BucketType{})
+		bucket := gcsx.NewSniffingContentTypeBucket(wrapped)
+
+		// Create the object.
+		req := &gcs.CreateObjectRequest{
+			Name:     tc.name,
+			Contents: strings.NewReader(tc.contents),
+		}
+
+		o, err := bucket.CreateObject(context.Background(), req)
+		if err != nil {
+			t.Fatalf("Test case %d: CreateObject: %v", i, err)
+		}
+
+		// Check the content type and contents.
+		if got, want := o.ContentType, tc.expected; got != want {
+			t.Errorf("Test case %d: o.ContentType is %q, want %q", i, got, want)
+		}
+		if got, want := o.Size, uint64(len(tc.contents)); got != want {
+			t.Errorf("Test case %d: o.Size is %d, want %d", i, got, want)
+		}
+	}
+}
+
+func TestSniffingContentTypeBucket_CreateObjectChunkWriter(t *testing.T) {
+	ctx := context.Background()
+
+	for i, tc := range sniffingContentTypeBucketTestCases {
+		// Set up a bucket.
+		wrapped := fake.NewFakeBucket(timeutil.RealClock(), "", gcs.BucketType{})
+		bucket := gcsx.NewSniffingContentTypeBucket(wrapped)
+
+		// Write the contents a few bytes at a time, as streaming writes may.
+		req := &gcs.CreateObjectRequest{Name: tc.name}
+		w, err := bucket.CreateObjectChunkWriter(ctx, req, 0, func(_ int64) {})
+		if err != nil {
+			t.Fatalf("Test case %d: CreateObjectChunkWriter: %v", i, err)
+		}
+		for contents := tc.contents; contents != ""; {
+			n := min(len(contents), 100)
+			if _, err = w.Write([]byte(contents[:n])); err != nil {
+				t.Fatalf("Test case %d: Write: %v", i, err)
+			}
+			contents = contents[n:]
+		}
+
+		o, err := bucket.FinalizeUpload(ctx, w)
+		if err != nil {
+			t.Fatalf("Test case %d: FinalizeUpload: %v", i, err)
+		}
+
+		// Check the content type and contents.
+		if got, want := contentTypeOf(t, wrapped, tc.name), tc.expected; got != want {
+			t.Errorf("Test case %d: ContentType is %q, want %q", i, got, want)
+		}
+		if got, want := o.Size, uint64(len(tc.contents)); got != want {
+			t.Errorf("Test case %d: o.Size is %d, want %d", i, got, want)
+		}
+	}
+}
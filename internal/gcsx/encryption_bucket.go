@@ -0,0 +1,617 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+
+	"cloud.google.com/go/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// The contents of an encrypted object are a sequence of chunks, each sealed
+// with AES-256-GCM under the data key of the object, using the chunk index as
+// nonce. Every chunk but the last holds exactly sealedChunkSize bytes of
+// plaintext. The last one holds less, possibly none, and is sealed with
+// lastChunkData as additional data so that truncated contents don't decrypt.
+// The plaintext size thus follows from the size of the object.
+const (
+	sealedChunkSize    = 64 * 1024
+	sealedTagSize      = 16
+	sealedNonceSize    = 12
+	encryptedChunkSize = sealedChunkSize + sealedTagSize
+
+	dataKeySize = 32
+
+	// The metadata key of encrypted objects holding their data key, sealed by
+	// the key-encryption key and prefixed by the nonce, in base64.
+	wrappedDataKeyMetadataKey = "gcsfuse_wrapped_data_key"
+)
+
+var lastChunkData = []byte{1}
+
+// NewEncryptionBucket creates a wrapper bucket that encrypts the contents of
+// the objects created through it with a random data key per object, so that
+// their plaintext never reaches GCS. The data keys are wrapped by the given
+// 32-byte key-encryption key and stored in the metadata of the objects.
+//
+// Encrypted objects are presented with the size of their plaintext and
+// decrypted on read, and ranges of them are read by decrypting only the
+// chunks covering the range. Objects without a wrapped data key are read as
+// stored. Composing objects fails with an error wrapping errors.ErrUnsupported,
+// for callers to write the composed contents in full.
+func NewEncryptionBucket(kek []byte, wrapped gcs.Bucket) (gcs.Bucket, error) {
+	aead, err := newChunkAEAD(kek)
+	if err != nil {
+		return nil, fmt.Errorf("key-encryption key: %w", err)
+	}
+
+	return &encryptionBucket{
+		Bucket: wrapped,
+		kek:    aead,
+	}, nil
+}
+
+type encryptionBucket struct {
+	gcs.Bucket
+	kek cipher.AEAD
+}
+
+func newChunkAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("invalid key size %d, expected %d", len(key), dataKeySize)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func sealedChunkNonce(index uint64) []byte {
+	nonce := make([]byte, sealedNonceSize)
+	binary.BigEndian.PutUint64(nonce[sealedNonceSize-8:], index)
+	return nonce
+}
+
+// Return the size of the plaintext of an encrypted object of the given size.
+func plaintextSize(encryptedSize uint64) (size uint64, ok bool) {
+	last := encryptedSize % encryptedChunkSize
+	if last < sealedTagSize {
+		return 0, false
+	}
+	return encryptedSize/encryptedChunkSize*sealedChunkSize + last - sealedTagSize, true
+}
+
+// Return the size of an encrypted object whose plaintext has the given size.
+func encryptedSize(size uint64) uint64 {
+	return size/sealedChunkSize*encryptedChunkSize + size%sealedChunkSize + sealedTagSize
+}
+
+// Generate a data key for a new object, returning it along with its wrapped
+// form.
+func (b *encryptionBucket) newDataKey() (aead cipher.AEAD, wrapped string, err error) {
+	key := make([]byte, dataKeySize)
+	nonce := make([]byte, sealedNonceSize)
+	if _, err = rand.Read(key); err != nil {
+		return
+	}
+	if _, err = rand.Read(nonce); err != nil {
+		return
+	}
+
+	if aead, err = newChunkAEAD(key); err != nil {
+		return
+	}
+	sealed := b.kek.Seal(nonce, nonce, key, []byte(wrappedDataKeyMetadataKey))
+	wrapped = base64.StdEncoding.EncodeToString(sealed)
+	return
+}
+
+func (b *encryptionBucket) unwrapDataKey(wrapped string) (cipher.AEAD, error) {
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil || len(sealed) < sealedNonceSize {
+		return nil, errors.New("malformed wrapped data key")
+	}
+	key, err := b.kek.Open(nil, sealed[:sealedNonceSize], sealed[sealedNonceSize:], []byte(wrappedDataKeyMetadataKey))
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %w", err)
+	}
+	return newChunkAEAD(key)
+}
+
+// Return a copy of the request for the encrypted contents, storing the wrapped
+// data key in the metadata.
+func (b *encryptionBucket) encryptedRequest(req *gcs.CreateObjectRequest) (mReq *gcs.CreateObjectRequest, aead cipher.AEAD, err error) {
+	aead, wrapped, err := b.newDataKey()
+	if err != nil {
+		return nil, nil, fmt.Errorf("generating data key: %w", err)
+	}
+
+	// The checksums of the request are of the plaintext.
+	mReq = new(gcs.CreateObjectRequest)
+	*mReq = *req
+	mReq.CRC32C = nil
+	mReq.MD5 = nil
+	mReq.Metadata = maps.Clone(req.Metadata)
+	if mReq.Metadata == nil {
+		mReq.Metadata = make(map[string]string)
+	}
+	mReq.Metadata[wrappedDataKeyMetadataKey] = wrapped
+	return
+}
+
+// Present the object as its plaintext, if it is encrypted.
+func (b *encryptionBucket) presentMinObject(m *gcs.MinObject) *gcs.MinObject {
+	if m == nil || m.Metadata[wrappedDataKeyMetadataKey] == "" {
+		return m
+	}
+	size, ok := plaintextSize(m.Size)
+	if !ok {
+		return m
+	}
+
+	presented := *m
+	presented.Size = size
+	presented.CRC32C = nil
+	return &presented
+}
+
+func (b *encryptionBucket) presentObject(o *gcs.Object) *gcs.Object {
+	if o == nil || o.Metadata[wrappedDataKeyMetadataKey] == "" {
+		return o
+	}
+	size, ok := plaintextSize(o.Size)
+	if !ok {
+		return o
+	}
+
+	presented := *o
+	presented.Size = size
+	presented.CRC32C = nil
+	presented.MD5 = nil
+	return &presented
+}
+
+// Return the record of the given generation of the named object, or of its
+// latest one if zero. The record the caller has, as presented by the bucket,
+// is used if it is of that generation.
+func (b *encryptionBucket) statGeneration(
+	ctx context.Context,
+	name string,
+	generation int64,
+	record *gcs.MinObject) (*gcs.MinObject, error) {
+	if record != nil && generation != 0 && record.Name == name && record.Generation == generation {
+		if record.Metadata[wrappedDataKeyMetadataKey] == "" {
+			return record, nil
+		}
+		stored := *record
+		stored.Size = encryptedSize(record.Size)
+		return &stored, nil
+	}
+
+	m, _, err := b.Bucket.StatObject(ctx, &gcs.StatObjectRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if generation == 0 || m.Generation == generation {
+		return m, nil
+	}
+
+	// Look for a noncurrent generation.
+	req := &gcs.ListObjectsRequest{
+		Prefix:        name,
+		ProjectionVal: gcs.NoAcl,
+		Versions:      true,
+	}
+	for {
+		listing, err := b.Bucket.ListObjects(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range listing.MinObjects {
+			if o.Name == name && o.Generation == generation {
+				return o, nil
+			}
+		}
+
+		if listing.ContinuationToken == "" {
+			return nil, &gcs.NotFoundError{
+				Err: fmt.Errorf("object %q has no generation %d", name, generation),
+			}
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+}
+
+// Return a reader of the given range of the plaintext of the encrypted object.
+func (b *encryptionBucket) newDecryptingReader(
+	ctx context.Context,
+	m *gcs.MinObject,
+	byteRange *gcs.ByteRange) (gcs.StorageReader, error) {
+	aead, err := b.unwrapDataKey(m.Metadata[wrappedDataKeyMetadataKey])
+	if err != nil {
+		return nil, fmt.Errorf("decrypting %q: %w", m.Name, err)
+	}
+	size, ok := plaintextSize(m.Size)
+	if !ok {
+		return nil, fmt.Errorf("decrypting %q: invalid encrypted size %d", m.Name, m.Size)
+	}
+
+	start, limit := uint64(0), size
+	if byteRange != nil {
+		limit = min(byteRange.Limit, size)
+		start = min(byteRange.Start, limit)
+	}
+
+	// Read the chunks covering the range, which include the last one if the
+	// range reaches the end.
+	first := start / sealedChunkSize
+	end := min(m.Size, (limit/sealedChunkSize+1)*encryptedChunkSize)
+	rc, err := b.Bucket.NewReaderWithReadHandle(ctx, &gcs.ReadObjectRequest{
+		Name:           m.Name,
+		Generation:     m.Generation,
+		Range:          &gcs.ByteRange{Start: first * encryptedChunkSize, Limit: end},
+		ReadCompressed: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptingReader{
+		rc:        rc,
+		name:      m.Name,
+		aead:      aead,
+		index:     first,
+		lastIndex: size / sealedChunkSize,
+		skip:      start - first*sealedChunkSize,
+		remaining: limit - start,
+	}, nil
+}
+
+// decryptingReader reads the plaintext of a range of an encrypted object from
+// a reader of the chunks covering it.
+type decryptingReader struct {
+	rc   gcs.StorageReader
+	name string
+	aead cipher.AEAD
+
+	// The index of the next chunk, and that of the last chunk of the object.
+	index     uint64
+	lastIndex uint64
+
+	// The number of plaintext bytes of the next chunk before the range, and
+	// those left in the range.
+	skip      uint64
+	remaining uint64
+
+	// The plaintext of the current chunk not read yet.
+	plaintext []byte
+	buf       []byte
+}
+
+func (r *decryptingReader) Read(p []byte) (n int, err error) {
+	if r.remaining == 0 {
+		return 0, io.EOF
+	}
+
+	if len(r.plaintext) == 0 {
+		if err = r.openChunk(); err != nil {
+			return
+		}
+	}
+
+	n = copy(p, r.plaintext[:min(uint64(len(r.plaintext)), r.remaining)])
+	r.plaintext = r.plaintext[n:]
+	r.remaining -= uint64(n)
+	return
+}
+
+// Read and decrypt the next chunk.
+func (r *decryptingReader) openChunk() error {
+	if r.buf == nil {
+		r.buf = make([]byte, encryptedChunkSize)
+	}
+	n, err := io.ReadFull(r.rc, r.buf)
+	if err != nil && !(err == io.ErrUnexpectedEOF && r.index == r.lastIndex) {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("reading chunk %d of %q: %w", r.index, r.name, err)
+	}
+
+	var additionalData []byte
+	if r.index == r.lastIndex {
+		additionalData = lastChunkData
+	}
+	plaintext, err := r.aead.Open(r.buf[:0], sealedChunkNonce(r.index), r.buf[:n], additionalData)
+	if err != nil {
+		return fmt.Errorf("decrypting chunk %d of %q: %w", r.index, r.name, err)
+	}
+
+	r.index++
+	r.plaintext = plaintext[min(r.skip, uint64(len(plaintext))):]
+	r.skip = 0
+	if len(r.plaintext) == 0 {
+		return fmt.Errorf("decrypting %q: %w", r.name, io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+func (r *decryptingReader) Close() error {
+	return r.rc.Close()
+}
+
+func (r *decryptingReader) ReadHandle() storage.ReadHandle {
+	return r.rc.ReadHandle()
+}
+
+// sealingWriter encrypts the plaintext written to it into chunks written to
+// the wrapped writer. Close seals the last chunk, without closing the wrapped
+// writer.
+type sealingWriter struct {
+	w     io.Writer
+	aead  cipher.AEAD
+	index uint64
+	buf   []byte
+
+	sealed []byte
+}
+
+func newSealingWriter(w io.Writer, aead cipher.AEAD) *sealingWriter {
+	return &sealingWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, sealedChunkSize),
+	}
+}
+
+func (w *sealingWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		copied := copy(w.buf[len(w.buf):sealedChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+copied]
+		p = p[copied:]
+		n += copied
+
+		// A full chunk is never the last one.
+		if len(w.buf) == sealedChunkSize {
+			if err = w.writeChunk(nil); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+func (w *sealingWriter) Close() error {
+	return w.writeChunk(lastChunkData)
+}
+
+func (w *sealingWriter) writeChunk(additionalData []byte) error {
+	w.sealed = w.aead.Seal(w.sealed[:0], sealedChunkNonce(w.index), w.buf, additionalData)
+	if _, err := w.w.Write(w.sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// encryptingWriter encrypts the contents written to a chunk writer.
+type encryptingWriter struct {
+	*sealingWriter
+	wrapped gcs.Writer
+}
+
+func (w *encryptingWriter) Close() error {
+	if err := w.sealingWriter.Close(); err != nil {
+		return err
+	}
+	return w.wrapped.Close()
+}
+
+// Flush is unsupported: the contents are only sealed once the writer is
+// closed.
+func (w *encryptingWriter) Flush() (int64, error) {
+	return 0, fmt.Errorf("flushing encrypted object %q: %w", w.ObjectName(), errors.ErrUnsupported)
+}
+
+func (w *encryptingWriter) ObjectName() string {
+	return w.wrapped.ObjectName()
+}
+
+func (w *encryptingWriter) Attrs() *storage.ObjectAttrs {
+	return w.wrapped.Attrs()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+func (b *encryptionBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
+	rc, err = b.NewReaderWithReadHandle(ctx, req)
+	return
+}
+
+func (b *encryptionBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	m, err := b.statGeneration(ctx, req.Name, req.Generation, req.Object)
+	if err != nil {
+		return nil, err
+	}
+	if m.Metadata[wrappedDataKeyMetadataKey] == "" {
+		return b.Bucket.NewReaderWithReadHandle(ctx, req)
+	}
+
+	return b.newDecryptingReader(ctx, m, req.Range)
+}
+
+func (b *encryptionBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	m, err := b.statGeneration(ctx, req.Name, req.Generation, req.Object)
+	if err != nil {
+		return nil, err
+	}
+	if m.Metadata[wrappedDataKeyMetadataKey] == "" {
+		return b.Bucket.NewMultiRangeDownloader(ctx, req)
+	}
+
+	return newReaderMultiRangeDownloader(ctx, func(ctx context.Context, r *gcs.ByteRange) (gcs.StorageReader, error) {
+		return b.newDecryptingReader(ctx, m, r)
+	}), nil
+}
+
+func (b *encryptionBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	mReq, aead, err := b.encryptedRequest(req)
+	if err != nil {
+		return
+	}
+
+	// Encrypt the contents as they are uploaded, giving up if the upload stops
+	// early.
+	pr, pw := io.Pipe()
+	defer pr.Close()
+	go func() {
+		sw := newSealingWriter(pw, aead)
+		_, err := io.Copy(sw, req.Contents)
+		if err == nil {
+			err = sw.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+
+	mReq.Contents = pr
+	o, err = b.Bucket.CreateObject(ctx, mReq)
+	o = b.presentObject(o)
+	return
+}
+
+func (b *encryptionBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	mReq, aead, err := b.encryptedRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	w, err := b.Bucket.CreateObjectChunkWriter(ctx, mReq, chunkSize, callBack)
+	if err != nil {
+		return nil, err
+	}
+
+	return &encryptingWriter{sealingWriter: newSealingWriter(w, aead), wrapped: w}, nil
+}
+
+func (b *encryptionBucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, fmt.Errorf("appending to encrypted object %q: %w", req.Name, errors.ErrUnsupported)
+}
+
+func (b *encryptionBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	ew, ok := w.(*encryptingWriter)
+	if !ok {
+		return b.Bucket.FinalizeUpload(ctx, w)
+	}
+
+	if err := ew.sealingWriter.Close(); err != nil {
+		return nil, err
+	}
+	m, err := b.Bucket.FinalizeUpload(ctx, ew.wrapped)
+	if err != nil {
+		return nil, err
+	}
+	return b.presentMinObject(m), nil
+}
+
+func (b *encryptionBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	// The wrapped data key is copied along with the metadata.
+	o, err = b.Bucket.CopyObject(ctx, req)
+	o = b.presentObject(o)
+	return
+}
+
+func (b *encryptionBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	// Objects encrypted with different data keys can't be composed.
+	return nil, fmt.Errorf("composing encrypted object %q: %w", req.DstName, errors.ErrUnsupported)
+}
+
+func (b *encryptionBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	m, e, err = b.Bucket.StatObject(ctx, req)
+	m = b.presentMinObject(m)
+	if e != nil && m != nil && m.Metadata[wrappedDataKeyMetadataKey] != "" {
+		// The MD5 hash is of the encrypted contents.
+		presented := *e
+		presented.MD5 = nil
+		e = &presented
+	}
+	return
+}
+
+func (b *encryptionBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	wrappedListing, err := b.Bucket.ListObjects(ctx, req)
+	if err != nil {
+		return
+	}
+
+	listing = &gcs.Listing{
+		CollapsedRuns:     wrappedListing.CollapsedRuns,
+		ContinuationToken: wrappedListing.ContinuationToken,
+		Lifetimes:         wrappedListing.Lifetimes,
+	}
+	for _, m := range wrappedListing.MinObjects {
+		listing.MinObjects = append(listing.MinObjects, b.presentMinObject(m))
+	}
+	return
+}
+
+func (b *encryptionBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
+	if _, ok := req.Metadata[wrappedDataKeyMetadataKey]; ok {
+		err = fmt.Errorf("metadata key %q is reserved for encryption", wrappedDataKeyMetadataKey)
+		return
+	}
+
+	o, err = b.Bucket.UpdateObject(ctx, req)
+	o = b.presentObject(o)
+	return
+}
+
+func (b *encryptionBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (o *gcs.Object, err error) {
+	o, err = b.Bucket.MoveObject(ctx, req)
+	o = b.presentObject(o)
+	return
+}
@@ -0,0 +1,290 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type EncryptionBucketTest struct {
+	suite.Suite
+	ctx     context.Context
+	kek     []byte
+	wrapped gcs.Bucket
+	bucket  gcs.Bucket
+}
+
+func TestEncryptionBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(EncryptionBucketTest))
+}
+
+func (t *EncryptionBucketTest) SetupTest() {
+	var err error
+	t.ctx = context.Background()
+	t.kek = bytes.Repeat([]byte{7}, dataKeySize)
+	t.wrapped = fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	t.bucket, err = NewEncryptionBucket(t.kek, t.wrapped)
+	require.NoError(t.T(), err)
+}
+
+// Return contents spanning several chunks.
+func encryptionTestContents(size int) []byte {
+	contents := make([]byte, size)
+	for i := range contents {
+		contents[i] = byte(i % 251)
+	}
+	return contents
+}
+
+func (t *EncryptionBucketTest) readRange(name string, start, limit uint64) []byte {
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:  name,
+		Range: &gcs.ByteRange{Start: start, Limit: limit},
+	})
+	require.NoError(t.T(), err)
+	defer rc.Close()
+	contents, err := io.ReadAll(rc)
+	require.NoError(t.T(), err)
+	return contents
+}
+
+func (t *EncryptionBucketTest) TestNewEncryptionBucketRejectsInvalidKey() {
+	_, err := NewEncryptionBucket([]byte("short"), t.wrapped)
+
+	assert.Error(t.T(), err)
+}
+
+func (t *EncryptionBucketTest) TestCreateObjectStoresCiphertext() {
+	contents := encryptionTestContents(2*sealedChunkSize + 100)
+
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", contents)
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), len(contents), o.Size)
+	assert.Nil(t.T(), o.CRC32C)
+	stored, err := storageutil.ReadObject(t.ctx, t.wrapped, "foo")
+	require.NoError(t.T(), err)
+	assert.Len(t.T(), stored, len(contents)+3*sealedTagSize)
+	assert.False(t.T(), bytes.Contains(stored, contents[:64]))
+	m, _, err := t.wrapped.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	assert.NotEmpty(t.T(), m.Metadata[wrappedDataKeyMetadataKey])
+}
+
+func (t *EncryptionBucketTest) TestReadsDecryptWholeAndRanges() {
+	contents := encryptionTestContents(3 * sealedChunkSize)
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", contents)
+	require.NoError(t.T(), err)
+
+	read, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), contents, read)
+	testCases := []struct{ start, limit uint64 }{
+		{0, 10},
+		{sealedChunkSize - 5, sealedChunkSize + 5},
+		{sealedChunkSize, 2 * sealedChunkSize},
+		{2*sealedChunkSize + 1, 3 * sealedChunkSize},
+		{3 * sealedChunkSize, 3 * sealedChunkSize},
+		{100, 10 * sealedChunkSize},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t.T(), contents[tc.start:min(tc.limit, uint64(len(contents)))], t.readRange("foo", tc.start, tc.limit))
+	}
+}
+
+func (t *EncryptionBucketTest) TestStatAndListPresentPlaintextSize() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", encryptionTestContents(1000))
+	require.NoError(t.T(), err)
+
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 1000, m.Size)
+	objects, _, err := storageutil.ListAll(t.ctx, t.bucket, &gcs.ListObjectsRequest{})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), objects, 1)
+	assert.EqualValues(t.T(), 1000, objects[0].Size)
+}
+
+func (t *EncryptionBucketTest) TestChunkWriterEncrypts() {
+	contents := encryptionTestContents(sealedChunkSize + 1)
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: "foo"}, 1024, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write(contents)
+	require.NoError(t.T(), err)
+
+	m, err := t.bucket.FinalizeUpload(t.ctx, w)
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), len(contents), m.Size)
+	read, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), contents, read)
+}
+
+func (t *EncryptionBucketTest) TestEmptyObject() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", nil)
+	require.NoError(t.T(), err)
+
+	read, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Empty(t.T(), read)
+}
+
+func (t *EncryptionBucketTest) TestTruncatedObjectFailsToDecrypt() {
+	contents := encryptionTestContents(2 * sealedChunkSize)
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", contents)
+	require.NoError(t.T(), err)
+	stored, err := storageutil.ReadObject(t.ctx, t.wrapped, "foo")
+	require.NoError(t.T(), err)
+	m, _, err := t.wrapped.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	// Drop the last chunk, leaving whole chunks which look complete.
+	_, err = t.wrapped.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "foo",
+		Contents: bytes.NewReader(stored[:2*encryptedChunkSize]),
+		Metadata: m.Metadata,
+	})
+	require.NoError(t.T(), err)
+
+	_, err = storageutil.ReadObject(t.ctx, t.bucket, "foo")
+
+	assert.Error(t.T(), err)
+}
+
+func (t *EncryptionBucketTest) TestWrongKeyFailsToDecrypt() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", encryptionTestContents(10))
+	require.NoError(t.T(), err)
+	bucket, err := NewEncryptionBucket(bytes.Repeat([]byte{8}, dataKeySize), t.wrapped)
+	require.NoError(t.T(), err)
+
+	_, err = bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo"})
+
+	assert.Error(t.T(), err)
+}
+
+func (t *EncryptionBucketTest) TestUnencryptedObjectsAreReadAsStored() {
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+
+	read, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(read))
+}
+
+func (t *EncryptionBucketTest) TestComposeObjectsFails() {
+	src, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "tmp", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	_, err = t.bucket.ComposeObjects(t.ctx, &gcs.ComposeObjectsRequest{
+		DstName:                   "foo",
+		DstGenerationPrecondition: &src.Generation,
+		Sources:                   []gcs.ComposeSource{{Name: "foo", Generation: src.Generation}, {Name: "tmp"}},
+	})
+
+	assert.ErrorIs(t.T(), err, errors.ErrUnsupported)
+	read, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(read))
+}
+
+func (t *EncryptionBucketTest) TestMultiRangeDownloaderDecrypts() {
+	contents := encryptionTestContents(2 * sealedChunkSize)
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", contents)
+	require.NoError(t.T(), err)
+	mrd, err := t.bucket.NewMultiRangeDownloader(t.ctx, &gcs.MultiRangeDownloaderRequest{
+		Name:       "foo",
+		Generation: o.Generation,
+	})
+	require.NoError(t.T(), err)
+	var buf bytes.Buffer
+	var callbackErr error
+
+	mrd.Add(&buf, sealedChunkSize-3, 10, func(_ int64, _ int64, err error) { callbackErr = err })
+	mrd.Wait()
+
+	require.NoError(t.T(), callbackErr)
+	assert.Equal(t.T(), contents[sealedChunkSize-3:sealedChunkSize+7], buf.Bytes())
+	assert.NoError(t.T(), mrd.Close())
+}
+
+func (t *EncryptionBucketTest) TestReadsUseRecordOfRequest() {
+	counting := &statCountingBucket{Bucket: t.wrapped}
+	var err error
+	t.bucket, err = NewEncryptionBucket(t.kek, counting)
+	require.NoError(t.T(), err)
+	contents := encryptionTestContents(2*sealedChunkSize + 100)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "foo", contents)
+	require.NoError(t.T(), err)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	counting.stats = 0
+
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:       "foo",
+		Generation: m.Generation,
+		Range:      &gcs.ByteRange{Start: sealedChunkSize - 3, Limit: 2*sealedChunkSize + 100},
+		Object:     m,
+	})
+	require.NoError(t.T(), err)
+	defer rc.Close()
+	read, err := io.ReadAll(rc)
+	require.NoError(t.T(), err)
+	mrd, err := t.bucket.NewMultiRangeDownloader(t.ctx, &gcs.MultiRangeDownloaderRequest{
+		Name:       "foo",
+		Generation: m.Generation,
+		Object:     m,
+	})
+	require.NoError(t.T(), err)
+	var buf bytes.Buffer
+	var callbackErr error
+	mrd.Add(&buf, 2*sealedChunkSize, 100, func(_ int64, _ int64, err error) { callbackErr = err })
+
+	assert.NoError(t.T(), mrd.Close())
+	assert.NoError(t.T(), callbackErr)
+	assert.Equal(t.T(), contents[sealedChunkSize-3:], read)
+	assert.Equal(t.T(), contents[2*sealedChunkSize:], buf.Bytes())
+	assert.Zero(t.T(), counting.stats)
+}
+
+func (t *EncryptionBucketTest) TestUpdateObjectRejectsWrappedKey() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	value := "bar"
+
+	_, err = t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+		Name:     "foo",
+		Metadata: map[string]*string{wrappedDataKeyMetadataKey: &value},
+	})
+
+	assert.Error(t.T(), err)
+}
@@ -0,0 +1,416 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// Set in the generations of the objects served from the secondary bucket,
+	// which are real generations well below it.
+	secondaryGeneration int64 = 1 << 62
+
+	// Prefixes the continuation tokens of listings served from the secondary
+	// bucket.
+	secondaryTokenPrefix = "secondary:"
+)
+
+var errFailoverReadOnly = fmt.Errorf("failover mounts are read-only: %w", syscall.EROFS)
+
+// NewFailoverBucket creates a read-only bucket serving reads, stats and
+// listings from the primary bucket, falling back to the secondary one, a
+// replica of it, when the primary fails with a not found or server error, or
+// doesn't respond within the timeout, if non-zero.
+//
+// Generations differ across buckets, so the generations of objects served
+// from the secondary are tagged, and reads of those generations go to the
+// secondary only. Reads of primary generations fall back to the live object
+// of the secondary only when the primary fails with a server error or times
+// out, and the size and CRC32C of that object match those of the record of
+// the read. Otherwise they fail with a not found error, and the object has to
+// be looked up again. Listings continued from a page of the primary don't fall
+// back, unlike new ones.
+func NewFailoverBucket(primary, secondary gcs.Bucket, timeout time.Duration) gcs.Bucket {
+	return &failoverBucket{
+		primary:   primary,
+		secondary: secondary,
+		timeout:   timeout,
+	}
+}
+
+type failoverBucket struct {
+	primary   gcs.Bucket
+	secondary gcs.Bucket
+	timeout   time.Duration
+}
+
+// Return true if requests failing with the given error should be sent to the
+// secondary bucket.
+func shouldFailOver(err error) bool {
+	var notFoundErr *gcs.NotFoundError
+	if errors.As(err, &notFoundErr) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var googleAPIErr *googleapi.Error
+	if errors.As(err, &googleAPIErr) {
+		return googleAPIErr.Code >= 500
+	}
+
+	// As returned by S3-compatible buckets.
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode() >= 500
+	}
+
+	if s, ok := status.FromError(err); ok {
+		switch s.Code() {
+		case codes.Internal, codes.Unavailable, codes.DeadlineExceeded:
+			return true
+		}
+	}
+	return false
+}
+
+// Call f with a context cancelled once the timeout elapses, unless f returned
+// by then. The returned function cancels the context once v is no longer used,
+// and discard releases v if f returned too late.
+func callWithTimeout[T any](
+	ctx context.Context,
+	timeout time.Duration,
+	f func(context.Context) (T, error),
+	discard func(T)) (v T, cancel context.CancelFunc, err error) {
+	ctx, cancel = context.WithCancel(ctx)
+	if timeout == 0 {
+		v, err = f(ctx)
+		return
+	}
+
+	timer := time.AfterFunc(timeout, cancel)
+	v, err = f(ctx)
+	if !timer.Stop() {
+		if err == nil && discard != nil {
+			discard(v)
+		}
+		var zero T
+		return zero, cancel, fmt.Errorf("no response within %v: %w", timeout, context.DeadlineExceeded)
+	}
+	return
+}
+
+// Send a request to the primary bucket, then to the secondary one if needed,
+// along with the error of the primary. The request may modify v when sent to
+// the secondary, whose generations must be tagged.
+func failOver[T any](
+	ctx context.Context,
+	b *failoverBucket,
+	op string,
+	name string,
+	f func(ctx context.Context, bucket gcs.Bucket, primaryErr error) (T, error),
+	discard func(T)) (v T, cancel context.CancelFunc, err error) {
+	v, cancel, err = callWithTimeout(ctx, b.timeout, func(ctx context.Context) (T, error) {
+		return f(ctx, b.primary, nil)
+	}, discard)
+	if err == nil || !shouldFailOver(err) {
+		return
+	}
+	cancel()
+
+	logger.Tracef("%s(%q) falling back to failover bucket %q after: %v", op, name, b.secondary.Name(), err)
+	ctx, cancel = context.WithCancel(ctx)
+	v, err = f(ctx, b.secondary, err)
+	return
+}
+
+// Return a copy of o with its generation tagged as a secondary one.
+func tagSecondary(o *gcs.MinObject) *gcs.MinObject {
+	if o == nil {
+		return nil
+	}
+	c := *o
+	c.Generation |= secondaryGeneration
+	return &c
+}
+
+func (b *failoverBucket) Name() string {
+	return b.primary.Name()
+}
+
+func (b *failoverBucket) BucketType() gcs.BucketType {
+	return b.primary.BucketType()
+}
+
+func (b *failoverBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *failoverBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	if req.Generation&secondaryGeneration != 0 {
+		sReq := *req
+		sReq.Generation &^= secondaryGeneration
+		sReq.ReadHandle = nil
+		return b.secondary.NewReaderWithReadHandle(ctx, &sReq)
+	}
+
+	rd, cancel, err := failOver(ctx, b, "NewReader", req.Name,
+		func(ctx context.Context, bucket gcs.Bucket, primaryErr error) (gcs.StorageReader, error) {
+			if primaryErr == nil {
+				return bucket.NewReaderWithReadHandle(ctx, req)
+			}
+			sReq := *req
+			sReq.ReadHandle = nil
+			if req.Generation != 0 {
+				replica, err := b.findReplica(ctx, req.Name, req.Generation, req.Object, primaryErr)
+				if err != nil {
+					return nil, err
+				}
+				sReq.Generation = replica.Generation
+				sReq.Object = replica
+			}
+			return bucket.NewReaderWithReadHandle(ctx, &sReq)
+		},
+		func(rd gcs.StorageReader) { rd.Close() })
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &failoverReader{StorageReader: rd, cancel: cancel}, nil
+}
+
+func (b *failoverBucket) primaryOnly(name string, generation int64) error {
+	return &gcs.NotFoundError{
+		Err: fmt.Errorf("generation %d of %q is in bucket %q only, which is unavailable", generation, name, b.primary.Name()),
+	}
+}
+
+// Return the live object of the secondary bucket with the given name, if it is
+// a replica of the given generation of the primary, whose record is that of
+// the read. The primary must have failed with an error other than not found,
+// which means that the generation is gone.
+func (b *failoverBucket) findReplica(
+	ctx context.Context,
+	name string,
+	generation int64,
+	record *gcs.MinObject,
+	primaryErr error) (*gcs.MinObject, error) {
+	var notFoundErr *gcs.NotFoundError
+	if errors.As(primaryErr, &notFoundErr) ||
+		record == nil || record.Generation != generation || record.CRC32C == nil {
+		return nil, b.primaryOnly(name, generation)
+	}
+
+	m, _, err := b.secondary.StatObject(ctx, &gcs.StatObjectRequest{Name: name})
+	if err != nil {
+		return nil, err
+	}
+	if m.Size != record.Size || m.CRC32C == nil || *m.CRC32C != *record.CRC32C {
+		return nil, b.primaryOnly(name, generation)
+	}
+	return m, nil
+}
+
+// failoverReader cancels the context of its request once closed.
+type failoverReader struct {
+	gcs.StorageReader
+	cancel context.CancelFunc
+}
+
+func (r *failoverReader) Close() error {
+	err := r.StorageReader.Close()
+	r.cancel()
+	return err
+}
+
+func (b *failoverBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	if req.Generation&secondaryGeneration != 0 {
+		sReq := *req
+		sReq.Generation &^= secondaryGeneration
+		return b.secondary.NewMultiRangeDownloader(ctx, &sReq)
+	}
+
+	// Downloads outlive the request, so they aren't timed.
+	mrd, err := b.primary.NewMultiRangeDownloader(ctx, req)
+	if err == nil || !shouldFailOver(err) {
+		return mrd, err
+	}
+	sReq := *req
+	if req.Generation != 0 {
+		replica, err := b.findReplica(ctx, req.Name, req.Generation, req.Object, err)
+		if err != nil {
+			return nil, err
+		}
+		sReq.Generation = replica.Generation
+		sReq.Object = replica
+	}
+	return b.secondary.NewMultiRangeDownloader(ctx, &sReq)
+}
+
+func (b *failoverBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	var e *gcs.ExtendedObjectAttributes
+	m, cancel, err := failOver(ctx, b, "StatObject", req.Name,
+		func(ctx context.Context, bucket gcs.Bucket, primaryErr error) (m *gcs.MinObject, err error) {
+			m, e, err = bucket.StatObject(ctx, req)
+			if primaryErr != nil {
+				m = tagSecondary(m)
+			}
+			return
+		}, nil)
+	cancel()
+	return m, e, err
+}
+
+func (b *failoverBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	listSecondary := func(ctx context.Context, req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+		listing, err := b.secondary.ListObjects(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		tagged := &gcs.Listing{CollapsedRuns: listing.CollapsedRuns}
+		for _, o := range listing.MinObjects {
+			tagged.MinObjects = append(tagged.MinObjects, tagSecondary(o))
+		}
+		for _, l := range listing.Lifetimes {
+			l.Generation |= secondaryGeneration
+			tagged.Lifetimes = append(tagged.Lifetimes, l)
+		}
+		if listing.ContinuationToken != "" {
+			tagged.ContinuationToken = secondaryTokenPrefix + listing.ContinuationToken
+		}
+		return tagged, nil
+	}
+
+	if token, ok := strings.CutPrefix(req.ContinuationToken, secondaryTokenPrefix); ok {
+		sReq := *req
+		sReq.ContinuationToken = token
+		return listSecondary(ctx, &sReq)
+	}
+	if req.ContinuationToken != "" {
+		return b.primary.ListObjects(ctx, req)
+	}
+
+	listing, cancel, err := failOver(ctx, b, "ListObjects", req.Prefix,
+		func(ctx context.Context, bucket gcs.Bucket, primaryErr error) (*gcs.Listing, error) {
+			if primaryErr != nil {
+				return listSecondary(ctx, req)
+			}
+			return bucket.ListObjects(ctx, req)
+		}, nil)
+	cancel()
+	return listing, err
+}
+
+func (b *failoverBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	f, cancel, err := failOver(ctx, b, "GetFolder", folderName,
+		func(ctx context.Context, bucket gcs.Bucket, _ error) (*gcs.Folder, error) {
+			return bucket.GetFolder(ctx, folderName)
+		}, nil)
+	cancel()
+	return f, err
+}
+
+func (b *failoverBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) CreateAppendableObjectWriter(
+	ctx context.Context,
+	req *gcs.AppendObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) FinalizeUpload(ctx context.Context, writer gcs.Writer) (*gcs.MinObject, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	return errFailoverReadOnly
+}
+
+func (b *failoverBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	return errFailoverReadOnly
+}
+
+func (b *failoverBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return nil, errFailoverReadOnly
+}
+
+func (b *failoverBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (*gcs.Folder, error) {
+	return nil, errFailoverReadOnly
+}
@@ -0,0 +1,262 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/googleapi"
+)
+
+// unavailableBucket fails reads, stats and listings with its error, if any, or
+// blocks them until their context is cancelled if hang is set.
+type unavailableBucket struct {
+	gcs.Bucket
+	err  error
+	hang bool
+}
+
+func (b *unavailableBucket) fail(ctx context.Context) error {
+	if b.hang {
+		<-ctx.Done()
+		return ctx.Err()
+	}
+	return b.err
+}
+
+func (b *unavailableBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	if err := b.fail(ctx); err != nil {
+		return nil, err
+	}
+	return b.Bucket.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *unavailableBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	if err := b.fail(ctx); err != nil {
+		return nil, nil, err
+	}
+	return b.Bucket.StatObject(ctx, req)
+}
+
+func (b *unavailableBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	if err := b.fail(ctx); err != nil {
+		return nil, err
+	}
+	return b.Bucket.ListObjects(ctx, req)
+}
+
+type FailoverBucketTest struct {
+	suite.Suite
+	ctx       context.Context
+	primary   *unavailableBucket
+	secondary gcs.Bucket
+	bucket    gcs.Bucket
+}
+
+func TestFailoverBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(FailoverBucketTest))
+}
+
+func (t *FailoverBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.primary = &unavailableBucket{
+		Bucket: fake.NewFakeBucket(timeutil.RealClock(), "primary", gcs.BucketType{}),
+	}
+	t.secondary = fake.NewFakeBucket(timeutil.RealClock(), "secondary", gcs.BucketType{})
+	t.bucket = NewFailoverBucket(t.primary, t.secondary, 50*time.Millisecond)
+
+	_, err := storageutil.CreateObject(t.ctx, t.primary.Bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	for _, name := range []string{"foo", "bar", "baz"} {
+		_, err = storageutil.CreateObject(t.ctx, t.secondary, name, []byte("replica of "+name))
+		require.NoError(t.T(), err)
+	}
+}
+
+func (t *FailoverBucketTest) stat(name string) (*gcs.MinObject, error) {
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+	return m, err
+}
+
+func (t *FailoverBucketTest) TestServesFromPrimary() {
+	m, err := t.stat("foo")
+	require.NoError(t.T(), err)
+	assert.Zero(t.T(), m.Generation&secondaryGeneration)
+
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *FailoverBucketTest) TestFallsBackOnNotFound() {
+	m, err := t.stat("bar")
+	require.NoError(t.T(), err)
+	assert.NotZero(t.T(), m.Generation&secondaryGeneration)
+
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "bar")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "replica of bar", string(contents))
+}
+
+func (t *FailoverBucketTest) TestFallsBackOnServerError() {
+	t.primary.err = &googleapi.Error{Code: http.StatusServiceUnavailable}
+
+	m, err := t.stat("foo")
+	require.NoError(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+
+	assert.NotZero(t.T(), m.Generation&secondaryGeneration)
+	assert.Equal(t.T(), "replica of foo", string(contents))
+}
+
+func (t *FailoverBucketTest) TestFallsBackOnTimeout() {
+	t.primary.hang = true
+
+	m, err := t.stat("foo")
+
+	require.NoError(t.T(), err)
+	assert.NotZero(t.T(), m.Generation&secondaryGeneration)
+}
+
+func (t *FailoverBucketTest) TestDoesntFallBackOnOtherErrors() {
+	t.primary.err = &googleapi.Error{Code: http.StatusForbidden}
+
+	_, err := t.stat("foo")
+
+	assert.Equal(t.T(), t.primary.err, err)
+}
+
+func (t *FailoverBucketTest) TestReadsOfGenerationsGoToTheirBucket() {
+	primaryObj, err := t.stat("foo")
+	require.NoError(t.T(), err)
+	secondaryObj, _, err := t.secondary.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	t.primary.err = &googleapi.Error{Code: http.StatusServiceUnavailable}
+
+	// The secondary can't resolve generations of the primary.
+	_, err = t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo", Generation: primaryObj.Generation})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+
+	// While tagged generations of the secondary are read from it.
+	t.primary.err = nil
+	rd, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:       "foo",
+		Generation: secondaryObj.Generation | secondaryGeneration,
+	})
+	require.NoError(t.T(), err)
+	defer rd.Close()
+	buf := make([]byte, 64)
+	n, _ := rd.Read(buf)
+	assert.Equal(t.T(), "replica of foo", string(buf[:n]))
+}
+
+func (t *FailoverBucketTest) TestReadsOfPrimaryGenerationsFallBackToReplicas() {
+	_, err := storageutil.CreateObject(t.ctx, t.primary.Bucket, "qux", []byte("burrito"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, t.secondary, "qux", []byte("burrito"))
+	require.NoError(t.T(), err)
+	record, err := t.stat("qux")
+	require.NoError(t.T(), err)
+
+	for _, unavailable := range []func(){
+		func() { t.primary.err = &googleapi.Error{Code: http.StatusServiceUnavailable} },
+		func() { t.primary.hang = true },
+	} {
+		t.primary.err, t.primary.hang = nil, false
+		unavailable()
+
+		rd, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+			Name:       "qux",
+			Generation: record.Generation,
+			Object:     record,
+		})
+
+		require.NoError(t.T(), err)
+		contents, err := io.ReadAll(rd)
+		rd.Close()
+		require.NoError(t.T(), err)
+		assert.Equal(t.T(), "burrito", string(contents))
+	}
+}
+
+func (t *FailoverBucketTest) TestReadsOfPrimaryGenerationsDontFallBackToDifferentObjects() {
+	record, err := t.stat("foo")
+	require.NoError(t.T(), err)
+	t.primary.err = &googleapi.Error{Code: http.StatusServiceUnavailable}
+
+	// The secondary has other contents.
+	_, err = t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:       "foo",
+		Generation: record.Generation,
+		Object:     record,
+	})
+
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
+
+func (t *FailoverBucketTest) TestContinuedListingsStayOnSecondary() {
+	t.primary.err = &googleapi.Error{Code: http.StatusInternalServerError}
+	req := &gcs.ListObjectsRequest{MaxResults: 2}
+
+	listing, err := t.bucket.ListObjects(t.ctx, req)
+	require.NoError(t.T(), err)
+	require.NotEmpty(t.T(), listing.ContinuationToken)
+	var names []string
+	for _, o := range listing.MinObjects {
+		names = append(names, o.Name)
+	}
+
+	// Even once the primary is back.
+	t.primary.err = nil
+	req.ContinuationToken = listing.ContinuationToken
+	listing, err = t.bucket.ListObjects(t.ctx, req)
+	require.NoError(t.T(), err)
+	for _, o := range listing.MinObjects {
+		assert.NotZero(t.T(), o.Generation&secondaryGeneration)
+		names = append(names, o.Name)
+	}
+
+	assert.Equal(t.T(), []string{"bar", "baz", "foo"}, names)
+}
+
+func (t *FailoverBucketTest) TestModificationsAreRejected() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "qux", []byte("burrito"))
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+
+	err = t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo"})
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+}
@@ -0,0 +1,613 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+	"google.golang.org/api/googleapi"
+	"gopkg.in/yaml.v3"
+)
+
+// The methods faults can be injected into. NewReader covers
+// NewReaderWithReadHandle too.
+var faultMethods = []string{
+	"NewReader",
+	"NewMultiRangeDownloader",
+	"CreateObject",
+	"CreateObjectChunkWriter",
+	"CreateAppendableObjectWriter",
+	"FinalizeUpload",
+	"CopyObject",
+	"ComposeObjects",
+	"StatObject",
+	"ListObjects",
+	"UpdateObject",
+	"DeleteObject",
+	"MoveObject",
+	"DeleteFolder",
+	"GetFolder",
+	"CreateFolder",
+	"RenameFolder",
+}
+
+// The distributions of injected latencies.
+const (
+	latencyFixed       = "fixed"
+	latencyUniform     = "uniform"
+	latencyExponential = "exponential"
+)
+
+// The errors injected besides HTTP status codes.
+const (
+	faultErrorNotFound     = "not-found"
+	faultErrorPrecondition = "precondition"
+)
+
+var errDroppedFinalize = fmt.Errorf("injected fault: finalize response dropped: %w", context.DeadlineExceeded)
+
+// FaultRule describes faults injected into the requests matching it. A file of
+// rules is a YAML list such as:
+//
+//	# Slow down and sometimes throttle stats.
+//	- methods: [StatObject]
+//	  latency: {distribution: exponential, min: 5ms, mean: 50ms, max: 2s}
+//	- methods: [StatObject]
+//	  probability: 0.01
+//	  error: "429"
+//	# Cut reads of logs short.
+//	- methods: [NewReader]
+//	  match: "logs/"
+//	  truncate-reads-after: 4096
+//
+// Every matching rule applies: latencies add up, and the first error, reader
+// fault or dropped finalize response applies.
+type FaultRule struct {
+	// The methods of gcs.Bucket the rule applies to, all of them if empty.
+	Methods []string `yaml:"methods"`
+
+	// A prefix of the object names, or a pattern as in ObjectAttributesTemplate,
+	// all names if empty. Listings are matched by their prefix, and copies,
+	// composes and moves by their destination.
+	Match string `yaml:"match"`
+
+	// The probability that the rule applies to a matching request, 1 if unset.
+	Probability *float64 `yaml:"probability"`
+
+	// Latency added to requests.
+	Latency *FaultLatency `yaml:"latency"`
+
+	// The error requests fail with, after the latency: an HTTP status code such
+	// as 429 or 503, "precondition" or "not-found".
+	//
+	// Errors are injected above the storage client, so they bypass its
+	// retries: a request failing with a 429 or 503 from GCS is retried with
+	// backoff, but one failing with an injected 429 or 503 fails right away.
+	// A latency can stand in for the time retries would take.
+	Error string `yaml:"error"`
+
+	// Readers fail with io.ErrUnexpectedEOF once this many bytes are read.
+	TruncateReadsAfter *int64 `yaml:"truncate-reads-after"`
+
+	// Readers block once this many bytes are read, for StallFor, or until they
+	// are closed or their request is cancelled if zero.
+	StallReadsAfter *int64        `yaml:"stall-reads-after"`
+	StallFor        time.Duration `yaml:"stall-for"`
+
+	// Uploads are finalized, but FinalizeUpload fails as if timed out, as when
+	// the response to the finalize is lost: the object is created although the
+	// caller can't tell.
+	DropFinalize bool `yaml:"drop-finalize"`
+}
+
+// FaultLatency is a distribution of latencies: Min for fixed ones, between
+// Min and Max for uniform ones, and Min plus an exponentially distributed
+// latency of the given Mean, capped at Max if set, for exponential ones.
+type FaultLatency struct {
+	Distribution string        `yaml:"distribution"`
+	Min          time.Duration `yaml:"min"`
+	Max          time.Duration `yaml:"max"`
+	Mean         time.Duration `yaml:"mean"`
+}
+
+func (l *FaultLatency) validate() error {
+	if l.Min < 0 || l.Max < 0 || l.Mean < 0 {
+		return fmt.Errorf("latencies can't be negative")
+	}
+	switch l.Distribution {
+	case latencyFixed:
+	case latencyUniform:
+		if l.Max < l.Min {
+			return fmt.Errorf("max latency %v is below min latency %v", l.Max, l.Min)
+		}
+	case latencyExponential:
+		if l.Max != 0 && l.Max < l.Min {
+			return fmt.Errorf("max latency %v is below min latency %v", l.Max, l.Min)
+		}
+	default:
+		return fmt.Errorf("unknown latency distribution %q", l.Distribution)
+	}
+	return nil
+}
+
+func (l *FaultLatency) sample() time.Duration {
+	switch l.Distribution {
+	case latencyUniform:
+		return l.Min + rand.N(l.Max-l.Min+1)
+	case latencyExponential:
+		d := l.Min + time.Duration(rand.ExpFloat64()*float64(l.Mean))
+		if l.Max != 0 {
+			d = min(d, l.Max)
+		}
+		return d
+	}
+	return l.Min
+}
+
+func (r *FaultRule) validate() error {
+	for _, m := range r.Methods {
+		if !slices.Contains(faultMethods, m) {
+			return fmt.Errorf("unknown method %q", m)
+		}
+	}
+	if strings.ContainsAny(r.Match, `*?[\`) {
+		if _, err := path.Match(r.Match, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", r.Match, err)
+		}
+	}
+	if p := r.Probability; p != nil && (*p < 0 || *p > 1) {
+		return fmt.Errorf("probability %v isn't between 0 and 1", *p)
+	}
+	if r.Latency != nil {
+		if err := r.Latency.validate(); err != nil {
+			return err
+		}
+	}
+	if r.Error != "" && faultError(r.Error) == nil {
+		return fmt.Errorf("unknown error %q", r.Error)
+	}
+	if n := r.TruncateReadsAfter; n != nil && *n < 0 {
+		return fmt.Errorf("truncate-reads-after can't be negative")
+	}
+	if n := r.StallReadsAfter; n != nil && *n < 0 {
+		return fmt.Errorf("stall-reads-after can't be negative")
+	}
+	if r.StallFor < 0 {
+		return fmt.Errorf("stall-for can't be negative")
+	}
+	return nil
+}
+
+func (r *FaultRule) matches(method string, name string) bool {
+	if len(r.Methods) > 0 && !slices.Contains(r.Methods, method) {
+		return false
+	}
+	if !strings.ContainsAny(r.Match, `*?[\`) {
+		return strings.HasPrefix(name, r.Match)
+	}
+
+	// The pattern was checked when loaded.
+	ok, _ := path.Match(r.Match, name)
+	return ok
+}
+
+// Return the error injected for the given value of FaultRule.Error, or nil if
+// it is invalid.
+func faultError(value string) error {
+	switch value {
+	case faultErrorNotFound:
+		return &gcs.NotFoundError{Err: errors.New("injected fault")}
+	case faultErrorPrecondition:
+		return &gcs.PreconditionError{Err: errors.New("injected fault")}
+	}
+
+	code, err := strconv.Atoi(value)
+	if err != nil || code < 400 || code > 599 {
+		return nil
+	}
+	return &googleapi.Error{
+		Code:    code,
+		Message: "injected fault: " + http.StatusText(code),
+	}
+}
+
+// LoadFaultRules reads the list of rules in the YAML file at the given path.
+func LoadFaultRules(filePath string) (rules []FaultRule, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&rules); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %q: %w", filePath, err)
+	}
+
+	for i := range rules {
+		if err = rules[i].validate(); err != nil {
+			return nil, fmt.Errorf("rule %d in %q: %w", i, filePath, err)
+		}
+	}
+	return rules, nil
+}
+
+// FaultInjector holds the fault rules of fault injection buckets, which can be
+// replaced while they are in use. Mounts reload them from their file on
+// SIGHUP; there is no admin socket to change them through.
+type FaultInjector struct {
+	filePath string
+	rules    atomic.Pointer[[]FaultRule]
+}
+
+// NewFaultInjector returns an injector of the rules in the given file, if any.
+func NewFaultInjector(filePath string) (*FaultInjector, error) {
+	fi := &FaultInjector{filePath: filePath}
+	if filePath != "" {
+		if err := fi.Reload(); err != nil {
+			return nil, err
+		}
+	}
+	return fi, nil
+}
+
+// Reload reads the rules from the file again, keeping the current ones if it
+// is invalid.
+func (fi *FaultInjector) Reload() error {
+	rules, err := LoadFaultRules(fi.filePath)
+	if err != nil {
+		return err
+	}
+	fi.SetRules(rules)
+	return nil
+}
+
+// SetRules replaces the rules, which must be valid.
+func (fi *FaultInjector) SetRules(rules []FaultRule) {
+	fi.rules.Store(&rules)
+}
+
+// The faults injected into a request.
+type faults struct {
+	latency      time.Duration
+	err          error
+	truncate     *int64
+	stall        *int64
+	stallFor     time.Duration
+	dropFinalize bool
+}
+
+func (fi *FaultInjector) faults(method string, name string) (f faults) {
+	rules := fi.rules.Load()
+	if rules == nil {
+		return
+	}
+
+	for i := range *rules {
+		r := &(*rules)[i]
+		if !r.matches(method, name) {
+			continue
+		}
+		if r.Probability != nil && rand.Float64() >= *r.Probability {
+			continue
+		}
+
+		if r.Latency != nil {
+			f.latency += r.Latency.sample()
+		}
+		if f.err == nil && r.Error != "" {
+			f.err = faultError(r.Error)
+		}
+		if f.truncate == nil && f.stall == nil {
+			f.truncate = r.TruncateReadsAfter
+			f.stall, f.stallFor = r.StallReadsAfter, r.StallFor
+		}
+		f.dropFinalize = f.dropFinalize || r.DropFinalize
+	}
+	return
+}
+
+// NewFaultInjectionBucket creates a bucket injecting the faults of the rules
+// of the given injector into the requests to the wrapped bucket. The faults
+// are injected above the retries of the storage client, which doesn't see
+// them.
+func NewFaultInjectionBucket(fi *FaultInjector, wrapped gcs.Bucket) gcs.Bucket {
+	return &faultInjectionBucket{
+		Bucket:   wrapped,
+		injector: fi,
+	}
+}
+
+type faultInjectionBucket struct {
+	gcs.Bucket
+	injector *FaultInjector
+}
+
+// Delay the request by the injected latency, and return the injected error,
+// if any.
+func (b *faultInjectionBucket) inject(ctx context.Context, method string, name string) (faults, error) {
+	f := b.injector.faults(method, name)
+	if f.latency > 0 {
+		timer := time.NewTimer(f.latency)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			return f, ctx.Err()
+		}
+	}
+	if f.err != nil {
+		logger.Tracef("Injecting fault into %s(%q): %v", method, name, f.err)
+	}
+	return f, f.err
+}
+
+// faultyReader truncates or stalls the contents read through it.
+type faultyReader struct {
+	gcs.StorageReader
+	ctx context.Context
+
+	// The bytes left until the fault, and the fault.
+	remaining int64
+	truncate  bool
+	stallFor  time.Duration
+
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (r *faultyReader) Read(p []byte) (int, error) {
+	if r.remaining == 0 {
+		if r.truncate {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err := r.stall(); err != nil {
+			return 0, err
+		}
+		// Stalls happen once.
+		r.remaining = -1
+	}
+
+	if r.remaining > 0 && int64(len(p)) > r.remaining {
+		p = p[:r.remaining]
+	}
+	n, err := r.StorageReader.Read(p)
+	if r.remaining > 0 {
+		r.remaining -= int64(n)
+	}
+	return n, err
+}
+
+func (r *faultyReader) stall() error {
+	var timeout <-chan time.Time
+	if r.stallFor > 0 {
+		timer := time.NewTimer(r.stallFor)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case <-timeout:
+		return nil
+	case <-r.ctx.Done():
+		return r.ctx.Err()
+	case <-r.closed:
+		return errors.New("reader closed while stalled")
+	}
+}
+
+func (r *faultyReader) Close() error {
+	r.closeOnce.Do(func() { close(r.closed) })
+	return r.StorageReader.Close()
+}
+
+func (b *faultInjectionBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *faultInjectionBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	f, err := b.inject(ctx, "NewReader", req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	rd, err := b.Bucket.NewReaderWithReadHandle(ctx, req)
+	if err != nil || (f.truncate == nil && f.stall == nil) {
+		return rd, err
+	}
+
+	fr := &faultyReader{
+		StorageReader: rd,
+		ctx:           ctx,
+		closed:        make(chan struct{}),
+	}
+	if f.truncate != nil {
+		fr.remaining, fr.truncate = *f.truncate, true
+	} else {
+		fr.remaining, fr.stallFor = *f.stall, f.stallFor
+	}
+	return fr, nil
+}
+
+func (b *faultInjectionBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	if _, err := b.inject(ctx, "NewMultiRangeDownloader", req.Name); err != nil {
+		return nil, err
+	}
+	return b.Bucket.NewMultiRangeDownloader(ctx, req)
+}
+
+func (b *faultInjectionBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	if _, err := b.inject(ctx, "CreateObject", req.Name); err != nil {
+		return nil, err
+	}
+	return b.Bucket.CreateObject(ctx, req)
+}
+
+func (b *faultInjectionBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if _, err := b.inject(ctx, "CreateObjectChunkWriter", req.Name); err != nil {
+		return nil, err
+	}
+	return b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+}
+
+func (b *faultInjectionBucket) CreateAppendableObjectWriter(
+	ctx context.Context,
+	req *gcs.AppendObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if _, err := b.inject(ctx, "CreateAppendableObjectWriter", req.Name); err != nil {
+		return nil, err
+	}
+	return b.Bucket.CreateAppendableObjectWriter(ctx, req, chunkSize, callBack)
+}
+
+func (b *faultInjectionBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	f, err := b.inject(ctx, "FinalizeUpload", w.ObjectName())
+	if err != nil {
+		return nil, err
+	}
+	if f.dropFinalize {
+		// Finalize the upload all the same, rather than leave its writer and
+		// session behind.
+		if _, err = b.Bucket.FinalizeUpload(ctx, w); err != nil {
+			return nil, err
+		}
+		logger.Tracef("Injecting fault into FinalizeUpload(%q): %v", w.ObjectName(), errDroppedFinalize)
+		return nil, errDroppedFinalize
+	}
+	return b.Bucket.FinalizeUpload(ctx, w)
+}
+
+func (b *faultInjectionBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	if _, err := b.inject(ctx, "CopyObject", req.DstName); err != nil {
+		return nil, err
+	}
+	return b.Bucket.CopyObject(ctx, req)
+}
+
+func (b *faultInjectionBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	if _, err := b.inject(ctx, "ComposeObjects", req.DstName); err != nil {
+		return nil, err
+	}
+	return b.Bucket.ComposeObjects(ctx, req)
+}
+
+func (b *faultInjectionBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	if _, err := b.inject(ctx, "StatObject", req.Name); err != nil {
+		return nil, nil, err
+	}
+	return b.Bucket.StatObject(ctx, req)
+}
+
+func (b *faultInjectionBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	if _, err := b.inject(ctx, "ListObjects", req.Prefix); err != nil {
+		return nil, err
+	}
+	return b.Bucket.ListObjects(ctx, req)
+}
+
+func (b *faultInjectionBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	if _, err := b.inject(ctx, "UpdateObject", req.Name); err != nil {
+		return nil, err
+	}
+	return b.Bucket.UpdateObject(ctx, req)
+}
+
+func (b *faultInjectionBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	if _, err := b.inject(ctx, "DeleteObject", req.Name); err != nil {
+		return err
+	}
+	return b.Bucket.DeleteObject(ctx, req)
+}
+
+func (b *faultInjectionBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	if _, err := b.inject(ctx, "MoveObject", req.DstName); err != nil {
+		return nil, err
+	}
+	return b.Bucket.MoveObject(ctx, req)
+}
+
+func (b *faultInjectionBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	if _, err := b.inject(ctx, "DeleteFolder", folderName); err != nil {
+		return err
+	}
+	return b.Bucket.DeleteFolder(ctx, folderName)
+}
+
+func (b *faultInjectionBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	if _, err := b.inject(ctx, "GetFolder", folderName); err != nil {
+		return nil, err
+	}
+	return b.Bucket.GetFolder(ctx, folderName)
+}
+
+func (b *faultInjectionBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	if _, err := b.inject(ctx, "CreateFolder", folderName); err != nil {
+		return nil, err
+	}
+	return b.Bucket.CreateFolder(ctx, folderName)
+}
+
+func (b *faultInjectionBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (*gcs.Folder, error) {
+	if _, err := b.inject(ctx, "RenameFolder", folderName); err != nil {
+		return nil, err
+	}
+	return b.Bucket.RenameFolder(ctx, folderName, destinationFolderId)
+}
@@ -0,0 +1,250 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"google.golang.org/api/googleapi"
+)
+
+type FaultInjectionBucketTest struct {
+	suite.Suite
+	ctx      context.Context
+	injector *FaultInjector
+	wrapped  gcs.Bucket
+	bucket   gcs.Bucket
+}
+
+func TestFaultInjectionBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(FaultInjectionBucketTest))
+}
+
+func (t *FaultInjectionBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.wrapped = fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	var err error
+	t.injector, err = NewFaultInjector("")
+	require.NoError(t.T(), err)
+	t.bucket = NewFaultInjectionBucket(t.injector, t.wrapped)
+
+	for _, name := range []string{"foo", "logs/bar"} {
+		_, err = storageutil.CreateObject(t.ctx, t.wrapped, name, []byte("0123456789"))
+		require.NoError(t.T(), err)
+	}
+}
+
+func (t *FaultInjectionBucketTest) stat(name string) error {
+	_, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+	return err
+}
+
+func (t *FaultInjectionBucketTest) loadRules(yaml string) ([]FaultRule, error) {
+	filePath := filepath.Join(t.T().TempDir(), "faults.yaml")
+	require.NoError(t.T(), os.WriteFile(filePath, []byte(yaml), 0600))
+	return LoadFaultRules(filePath)
+}
+
+func (t *FaultInjectionBucketTest) TestNoRules() {
+	assert.NoError(t.T(), t.stat("foo"))
+}
+
+func (t *FaultInjectionBucketTest) TestErrorsByMethodAndName() {
+	t.injector.SetRules([]FaultRule{
+		{Methods: []string{"StatObject"}, Match: "logs/", Error: "503"},
+		{Methods: []string{"NewReader"}, Match: "*o", Error: "not-found"},
+		{Methods: []string{"CreateObject"}, Error: "precondition"},
+	})
+
+	var googleAPIErr *googleapi.Error
+	require.True(t.T(), errors.As(t.stat("logs/bar"), &googleAPIErr))
+	assert.Equal(t.T(), http.StatusServiceUnavailable, googleAPIErr.Code)
+	assert.NoError(t.T(), t.stat("foo"))
+
+	_, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+	_, err = storageutil.ReadObject(t.ctx, t.bucket, "logs/bar")
+	assert.NoError(t.T(), err)
+
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "baz", nil)
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr))
+}
+
+func (t *FaultInjectionBucketTest) TestProbability() {
+	never, always := 0.0, 1.0
+	t.injector.SetRules([]FaultRule{{Error: "429", Probability: &never}})
+	assert.NoError(t.T(), t.stat("foo"))
+
+	t.injector.SetRules([]FaultRule{{Error: "429", Probability: &always}})
+	assert.Error(t.T(), t.stat("foo"))
+}
+
+func (t *FaultInjectionBucketTest) TestLatency() {
+	t.injector.SetRules([]FaultRule{
+		{Latency: &FaultLatency{Distribution: latencyFixed, Min: 20 * time.Millisecond}},
+		{Latency: &FaultLatency{Distribution: latencyUniform, Min: 10 * time.Millisecond, Max: 20 * time.Millisecond}},
+	})
+
+	start := time.Now()
+	require.NoError(t.T(), t.stat("foo"))
+	assert.GreaterOrEqual(t.T(), time.Since(start), 30*time.Millisecond)
+
+	// Cancelled requests don't wait.
+	ctx, cancel := context.WithCancel(t.ctx)
+	cancel()
+	_, _, err := t.bucket.StatObject(ctx, &gcs.StatObjectRequest{Name: "foo"})
+	assert.ErrorIs(t.T(), err, context.Canceled)
+}
+
+func (t *FaultInjectionBucketTest) TestTruncatedReads() {
+	n := int64(4)
+	t.injector.SetRules([]FaultRule{{TruncateReadsAfter: &n}})
+
+	rd, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	defer rd.Close()
+	contents, err := io.ReadAll(rd)
+
+	assert.ErrorIs(t.T(), err, io.ErrUnexpectedEOF)
+	assert.Equal(t.T(), "0123", string(contents))
+}
+
+func (t *FaultInjectionBucketTest) TestStalledReads() {
+	n := int64(4)
+	t.injector.SetRules([]FaultRule{{StallReadsAfter: &n, StallFor: 20 * time.Millisecond}})
+
+	start := time.Now()
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "0123456789", string(contents))
+	assert.GreaterOrEqual(t.T(), time.Since(start), 20*time.Millisecond)
+}
+
+func (t *FaultInjectionBucketTest) TestReadsStalledUntilClosed() {
+	n := int64(0)
+	t.injector.SetRules([]FaultRule{{StallReadsAfter: &n}})
+	rd, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		rd.Close()
+	}()
+	_, err = rd.Read(make([]byte, 10))
+
+	assert.Error(t.T(), err)
+}
+
+func (t *FaultInjectionBucketTest) TestDroppedFinalize() {
+	t.injector.SetRules([]FaultRule{{Methods: []string{"FinalizeUpload"}, DropFinalize: true}})
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: "baz"}, 0, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte("taco"))
+	require.NoError(t.T(), err)
+
+	_, err = t.bucket.FinalizeUpload(t.ctx, w)
+
+	assert.ErrorIs(t.T(), err, context.DeadlineExceeded)
+	contents, err := storageutil.ReadObject(t.ctx, t.wrapped, "baz")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *FaultInjectionBucketTest) TestLoadFaultRules() {
+	rules, err := t.loadRules(`
+- methods: [StatObject, ListObjects]
+  match: "logs/"
+  probability: 0.5
+  latency: {distribution: exponential, min: 5ms, mean: 50ms, max: 2s}
+  error: "429"
+- stall-reads-after: 1024
+  stall-for: 30s
+`)
+
+	require.NoError(t.T(), err)
+	require.Len(t.T(), rules, 2)
+	assert.Equal(t.T(), []string{"StatObject", "ListObjects"}, rules[0].Methods)
+	assert.Equal(t.T(), 0.5, *rules[0].Probability)
+	assert.Equal(t.T(), FaultLatency{Distribution: latencyExponential, Min: 5 * time.Millisecond, Mean: 50 * time.Millisecond, Max: 2 * time.Second}, *rules[0].Latency)
+	assert.Equal(t.T(), int64(1024), *rules[1].StallReadsAfter)
+	assert.Equal(t.T(), 30*time.Second, rules[1].StallFor)
+}
+
+func (t *FaultInjectionBucketTest) TestLoadFaultRulesForAppends() {
+	rules, err := t.loadRules(`
+- methods: [CreateAppendableObjectWriter]
+  error: "503"
+`)
+	require.NoError(t.T(), err)
+	t.injector.SetRules(rules)
+
+	_, err = t.bucket.CreateAppendableObjectWriter(t.ctx, &gcs.AppendObjectRequest{Name: "foo"}, 0, nil)
+
+	var googleAPIErr *googleapi.Error
+	require.True(t.T(), errors.As(err, &googleAPIErr))
+	assert.Equal(t.T(), http.StatusServiceUnavailable, googleAPIErr.Code)
+}
+
+func (t *FaultInjectionBucketTest) TestLoadInvalidFaultRules() {
+	for _, yaml := range []string{
+		"- methods: [ReadObject]",
+		`- match: "[a"`,
+		"- probability: 2",
+		"- latency: {distribution: normal}",
+		"- latency: {distribution: uniform, min: 2s, max: 1s}",
+		"- error: 200",
+		"- error: teapot",
+		"- truncate-reads-after: -1",
+		"- unknown-field: true",
+	} {
+		_, err := t.loadRules(yaml)
+		assert.Error(t.T(), err, yaml)
+	}
+}
+
+func (t *FaultInjectionBucketTest) TestReload() {
+	filePath := filepath.Join(t.T().TempDir(), "faults.yaml")
+	require.NoError(t.T(), os.WriteFile(filePath, []byte(`- error: "503"`), 0600))
+	fi, err := NewFaultInjector(filePath)
+	require.NoError(t.T(), err)
+	t.bucket = NewFaultInjectionBucket(fi, t.wrapped)
+	require.Error(t.T(), t.stat("foo"))
+
+	// Invalid rules are ignored.
+	require.NoError(t.T(), os.WriteFile(filePath, []byte(`- error: "200"`), 0600))
+	assert.Error(t.T(), fi.Reload())
+	assert.Error(t.T(), t.stat("foo"))
+
+	require.NoError(t.T(), os.WriteFile(filePath, nil, 0600))
+	require.NoError(t.T(), fi.Reload())
+	assert.NoError(t.T(), t.stat("foo"))
+}
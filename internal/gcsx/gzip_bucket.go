@@ -0,0 +1,524 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"cloud.google.com/go/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/fuse/fsutil"
+	"golang.org/x/net/context"
+)
+
+// Metadata keys of the objects compressed by a gzip bucket, holding the size
+// and CRC32C of their uncompressed contents.
+const (
+	uncompressedSizeMetadataKey   = "gcsfuse_uncompressed_size"
+	uncompressedCRC32CMetadataKey = "gcsfuse_uncompressed_crc32c"
+)
+
+var castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+// NewGzipBucket creates a wrapper bucket that compresses the contents of
+// objects created with names matching any of the given patterns with gzip,
+// setting their Content-Encoding, and decompresses them on read. A pattern
+// "*.ext" matches the names ending in ".ext", and any other pattern matches
+// the names it prefixes.
+//
+// The objects it compressed are presented with the size and CRC32C of their
+// uncompressed contents, for as long as their names match. These are recorded
+// in their metadata when they are created, so the compressed contents are
+// staged in a temporary file in tempDir, or the system default if empty, until
+// their size and CRC32C are known. Reads of them within a range decompress
+// from the start of the object, so they take time in proportion to the end of
+// the range rather than its length, and composing into them fails with an
+// error wrapping errors.ErrUnsupported, for callers to write them in full.
+func NewGzipBucket(patterns []string, tempDir string, wrapped gcs.Bucket) gcs.Bucket {
+	return &gzipBucket{
+		Bucket:   wrapped,
+		patterns: patterns,
+		tempDir:  tempDir,
+	}
+}
+
+type gzipBucket struct {
+	gcs.Bucket
+	patterns []string
+	tempDir  string
+}
+
+func (b *gzipBucket) matches(name string) bool {
+	for _, p := range b.patterns {
+		if ext, ok := strings.CutPrefix(p, "*"); ok {
+			if strings.HasSuffix(name, ext) {
+				return true
+			}
+		} else if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Return the size and CRC32C of the uncompressed contents of the object with
+// the given attributes, if it was compressed by the bucket.
+func (b *gzipBucket) uncompressed(
+	name string,
+	contentEncoding string,
+	metadata map[string]string) (size uint64, crc uint32, ok bool) {
+	if !b.matches(name) || contentEncoding != gcs.ContentEncodingGzip {
+		return
+	}
+
+	size, err := strconv.ParseUint(metadata[uncompressedSizeMetadataKey], 10, 64)
+	if err != nil {
+		return
+	}
+	crc64, err := strconv.ParseUint(metadata[uncompressedCRC32CMetadataKey], 10, 32)
+	if err != nil {
+		return
+	}
+
+	return size, uint32(crc64), true
+}
+
+// Present the object as its uncompressed contents, if it was compressed by the
+// bucket.
+func (b *gzipBucket) presentMinObject(m *gcs.MinObject) *gcs.MinObject {
+	if m == nil {
+		return nil
+	}
+	size, crc, ok := b.uncompressed(m.Name, m.ContentEncoding, m.Metadata)
+	if !ok {
+		return m
+	}
+
+	presented := *m
+	presented.Size = size
+	presented.ContentEncoding = ""
+	presented.CRC32C = &crc
+	return &presented
+}
+
+func (b *gzipBucket) presentObject(o *gcs.Object) *gcs.Object {
+	if o == nil {
+		return nil
+	}
+	size, crc, ok := b.uncompressed(o.Name, o.ContentEncoding, o.Metadata)
+	if !ok {
+		return o
+	}
+
+	presented := *o
+	presented.Size = size
+	presented.ContentEncoding = ""
+	presented.CRC32C = &crc
+	presented.MD5 = nil
+	return &presented
+}
+
+// Return whether the record, as presented by the bucket, is of an object it
+// compressed.
+func (b *gzipBucket) presentedCompressed(m *gcs.MinObject) bool {
+	if m.ContentEncoding != "" {
+		// Not presented: the object is compressed only if it was by us.
+		_, _, ok := b.uncompressed(m.Name, m.ContentEncoding, m.Metadata)
+		return ok
+	}
+
+	size, crc, ok := b.uncompressed(m.Name, gcs.ContentEncodingGzip, m.Metadata)
+	return ok && m.Size == size && m.CRC32C != nil && *m.CRC32C == crc
+}
+
+// Look up whether the given generation of the named object, or its latest one
+// if zero, was compressed by the bucket, returning its record as presented.
+// The record the caller has is used if it is of that generation.
+func (b *gzipBucket) statCompressed(
+	ctx context.Context,
+	name string,
+	generation int64,
+	record *gcs.MinObject) (m *gcs.MinObject, ok bool, err error) {
+	if !b.matches(name) {
+		return
+	}
+
+	if record != nil && generation != 0 && record.Generation == generation {
+		m = b.presentMinObject(record)
+		return m, b.presentedCompressed(m), nil
+	}
+
+	m, _, err = b.Bucket.StatObject(ctx, &gcs.StatObjectRequest{Name: name})
+	var notFoundErr *gcs.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		// Let the read report it.
+		return nil, false, nil
+	}
+	if err != nil || (generation != 0 && m.Generation != generation) {
+		return
+	}
+
+	m = b.presentMinObject(m)
+	return m, b.presentedCompressed(m), nil
+}
+
+// Create the object of the request from the compressed contents, recording the
+// size and CRC32C of the uncompressed contents in its metadata.
+func (b *gzipBucket) createCompressed(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	compressed io.Reader,
+	size int64,
+	crc uint32) (*gcs.Object, error) {
+	metadata := make(map[string]string, len(req.Metadata)+2)
+	for k, v := range req.Metadata {
+		metadata[k] = v
+	}
+	metadata[uncompressedSizeMetadataKey] = strconv.FormatInt(size, 10)
+	metadata[uncompressedCRC32CMetadataKey] = strconv.FormatUint(uint64(crc), 10)
+
+	// The checksums of the request are of the uncompressed contents.
+	mReq := *req
+	mReq.Contents = compressed
+	mReq.ContentEncoding = gcs.ContentEncodingGzip
+	mReq.Metadata = metadata
+	mReq.CRC32C = nil
+	mReq.MD5 = nil
+	o, err := b.Bucket.CreateObject(ctx, &mReq)
+	return b.presentObject(o), err
+}
+
+// Compress the contents read from r to a new temporary file, returning it
+// positioned at its start along with the size and CRC32C of the contents.
+func (b *gzipBucket) compressToTempFile(r io.Reader) (f *os.File, size int64, crc uint32, err error) {
+	f, err = b.newCompressedTempFile()
+	if err != nil {
+		return
+	}
+
+	cr := &checksummingReader{r: r}
+	zw := gzip.NewWriter(f)
+	if _, err = io.Copy(zw, cr); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return nil, 0, 0, fmt.Errorf("compressing: %w", err)
+	}
+
+	return f, cr.size, cr.crc, nil
+}
+
+// Create an anonymous temporary file for compressed contents, which is removed
+// once closed.
+func (b *gzipBucket) newCompressedTempFile() (*os.File, error) {
+	f, err := fsutil.AnonymousFile(b.tempDir)
+	if err != nil {
+		return nil, fmt.Errorf("AnonymousFile: %w", err)
+	}
+	return f, nil
+}
+
+// Return a reader of the given range of the uncompressed contents of the
+// object, whose record is as presented.
+func (b *gzipBucket) newDecompressingReader(
+	ctx context.Context,
+	m *gcs.MinObject,
+	byteRange *gcs.ByteRange) (gcs.StorageReader, error) {
+	rc, err := b.Bucket.NewReaderWithReadHandle(ctx, &gcs.ReadObjectRequest{
+		Name:           m.Name,
+		Generation:     m.Generation,
+		ReadCompressed: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := gzip.NewReader(rc)
+	if err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("decompressing %q: %w", m.Name, err)
+	}
+
+	size := m.Size
+	start, limit := uint64(0), size
+	if byteRange != nil {
+		limit = min(byteRange.Limit, size)
+		start = min(byteRange.Start, limit)
+	}
+	if _, err = io.CopyN(io.Discard, zr, int64(start)); err != nil {
+		rc.Close()
+		return nil, fmt.Errorf("decompressing %q: %w", m.Name, err)
+	}
+
+	return &gzipReader{
+		Reader: io.LimitReader(zr, int64(limit-start)),
+		closer: rc,
+	}, nil
+}
+
+// gzipReader reads the decompressed contents of an object.
+type gzipReader struct {
+	io.Reader
+	closer io.Closer
+}
+
+func (r *gzipReader) Close() error {
+	return r.closer.Close()
+}
+
+func (r *gzipReader) ReadHandle() storage.ReadHandle {
+	return nil
+}
+
+// gzipWriter compresses the contents written to it to a temporary file, from
+// which the object is created once the writer is closed.
+type gzipWriter struct {
+	ctx    context.Context
+	bucket *gzipBucket
+	req    *gcs.CreateObjectRequest
+	f      *os.File
+	zw     *gzip.Writer
+
+	// The size and CRC32C of the uncompressed contents written so far.
+	size int64
+	crc  uint32
+
+	// The object created once closed.
+	object *gcs.Object
+}
+
+func (w *gzipWriter) Write(p []byte) (int, error) {
+	w.size += int64(len(p))
+	w.crc = crc32.Update(w.crc, castagnoliTable, p)
+	return w.zw.Write(p)
+}
+
+func (w *gzipWriter) Close() error {
+	return w.finalize(w.ctx)
+}
+
+// Create the object from the compressed contents, unless already created.
+func (w *gzipWriter) finalize(ctx context.Context) (err error) {
+	if w.object != nil {
+		return nil
+	}
+	defer w.f.Close()
+
+	if err = w.zw.Close(); err != nil {
+		return fmt.Errorf("compressing %q: %w", w.req.Name, err)
+	}
+	if _, err = w.f.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("compressing %q: %w", w.req.Name, err)
+	}
+	w.object, err = w.bucket.createCompressed(ctx, w.req, w.f, w.size, w.crc)
+	return
+}
+
+// Flush is unsupported: the compressed stream is only complete once the
+// writer is closed.
+func (w *gzipWriter) Flush() (int64, error) {
+	return 0, fmt.Errorf("flushing compressed object %q: %w", w.ObjectName(), errors.ErrUnsupported)
+}
+
+func (w *gzipWriter) ObjectName() string {
+	return w.req.Name
+}
+
+// Attrs returns nil: the object is created by another writer.
+func (w *gzipWriter) Attrs() *storage.ObjectAttrs {
+	return nil
+}
+
+// checksummingReader records the size and CRC32C of the contents read through
+// it.
+type checksummingReader struct {
+	r    io.Reader
+	size int64
+	crc  uint32
+}
+
+func (r *checksummingReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	r.size += int64(n)
+	r.crc = crc32.Update(r.crc, castagnoliTable, p[:n])
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+func (b *gzipBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
+	rc, err = b.NewReaderWithReadHandle(ctx, req)
+	return
+}
+
+func (b *gzipBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	m, ok, err := b.statCompressed(ctx, req.Name, req.Generation, req.Object)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return b.Bucket.NewReaderWithReadHandle(ctx, req)
+	}
+
+	return b.newDecompressingReader(ctx, m, req.Range)
+}
+
+func (b *gzipBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	m, ok, err := b.statCompressed(ctx, req.Name, req.Generation, req.Object)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return b.Bucket.NewMultiRangeDownloader(ctx, req)
+	}
+
+	return newReaderMultiRangeDownloader(ctx, func(ctx context.Context, r *gcs.ByteRange) (gcs.StorageReader, error) {
+		return b.newDecompressingReader(ctx, m, r)
+	}), nil
+}
+
+func (b *gzipBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	if !b.matches(req.Name) {
+		o, err = b.Bucket.CreateObject(ctx, req)
+		return
+	}
+
+	f, size, crc, err := b.compressToTempFile(req.Contents)
+	if err != nil {
+		err = fmt.Errorf("creating %q: %w", req.Name, err)
+		return
+	}
+	defer f.Close()
+
+	o, err = b.createCompressed(ctx, req, f, size, crc)
+	return
+}
+
+func (b *gzipBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if !b.matches(req.Name) {
+		return b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+	}
+
+	f, err := b.newCompressedTempFile()
+	if err != nil {
+		return nil, fmt.Errorf("creating %q: %w", req.Name, err)
+	}
+
+	return &gzipWriter{ctx: ctx, bucket: b, req: req, f: f, zw: gzip.NewWriter(f)}, nil
+}
+
+func (b *gzipBucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if !b.matches(req.Name) {
+		return b.Bucket.CreateAppendableObjectWriter(ctx, req, chunkSize, callBack)
+	}
+	return nil, fmt.Errorf("appending to compressed object %q: %w", req.Name, errors.ErrUnsupported)
+}
+
+func (b *gzipBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	gw, ok := w.(*gzipWriter)
+	if !ok {
+		return b.Bucket.FinalizeUpload(ctx, w)
+	}
+
+	if err := gw.finalize(ctx); err != nil {
+		return nil, err
+	}
+	return storageutil.ConvertObjToMinObject(gw.object), nil
+}
+
+func (b *gzipBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	o, err = b.Bucket.CopyObject(ctx, req)
+	o = b.presentObject(o)
+	return
+}
+
+func (b *gzipBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	if !b.matches(req.DstName) {
+		o, err = b.Bucket.ComposeObjects(ctx, req)
+		o = b.presentObject(o)
+		return
+	}
+
+	// Compressed contents can't be composed with others.
+	return nil, fmt.Errorf("composing compressed object %q: %w", req.DstName, errors.ErrUnsupported)
+}
+
+func (b *gzipBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	m, e, err = b.Bucket.StatObject(ctx, req)
+	m = b.presentMinObject(m)
+	return
+}
+
+func (b *gzipBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	wrappedListing, err := b.Bucket.ListObjects(ctx, req)
+	if err != nil {
+		return
+	}
+
+	listing = &gcs.Listing{
+		CollapsedRuns:     wrappedListing.CollapsedRuns,
+		ContinuationToken: wrappedListing.ContinuationToken,
+		Lifetimes:         wrappedListing.Lifetimes,
+	}
+	for _, m := range wrappedListing.MinObjects {
+		listing.MinObjects = append(listing.MinObjects, b.presentMinObject(m))
+	}
+	return
+}
+
+func (b *gzipBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
+	o, err = b.Bucket.UpdateObject(ctx, req)
+	o = b.presentObject(o)
+	return
+}
+
+func (b *gzipBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (o *gcs.Object, err error) {
+	o, err = b.Bucket.MoveObject(ctx, req)
+	o = b.presentObject(o)
+	return
+}
@@ -0,0 +1,244 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const gzipTestContents = "taco burrito enchilada taco burrito enchilada"
+
+type GzipBucketTest struct {
+	suite.Suite
+	ctx     context.Context
+	wrapped gcs.Bucket
+	bucket  gcs.Bucket
+}
+
+func TestGzipBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(GzipBucketTest))
+}
+
+func (t *GzipBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.wrapped = fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	t.bucket = NewGzipBucket([]string{"*.log", "logs/"}, "", t.wrapped)
+}
+
+// Return the contents of the object as stored in the wrapped bucket,
+// decompressed.
+func (t *GzipBucketTest) readStored(name string) string {
+	contents, err := storageutil.ReadObject(t.ctx, t.wrapped, name)
+	require.NoError(t.T(), err)
+	zr, err := gzip.NewReader(bytes.NewReader(contents))
+	require.NoError(t.T(), err)
+	decompressed, err := io.ReadAll(zr)
+	require.NoError(t.T(), err)
+	return string(decompressed)
+}
+
+func (t *GzipBucketTest) TestCreateObjectCompressesMatchingNames() {
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, "logs/foo", []byte(gzipTestContents))
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), len(gzipTestContents), o.Size)
+	assert.Empty(t.T(), o.ContentEncoding)
+	assert.Equal(t.T(), gzipTestContents, t.readStored("logs/foo"))
+	m, _, err := t.wrapped.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "logs/foo"})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), gcs.ContentEncodingGzip, m.ContentEncoding)
+	assert.NotEqualValues(t.T(), len(gzipTestContents), m.Size)
+}
+
+func (t *GzipBucketTest) TestCreateObjectPassesThroughOtherNames() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.txt", []byte(gzipTestContents))
+
+	require.NoError(t.T(), err)
+	contents, err := storageutil.ReadObject(t.ctx, t.wrapped, "foo.txt")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), gzipTestContents, string(contents))
+}
+
+func (t *GzipBucketTest) TestStatAndListPresentUncompressedSize() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.log", []byte(gzipTestContents))
+	require.NoError(t.T(), err)
+
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo.log"})
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), len(gzipTestContents), m.Size)
+	assert.Empty(t.T(), m.ContentEncoding)
+	objects, _, err := storageutil.ListAll(t.ctx, t.bucket, &gcs.ListObjectsRequest{})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), objects, 1)
+	assert.EqualValues(t.T(), len(gzipTestContents), objects[0].Size)
+}
+
+func (t *GzipBucketTest) TestNewReaderDecompressesRange() {
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.log", []byte(gzipTestContents))
+	require.NoError(t.T(), err)
+
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:       "foo.log",
+		Generation: o.Generation,
+		Range:      &gcs.ByteRange{Start: 5, Limit: 12},
+	})
+
+	require.NoError(t.T(), err)
+	defer rc.Close()
+	contents, err := io.ReadAll(rc)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), gzipTestContents[5:12], string(contents))
+}
+
+func (t *GzipBucketTest) TestChunkWriterCompresses() {
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: "foo.log"}, 1024, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte(gzipTestContents))
+	require.NoError(t.T(), err)
+
+	m, err := t.bucket.FinalizeUpload(t.ctx, w)
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), len(gzipTestContents), m.Size)
+	assert.Equal(t.T(), gzipTestContents, t.readStored("foo.log"))
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "foo.log")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), gzipTestContents, string(contents))
+}
+
+func (t *GzipBucketTest) TestComposeObjectsFailsForCompressedObject() {
+	src, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.log", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "tmp", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	_, err = t.bucket.ComposeObjects(t.ctx, &gcs.ComposeObjectsRequest{
+		DstName:                   "foo.log",
+		DstGenerationPrecondition: &src.Generation,
+		Sources:                   []gcs.ComposeSource{{Name: "foo.log", Generation: src.Generation}, {Name: "tmp"}},
+	})
+
+	assert.ErrorIs(t.T(), err, errors.ErrUnsupported)
+	assert.Equal(t.T(), "taco", t.readStored("foo.log"))
+}
+
+func (t *GzipBucketTest) TestMultiRangeDownloaderDecompresses() {
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.log", []byte(gzipTestContents))
+	require.NoError(t.T(), err)
+	mrd, err := t.bucket.NewMultiRangeDownloader(t.ctx, &gcs.MultiRangeDownloaderRequest{
+		Name:       "foo.log",
+		Generation: o.Generation,
+	})
+	require.NoError(t.T(), err)
+	var buf bytes.Buffer
+	var callbackErr error
+
+	mrd.Add(&buf, 13, 9, func(_ int64, _ int64, err error) { callbackErr = err })
+	mrd.Wait()
+
+	require.NoError(t.T(), callbackErr)
+	assert.Equal(t.T(), gzipTestContents[13:22], buf.String())
+	assert.NoError(t.T(), mrd.Close())
+}
+
+func (t *GzipBucketTest) TestObjectsNoLongerMatchingAreReadAsStored() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.log", []byte(gzipTestContents))
+	require.NoError(t.T(), err)
+	bucket := NewGzipBucket([]string{"*.txt"}, "", t.wrapped)
+
+	m, _, err := bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo.log"})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), gcs.ContentEncodingGzip, m.ContentEncoding)
+	contents, err := storageutil.ReadObject(t.ctx, bucket, "foo.log")
+	require.NoError(t.T(), err)
+	assert.False(t.T(), strings.HasPrefix(string(contents), "taco"))
+}
+
+func (t *GzipBucketTest) TestCreateObjectRecordsUncompressedSizeWithoutUpdate() {
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.log", []byte(gzipTestContents))
+
+	require.NoError(t.T(), err)
+	// The object isn't updated after its creation.
+	assert.EqualValues(t.T(), 1, o.MetaGeneration)
+	m, _, err := t.wrapped.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo.log"})
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 1, m.MetaGeneration)
+	assert.Equal(t.T(), "45", m.Metadata[uncompressedSizeMetadataKey])
+}
+
+func (t *GzipBucketTest) TestCompressedContentsAreStagedInTempDir() {
+	tempDir := path.Join(t.T().TempDir(), "missing")
+	bucket := NewGzipBucket([]string{"*.log"}, tempDir, t.wrapped)
+
+	_, err := storageutil.CreateObject(t.ctx, bucket, "foo.log", []byte(gzipTestContents))
+
+	assert.ErrorContains(t.T(), err, tempDir)
+	_, err = storageutil.CreateObject(t.ctx, bucket, "foo.txt", []byte(gzipTestContents))
+	assert.NoError(t.T(), err)
+}
+
+// statCountingBucket counts the objects statted through it.
+type statCountingBucket struct {
+	gcs.Bucket
+	stats int
+}
+
+func (b *statCountingBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	b.stats++
+	return b.Bucket.StatObject(ctx, req)
+}
+
+func (t *GzipBucketTest) TestNewReaderUsesRecordOfRequest() {
+	counting := &statCountingBucket{Bucket: t.wrapped}
+	t.bucket = NewGzipBucket([]string{"*.log"}, "", counting)
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo.log", []byte(gzipTestContents))
+	require.NoError(t.T(), err)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo.log"})
+	require.NoError(t.T(), err)
+	counting.stats = 0
+
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:       "foo.log",
+		Generation: m.Generation,
+		Range:      &gcs.ByteRange{Start: 5, Limit: 12},
+		Object:     m,
+	})
+
+	require.NoError(t.T(), err)
+	defer rc.Close()
+	contents, err := io.ReadAll(rc)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), gzipTestContents[5:12], string(contents))
+	assert.Zero(t.T(), counting.stats)
+}
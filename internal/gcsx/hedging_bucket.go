@@ -0,0 +1,275 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"io"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+	"golang.org/x/time/rate"
+)
+
+// The outcomes of hedged reads, annotating the hedged read count metric.
+const (
+	hedgeWon       = "won"
+	hedgeLost      = "lost"
+	hedgeThrottled = "throttled"
+)
+
+const (
+	// The number of recent first-byte latencies from which the hedging delay
+	// is derived, and the number needed before it is.
+	hedgingLatencySamples    = 1000
+	hedgingMinLatencySamples = 20
+)
+
+// HedgedReadsConfig configures the hedging of reads. See NewHedgingBucket.
+type HedgedReadsConfig struct {
+	// Reads are hedged once their first byte takes longer than this percentile
+	// of recent first-byte latencies, in (0, 1).
+	TargetPercentile float64
+
+	// Bounds of the delay after which reads are hedged. The upper bound is
+	// also the delay until enough reads have been timed.
+	MinDelay time.Duration
+	MaxDelay time.Duration
+
+	// The maximum number of hedges issued per second.
+	MaxHedgesPerSec float64
+}
+
+// NewHedgingBucket creates a wrapper bucket that hedges reads of objects: if
+// the first byte of a read hasn't arrived after a delay derived from the
+// first-byte latencies of recent reads, a duplicate request is issued, and
+// the reader of whichever request gets its first byte first is returned,
+// while the other request is cancelled.
+//
+// Hedges beyond the configured rate aren't issued. The outcome of each read
+// that was late enough to be hedged is recorded with the metric handle. Only
+// readers are hedged, not multi-range downloads.
+func NewHedgingBucket(config HedgedReadsConfig, metricHandle common.MetricHandle, wrapped gcs.Bucket) gcs.Bucket {
+	burst := max(1, int(config.MaxHedgesPerSec))
+	return &hedgingBucket{
+		Bucket:       wrapped,
+		config:       config,
+		metricHandle: metricHandle,
+		limiter:      rate.NewLimiter(rate.Limit(config.MaxHedgesPerSec), burst),
+	}
+}
+
+type hedgingBucket struct {
+	gcs.Bucket
+	config       HedgedReadsConfig
+	metricHandle common.MetricHandle
+	limiter      *rate.Limiter
+
+	mu sync.Mutex
+
+	// The most recent first-byte latencies, as a ring buffer whose oldest
+	// entry is at index next once full.
+	latencies []time.Duration // GUARDED_BY(mu)
+	next      int             // GUARDED_BY(mu)
+}
+
+// Return the delay after which reads are hedged.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *hedgingBucket) delay() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.latencies) < hedgingMinLatencySamples {
+		return b.config.MaxDelay
+	}
+
+	sorted := slices.Clone(b.latencies)
+	slices.Sort(sorted)
+	d := sorted[int(b.config.TargetPercentile*float64(len(sorted)-1))]
+	return min(max(d, b.config.MinDelay), b.config.MaxDelay)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *hedgingBucket) recordLatency(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.latencies) < hedgingLatencySamples {
+		b.latencies = append(b.latencies, d)
+		return
+	}
+	b.latencies[b.next] = d
+	b.next = (b.next + 1) % hedgingLatencySamples
+}
+
+// readAttempt is a request for the contents of an object, which is done once
+// its first byte has arrived or it failed.
+type readAttempt struct {
+	hedge  bool
+	cancel context.CancelFunc
+
+	// Set when done.
+	reader  gcs.StorageReader
+	first   []byte
+	latency time.Duration
+	err     error
+}
+
+// Start an attempt, which is sent to done once done.
+func (b *hedgingBucket) startAttempt(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest,
+	hedge bool,
+	done chan<- *readAttempt) *readAttempt {
+	ctx, cancel := context.WithCancel(ctx)
+	a := &readAttempt{hedge: hedge, cancel: cancel}
+	start := time.Now()
+
+	go func() {
+		a.reader, a.err = b.Bucket.NewReaderWithReadHandle(ctx, req)
+		if a.err == nil {
+			a.first, a.err = readFirstByte(a.reader)
+			if a.err != nil {
+				a.reader.Close()
+				a.reader = nil
+			}
+		}
+		a.latency = time.Since(start)
+		done <- a
+	}()
+
+	return a
+}
+
+// Read the first byte of the reader, if the contents aren't empty.
+func readFirstByte(r io.Reader) ([]byte, error) {
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			return buf, nil
+		}
+		if err == io.EOF {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// Release the attempts still running once they're done.
+func discardAttempts(pending int, done <-chan *readAttempt) {
+	for range pending {
+		a := <-done
+		if a.reader != nil {
+			a.reader.Close()
+		}
+		a.cancel()
+	}
+}
+
+func (b *hedgingBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *hedgingBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	// Both attempts may be sent before one of them is received.
+	done := make(chan *readAttempt, 2)
+	attempts := []*readAttempt{b.startAttempt(ctx, req, false, done)}
+	timer := time.NewTimer(b.delay())
+	defer timer.Stop()
+
+	var firstErr error
+	for pending := 1; ; {
+		select {
+		case <-timer.C:
+			if !b.limiter.Allow() {
+				b.metricHandle.GCSHedgedReadCount(ctx, 1, []common.MetricAttr{{Key: common.HedgeOutcome, Value: hedgeThrottled}})
+				continue
+			}
+			attempts = append(attempts, b.startAttempt(ctx, req, true, done))
+			pending++
+
+		case a := <-done:
+			pending--
+			if a.err != nil {
+				a.cancel()
+				if firstErr == nil {
+					firstErr = a.err
+				}
+				if pending > 0 {
+					continue
+				}
+				return nil, firstErr
+			}
+
+			b.recordLatency(a.latency)
+			if len(attempts) > 1 {
+				outcome := hedgeLost
+				if a.hedge {
+					outcome = hedgeWon
+				}
+				b.metricHandle.GCSHedgedReadCount(ctx, 1, []common.MetricAttr{{Key: common.HedgeOutcome, Value: outcome}})
+			}
+
+			if pending > 0 {
+				for _, other := range attempts {
+					if other != a {
+						other.cancel()
+					}
+				}
+				go discardAttempts(pending, done)
+			}
+
+			return &hedgedReader{
+				StorageReader: a.reader,
+				first:         a.first,
+				cancel:        a.cancel,
+			}, nil
+		}
+	}
+}
+
+// hedgedReader is the reader of the attempt that won, whose first byte it
+// already read.
+type hedgedReader struct {
+	gcs.StorageReader
+	first  []byte
+	cancel context.CancelFunc
+}
+
+func (r *hedgedReader) Read(p []byte) (int, error) {
+	if len(r.first) > 0 && len(p) > 0 {
+		n := copy(p, r.first)
+		r.first = r.first[n:]
+		return n, nil
+	}
+	return r.StorageReader.Read(p)
+}
+
+func (r *hedgedReader) Close() error {
+	err := r.StorageReader.Close()
+	r.cancel()
+	return err
+}
@@ -0,0 +1,207 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// slowReadsBucket delays the readers it creates by the delay returned for the
+// number of the call, starting at 1, or until their context is cancelled if
+// negative.
+type slowReadsBucket struct {
+	gcs.Bucket
+	delay func(call int) time.Duration
+
+	mu        sync.Mutex
+	calls     int
+	cancelled int
+}
+
+func (b *slowReadsBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	b.mu.Lock()
+	b.calls++
+	d := b.delay(b.calls)
+	b.mu.Unlock()
+
+	if d < 0 {
+		<-ctx.Done()
+		b.mu.Lock()
+		b.cancelled++
+		b.mu.Unlock()
+		return nil, ctx.Err()
+	}
+	time.Sleep(d)
+	return b.Bucket.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *slowReadsBucket) counts() (calls int, cancelled int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.calls, b.cancelled
+}
+
+type hedgeMetricHandle struct {
+	common.MetricHandle
+
+	mu       sync.Mutex
+	outcomes []string
+}
+
+func (h *hedgeMetricHandle) GCSHedgedReadCount(_ context.Context, _ int64, attrs []common.MetricAttr) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.outcomes = append(h.outcomes, attrs[0].Value)
+}
+
+type HedgingBucketTest struct {
+	suite.Suite
+	ctx     context.Context
+	slow    *slowReadsBucket
+	metrics *hedgeMetricHandle
+	config  HedgedReadsConfig
+}
+
+func TestHedgingBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(HedgingBucketTest))
+}
+
+func (t *HedgingBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.slow = &slowReadsBucket{
+		Bucket: fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{}),
+		delay:  func(int) time.Duration { return 0 },
+	}
+	t.metrics = &hedgeMetricHandle{MetricHandle: common.NewNoopMetrics()}
+	t.config = HedgedReadsConfig{
+		TargetPercentile: 0.9,
+		MinDelay:         time.Millisecond,
+		MaxDelay:         10 * time.Millisecond,
+		MaxHedgesPerSec:  100,
+	}
+	_, err := storageutil.CreateObject(t.ctx, t.slow.Bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+}
+
+func (t *HedgingBucketTest) read(bucket gcs.Bucket) string {
+	contents, err := storageutil.ReadObject(t.ctx, bucket, "foo")
+	require.NoError(t.T(), err)
+	return string(contents)
+}
+
+func (t *HedgingBucketTest) TestFastReadIsNotHedged() {
+	bucket := NewHedgingBucket(t.config, t.metrics, t.slow)
+
+	assert.Equal(t.T(), "taco", t.read(bucket))
+
+	calls, _ := t.slow.counts()
+	assert.Equal(t.T(), 1, calls)
+	assert.Empty(t.T(), t.metrics.outcomes)
+}
+
+func (t *HedgingBucketTest) TestStalledReadIsHedged() {
+	t.slow.delay = func(call int) time.Duration {
+		if call == 1 {
+			return -1
+		}
+		return 0
+	}
+	bucket := NewHedgingBucket(t.config, t.metrics, t.slow)
+
+	assert.Equal(t.T(), "taco", t.read(bucket))
+
+	assert.Eventually(t.T(), func() bool {
+		calls, cancelled := t.slow.counts()
+		return calls == 2 && cancelled == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t.T(), []string{hedgeWon}, t.metrics.outcomes)
+}
+
+func (t *HedgingBucketTest) TestHedgeLosesToSlowRead() {
+	t.slow.delay = func(call int) time.Duration {
+		if call == 1 {
+			return 50 * time.Millisecond
+		}
+		return -1
+	}
+	bucket := NewHedgingBucket(t.config, t.metrics, t.slow)
+
+	assert.Equal(t.T(), "taco", t.read(bucket))
+
+	assert.Eventually(t.T(), func() bool {
+		calls, cancelled := t.slow.counts()
+		return calls == 2 && cancelled == 1
+	}, time.Second, time.Millisecond)
+	assert.Equal(t.T(), []string{hedgeLost}, t.metrics.outcomes)
+}
+
+func (t *HedgingBucketTest) TestHedgesAreThrottled() {
+	t.slow.delay = func(int) time.Duration { return 30 * time.Millisecond }
+	t.config.MaxHedgesPerSec = 0.001
+	bucket := NewHedgingBucket(t.config, t.metrics, t.slow)
+
+	assert.Equal(t.T(), "taco", t.read(bucket))
+	assert.Equal(t.T(), "taco", t.read(bucket))
+
+	calls, _ := t.slow.counts()
+	assert.Equal(t.T(), 3, calls)
+	assert.Equal(t.T(), []string{hedgeLost, hedgeThrottled}, t.metrics.outcomes)
+}
+
+func (t *HedgingBucketTest) TestFailedReadIsNotHedged() {
+	bucket := NewHedgingBucket(t.config, t.metrics, t.slow)
+
+	_, err := bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "bar"})
+
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+	calls, _ := t.slow.counts()
+	assert.Equal(t.T(), 1, calls)
+}
+
+func (t *HedgingBucketTest) TestDelayFollowsRecentLatencies() {
+	t.config.MaxDelay = time.Second
+	b := NewHedgingBucket(t.config, t.metrics, t.slow).(*hedgingBucket)
+	for i := 1; i < hedgingMinLatencySamples; i++ {
+		b.recordLatency(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t.T(), time.Second, b.delay())
+
+	for i := hedgingMinLatencySamples; i <= 100; i++ {
+		b.recordLatency(time.Duration(i) * time.Millisecond)
+	}
+	assert.Equal(t.T(), 90*time.Millisecond, b.delay())
+
+	// Old latencies are forgotten.
+	for range hedgingLatencySamples {
+		b.recordLatency(2 * time.Second)
+	}
+	assert.Equal(t.T(), time.Second, b.delay())
+}
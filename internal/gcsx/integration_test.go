@@ -93,6 +93,7 @@ func (t *IntegrationTest) SetUp(ti *TestInfo) {
 		appendThreshold,
 		chunkTransferTimeoutSecs,
 		tmpObjectPrefix,
+		nil,
 		t.bucket)
 }
 
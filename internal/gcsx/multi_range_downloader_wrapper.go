@@ -169,6 +169,7 @@ func (mrdWrapper *MultiRangeDownloaderWrapper) ensureMultiRangeDownloader() (err
 			Name:           mrdWrapper.object.Name,
 			Generation:     mrdWrapper.object.Generation,
 			ReadCompressed: mrdWrapper.object.HasContentEncodingGzip(),
+			Object:         mrdWrapper.object,
 		})
 		if err == nil {
 			// Updating mrdWrapper.Wrapped only when MRD creation was successful.
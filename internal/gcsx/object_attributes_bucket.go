@@ -0,0 +1,217 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+	"gopkg.in/yaml.v3"
+)
+
+// ObjectAttributesTemplate holds the attributes set on newly created objects
+// whose names match it. A file of templates is a YAML list such as:
+//
+//	# Archive everything under archive/.
+//	- match: archive/
+//	  storage-class: ARCHIVE
+//	- match: "*.html"
+//	  cache-control: no-cache
+//	  metadata:
+//	    team: web
+type ObjectAttributesTemplate struct {
+	// A prefix of the object names, or a pattern in the syntax of path.Match if
+	// it contains any of the characters "*?[\". Patterns match the whole name,
+	// and their wildcards don't match "/".
+	Match string `yaml:"match"`
+
+	StorageClass       string            `yaml:"storage-class"`
+	CacheControl       string            `yaml:"cache-control"`
+	ContentDisposition string            `yaml:"content-disposition"`
+	Metadata           map[string]string `yaml:"metadata"`
+	KmsKeyName         string            `yaml:"kms-key-name"`
+}
+
+func (t *ObjectAttributesTemplate) isPattern() bool {
+	return strings.ContainsAny(t.Match, `*?[\`)
+}
+
+func (t *ObjectAttributesTemplate) matches(name string) bool {
+	if !t.isPattern() {
+		return strings.HasPrefix(name, t.Match)
+	}
+
+	// The pattern was checked when loaded.
+	ok, _ := path.Match(t.Match, name)
+	return ok
+}
+
+// LoadObjectAttributesTemplates reads the list of templates in the YAML file
+// at the given path.
+func LoadObjectAttributesTemplates(filePath string) (templates []ObjectAttributesTemplate, err error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	decoder := yaml.NewDecoder(f)
+	decoder.KnownFields(true)
+	if err = decoder.Decode(&templates); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing %q: %w", filePath, err)
+	}
+
+	for i := range templates {
+		t := &templates[i]
+		if t.Match == "" {
+			return nil, fmt.Errorf("template %d in %q: match is empty", i, filePath)
+		}
+		if t.isPattern() {
+			if _, err = path.Match(t.Match, ""); err != nil {
+				return nil, fmt.Errorf("template %d in %q: invalid pattern %q: %w", i, filePath, t.Match, err)
+			}
+		}
+	}
+
+	return templates, nil
+}
+
+// NewObjectAttributesBucket creates a wrapper bucket that sets the attributes
+// of the first of the given templates matching the name of each object
+// created through CreateObject, CreateObjectChunkWriter or ComposeObjects.
+// Attributes already set by the request, including individual metadata keys,
+// are kept. Composes into objects whose template requires a KMS key fail, as
+// composes can't set it.
+func NewObjectAttributesBucket(templates []ObjectAttributesTemplate, b gcs.Bucket) gcs.Bucket {
+	return objectAttributesBucket{
+		Bucket:    b,
+		templates: templates,
+	}
+}
+
+type objectAttributesBucket struct {
+	gcs.Bucket
+	templates []ObjectAttributesTemplate
+}
+
+// Return the first template matching the name, or nil.
+func (b objectAttributesBucket) template(name string) *ObjectAttributesTemplate {
+	for i := range b.templates {
+		if b.templates[i].matches(name) {
+			return &b.templates[i]
+		}
+	}
+	return nil
+}
+
+// Fill in the unset attributes of the request from the first matching
+// template, if any.
+func (b objectAttributesBucket) applyTemplate(req *gcs.CreateObjectRequest) {
+	t := b.template(req.Name)
+	if t == nil {
+		return
+	}
+
+	if req.StorageClass == "" {
+		req.StorageClass = t.StorageClass
+	}
+	if req.CacheControl == "" {
+		req.CacheControl = t.CacheControl
+	}
+	if req.ContentDisposition == "" {
+		req.ContentDisposition = t.ContentDisposition
+	}
+	if req.KmsKeyName == "" {
+		req.KmsKeyName = t.KmsKeyName
+	}
+	req.Metadata = t.mergeMetadata(req.Metadata)
+}
+
+// Like applyTemplate, for the destination of a compose request. Composes
+// can't set the KMS key of their destination, so they fail with an error
+// wrapping errors.ErrUnsupported for templates requiring one, for callers to
+// write the object in full instead.
+func (b objectAttributesBucket) applyComposeTemplate(req *gcs.ComposeObjectsRequest) error {
+	t := b.template(req.DstName)
+	if t == nil {
+		return nil
+	}
+	if t.KmsKeyName != "" {
+		return fmt.Errorf("composing %q, which requires KMS key %q: %w", req.DstName, t.KmsKeyName, errors.ErrUnsupported)
+	}
+
+	if req.StorageClass == "" {
+		req.StorageClass = t.StorageClass
+	}
+	if req.CacheControl == "" {
+		req.CacheControl = t.CacheControl
+	}
+	if req.ContentDisposition == "" {
+		req.ContentDisposition = t.ContentDisposition
+	}
+	req.Metadata = t.mergeMetadata(req.Metadata)
+	return nil
+}
+
+// Return the metadata of the template overridden by the given metadata.
+func (t *ObjectAttributesTemplate) mergeMetadata(m map[string]string) map[string]string {
+	if len(t.Metadata) == 0 {
+		return m
+	}
+
+	// Don't modify the caller's map, which may be shared with a source object.
+	metadata := make(map[string]string, len(m)+len(t.Metadata))
+	for k, v := range t.Metadata {
+		metadata[k] = v
+	}
+	for k, v := range m {
+		metadata[k] = v
+	}
+	return metadata
+}
+
+func (b objectAttributesBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	b.applyTemplate(req)
+
+	// Pass on the request.
+	o, err = b.Bucket.CreateObject(ctx, req)
+	return
+}
+
+func (b objectAttributesBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	b.applyTemplate(req)
+
+	// Pass on the request.
+	return b.Bucket.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+}
+
+func (b objectAttributesBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	if err := b.applyComposeTemplate(req); err != nil {
+		return nil, err
+	}
+
+	// Pass on the request.
+	return b.Bucket.ComposeObjects(ctx, req)
+}
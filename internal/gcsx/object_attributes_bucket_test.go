@@ -0,0 +1,186 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type ObjectAttributesBucketTest struct {
+	suite.Suite
+	ctx    context.Context
+	bucket gcs.Bucket
+}
+
+func TestObjectAttributesBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(ObjectAttributesBucketTest))
+}
+
+func (t *ObjectAttributesBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.bucket = NewObjectAttributesBucket(
+		[]ObjectAttributesTemplate{
+			{
+				Match:        "archive/",
+				StorageClass: "ARCHIVE",
+				Metadata:     map[string]string{"tier": "cold", "owner": "archiver"},
+			},
+			{Match: "*.html", CacheControl: "no-cache"},
+			{Match: "archive/*.html", CacheControl: "public"},
+			{Match: "secret/", KmsKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/k"},
+		},
+		fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{}))
+}
+
+func (t *ObjectAttributesBucketTest) create(req *gcs.CreateObjectRequest) *gcs.Object {
+	req.Contents = strings.NewReader("taco")
+	o, err := t.bucket.CreateObject(t.ctx, req)
+	require.NoError(t.T(), err)
+	return o
+}
+
+func (t *ObjectAttributesBucketTest) TestCreateObjectAppliesMatchingPrefix() {
+	o := t.create(&gcs.CreateObjectRequest{
+		Name:     "archive/2024/foo.html",
+		Metadata: map[string]string{"owner": "me"},
+	})
+
+	assert.Equal(t.T(), "ARCHIVE", o.StorageClass)
+	// Only the first matching template applies.
+	assert.Empty(t.T(), o.CacheControl)
+	// Metadata set by the request takes precedence.
+	assert.Equal(t.T(), map[string]string{"tier": "cold", "owner": "me"}, o.Metadata)
+}
+
+func (t *ObjectAttributesBucketTest) TestCreateObjectAppliesMatchingPattern() {
+	o := t.create(&gcs.CreateObjectRequest{Name: "index.html"})
+
+	assert.Equal(t.T(), "no-cache", o.CacheControl)
+	assert.Equal(t.T(), "STANDARD", o.StorageClass)
+	// Wildcards don't match "/".
+	o = t.create(&gcs.CreateObjectRequest{Name: "dir/index.html"})
+	assert.Empty(t.T(), o.CacheControl)
+}
+
+func (t *ObjectAttributesBucketTest) TestCreateObjectKeepsRequestedAttributes() {
+	o := t.create(&gcs.CreateObjectRequest{Name: "archive/foo", StorageClass: "NEARLINE"})
+
+	assert.Equal(t.T(), "NEARLINE", o.StorageClass)
+}
+
+func (t *ObjectAttributesBucketTest) TestCreateObjectChunkWriterAppliesTemplate() {
+	req := &gcs.CreateObjectRequest{Name: "archive/foo"}
+
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, req, 1024, nil)
+
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = t.bucket.FinalizeUpload(t.ctx, w)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "ARCHIVE", req.StorageClass)
+}
+
+func (t *ObjectAttributesBucketTest) TestComposeObjectsAppliesTemplate() {
+	t.create(&gcs.CreateObjectRequest{Name: "foo"})
+
+	o, err := t.bucket.ComposeObjects(t.ctx, &gcs.ComposeObjectsRequest{
+		DstName:  "archive/foo",
+		Sources:  []gcs.ComposeSource{{Name: "foo"}, {Name: "foo"}},
+		Metadata: map[string]string{"owner": "me"},
+	})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "ARCHIVE", o.StorageClass)
+	assert.Equal(t.T(), map[string]string{"tier": "cold", "owner": "me"}, o.Metadata)
+}
+
+func (t *ObjectAttributesBucketTest) TestComposeObjectsFailsForTemplatesWithKmsKey() {
+	t.create(&gcs.CreateObjectRequest{Name: "foo"})
+	req := &gcs.CreateObjectRequest{Name: "secret/foo"}
+	t.create(req)
+	assert.Equal(t.T(), "projects/p/locations/l/keyRings/r/cryptoKeys/k", req.KmsKeyName)
+
+	_, err := t.bucket.ComposeObjects(t.ctx, &gcs.ComposeObjectsRequest{
+		DstName: "secret/foo",
+		Sources: []gcs.ComposeSource{{Name: "secret/foo"}, {Name: "foo"}},
+	})
+
+	assert.ErrorIs(t.T(), err, errors.ErrUnsupported)
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "secret/foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *ObjectAttributesBucketTest) TestLoadObjectAttributesTemplates() {
+	filePath := path.Join(t.T().TempDir(), "templates.yaml")
+	require.NoError(t.T(), os.WriteFile(filePath, []byte(`
+- match: archive/
+  storage-class: ARCHIVE
+  kms-key-name: projects/p/locations/l/keyRings/r/cryptoKeys/k
+- match: "*.html"
+  cache-control: no-cache
+  content-disposition: inline
+  metadata:
+    team: web
+`), 0644))
+
+	templates, err := LoadObjectAttributesTemplates(filePath)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), []ObjectAttributesTemplate{
+		{
+			Match:        "archive/",
+			StorageClass: "ARCHIVE",
+			KmsKeyName:   "projects/p/locations/l/keyRings/r/cryptoKeys/k",
+		},
+		{
+			Match:              "*.html",
+			CacheControl:       "no-cache",
+			ContentDisposition: "inline",
+			Metadata:           map[string]string{"team": "web"},
+		},
+	}, templates)
+}
+
+func (t *ObjectAttributesBucketTest) TestLoadObjectAttributesTemplatesRejectsInvalidTemplates() {
+	testCases := []string{
+		"- storage-class: ARCHIVE\n",
+		"- match: \"[a\"\n",
+		"- match: foo\n  storage_class: ARCHIVE\n",
+	}
+
+	for _, contents := range testCases {
+		filePath := path.Join(t.T().TempDir(), "templates.yaml")
+		require.NoError(t.T(), os.WriteFile(filePath, []byte(contents), 0644))
+
+		_, err := LoadObjectAttributesTemplates(filePath)
+
+		assert.Error(t.T(), err, contents)
+	}
+}
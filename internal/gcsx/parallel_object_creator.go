@@ -0,0 +1,270 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/sync/errgroup"
+)
+
+// ParallelUploadConfig configures parallel composite uploads of large files.
+type ParallelUploadConfig struct {
+	// Contents of at least this many bytes are uploaded in parallel parts.
+	Threshold int64
+
+	// Size in bytes of each part. It is increased as required to keep the
+	// number of parts within gcs.MaxComponentCount.
+	PartSize int64
+
+	// Maximum number of parts uploaded concurrently.
+	Concurrency int
+}
+
+// Create an objectCreator that uploads contents of at least config.Threshold
+// bytes as parts in parallel under temporary names using the supplied prefix,
+// and composes them into the destination object. Smaller contents, and
+// readers that don't support random access, are passed on to fallback.
+//
+// As with composeObjectCreator, the temporary objects are deleted once done
+// but may be left behind if the process is interrupted. Users should arrange
+// for garbage collection.
+//
+// Create guarantees to return *gcs.PreconditionError when the source object
+// has been clobbered.
+func newParallelObjectCreator(
+	config *ParallelUploadConfig,
+	prefix string,
+	bucket gcs.Bucket,
+	fallback objectCreator) (oc objectCreator) {
+	oc = &parallelObjectCreator{
+		config:   *config,
+		prefix:   prefix,
+		bucket:   bucket,
+		fallback: fallback,
+	}
+
+	return
+}
+
+////////////////////////////////////////////////////////////////////////
+// Implementation
+////////////////////////////////////////////////////////////////////////
+
+type parallelObjectCreator struct {
+	config   ParallelUploadConfig
+	prefix   string
+	bucket   gcs.Bucket
+	fallback objectCreator
+}
+
+// readSeekerAt is the subset of TempFile required for uploading parts in
+// parallel.
+type readSeekerAt interface {
+	io.ReadSeeker
+	io.ReaderAt
+}
+
+// partSize returns the size of each part for contents of the given size.
+func (oc *parallelObjectCreator) partSize(size int64) int64 {
+	minPartSize := (size + gcs.MaxComponentCount - 1) / gcs.MaxComponentCount
+	return max(oc.config.PartSize, minPartSize)
+}
+
+func (oc *parallelObjectCreator) Create(
+	ctx context.Context,
+	objectName string,
+	srcObject *gcs.Object,
+	mtime *time.Time,
+	chunkTransferTimeoutSecs int64,
+	r io.Reader) (o *gcs.Object, err error) {
+	rs, ok := r.(readSeekerAt)
+	if !ok {
+		return oc.fallback.Create(ctx, objectName, srcObject, mtime, chunkTransferTimeoutSecs, r)
+	}
+
+	// Find the range of contents to be uploaded, i.e. from the current seek
+	// position to the end.
+	start, err := rs.Seek(0, io.SeekCurrent)
+	if err != nil {
+		err = fmt.Errorf("seek: %w", err)
+		return
+	}
+	end, err := rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		err = fmt.Errorf("seek: %w", err)
+		return
+	}
+	if _, err = rs.Seek(start, io.SeekStart); err != nil {
+		err = fmt.Errorf("seek: %w", err)
+		return
+	}
+
+	if end-start < oc.config.Threshold {
+		return oc.fallback.Create(ctx, objectName, srcObject, mtime, chunkTransferTimeoutSecs, r)
+	}
+
+	baseName, err := chooseTmpObjectName(oc.prefix)
+	if err != nil {
+		err = fmt.Errorf("chooseTmpObjectName: %w", err)
+		return
+	}
+
+	// Delete all the temporary objects when we're done, whether or not the
+	// upload succeeded. Failing to do so doesn't fail the sync, since the
+	// garbage collector will eventually catch them.
+	var tmpObjects []*gcs.Object
+	var tmpObjectsMu sync.Mutex
+	recordTmpObject := func(o *gcs.Object) {
+		tmpObjectsMu.Lock()
+		defer tmpObjectsMu.Unlock()
+		tmpObjects = append(tmpObjects, o)
+	}
+	defer func() {
+		oc.deleteTmpObjects(context.WithoutCancel(ctx), tmpObjects)
+	}()
+
+	// Upload the parts.
+	partSize := oc.partSize(end - start)
+	partCount := int((end - start + partSize - 1) / partSize)
+	parts := make([]gcs.ComposeSource, partCount)
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(oc.config.Concurrency)
+	for i := range partCount {
+		group.Go(func() error {
+			offset := start + int64(i)*partSize
+			req := gcs.NewCreateObjectRequest(nil, fmt.Sprintf("%s-part-%04d", baseName, i), nil, chunkTransferTimeoutSecs)
+			req.Contents = io.NewSectionReader(rs, offset, min(partSize, end-offset))
+			part, err := oc.bucket.CreateObject(groupCtx, req)
+			if err != nil {
+				return fmt.Errorf("CreateObject(%q): %w", req.Name, err)
+			}
+			recordTmpObject(part)
+			parts[i] = gcs.ComposeSource{Name: part.Name, Generation: part.Generation}
+			return nil
+		})
+	}
+	if err = group.Wait(); err != nil {
+		return
+	}
+
+	// Compose the parts in as many levels as required to get to a single
+	// request.
+	for level := 0; len(parts) > gcs.MaxSourcesPerComposeRequest; level++ {
+		parts, err = oc.composeLevel(ctx, fmt.Sprintf("%s-level-%d", baseName, level), parts, recordTmpObject)
+		if err != nil {
+			return oc.fallBackIfUnsupported(ctx, objectName, srcObject, mtime, chunkTransferTimeoutSecs, rs, start, err)
+		}
+	}
+
+	// Compose the final object with the same preconditions and properties as a
+	// full upload would have.
+	req := gcs.NewCreateObjectRequest(srcObject, objectName, mtime, chunkTransferTimeoutSecs)
+	o, err = oc.bucket.ComposeObjects(
+		ctx,
+		&gcs.ComposeObjectsRequest{
+			DstName:                       req.Name,
+			DstGenerationPrecondition:     req.GenerationPrecondition,
+			DstMetaGenerationPrecondition: req.MetaGenerationPrecondition,
+			Sources:                       parts,
+			Metadata:                      req.Metadata,
+			CacheControl:                  req.CacheControl,
+			ContentDisposition:            req.ContentDisposition,
+			ContentEncoding:               req.ContentEncoding,
+			ContentType:                   req.ContentType,
+			CustomTime:                    req.CustomTime,
+			EventBasedHold:                req.EventBasedHold,
+			StorageClass:                  req.StorageClass,
+		})
+	if err != nil {
+		return oc.fallBackIfUnsupported(ctx, objectName, srcObject, mtime, chunkTransferTimeoutSecs, rs, start, fmt.Errorf("ComposeObjects: %w", err))
+	}
+
+	return
+}
+
+// Upload the contents from the given start in full, if the parts of them
+// couldn't be composed because composes aren't supported for the object, such
+// as when it requires a KMS key. Return the compose error otherwise.
+func (oc *parallelObjectCreator) fallBackIfUnsupported(
+	ctx context.Context,
+	objectName string,
+	srcObject *gcs.Object,
+	mtime *time.Time,
+	chunkTransferTimeoutSecs int64,
+	rs readSeekerAt,
+	start int64,
+	composeErr error) (*gcs.Object, error) {
+	if !errors.Is(composeErr, errors.ErrUnsupported) {
+		return nil, composeErr
+	}
+	if _, err := rs.Seek(start, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seek: %w", err)
+	}
+	return oc.fallback.Create(ctx, objectName, srcObject, mtime, chunkTransferTimeoutSecs, rs)
+}
+
+// composeLevel composes each run of gcs.MaxSourcesPerComposeRequest sources
+// into an intermediate temporary object, and returns the resulting objects as
+// sources for the next level.
+func (oc *parallelObjectCreator) composeLevel(
+	ctx context.Context,
+	levelName string,
+	sources []gcs.ComposeSource,
+	recordTmpObject func(*gcs.Object)) (composed []gcs.ComposeSource, err error) {
+	composed = make([]gcs.ComposeSource, (len(sources)+gcs.MaxSourcesPerComposeRequest-1)/gcs.MaxSourcesPerComposeRequest)
+	group, groupCtx := errgroup.WithContext(ctx)
+	group.SetLimit(oc.config.Concurrency)
+	for i := range composed {
+		group.Go(func() error {
+			var preCond int64
+			req := &gcs.ComposeObjectsRequest{
+				DstName:                   fmt.Sprintf("%s-%04d", levelName, i),
+				DstGenerationPrecondition: &preCond,
+				Sources:                   sources[i*gcs.MaxSourcesPerComposeRequest : min((i+1)*gcs.MaxSourcesPerComposeRequest, len(sources))],
+			}
+			o, err := oc.bucket.ComposeObjects(groupCtx, req)
+			if err != nil {
+				return fmt.Errorf("ComposeObjects(%q): %w", req.DstName, err)
+			}
+			recordTmpObject(o)
+			composed[i] = gcs.ComposeSource{Name: o.Name, Generation: o.Generation}
+			return nil
+		})
+	}
+	err = group.Wait()
+	return
+}
+
+func (oc *parallelObjectCreator) deleteTmpObjects(ctx context.Context, objects []*gcs.Object) {
+	for _, o := range objects {
+		err := oc.bucket.DeleteObject(
+			ctx,
+			&gcs.DeleteObjectRequest{
+				Name:       o.Name,
+				Generation: o.Generation,
+			})
+		if err != nil {
+			logger.Warnf("Failed to delete temporary object %q: %v", o.Name, err)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const parallelUploadTmpPrefix = ".gcsfuse_tmp/"
+
+// failingCreateBucket fails CreateObject for the objects whose name ends with
+// failSuffix.
+type failingCreateBucket struct {
+	gcs.Bucket
+	failSuffix string
+}
+
+func (b *failingCreateBucket) CreateObject(ctx context.Context, req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	if strings.HasSuffix(req.Name, b.failSuffix) {
+		return nil, errors.New("taco")
+	}
+	return b.Bucket.CreateObject(ctx, req)
+}
+
+type ParallelObjectCreatorTest struct {
+	suite.Suite
+	ctx      context.Context
+	bucket   gcs.Bucket
+	fallback fakeObjectCreator
+	clock    timeutil.SimulatedClock
+	mtime    time.Time
+}
+
+func TestParallelObjectCreatorTestSuite(t *testing.T) {
+	suite.Run(t, new(ParallelObjectCreatorTest))
+}
+
+func (t *ParallelObjectCreatorTest) SetupTest() {
+	t.ctx = context.Background()
+	t.clock.SetTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	t.bucket = fake.NewFakeBucket(&t.clock, "some_bucket", gcs.BucketType{})
+	t.fallback = fakeObjectCreator{err: errors.New("fallback")}
+	t.mtime = t.clock.Now().Add(time.Hour)
+}
+
+func (t *ParallelObjectCreatorTest) newCreator(threshold, partSize int64) objectCreator {
+	return newParallelObjectCreator(
+		&ParallelUploadConfig{Threshold: threshold, PartSize: partSize, Concurrency: 4},
+		parallelUploadTmpPrefix,
+		t.bucket,
+		&t.fallback)
+}
+
+func (t *ParallelObjectCreatorTest) tmpObjectNames() []string {
+	objects, _, err := storageutil.ListAll(t.ctx, t.bucket, &gcs.ListObjectsRequest{Prefix: parallelUploadTmpPrefix})
+	require.NoError(t.T(), err)
+	var names []string
+	for _, o := range objects {
+		names = append(names, o.Name)
+	}
+	return names
+}
+
+func (t *ParallelObjectCreatorTest) TestContentsBelowThresholdArePassedToFallback() {
+	creator := t.newCreator(10, 2)
+
+	_, err := creator.Create(t.ctx, "foo", nil, &t.mtime, chunkTransferTimeoutSecs, strings.NewReader("taco"))
+
+	assert.ErrorContains(t.T(), err, "fallback")
+	assert.True(t.T(), t.fallback.called)
+	assert.Equal(t.T(), "taco", string(t.fallback.contents))
+}
+
+func (t *ParallelObjectCreatorTest) TestCreatesNewObjectFromParts() {
+	creator := t.newCreator(1, 3)
+
+	o, err := creator.Create(t.ctx, "foo", nil, &t.mtime, chunkTransferTimeoutSecs, strings.NewReader("burrito"))
+
+	require.NoError(t.T(), err)
+	assert.False(t.T(), t.fallback.called)
+	assert.Equal(t.T(), "foo", o.Name)
+	assert.Equal(t.T(), t.mtime.Format(time.RFC3339Nano), o.Metadata[gcs.MtimeMetadataKey])
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+	assert.Empty(t.T(), t.tmpObjectNames())
+}
+
+func (t *ParallelObjectCreatorTest) TestFallsBackWhenObjectCantBeComposed() {
+	t.bucket = NewObjectAttributesBucket(
+		[]ObjectAttributesTemplate{{Match: "foo", KmsKeyName: "projects/p/locations/l/keyRings/r/cryptoKeys/k"}},
+		t.bucket)
+	t.fallback.err = nil
+	t.fallback.o = &gcs.Object{Name: "foo"}
+	creator := t.newCreator(1, 3)
+
+	o, err := creator.Create(t.ctx, "foo", nil, &t.mtime, chunkTransferTimeoutSecs, strings.NewReader("burrito"))
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), t.fallback.o, o)
+	assert.Equal(t.T(), "burrito", string(t.fallback.contents))
+	assert.Empty(t.T(), t.tmpObjectNames())
+}
+
+func (t *ParallelObjectCreatorTest) TestComposesInMultipleLevelsBeyondMaxSources() {
+	contents := strings.Repeat("0123456789", 110)
+	creator := t.newCreator(1, 1)
+
+	_, err := creator.Create(t.ctx, "foo", nil, &t.mtime, chunkTransferTimeoutSecs, strings.NewReader(contents))
+
+	require.NoError(t.T(), err)
+	actual, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), contents, string(actual))
+	assert.Empty(t.T(), t.tmpObjectNames())
+}
+
+func (t *ParallelObjectCreatorTest) TestUploadsFromCurrentSeekPosition() {
+	r := strings.NewReader("xxtaco")
+	_, err := r.Seek(2, 0)
+	require.NoError(t.T(), err)
+	creator := t.newCreator(1, 3)
+
+	_, err = creator.Create(t.ctx, "foo", nil, &t.mtime, chunkTransferTimeoutSecs, r)
+
+	require.NoError(t.T(), err)
+	actual, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(actual))
+}
+
+func (t *ParallelObjectCreatorTest) TestOverwritesSourceObjectWithItsProperties() {
+	src, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:        "foo",
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"test_key": "test_value"},
+		Contents:    strings.NewReader("taco"),
+	})
+	require.NoError(t.T(), err)
+	creator := t.newCreator(1, 3)
+
+	o, err := creator.Create(t.ctx, "foo", src, &t.mtime, chunkTransferTimeoutSecs, strings.NewReader("burrito"))
+
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), o.Generation, src.Generation)
+	assert.Equal(t.T(), "text/plain", o.ContentType)
+	assert.Equal(t.T(), "test_value", o.Metadata["test_key"])
+	actual, err := storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(actual))
+}
+
+func (t *ParallelObjectCreatorTest) TestReturnsPreconditionErrorWhenSourceObjectIsClobbered() {
+	src, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "foo",
+		Contents: strings.NewReader("taco"),
+	})
+	require.NoError(t.T(), err)
+	_, err = t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "foo",
+		Contents: strings.NewReader("enchilada"),
+	})
+	require.NoError(t.T(), err)
+	creator := t.newCreator(1, 3)
+
+	_, err = creator.Create(t.ctx, "foo", src, &t.mtime, chunkTransferTimeoutSecs, strings.NewReader("burrito"))
+
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr))
+	assert.Empty(t.T(), t.tmpObjectNames())
+}
+
+func (t *ParallelObjectCreatorTest) TestDeletesUploadedPartsWhenPartUploadFails() {
+	t.bucket = &failingCreateBucket{Bucket: t.bucket, failSuffix: "-part-0002"}
+	creator := t.newCreator(1, 3)
+
+	_, err := creator.Create(t.ctx, "foo", nil, &t.mtime, chunkTransferTimeoutSecs, strings.NewReader("burrito"))
+
+	assert.ErrorContains(t.T(), err, "taco")
+	assert.Empty(t.T(), t.tmpObjectNames())
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
@@ -118,6 +118,15 @@ func (b *prefixBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.Cre
 	return wc, err
 }
 
+func (b *prefixBucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	// Modify the request and call through.
+	mReq := new(gcs.AppendObjectRequest)
+	*mReq = *req
+	mReq.Name = b.wrappedName(req.Name)
+
+	return b.wrapped.CreateAppendableObjectWriter(ctx, mReq, chunkSize, callBack)
+}
+
 func (b *prefixBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (o *gcs.MinObject, err error) {
 	o, err = b.wrapped.FinalizeUpload(ctx, w)
 	// Modify the returned object.
@@ -204,6 +213,10 @@ func (b *prefixBucket) ListObjects(
 			o.Name = b.localName(o.Name)
 		}
 
+		for i := range l.Lifetimes {
+			l.Lifetimes[i].Name = b.localName(l.Lifetimes[i].Name)
+		}
+
 		for i, n := range l.CollapsedRuns {
 			l.CollapsedRuns[i] = strings.TrimPrefix(n, b.prefix)
 		}
@@ -479,6 +479,7 @@ func (rr *randomReader) startRead(start int64, end int64) (err error) {
 			},
 			ReadCompressed: rr.object.HasContentEncodingGzip(),
 			ReadHandle:     rr.readHandle,
+			Object:         rr.object,
 		})
 
 	// If a file handle is open locally, but the corresponding object doesn't exist
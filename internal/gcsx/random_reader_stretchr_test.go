@@ -75,8 +75,8 @@ func (t *RandomReaderStretchrTest) SetupTest() {
 	lruCache := lru.NewCache(CacheMaxSize)
 	t.jobManager = downloader.NewJobManager(lruCache, util.DefaultFilePerm, util.DefaultDirPerm, t.cacheDir, sequentialReadSizeInMb, &cfg.FileCacheConfig{
 		EnableCrc: false,
-	}, nil)
-	t.cacheHandler = file.NewCacheHandler(lruCache, t.jobManager, t.cacheDir, util.DefaultFilePerm, util.DefaultDirPerm)
+	}, nil, nil)
+	t.cacheHandler = file.NewCacheHandler(lruCache, t.jobManager, t.cacheDir, util.DefaultFilePerm, util.DefaultDirPerm, nil)
 
 	// Set up the reader.
 	rr := NewRandomReader(t.object, t.mockBucket, sequentialReadSizeInMb, nil, false, common.NewNoopMetrics(), nil)
@@ -273,6 +273,7 @@ func (t *RandomReaderStretchrTest) Test_ReadFromRangeReader_WhenExistingReaderIs
 				},
 				ReadCompressed: t.rr.wrapped.object.HasContentEncodingGzip(),
 				ReadHandle:     t.rr.wrapped.readHandle,
+				Object:         t.rr.wrapped.object,
 			}
 			t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, readObjectRequest).Return(rc, nil).Times(1)
 			buf := make([]byte, dataSize)
@@ -503,6 +504,7 @@ func (t *RandomReaderStretchrTest) Test_ExistingReader_WrongOffset() {
 				},
 				ReadCompressed: t.rr.wrapped.object.HasContentEncodingGzip(),
 				ReadHandle:     t.rr.wrapped.readHandle,
+				Object:         t.rr.wrapped.object,
 			}
 			t.mockBucket.
 				On("NewReaderWithReadHandle", mock.Anything, readObjectRequest).
@@ -538,6 +540,7 @@ func (t *RandomReaderStretchrTest) Test_ReadAt_ExistingReaderLimitIsLessThanRequ
 		},
 		ReadCompressed: t.rr.wrapped.object.HasContentEncodingGzip(),
 		ReadHandle:     expectedHandleInRequest,
+		Object:         t.rr.wrapped.object,
 	}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, readObjectRequest).Return(rc, nil)
 	t.mockBucket.On("BucketType", mock.Anything).Return(gcs.BucketType{}).Times(1)
@@ -572,6 +575,7 @@ func (t *RandomReaderStretchrTest) Test_ReadAt_ExistingReaderLimitIsLessThanRequ
 		},
 		ReadCompressed: t.rr.wrapped.object.HasContentEncodingGzip(),
 		ReadHandle:     expectedHandleInRequest,
+		Object:         t.rr.wrapped.object,
 	}
 	t.mockBucket.On("NewReaderWithReadHandle", mock.Anything, readObjectRequest).Return(rc, nil)
 	t.mockBucket.On("BucketType", mock.Anything).Return(gcs.BucketType{}).Times(1)
@@ -0,0 +1,67 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"io"
+	"sync"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// Helpers for the wrapper buckets which store contents differently from how
+// they present them, so that GCS can't serve ranges of them.
+
+// readerMultiRangeDownloader serves each range added to it from a reader of
+// that range.
+type readerMultiRangeDownloader struct {
+	ctx       context.Context
+	newReader func(ctx context.Context, r *gcs.ByteRange) (gcs.StorageReader, error)
+	wg        sync.WaitGroup
+}
+
+func newReaderMultiRangeDownloader(
+	ctx context.Context,
+	newReader func(ctx context.Context, r *gcs.ByteRange) (gcs.StorageReader, error)) gcs.MultiRangeDownloader {
+	return &readerMultiRangeDownloader{ctx: ctx, newReader: newReader}
+}
+
+func (d *readerMultiRangeDownloader) Add(output io.Writer, offset, length int64, callback func(int64, int64, error)) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		var n int64
+		r, err := d.newReader(d.ctx, &gcs.ByteRange{
+			Start: uint64(offset),
+			Limit: uint64(offset + length),
+		})
+		if err == nil {
+			n, err = io.Copy(output, r)
+			r.Close()
+		}
+		callback(offset, n, err)
+	}()
+}
+
+func (d *readerMultiRangeDownloader) Close() error {
+	d.wg.Wait()
+	return nil
+}
+
+func (d *readerMultiRangeDownloader) Wait() {
+	d.wg.Wait()
+}
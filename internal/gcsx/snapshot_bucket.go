@@ -0,0 +1,386 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"syscall"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// NewSnapshotBucket creates a read-only view on the wrapped bucket as of the
+// given time, using versioned listings of the wrapped bucket: each object name
+// resolves to its newest generation created at or before that time, unless the
+// generation had already become noncurrent by then.
+//
+// The view never changes, so its results can be cached indefinitely.
+func NewSnapshotBucket(t time.Time, wrapped gcs.Bucket) gcs.Bucket {
+	return &snapshotBucket{
+		time:    t,
+		wrapped: wrapped,
+	}
+}
+
+var errSnapshotReadOnly = fmt.Errorf("snapshot mounts are read-only: %w", syscall.EROFS)
+
+type snapshotBucket struct {
+	time    time.Time
+	wrapped gcs.Bucket
+}
+
+// Return the generations of the given versioned listing which were live at
+// the snapshot time. Generations without a lifetime in the listing, from
+// buckets without versioning, are always live.
+func (b *snapshotBucket) liveObjects(listing *gcs.Listing) (live []*gcs.MinObject) {
+	type key struct {
+		name       string
+		generation int64
+	}
+	lifetimes := make(map[key]gcs.GenerationLifetime, len(listing.Lifetimes))
+	for _, l := range listing.Lifetimes {
+		lifetimes[key{l.Name, l.Generation}] = l
+	}
+
+	for _, o := range listing.MinObjects {
+		l := lifetimes[key{o.Name, o.Generation}]
+		if !l.Created.After(b.time) && (l.Deleted.IsZero() || l.Deleted.After(b.time)) {
+			live = append(live, o)
+		}
+	}
+	return
+}
+
+// Return the generation of the named object which was live at the snapshot
+// time.
+func (b *snapshotBucket) resolve(ctx context.Context, name string) (*gcs.MinObject, error) {
+	// Collapse the subtree under the name, which doesn't matter.
+	req := &gcs.ListObjectsRequest{
+		Prefix:        name,
+		Delimiter:     "/",
+		ProjectionVal: gcs.NoAcl,
+		Versions:      true,
+	}
+
+	// The generations of the named object are listed before the objects whose
+	// names it prefixes.
+	for {
+		listing, err := b.wrapped.ListObjects(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, o := range b.liveObjects(listing) {
+			if o.Name == name {
+				return o, nil
+			}
+		}
+
+		done := len(listing.CollapsedRuns) > 0 || listing.ContinuationToken == ""
+		if n := len(listing.MinObjects); done || (n > 0 && listing.MinObjects[n-1].Name != name) {
+			return nil, b.notFound(name)
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+}
+
+func (b *snapshotBucket) notFound(name string) error {
+	return &gcs.NotFoundError{Err: fmt.Errorf("object %q not found at %v", name, b.time)}
+}
+
+func (b *snapshotBucket) Name() string {
+	return b.wrapped.Name()
+}
+
+func (b *snapshotBucket) BucketType() gcs.BucketType {
+	// Folders have no history, so the view is made of objects only.
+	t := b.wrapped.BucketType()
+	t.Hierarchical = false
+	return t
+}
+
+func (b *snapshotBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
+	rc, err = b.NewReaderWithReadHandle(ctx, req)
+	return
+}
+
+func (b *snapshotBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rd gcs.StorageReader, err error) {
+	// Modify the request to read the live generation at the snapshot time, if
+	// it doesn't specify one, and call through.
+	mReq := new(gcs.ReadObjectRequest)
+	*mReq = *req
+	if mReq.Generation == 0 {
+		var m *gcs.MinObject
+		if m, err = b.resolve(ctx, req.Name); err != nil {
+			return
+		}
+		mReq.Generation = m.Generation
+	}
+
+	rd, err = b.wrapped.NewReaderWithReadHandle(ctx, mReq)
+	return
+}
+
+func (b *snapshotBucket) NewMultiRangeDownloader(
+	ctx context.Context, req *gcs.MultiRangeDownloaderRequest) (mrd gcs.MultiRangeDownloader, err error) {
+	mReq := new(gcs.MultiRangeDownloaderRequest)
+	*mReq = *req
+	if mReq.Generation == 0 {
+		var m *gcs.MinObject
+		if m, err = b.resolve(ctx, req.Name); err != nil {
+			return
+		}
+		mReq.Generation = m.Generation
+	}
+
+	mrd, err = b.wrapped.NewMultiRangeDownloader(ctx, mReq)
+	return
+}
+
+func (b *snapshotBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	if m, err = b.resolve(ctx, req.Name); err != nil {
+		return
+	}
+
+	if req.ReturnExtendedObjectAttributes {
+		e = &gcs.ExtendedObjectAttributes{}
+	}
+	return
+}
+
+// The maximum number of pages of the wrapped bucket a listing of the view
+// fetches, beyond the one it resumes from, before returning a continuation
+// token. A single listing of a directory whose subtrees have a long history
+// then can't take unbounded time.
+const snapshotListingMaxPages = 16
+
+// snapshotListingState is carried in the continuation tokens of listings of
+// the view.
+type snapshotListingState struct {
+	// The continuation token of the wrapped page being returned.
+	Token string
+
+	// Whether the objects of the page have been returned, and how many of its
+	// collapsed runs have been resolved, if the page was left part way.
+	ObjectsListed bool
+	RunsResolved  int
+
+	// The continuation token of the listing of the next collapsed run of the
+	// page to resolve, if left part way.
+	RunToken string
+}
+
+func (s *snapshotListingState) encode() string {
+	buf, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeSnapshotListingState(token string) (s snapshotListingState, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(buf, &s)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid continuation token %q: %w", token, err)
+	}
+	return
+}
+
+func (b *snapshotBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	var state snapshotListingState
+	if req.ContinuationToken != "" {
+		if state, err = decodeSnapshotListingState(req.ContinuationToken); err != nil {
+			return
+		}
+	}
+
+	wrappedReq := &gcs.ListObjectsRequest{
+		Prefix:                   req.Prefix,
+		Delimiter:                req.Delimiter,
+		IncludeTrailingDelimiter: req.IncludeTrailingDelimiter,
+		ContinuationToken:        state.Token,
+		MaxResults:               req.MaxResults,
+		ProjectionVal:            req.ProjectionVal,
+		Versions:                 true,
+	}
+
+	// Skip the pages without anything live, so that an empty listing with a
+	// continuation token isn't mistaken for an empty prefix, up to the maximum
+	// number of pages. Fetching again the page left part way doesn't count
+	// towards it, so that each call makes progress.
+	listing = new(gcs.Listing)
+	pages := 0
+	if state.ObjectsListed {
+		pages--
+	}
+	for {
+		if pages >= snapshotListingMaxPages {
+			listing.ContinuationToken = state.encode()
+			return
+		}
+
+		var wrappedListing *gcs.Listing
+		if wrappedListing, err = b.wrapped.ListObjects(ctx, wrappedReq); err != nil {
+			return nil, err
+		}
+		pages++
+
+		if !state.ObjectsListed {
+			listing.MinObjects = append(listing.MinObjects, b.liveObjects(wrappedListing)...)
+			state.ObjectsListed = true
+		}
+
+		// Runs collapsed by the wrapped bucket may only contain generations which
+		// weren't live at the snapshot time.
+		for state.RunsResolved < len(wrappedListing.CollapsedRuns) {
+			run := wrappedListing.CollapsedRuns[state.RunsResolved]
+			var live, resolved bool
+			if live, resolved, err = b.resolveRun(ctx, run, req.MaxResults, &state, &pages); err != nil {
+				return nil, err
+			}
+			if !resolved {
+				listing.ContinuationToken = state.encode()
+				return
+			}
+
+			if live {
+				listing.CollapsedRuns = append(listing.CollapsedRuns, run)
+			}
+			state.RunsResolved++
+		}
+
+		if wrappedListing.ContinuationToken == "" {
+			return
+		}
+		state = snapshotListingState{Token: wrappedListing.ContinuationToken}
+		if len(listing.MinObjects) > 0 || len(listing.CollapsedRuns) > 0 {
+			listing.ContinuationToken = state.encode()
+			return
+		}
+		wrappedReq.ContinuationToken = state.Token
+	}
+}
+
+// Find out whether any generation under the given collapsed run was live at
+// the snapshot time, listing its generations a page of the given size at a
+// time from state.RunToken on. Returns resolved false, with state.RunToken
+// updated, if the maximum number of pages is reached first.
+func (b *snapshotBucket) resolveRun(
+	ctx context.Context,
+	run string,
+	pageSize int,
+	state *snapshotListingState,
+	pages *int) (live bool, resolved bool, err error) {
+	req := &gcs.ListObjectsRequest{
+		Prefix:            run,
+		ContinuationToken: state.RunToken,
+		MaxResults:        pageSize,
+		ProjectionVal:     gcs.NoAcl,
+		Versions:          true,
+	}
+
+	for *pages < snapshotListingMaxPages {
+		var listing *gcs.Listing
+		if listing, err = b.wrapped.ListObjects(ctx, req); err != nil {
+			return
+		}
+		*pages++
+
+		live = len(b.liveObjects(listing)) > 0
+		if live || listing.ContinuationToken == "" {
+			state.RunToken = ""
+			resolved = true
+			return
+		}
+		req.ContinuationToken = listing.ContinuationToken
+		state.RunToken = listing.ContinuationToken
+	}
+	return
+}
+
+func (b *snapshotBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	return errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	return errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return nil, &gcs.NotFoundError{Err: fmt.Errorf("folder %q not found", folderName)}
+}
+
+func (b *snapshotBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return nil, errSnapshotReadOnly
+}
+
+func (b *snapshotBucket) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (*gcs.Folder, error) {
+	return nil, errSnapshotReadOnly
+}
@@ -0,0 +1,206 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SnapshotBucketTest struct {
+	suite.Suite
+	ctx     context.Context
+	clock   timeutil.SimulatedClock
+	wrapped gcs.Bucket
+
+	// The time between creating the first generations of the objects and
+	// modifying them.
+	snapshotTime time.Time
+	// The generation of "foo" live at snapshotTime.
+	fooGeneration int64
+}
+
+func TestSnapshotBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(SnapshotBucketTest))
+}
+
+func (t *SnapshotBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.clock.SetTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	t.wrapped = fake.NewFakeBucketWithVersioning(&t.clock, "some_bucket", gcs.BucketType{})
+
+	foo, err := storageutil.CreateObject(t.ctx, t.wrapped, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	t.fooGeneration = foo.Generation
+	_, err = storageutil.CreateObject(t.ctx, t.wrapped, "dir/bar", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	t.clock.AdvanceTime(time.Minute)
+	t.snapshotTime = t.clock.Now()
+	t.clock.AdvanceTime(time.Minute)
+
+	_, err = storageutil.CreateObject(t.ctx, t.wrapped, "foo", []byte("enchilada"))
+	require.NoError(t.T(), err)
+	require.NoError(t.T(), t.wrapped.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "dir/bar"}))
+	_, err = storageutil.CreateObject(t.ctx, t.wrapped, "baz", []byte("queso"))
+	require.NoError(t.T(), err)
+}
+
+func (t *SnapshotBucketTest) TestStatObjectResolvesGenerationAtSnapshotTime() {
+	bucket := NewSnapshotBucket(t.snapshotTime, t.wrapped)
+
+	m, _, err := bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), t.fooGeneration, m.Generation)
+	_, _, err = bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "dir/bar"})
+	assert.NoError(t.T(), err)
+}
+
+func (t *SnapshotBucketTest) TestStatObjectReturnsNotFound() {
+	bucket := NewSnapshotBucket(t.snapshotTime, t.wrapped)
+
+	// "baz" was created after the snapshot time.
+	_, _, err := bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "baz"})
+
+	var notFoundErr *gcs.NotFoundError
+	assert.ErrorAs(t.T(), err, &notFoundErr)
+	// "dir/bar" was deleted before the current time.
+	_, _, err = NewSnapshotBucket(t.clock.Now(), t.wrapped).StatObject(t.ctx, &gcs.StatObjectRequest{Name: "dir/bar"})
+	assert.ErrorAs(t.T(), err, &notFoundErr)
+}
+
+func (t *SnapshotBucketTest) TestNewReaderReadsGenerationAtSnapshotTime() {
+	bucket := NewSnapshotBucket(t.snapshotTime, t.wrapped)
+
+	contents, err := storageutil.ReadObject(t.ctx, bucket, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *SnapshotBucketTest) TestListObjects() {
+	bucket := NewSnapshotBucket(t.snapshotTime, t.wrapped)
+
+	listing, err := bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Delimiter: "/"})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), []string{"dir/"}, listing.CollapsedRuns)
+	require.Len(t.T(), listing.MinObjects, 1)
+	assert.Equal(t.T(), "foo", listing.MinObjects[0].Name)
+	assert.Equal(t.T(), t.fooGeneration, listing.MinObjects[0].Generation)
+}
+
+func (t *SnapshotBucketTest) TestListObjectsAtCurrentTime() {
+	bucket := NewSnapshotBucket(t.clock.Now(), t.wrapped)
+
+	listing, err := bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Delimiter: "/"})
+
+	require.NoError(t.T(), err)
+	assert.Empty(t.T(), listing.CollapsedRuns)
+	require.Len(t.T(), listing.MinObjects, 2)
+	assert.Equal(t.T(), "baz", listing.MinObjects[0].Name)
+	assert.Equal(t.T(), "foo", listing.MinObjects[1].Name)
+	assert.NotEqual(t.T(), t.fooGeneration, listing.MinObjects[1].Generation)
+}
+
+func (t *SnapshotBucketTest) TestListObjectsReturnsRunsOnceAcrossPages() {
+	for _, name := range []string{"qux/a", "qux/b", "qux/c"} {
+		_, err := storageutil.CreateObject(t.ctx, t.wrapped, name, []byte("taco"))
+		require.NoError(t.T(), err)
+	}
+	bucket := NewSnapshotBucket(t.clock.Now(), &pagingBucket{Bucket: t.wrapped})
+
+	var names, runs []string
+	req := &gcs.ListObjectsRequest{Delimiter: "/", MaxResults: 1}
+	for {
+		listing, err := bucket.ListObjects(t.ctx, req)
+		require.NoError(t.T(), err)
+		for _, o := range listing.MinObjects {
+			names = append(names, o.Name)
+		}
+		runs = append(runs, listing.CollapsedRuns...)
+		if listing.ContinuationToken == "" {
+			break
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+
+	assert.Equal(t.T(), []string{"baz", "foo"}, names)
+	assert.Equal(t.T(), []string{"qux/"}, runs)
+}
+
+// listCountingBucket counts the listings made of the bucket it wraps.
+type listCountingBucket struct {
+	gcs.Bucket
+	lists int
+}
+
+func (b *listCountingBucket) ListObjects(ctx context.Context, req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	b.lists++
+	return b.Bucket.ListObjects(ctx, req)
+}
+
+func (t *SnapshotBucketTest) TestListObjectsBoundsPagesOfRunsWithoutLiveGenerations() {
+	for i := 0; i < 2*snapshotListingMaxPages; i++ {
+		name := fmt.Sprintf("old/%02d", i)
+		_, err := storageutil.CreateObject(t.ctx, t.wrapped, name, []byte("taco"))
+		require.NoError(t.T(), err)
+		require.NoError(t.T(), t.wrapped.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: name}))
+	}
+	wrapped := &listCountingBucket{Bucket: &pagingBucket{Bucket: t.wrapped}}
+	bucket := NewSnapshotBucket(t.clock.Now(), wrapped)
+
+	var names, runs []string
+	req := &gcs.ListObjectsRequest{Delimiter: "/", MaxResults: 1}
+	for {
+		wrapped.lists = 0
+		listing, err := bucket.ListObjects(t.ctx, req)
+		require.NoError(t.T(), err)
+		assert.LessOrEqual(t.T(), wrapped.lists, snapshotListingMaxPages+1)
+		for _, o := range listing.MinObjects {
+			names = append(names, o.Name)
+		}
+		runs = append(runs, listing.CollapsedRuns...)
+		if listing.ContinuationToken == "" {
+			break
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+
+	assert.Equal(t.T(), []string{"baz", "foo"}, names)
+	assert.Empty(t.T(), runs)
+}
+
+func (t *SnapshotBucketTest) TestMutationsFailAsReadOnly() {
+	bucket := NewSnapshotBucket(t.snapshotTime, t.wrapped)
+
+	_, err := storageutil.CreateObject(t.ctx, bucket, "foo", []byte("taco"))
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+
+	err = bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo"})
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+}
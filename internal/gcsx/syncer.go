@@ -15,6 +15,7 @@
 package gcsx
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"time"
@@ -48,13 +49,18 @@ type Syncer interface {
 // Temporary blobs have names beginning with tmpObjectPrefix. We make an effort
 // to delete them, but if we are interrupted for some reason we may not be able
 // to do so. Therefore the user should arrange for garbage collection.
+//
+// If parallelUploadConfig is non-nil, contents written out in their entirety
+// that are at least parallelUploadConfig.Threshold bytes long are uploaded as
+// temporary parts in parallel, which are then composed into the object.
 func NewSyncer(
 	composeThreshold int64,
 	chunkTransferTimeoutSecs int64,
 	tmpObjectPrefix string,
+	parallelUploadConfig *ParallelUploadConfig,
 	bucket gcs.Bucket) (os Syncer) {
 	// Create the object creators.
-	fullCreator := &fullObjectCreator{
+	var fullCreator objectCreator = &fullObjectCreator{
 		bucket: bucket,
 	}
 
@@ -65,6 +71,14 @@ func NewSyncer(
 		composeCreator = newComposeObjectCreator(
 			tmpObjectPrefix,
 			bucket)
+
+		if parallelUploadConfig != nil {
+			fullCreator = newParallelObjectCreator(
+				parallelUploadConfig,
+				tmpObjectPrefix,
+				bucket,
+				fullCreator)
+		}
 	}
 
 	// And the syncer.
@@ -202,6 +216,7 @@ func (os *syncer) SyncObject(
 	// Otherwise, we need to create a new generation. If the source object is
 	// long enough, hasn't been dirtied, and has a low enough component count,
 	// then we can make the optimization of not rewriting its contents.
+	composed := false
 	if os.composeCreator != nil && srcSize >= os.composeThreshold &&
 		sr.DirtyThreshold == srcSize &&
 		srcObject.ComponentCount < gcs.MaxComponentCount {
@@ -212,7 +227,13 @@ func (os *syncer) SyncObject(
 		}
 
 		o, err = os.composeCreator.Create(ctx, objectName, srcObject, sr.Mtime, os.chunkTransferTimeoutSecs, content)
-	} else {
+
+		// Objects that can't be composed, such as those requiring a KMS key,
+		// are written in full.
+		composed = !errors.Is(err, errors.ErrUnsupported)
+	}
+
+	if !composed {
 		_, err = content.Seek(0, 0)
 		if err != nil {
 			err = fmt.Errorf("seek: %w", err)
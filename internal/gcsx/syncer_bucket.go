@@ -29,8 +29,9 @@ func NewSyncerBucket(
 	appendThreshold int64,
 	chunkTransferTimeoutSecs int64,
 	tmpObjectPrefix string,
+	parallelUploadConfig *ParallelUploadConfig,
 	bucket gcs.Bucket,
 ) SyncerBucket {
-	syncer := NewSyncer(appendThreshold, chunkTransferTimeoutSecs, tmpObjectPrefix, bucket)
+	syncer := NewSyncer(appendThreshold, chunkTransferTimeoutSecs, tmpObjectPrefix, parallelUploadConfig, bucket)
 	return SyncerBucket{bucket, syncer}
 }
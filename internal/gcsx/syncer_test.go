@@ -16,6 +16,7 @@ package gcsx
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"strings"
 	"testing"
@@ -581,6 +582,25 @@ func (t *SyncerTest) AppendCreatorReturnsPreconditionError() {
 	ExpectTrue(errors.As(err, &preconditionErr))
 }
 
+func (t *SyncerTest) AppendCreatorUnsupported() {
+	var err error
+	t.appendCreator.err = fmt.Errorf("taco: %w", errors.ErrUnsupported)
+	t.fullCreator.o = &gcs.Object{}
+	t.fullCreator.err = nil
+
+	// Append some data.
+	_, err = t.content.WriteAt([]byte("burrito"), int64(t.srcObject.Size))
+	AssertEq(nil, err)
+
+	// Call
+	o, err := t.call()
+
+	AssertEq(nil, err)
+	ExpectEq(t.fullCreator.o, o)
+	ExpectTrue(t.appendCreator.called)
+	ExpectEq("tacoburrito", string(t.fullCreator.contents))
+}
+
 func (t *SyncerTest) AppendCreatorSucceeds() {
 	var err error
 	t.appendCreator.o = &gcs.Object{}
@@ -0,0 +1,924 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"cmp"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+const (
+	// The deletions of objects of lower layers are recorded as empty objects
+	// of the upper layer named after them under this prefix, which is hidden.
+	whiteoutPrefix = ".gcsfuse_whiteouts/"
+
+	// The generations of the objects of lower layers are tagged with the
+	// number of their layer in these bits, above real generations.
+	layerGenerationShift       = 56
+	layerGenerationMask  int64 = 0x3f << layerGenerationShift
+	maxLowerLayers             = 0x3f
+)
+
+var errWhiteoutName = fmt.Errorf("names under %q are reserved for union mounts: %w", whiteoutPrefix, syscall.EPERM)
+
+// NewUnionBucket creates a bucket overlaying the lower buckets, in decreasing
+// order of precedence, with the upper one, which takes precedence over all
+// of them and receives all modifications. Objects of lower layers being
+// modified are copied up first, and their deletions are recorded as whiteouts
+// in the upper layer, which hide them from then on.
+//
+// Generations of the objects of lower layers are tagged with their layer, in
+// high bits that the generations of every layer must leave clear, like those
+// of GCS, so that requests for them go to their layer. Listings merge those
+// of every layer in order, with the same precedence, page by page. Folders of
+// lower layers can't be renamed, failing with EXDEV like renames across file
+// systems.
+func NewUnionBucket(upper gcs.Bucket, lowers []gcs.Bucket) (gcs.Bucket, error) {
+	if len(lowers) > maxLowerLayers {
+		return nil, fmt.Errorf("at most %d lower layers are supported, got %d", maxLowerLayers, len(lowers))
+	}
+
+	return &unionBucket{
+		layers: append([]gcs.Bucket{upper}, lowers...),
+	}, nil
+}
+
+type unionBucket struct {
+	// The upper layer followed by the lower ones, numbered from 0.
+	layers []gcs.Bucket
+}
+
+func (b *unionBucket) upper() gcs.Bucket {
+	return b.layers[0]
+}
+
+func isNotFound(err error) bool {
+	var notFoundErr *gcs.NotFoundError
+	return errors.As(err, &notFoundErr)
+}
+
+func notFoundInLayers(name string) error {
+	return &gcs.NotFoundError{Err: fmt.Errorf("object %q not found in any layer", name)}
+}
+
+// Return the layer of the given generation, and the generation within it.
+func splitGeneration(generation int64) (layer int, g int64) {
+	return int((generation & layerGenerationMask) >> layerGenerationShift), generation &^ layerGenerationMask
+}
+
+// Return the layer of the given generation of the named object, and the
+// generation within it, failing with a not found error for generations not
+// tagged by the union, whose layer doesn't exist.
+func (b *unionBucket) splitGeneration(name string, generation int64) (layer int, g int64, err error) {
+	layer, g = splitGeneration(generation)
+	if layer >= len(b.layers) {
+		err = &gcs.NotFoundError{Err: fmt.Errorf("generation %d of %q is of no layer", generation, name)}
+	}
+	return
+}
+
+// Return a record of the object of the given layer, with its generation
+// tagged with the layer. Generations using the bits of the tags, which those
+// of GCS leave clear, can't be told apart from those of other layers.
+func tagLayer(o *gcs.MinObject, layer int) (*gcs.MinObject, error) {
+	if o == nil {
+		return nil, nil
+	}
+	if o.Generation&layerGenerationMask != 0 {
+		return nil, fmt.Errorf("generation %d of %q of layer %d uses the bits reserved for tagging layers, which union mounts require to be clear", o.Generation, o.Name, layer)
+	}
+	if layer == 0 {
+		return o, nil
+	}
+	c := *o
+	c.Generation |= int64(layer) << layerGenerationShift
+	return &c, nil
+}
+
+// Return true if the deletion of the named object is recorded in the upper
+// layer.
+func (b *unionBucket) whitedOut(ctx context.Context, name string) (bool, error) {
+	_, _, err := b.upper().StatObject(ctx, &gcs.StatObjectRequest{Name: whiteoutPrefix + name})
+	if isNotFound(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Look up the layer serving the named object, if any, and its record there.
+func (b *unionBucket) lookUp(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (layer int, m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	if strings.HasPrefix(req.Name, whiteoutPrefix) {
+		err = notFoundInLayers(req.Name)
+		return
+	}
+
+	m, e, err = b.upper().StatObject(ctx, req)
+	if !isNotFound(err) {
+		return
+	}
+
+	whitedOut, err := b.whitedOut(ctx, req.Name)
+	if err != nil {
+		return
+	}
+	if !whitedOut {
+		for layer = 1; layer < len(b.layers); layer++ {
+			m, e, err = b.layers[layer].StatObject(ctx, req)
+			if !isNotFound(err) {
+				return
+			}
+		}
+	}
+
+	err = notFoundInLayers(req.Name)
+	return
+}
+
+// Return the layer from which to read the given generation of the named
+// object, zero for the latest, and the generation within the layer.
+func (b *unionBucket) resolve(ctx context.Context, name string, generation int64) (layer int, g int64, err error) {
+	if generation != 0 {
+		return b.splitGeneration(name, generation)
+	}
+
+	layer, m, _, err := b.lookUp(ctx, &gcs.StatObjectRequest{Name: name})
+	if err != nil {
+		return
+	}
+	return layer, m.Generation, nil
+}
+
+// Return true if a lower layer has the named object or, for names of
+// directories, any object in it.
+func (b *unionBucket) inLowerLayers(ctx context.Context, name string) (bool, error) {
+	for _, lower := range b.layers[1:] {
+		_, _, err := lower.StatObject(ctx, &gcs.StatObjectRequest{Name: name})
+		if err == nil {
+			return true, nil
+		}
+		if !isNotFound(err) {
+			return false, err
+		}
+
+		if strings.HasSuffix(name, "/") {
+			listing, err := lower.ListObjects(ctx, &gcs.ListObjectsRequest{Prefix: name, MaxResults: 1})
+			if err != nil {
+				return false, err
+			}
+			if len(listing.MinObjects) > 0 || len(listing.CollapsedRuns) > 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// Record the deletion of the named object from the lower layers.
+func (b *unionBucket) whiteOut(ctx context.Context, name string) error {
+	_, err := b.upper().CreateObject(ctx, &gcs.CreateObjectRequest{
+		Name:     whiteoutPrefix + name,
+		Contents: strings.NewReader(""),
+	})
+	if err != nil {
+		return fmt.Errorf("recording the deletion of %q: %w", name, err)
+	}
+	return nil
+}
+
+// Preconditions on the generations of objects of lower layers hold as long as
+// the upper layer has no object with the name.
+func upperPreconditions(generation, metaGeneration *int64) (*int64, *int64) {
+	if generation == nil {
+		return nil, metaGeneration
+	}
+	if layer, _ := splitGeneration(*generation); layer > 0 {
+		var absent int64
+		return &absent, nil
+	}
+	return generation, metaGeneration
+}
+
+// Copy the given generation of the named object of a lower layer to the upper
+// layer, under the given name, if its metageneration matches the given
+// precondition, if any.
+func (b *unionBucket) copyUp(
+	ctx context.Context,
+	layer int,
+	srcName string,
+	srcGeneration int64,
+	srcMetaGenerationPrecondition *int64,
+	dstName string,
+	dstGenerationPrecondition *int64) (*gcs.Object, error) {
+	lower := b.layers[layer]
+	m, e, err := lower.StatObject(ctx, &gcs.StatObjectRequest{
+		Name:                           srcName,
+		ForceFetchFromGcs:              true,
+		ReturnExtendedObjectAttributes: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if m.Generation != srcGeneration {
+		return nil, &gcs.NotFoundError{Err: fmt.Errorf("generation %d of %q not found", srcGeneration, srcName)}
+	}
+	if srcMetaGenerationPrecondition != nil && m.MetaGeneration != *srcMetaGenerationPrecondition {
+		return nil, &gcs.PreconditionError{Err: fmt.Errorf("metageneration of %q is %d, not %d", srcName, m.MetaGeneration, *srcMetaGenerationPrecondition)}
+	}
+
+	rc, err := lower.NewReader(ctx, &gcs.ReadObjectRequest{
+		Name:           srcName,
+		Generation:     srcGeneration,
+		ReadCompressed: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	req := &gcs.CreateObjectRequest{
+		Name:                   dstName,
+		Metadata:               m.Metadata,
+		ContentEncoding:        m.ContentEncoding,
+		Contents:               rc,
+		GenerationPrecondition: dstGenerationPrecondition,
+	}
+	if e != nil {
+		req.ContentType = e.ContentType
+		req.ContentLanguage = e.ContentLanguage
+		req.CacheControl = e.CacheControl
+		req.ContentDisposition = e.ContentDisposition
+		req.CustomTime = e.CustomTime
+	}
+	return b.upper().CreateObject(ctx, req)
+}
+
+func (b *unionBucket) Name() string {
+	return b.upper().Name()
+}
+
+func (b *unionBucket) BucketType() gcs.BucketType {
+	return b.upper().BucketType()
+}
+
+func (b *unionBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *unionBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	layer, g, err := b.resolve(ctx, req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	lReq := *req
+	lReq.Generation = g
+	return b.layers[layer].NewReaderWithReadHandle(ctx, &lReq)
+}
+
+func (b *unionBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	layer, g, err := b.resolve(ctx, req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	lReq := *req
+	lReq.Generation = g
+	return b.layers[layer].NewMultiRangeDownloader(ctx, &lReq)
+}
+
+func (b *unionBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	if strings.HasPrefix(req.Name, whiteoutPrefix) {
+		return nil, errWhiteoutName
+	}
+
+	uReq := *req
+	uReq.GenerationPrecondition, uReq.MetaGenerationPrecondition = upperPreconditions(req.GenerationPrecondition, req.MetaGenerationPrecondition)
+	return b.upper().CreateObject(ctx, &uReq)
+}
+
+func (b *unionBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if strings.HasPrefix(req.Name, whiteoutPrefix) {
+		return nil, errWhiteoutName
+	}
+
+	uReq := *req
+	uReq.GenerationPrecondition, uReq.MetaGenerationPrecondition = upperPreconditions(req.GenerationPrecondition, req.MetaGenerationPrecondition)
+	return b.upper().CreateObjectChunkWriter(ctx, &uReq, chunkSize, callBack)
+}
+
+// CreateAppendableObjectWriter appends to objects of the upper layer only:
+// those of lower layers are copied up by rewriting them instead.
+func (b *unionBucket) CreateAppendableObjectWriter(
+	ctx context.Context,
+	req *gcs.AppendObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if strings.HasPrefix(req.Name, whiteoutPrefix) {
+		return nil, errWhiteoutName
+	}
+	layer, _, err := b.splitGeneration(req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+	if layer > 0 {
+		return nil, &gcs.PreconditionError{Err: fmt.Errorf("%q is an object of lower layer %d", req.Name, layer)}
+	}
+	return b.upper().CreateAppendableObjectWriter(ctx, req, chunkSize, callBack)
+}
+
+func (b *unionBucket) FinalizeUpload(ctx context.Context, writer gcs.Writer) (*gcs.MinObject, error) {
+	return b.upper().FinalizeUpload(ctx, writer)
+}
+
+func (b *unionBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	if strings.HasPrefix(req.DstName, whiteoutPrefix) {
+		return nil, errWhiteoutName
+	}
+
+	layer, g, err := b.resolve(ctx, req.SrcName, req.SrcGeneration)
+	if err != nil {
+		return nil, err
+	}
+	dstPrecondition, _ := upperPreconditions(req.DstGenerationPrecondition, nil)
+
+	if layer > 0 {
+		return b.copyUp(ctx, layer, req.SrcName, g, req.SrcMetaGenerationPrecondition, req.DstName, dstPrecondition)
+	}
+
+	uReq := *req
+	uReq.SrcGeneration = g
+	uReq.DstGenerationPrecondition = dstPrecondition
+	return b.upper().CopyObject(ctx, &uReq)
+}
+
+func (b *unionBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	if strings.HasPrefix(req.DstName, whiteoutPrefix) {
+		return nil, errWhiteoutName
+	}
+
+	// Pin the sources, which may be in any layer, before writing the
+	// destination, which may be among them.
+	layers := make([]int, len(req.Sources))
+	sources := make([]gcs.ComposeSource, len(req.Sources))
+	lower := false
+	for i, s := range req.Sources {
+		layer, g, err := b.resolve(ctx, s.Name, s.Generation)
+		if err != nil {
+			return nil, err
+		}
+		layers[i] = layer
+		sources[i] = gcs.ComposeSource{Name: s.Name, Generation: g}
+		lower = lower || layer > 0
+	}
+
+	uReq := *req
+	uReq.Sources = sources
+	uReq.DstGenerationPrecondition, uReq.DstMetaGenerationPrecondition = upperPreconditions(req.DstGenerationPrecondition, req.DstMetaGenerationPrecondition)
+	if !lower {
+		return b.upper().ComposeObjects(ctx, &uReq)
+	}
+
+	// Compose objects across layers by concatenating them into the upper one.
+	pr, pw := io.Pipe()
+	go func() {
+		for i, s := range sources {
+			rc, err := b.layers[layers[i]].NewReader(ctx, &gcs.ReadObjectRequest{Name: s.Name, Generation: s.Generation})
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			_, err = io.Copy(pw, rc)
+			rc.Close()
+			if err != nil {
+				pw.CloseWithError(fmt.Errorf("copying %q: %w", s.Name, err))
+				return
+			}
+		}
+		pw.Close()
+	}()
+	defer pr.Close()
+
+	return b.upper().CreateObject(ctx, &gcs.CreateObjectRequest{
+		Name:                       uReq.DstName,
+		ContentType:                uReq.ContentType,
+		Metadata:                   uReq.Metadata,
+		ContentLanguage:            uReq.ContentLanguage,
+		ContentEncoding:            uReq.ContentEncoding,
+		CacheControl:               uReq.CacheControl,
+		ContentDisposition:         uReq.ContentDisposition,
+		CustomTime:                 uReq.CustomTime,
+		EventBasedHold:             uReq.EventBasedHold,
+		StorageClass:               uReq.StorageClass,
+		Acl:                        uReq.Acl,
+		Contents:                   pr,
+		GenerationPrecondition:     uReq.DstGenerationPrecondition,
+		MetaGenerationPrecondition: uReq.DstMetaGenerationPrecondition,
+	})
+}
+
+func (b *unionBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	layer, m, e, err := b.lookUp(ctx, req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if m, err = tagLayer(m, layer); err != nil {
+		return nil, nil, err
+	}
+	return m, e, nil
+}
+
+// List all the pages of the given listing of the given layer.
+func listAll(
+	ctx context.Context,
+	bucket gcs.Bucket,
+	req *gcs.ListObjectsRequest) (objects []*gcs.MinObject, runs []string, err error) {
+	lReq := *req
+	for {
+		var listing *gcs.Listing
+		if listing, err = bucket.ListObjects(ctx, &lReq); err != nil {
+			return
+		}
+		objects = append(objects, listing.MinObjects...)
+		runs = append(runs, listing.CollapsedRuns...)
+
+		if listing.ContinuationToken == "" {
+			return
+		}
+		lReq.ContinuationToken = listing.ContinuationToken
+	}
+}
+
+// An entry of the listing of a layer, either an object or a collapsed run.
+type unionEntry struct {
+	name   string
+	run    bool
+	object *gcs.MinObject
+}
+
+func (e *unionEntry) generation() int64 {
+	if e.run {
+		return 0
+	}
+	return e.object.Generation
+}
+
+// Compare entries by name, putting objects before the collapsed runs of the
+// same name.
+func compareUnionEntries(xName string, xRun bool, yName string, yRun bool) int {
+	toInt := func(run bool) int {
+		if run {
+			return 1
+		}
+		return 0
+	}
+	return cmp.Or(strings.Compare(xName, yName), cmp.Compare(toInt(xRun), toInt(yRun)))
+}
+
+// unionPosition is the last entry merged by a listing of the union, which
+// the next page starts after.
+type unionPosition struct {
+	Name       string
+	Run        bool
+	Layer      int
+	Generation int64
+}
+
+// Return true if the entry of the layer was merged before the position:
+// either it sorts before it, or it shares its name with an entry of another
+// layer or of an earlier generation.
+func (p *unionPosition) after(e *unionEntry, layer int) bool {
+	if p == nil {
+		return false
+	}
+	if c := compareUnionEntries(e.name, e.run, p.Name, p.Run); c != 0 {
+		return c < 0
+	}
+	return layer != p.Layer || e.generation() <= p.Generation
+}
+
+// unionLayerState is the progress of a listing of the union through one of
+// its layers.
+type unionLayerState struct {
+	// The continuation token of the page of the layer being merged.
+	Token string
+
+	// Whether the layer was listed to its end.
+	Done bool
+}
+
+// unionListingState is carried in the continuation tokens of listings of
+// the union.
+type unionListingState struct {
+	Layers []unionLayerState
+	Last   *unionPosition
+}
+
+func (s *unionListingState) encode() string {
+	buf, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeUnionListingState(token string, layers int) (s unionListingState, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(buf, &s)
+	}
+	if err == nil && len(s.Layers) != layers {
+		err = fmt.Errorf("%d layers recorded, expected %d", len(s.Layers), layers)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid continuation token %q: %w", token, err)
+	}
+	return
+}
+
+// unionLayerLister goes through the entries of a layer in order, page by
+// page, from where a listing of the union left off.
+type unionLayerLister struct {
+	bucket gcs.Bucket
+	layer  int
+	req    gcs.ListObjectsRequest
+	state  *unionLayerState
+	last   *unionPosition
+
+	// The entries of the current page left to merge, and the continuation
+	// token of the next one.
+	entries []unionEntry
+	fetched bool
+	next    string
+}
+
+// Move on to the next page once the current one is merged.
+func (l *unionLayerLister) settle() {
+	if len(l.entries) > 0 || !l.fetched || l.state.Done {
+		return
+	}
+	if l.next == "" {
+		l.state.Done = true
+		return
+	}
+	l.state.Token = l.next
+	l.fetched = false
+}
+
+// Return true if entries of the layer may be left.
+func (l *unionLayerLister) more() bool {
+	l.settle()
+	return !l.state.Done
+}
+
+// Return the next entry of the layer, or nil at its end.
+func (l *unionLayerLister) head(ctx context.Context) (*unionEntry, error) {
+	for l.more() && len(l.entries) == 0 {
+		req := l.req
+		req.ContinuationToken = l.state.Token
+		listing, err := l.bucket.ListObjects(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		l.fetched = true
+		l.next = listing.ContinuationToken
+
+		for _, o := range listing.MinObjects {
+			l.entries = append(l.entries, unionEntry{name: o.Name, object: o})
+		}
+		for _, r := range listing.CollapsedRuns {
+			l.entries = append(l.entries, unionEntry{name: r, run: true})
+		}
+		l.entries = slices.DeleteFunc(l.entries, func(e unionEntry) bool {
+			return l.last.after(&e, l.layer)
+		})
+		slices.SortStableFunc(l.entries, func(x, y unionEntry) int {
+			return cmp.Or(compareUnionEntries(x.name, x.run, y.name, y.run), cmp.Compare(x.generation(), y.generation()))
+		})
+	}
+	if len(l.entries) == 0 {
+		return nil, nil
+	}
+	return &l.entries[0], nil
+}
+
+func (l *unionLayerLister) pop() {
+	l.entries = l.entries[1:]
+}
+
+// Listings of the union merge the sorted listings of its layers, stopping
+// once MaxResults entries were returned. Their continuation tokens record
+// the page of each layer being merged and the last entry merged.
+func (b *unionBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	state := unionListingState{Layers: make([]unionLayerState, len(b.layers))}
+	if req.ContinuationToken != "" {
+		var err error
+		if state, err = decodeUnionListingState(req.ContinuationToken, len(b.layers)); err != nil {
+			return nil, err
+		}
+	}
+
+	// The whiteouts of the names listed, including those of directories.
+	whitedOut := make(map[string]bool)
+	whiteouts, _, err := listAll(ctx, b.upper(), &gcs.ListObjectsRequest{
+		Prefix:                   whiteoutPrefix + req.Prefix,
+		Delimiter:                req.Delimiter,
+		IncludeTrailingDelimiter: true,
+		ProjectionVal:            gcs.NoAcl,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, o := range whiteouts {
+		whitedOut[strings.TrimPrefix(o.Name, whiteoutPrefix)] = true
+	}
+
+	listers := make([]*unionLayerLister, len(b.layers))
+	for layer, bucket := range b.layers {
+		listers[layer] = &unionLayerLister{
+			bucket: bucket,
+			layer:  layer,
+			req:    *req,
+			state:  &state.Layers[layer],
+			last:   state.Last,
+		}
+	}
+
+	listing := &gcs.Listing{}
+	results := 0
+	for req.MaxResults == 0 || results < req.MaxResults {
+		// The first entry of all layers, from the layer with the highest
+		// precedence among those listing it, which hides it in the others.
+		var first unionEntry
+		winner := -1
+		for layer, l := range listers {
+			e, err := l.head(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if e != nil && (winner < 0 || compareUnionEntries(e.name, e.run, first.name, first.run) < 0) {
+				first = *e
+				winner = layer
+			}
+		}
+		if winner < 0 {
+			break
+		}
+		same := func(e *unionEntry) bool {
+			return e != nil && compareUnionEntries(e.name, e.run, first.name, first.run) == 0
+		}
+
+		hidden := strings.HasPrefix(first.name, whiteoutPrefix) || (winner > 0 && whitedOut[first.name])
+		for layer, l := range listers {
+			for {
+				e, err := l.head(ctx)
+				if err != nil {
+					return nil, err
+				}
+				if !same(e) {
+					break
+				}
+				if layer == winner && !hidden {
+					// Stop within the generations of an object once the page
+					// is full, for the next page to return the others.
+					if req.MaxResults != 0 && results == req.MaxResults {
+						break
+					}
+					if e.run {
+						listing.CollapsedRuns = append(listing.CollapsedRuns, e.name)
+					} else {
+						m, err := tagLayer(e.object, layer)
+						if err != nil {
+							return nil, err
+						}
+						listing.MinObjects = append(listing.MinObjects, m)
+					}
+					results++
+				}
+				if layer == winner {
+					state.Last = &unionPosition{Name: e.name, Run: e.run, Layer: layer, Generation: e.generation()}
+				}
+				l.pop()
+			}
+		}
+	}
+
+	for _, l := range listers {
+		if l.more() {
+			listing.ContinuationToken = state.encode()
+			break
+		}
+	}
+	return listing, nil
+}
+
+func (b *unionBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	layer, g, err := b.resolve(ctx, req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	uReq := *req
+	uReq.Generation = g
+	if layer > 0 {
+		var absent int64
+		o, err := b.copyUp(ctx, layer, req.Name, g, req.MetaGenerationPrecondition, req.Name, &absent)
+		if err != nil {
+			return nil, err
+		}
+		uReq.Generation = o.Generation
+		uReq.MetaGenerationPrecondition = nil
+	}
+	return b.upper().UpdateObject(ctx, &uReq)
+}
+
+func (b *unionBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	if strings.HasPrefix(req.Name, whiteoutPrefix) {
+		return notFoundInLayers(req.Name)
+	}
+
+	layer, g, err := b.splitGeneration(req.Name, req.Generation)
+	if err != nil {
+		return err
+	}
+	inUpper := false
+	if layer == 0 {
+		uReq := *req
+		uReq.Generation = g
+		err := b.upper().DeleteObject(ctx, &uReq)
+		if err != nil && !isNotFound(err) {
+			return err
+		}
+		// Generations of the upper layer must be found there.
+		if err != nil && g != 0 {
+			return err
+		}
+		inUpper = err == nil
+	}
+
+	inLower, err := b.inLowerLayers(ctx, req.Name)
+	if err != nil {
+		return err
+	}
+	if !inLower {
+		if !inUpper {
+			return notFoundInLayers(req.Name)
+		}
+		return nil
+	}
+	return b.whiteOut(ctx, req.Name)
+}
+
+func (b *unionBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	if strings.HasPrefix(req.DstName, whiteoutPrefix) {
+		return nil, errWhiteoutName
+	}
+
+	layer, g, err := b.resolve(ctx, req.SrcName, req.SrcGeneration)
+	if err != nil {
+		return nil, err
+	}
+	if layer == 0 {
+		uReq := *req
+		uReq.SrcGeneration = g
+		return b.upper().MoveObject(ctx, &uReq)
+	}
+
+	o, err := b.copyUp(ctx, layer, req.SrcName, g, req.SrcMetaGenerationPrecondition, req.DstName, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = b.whiteOut(ctx, req.SrcName); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
+
+// Return true if a hierarchical lower layer has the named folder, or any
+// lower layer objects in it.
+func (b *unionBucket) folderInLowerLayers(ctx context.Context, folderName string) (bool, error) {
+	for _, lower := range b.layers[1:] {
+		if !lower.BucketType().Hierarchical {
+			continue
+		}
+		_, err := lower.GetFolder(ctx, folderName)
+		if err == nil {
+			return true, nil
+		}
+		if !isNotFound(err) {
+			return false, err
+		}
+	}
+	return b.inLowerLayers(ctx, folderName)
+}
+
+func (b *unionBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	f, err := b.upper().GetFolder(ctx, folderName)
+	if !isNotFound(err) {
+		return f, err
+	}
+
+	whitedOut, err := b.whitedOut(ctx, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if !whitedOut {
+		for _, lower := range b.layers[1:] {
+			if !lower.BucketType().Hierarchical {
+				continue
+			}
+			f, err = lower.GetFolder(ctx, folderName)
+			if !isNotFound(err) {
+				return f, err
+			}
+		}
+	}
+	return nil, &gcs.NotFoundError{Err: fmt.Errorf("folder %q not found in any layer", folderName)}
+}
+
+func (b *unionBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	if strings.HasPrefix(folderName, whiteoutPrefix) {
+		return nil, errWhiteoutName
+	}
+	return b.upper().CreateFolder(ctx, folderName)
+}
+
+func (b *unionBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	err := b.upper().DeleteFolder(ctx, folderName)
+	if err != nil && !isNotFound(err) {
+		return err
+	}
+	inUpper := err == nil
+
+	inLower, err := b.folderInLowerLayers(ctx, folderName)
+	if err != nil {
+		return err
+	}
+	if !inLower {
+		if !inUpper {
+			return &gcs.NotFoundError{Err: fmt.Errorf("folder %q not found in any layer", folderName)}
+		}
+		return nil
+	}
+	return b.whiteOut(ctx, folderName)
+}
+
+func (b *unionBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (*gcs.Folder, error) {
+	inLower, err := b.folderInLowerLayers(ctx, folderName)
+	if err != nil {
+		return nil, err
+	}
+	if inLower {
+		return nil, fmt.Errorf("folder %q has contents in lower layers: %w", folderName, syscall.EXDEV)
+	}
+	return b.upper().RenameFolder(ctx, folderName, destinationFolderId)
+}
@@ -0,0 +1,369 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/local"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type UnionBucketTest struct {
+	suite.Suite
+	ctx    context.Context
+	upper  gcs.Bucket
+	middle gcs.Bucket
+	bottom gcs.Bucket
+	bucket gcs.Bucket
+}
+
+func TestUnionBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(UnionBucketTest))
+}
+
+func (t *UnionBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.upper = fake.NewFakeBucket(timeutil.RealClock(), "upper", gcs.BucketType{})
+	t.middle = fake.NewFakeBucket(timeutil.RealClock(), "middle", gcs.BucketType{})
+	t.bottom = fake.NewFakeBucket(timeutil.RealClock(), "bottom", gcs.BucketType{})
+
+	// The middle layer is limited to a directory of its bucket.
+	middle, err := NewPrefixBucket("v2/", t.middle)
+	require.NoError(t.T(), err)
+	t.bucket, err = NewUnionBucket(t.upper, []gcs.Bucket{middle, t.bottom})
+	require.NoError(t.T(), err)
+
+	t.create(t.upper, "shared", "upper")
+	t.create(t.upper, "dir/upper", "upper")
+	t.create(t.middle, "v2/shared", "middle")
+	t.create(t.middle, "v2/lower", "middle")
+	t.create(t.middle, "v1/unused", "middle")
+	t.create(t.bottom, "lower", "bottom")
+	t.create(t.bottom, "bottom", "bottom")
+	t.create(t.bottom, "dir/bottom", "bottom")
+}
+
+func (t *UnionBucketTest) create(bucket gcs.Bucket, name string, contents string) {
+	_, err := storageutil.CreateObject(t.ctx, bucket, name, []byte(contents))
+	require.NoError(t.T(), err)
+}
+
+func (t *UnionBucketTest) read(name string) string {
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, name)
+	require.NoError(t.T(), err)
+	return string(contents)
+}
+
+func (t *UnionBucketTest) stat(name string) (*gcs.MinObject, error) {
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+	return m, err
+}
+
+func (t *UnionBucketTest) list(prefix string) (names []string) {
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Prefix: prefix, Delimiter: "/"})
+	require.NoError(t.T(), err)
+	for _, o := range listing.MinObjects {
+		names = append(names, o.Name)
+	}
+	return append(names, listing.CollapsedRuns...)
+}
+
+func (t *UnionBucketTest) assertNotFound(name string) {
+	_, err := t.stat(name)
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr), "%q: %v", name, err)
+}
+
+func (t *UnionBucketTest) TestUpperLayersTakePrecedence() {
+	assert.Equal(t.T(), "upper", t.read("shared"))
+	assert.Equal(t.T(), "middle", t.read("lower"))
+	assert.Equal(t.T(), "bottom", t.read("bottom"))
+	t.assertNotFound("v1/unused")
+
+	m, err := t.stat("shared")
+	require.NoError(t.T(), err)
+	assert.Zero(t.T(), m.Generation&layerGenerationMask)
+	m, err = t.stat("bottom")
+	require.NoError(t.T(), err)
+	layer, _ := splitGeneration(m.Generation)
+	assert.Equal(t.T(), 2, layer)
+}
+
+func (t *UnionBucketTest) TestListingsMergeLayers() {
+	assert.Equal(t.T(), []string{"bottom", "lower", "shared", "dir/"}, t.list(""))
+	assert.Equal(t.T(), []string{"dir/bottom", "dir/upper"}, t.list("dir/"))
+
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Prefix: "shared"})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), listing.MinObjects, 1)
+	assert.Zero(t.T(), listing.MinObjects[0].Generation&layerGenerationMask)
+}
+
+// List all the pages of the listing, of the given size.
+func (t *UnionBucketTest) listPages(req gcs.ListObjectsRequest, pageSize int) (objects []string, runs []string) {
+	req.MaxResults = pageSize
+	for {
+		listing, err := t.bucket.ListObjects(t.ctx, &req)
+		require.NoError(t.T(), err)
+		if pageSize != 0 {
+			assert.LessOrEqual(t.T(), len(listing.MinObjects)+len(listing.CollapsedRuns), pageSize)
+		}
+		for _, o := range listing.MinObjects {
+			objects = append(objects, o.Name)
+		}
+		runs = append(runs, listing.CollapsedRuns...)
+
+		if listing.ContinuationToken == "" {
+			return
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+}
+
+func (t *UnionBucketTest) TestListingsArePaginated() {
+	t.create(t.upper, "a", "upper")
+	t.create(t.middle, "v2/b", "middle")
+	t.create(t.bottom, "c", "bottom")
+	t.create(t.bottom, "other/c", "bottom")
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "lower"}))
+
+	for _, req := range []gcs.ListObjectsRequest{{}, {Delimiter: "/"}, {Prefix: "dir/"}} {
+		objects, runs := t.listPages(req, 0)
+		for pageSize := 1; pageSize <= len(objects)+len(runs)+1; pageSize++ {
+			pagedObjects, pagedRuns := t.listPages(req, pageSize)
+
+			assert.Equal(t.T(), objects, pagedObjects, "%+v, pages of %d", req, pageSize)
+			assert.Equal(t.T(), runs, pagedRuns, "%+v, pages of %d", req, pageSize)
+		}
+	}
+}
+
+func (t *UnionBucketTest) TestInvalidContinuationTokensAreRejected() {
+	_, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{ContinuationToken: "taco"})
+
+	assert.ErrorContains(t.T(), err, "invalid continuation token")
+}
+
+func (t *UnionBucketTest) TestWritesGoToUpperLayer() {
+	m, err := t.stat("lower")
+	require.NoError(t.T(), err)
+
+	// Preconditions on generations of lower layers hold.
+	_, err = t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "lower",
+		Contents:               strings.NewReader("upper"),
+		GenerationPrecondition: &m.Generation,
+	})
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), "upper", t.read("lower"))
+	contents, err := storageutil.ReadObject(t.ctx, t.middle, "v2/lower")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "middle", string(contents))
+}
+
+func (t *UnionBucketTest) TestDeletionsOfLowerObjectsAreWhitedOut() {
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "shared"}))
+
+	t.assertNotFound("shared")
+	assert.Equal(t.T(), []string{"bottom", "lower", "dir/"}, t.list(""))
+	_, err := storageutil.ReadObject(t.ctx, t.upper, whiteoutPrefix+"shared")
+	assert.NoError(t.T(), err)
+
+	// Recreated objects are visible again, until deleted again.
+	t.create(t.bucket, "shared", "recreated")
+	assert.Equal(t.T(), "recreated", t.read("shared"))
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "shared"}))
+	t.assertNotFound("shared")
+}
+
+func (t *UnionBucketTest) TestDeletedDirectoriesDisappear() {
+	for _, name := range []string{"dir/bottom", "dir/upper", "dir/"} {
+		require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: name}))
+	}
+
+	assert.Equal(t.T(), []string{"bottom", "lower", "shared"}, t.list(""))
+	assert.Empty(t.T(), t.list("dir/"))
+}
+
+func (t *UnionBucketTest) TestDeletionsOfUpperObjectsArentWhitedOut() {
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "dir/upper"}))
+
+	t.assertNotFound("dir/upper")
+	_, _, err := t.upper.StatObject(t.ctx, &gcs.StatObjectRequest{Name: whiteoutPrefix + "dir/upper"})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
+
+func (t *UnionBucketTest) TestUpdatesCopyUp() {
+	contentType := "text/plain"
+	o, err := t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{Name: "bottom", ContentType: &contentType})
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), contentType, o.ContentType)
+	contents, err := storageutil.ReadObject(t.ctx, t.upper, "bottom")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "bottom", string(contents))
+}
+
+func (t *UnionBucketTest) TestCopiesAndMovesFromLowerLayers() {
+	_, err := t.bucket.CopyObject(t.ctx, &gcs.CopyObjectRequest{SrcName: "lower", DstName: "copy"})
+	require.NoError(t.T(), err)
+	_, err = t.bucket.MoveObject(t.ctx, &gcs.MoveObjectRequest{SrcName: "bottom", DstName: "moved"})
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), "middle", t.read("copy"))
+	assert.Equal(t.T(), "middle", t.read("lower"))
+	assert.Equal(t.T(), "bottom", t.read("moved"))
+	t.assertNotFound("bottom")
+}
+
+func (t *UnionBucketTest) assertPreconditionError(err error) {
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr), "%v", err)
+}
+
+func (t *UnionBucketTest) TestCopiesFromLowerLayersCheckMetaGeneration() {
+	m, err := t.stat("bottom")
+	require.NoError(t.T(), err)
+	stale := m.MetaGeneration + 1
+
+	_, err = t.bucket.CopyObject(t.ctx, &gcs.CopyObjectRequest{SrcName: "bottom", DstName: "copy", SrcMetaGenerationPrecondition: &stale})
+	t.assertPreconditionError(err)
+	t.assertNotFound("copy")
+
+	_, err = t.bucket.CopyObject(t.ctx, &gcs.CopyObjectRequest{SrcName: "bottom", DstName: "copy", SrcMetaGenerationPrecondition: &m.MetaGeneration})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "bottom", t.read("copy"))
+}
+
+func (t *UnionBucketTest) TestUpdatesOfLowerObjectsCheckMetaGeneration() {
+	m, err := t.stat("bottom")
+	require.NoError(t.T(), err)
+	stale := m.MetaGeneration + 1
+	contentType := "text/plain"
+
+	_, err = t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{Name: "bottom", ContentType: &contentType, MetaGenerationPrecondition: &stale})
+
+	t.assertPreconditionError(err)
+	_, _, err = t.upper.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "bottom"})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr), "%v", err)
+}
+
+func (t *UnionBucketTest) TestMovesFromLowerLayersCheckMetaGeneration() {
+	m, err := t.stat("bottom")
+	require.NoError(t.T(), err)
+	stale := m.MetaGeneration + 1
+
+	_, err = t.bucket.MoveObject(t.ctx, &gcs.MoveObjectRequest{SrcName: "bottom", DstName: "moved", SrcMetaGenerationPrecondition: &stale})
+
+	t.assertPreconditionError(err)
+	t.assertNotFound("moved")
+	assert.Equal(t.T(), "bottom", t.read("bottom"))
+}
+
+func (t *UnionBucketTest) TestComposeAcrossLayers() {
+	_, err := t.bucket.ComposeObjects(t.ctx, &gcs.ComposeObjectsRequest{
+		DstName: "lower",
+		Sources: []gcs.ComposeSource{{Name: "lower"}, {Name: "shared"}},
+	})
+	require.NoError(t.T(), err)
+
+	assert.Equal(t.T(), "middleupper", t.read("lower"))
+}
+
+func (t *UnionBucketTest) TestWhiteoutsAreHidden() {
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "lower"}))
+
+	assert.NotContains(t.T(), t.list(""), whiteoutPrefix)
+	t.assertNotFound(whiteoutPrefix + "lower")
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, whiteoutPrefix+"foo", nil)
+	assert.ErrorIs(t.T(), err, syscall.EPERM)
+}
+
+func (t *UnionBucketTest) TestGenerationsOfNoLayerAreNotFound() {
+	_, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "shared", Generation: 40<<layerGenerationShift | 1})
+
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr), "%v", err)
+}
+
+// highGenerationsBucket reports generations using the bits of layer tags.
+type highGenerationsBucket struct {
+	gcs.Bucket
+}
+
+func (b *highGenerationsBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	m, e, err := b.Bucket.StatObject(ctx, req)
+	if m != nil {
+		m.Generation |= 1 << layerGenerationShift
+	}
+	return m, e, err
+}
+
+func (t *UnionBucketTest) TestGenerationsUsingTagBitsAreRejected() {
+	bucket, err := NewUnionBucket(&highGenerationsBucket{Bucket: t.upper}, []gcs.Bucket{t.bottom})
+	require.NoError(t.T(), err)
+
+	_, _, err = bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "shared"})
+
+	assert.ErrorContains(t.T(), err, "reserved for tagging layers")
+}
+
+func TestUnionOfLocalBuckets(t *testing.T) {
+	ctx := context.Background()
+	upper, err := local.NewBucket(t.TempDir(), "upper")
+	require.NoError(t, err)
+	lower, err := local.NewBucket(t.TempDir(), "lower")
+	require.NoError(t, err)
+	_, err = storageutil.CreateObject(ctx, upper, "foo", []byte("taco"))
+	require.NoError(t, err)
+	_, err = storageutil.CreateObject(ctx, lower, "bar", []byte("burrito"))
+	require.NoError(t, err)
+	bucket, err := NewUnionBucket(upper, []gcs.Bucket{lower})
+	require.NoError(t, err)
+
+	for name, contents := range map[string]string{"foo": "taco", "bar": "burrito"} {
+		m, _, err := bucket.StatObject(ctx, &gcs.StatObjectRequest{Name: name})
+		require.NoError(t, err)
+		rc, err := bucket.NewReader(ctx, &gcs.ReadObjectRequest{Name: name, Generation: m.Generation})
+		require.NoError(t, err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+
+		require.NoError(t, err)
+		assert.Equal(t, contents, string(data))
+	}
+	listing, err := bucket.ListObjects(ctx, &gcs.ListObjectsRequest{})
+	require.NoError(t, err)
+	require.Len(t, listing.MinObjects, 2)
+	assert.Equal(t, "bar", listing.MinObjects[0].Name)
+	assert.Equal(t, "foo", listing.MinObjects[1].Name)
+}
@@ -0,0 +1,477 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// NewVersionsBucket creates a read-only view on the wrapped bucket that
+// exposes every generation of every object, including the noncurrent ones in
+// buckets with object versioning enabled, as an object named
+// "<root><object name>/<generation>". Reading such an object reads that
+// generation of the wrapped object. root is either empty or ends with a slash.
+//
+// Every directory of the view, including "<root><object name>/", is exposed as
+// an explicit placeholder object, so that the view can be browsed without
+// implicit directories. Names outside of the root don't exist in the view.
+func NewVersionsBucket(wrapped gcs.Bucket, root string) gcs.Bucket {
+	return &versionsBucket{wrapped: wrapped, root: root}
+}
+
+var errVersionsReadOnly = fmt.Errorf("object versions are read-only: %w", syscall.EROFS)
+
+type versionsBucket struct {
+	wrapped gcs.Bucket
+	root    string
+}
+
+// Return the name in the view of the given generation of a wrapped object.
+func (b *versionsBucket) versionObject(o *gcs.MinObject) *gcs.MinObject {
+	v := *o
+	v.Name = b.root + o.Name + "/" + strconv.FormatInt(o.Generation, 10)
+	return &v
+}
+
+// Split a name in the view, relative to its root, into the name and
+// generation of the wrapped object, returning false if it isn't the name of a
+// generation.
+func parseVersionName(name string) (objectName string, generation int64, ok bool) {
+	i := strings.LastIndex(name, "/")
+	if i <= 0 {
+		return
+	}
+
+	generation, err := strconv.ParseInt(name[i+1:], 10, 64)
+	if err != nil || strconv.FormatInt(generation, 10) != name[i+1:] {
+		return "", 0, false
+	}
+
+	return name[:i], generation, true
+}
+
+// Return the name and generation of the wrapped object the given name in the
+// view reads.
+func (b *versionsBucket) wrappedVersion(name string) (objectName string, generation int64, ok bool) {
+	if name, ok = strings.CutPrefix(name, b.root); !ok {
+		return
+	}
+	return parseVersionName(name)
+}
+
+// List the generations of the named wrapped object. They come first in the
+// listing of the names beginning with the object name, so the listing stops
+// at the first other name.
+func (b *versionsBucket) listGenerations(
+	ctx context.Context,
+	objectName string) (generations []*gcs.MinObject, err error) {
+	// The placeholder objects of directories have no generations in the view.
+	if objectName == "" || strings.HasSuffix(objectName, "/") {
+		return
+	}
+
+	req := &gcs.ListObjectsRequest{
+		Prefix:        objectName,
+		Delimiter:     "/",
+		ProjectionVal: gcs.NoAcl,
+		Versions:      true,
+	}
+	for {
+		var listing *gcs.Listing
+		if listing, err = b.wrapped.ListObjects(ctx, req); err != nil {
+			return nil, err
+		}
+
+		for _, o := range listing.MinObjects {
+			if o.Name != objectName {
+				return
+			}
+			generations = append(generations, o)
+		}
+
+		if len(listing.CollapsedRuns) > 0 || listing.ContinuationToken == "" {
+			return
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+}
+
+// Return the placeholder object for the directory of the view with the given
+// name relative to its root, or nil if it doesn't exist. The directory exists
+// if the wrapped object named like it has generations, or if any wrapped
+// object lies under it.
+func (b *versionsBucket) statDir(
+	ctx context.Context,
+	dirName string) (m *gcs.MinObject, err error) {
+	generations, err := b.listGenerations(ctx, strings.TrimSuffix(dirName, "/"))
+	if err != nil {
+		return
+	}
+
+	if len(generations) == 0 {
+		var listing *gcs.Listing
+		listing, err = b.wrapped.ListObjects(ctx, &gcs.ListObjectsRequest{
+			Prefix:        dirName,
+			MaxResults:    1,
+			ProjectionVal: gcs.NoAcl,
+			Versions:      true,
+		})
+		if err != nil || len(listing.MinObjects) == 0 {
+			return
+		}
+	}
+
+	m = &gcs.MinObject{Name: b.root + dirName}
+	for _, g := range generations {
+		if g.Updated.After(m.Updated) {
+			m.Updated = g.Updated
+		}
+	}
+	return
+}
+
+// versionsListingState is carried in the continuation tokens of listings of
+// the view.
+type versionsListingState struct {
+	// The continuation token of the wrapped listing.
+	Token string
+
+	// The directories already returned which the rest of the wrapped listing
+	// may return again: both a wrapped object and the wrapped directory named
+	// like it are the same directory of the view, and other names may sort
+	// between them.
+	Runs []string
+}
+
+func (s *versionsListingState) encode() string {
+	buf, _ := json.Marshal(s)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
+
+func decodeVersionsListingState(token string) (s versionsListingState, err error) {
+	buf, err := base64.RawURLEncoding.DecodeString(token)
+	if err == nil {
+		err = json.Unmarshal(buf, &s)
+	}
+	if err != nil {
+		err = fmt.Errorf("invalid continuation token %q: %w", token, err)
+	}
+	return
+}
+
+func (b *versionsBucket) Name() string {
+	return b.wrapped.Name()
+}
+
+func (b *versionsBucket) BucketType() gcs.BucketType {
+	// The view is made of objects only, even in hierarchical buckets.
+	t := b.wrapped.BucketType()
+	t.Hierarchical = false
+	return t
+}
+
+func (b *versionsBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rc io.ReadCloser, err error) {
+	rc, err = b.NewReaderWithReadHandle(ctx, req)
+	return
+}
+
+func (b *versionsBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (rd gcs.StorageReader, err error) {
+	objectName, generation, ok := b.wrappedVersion(req.Name)
+	if !ok {
+		err = &gcs.NotFoundError{Err: fmt.Errorf("object %q not found", req.Name)}
+		return
+	}
+
+	// Modify the request and call through.
+	mReq := new(gcs.ReadObjectRequest)
+	*mReq = *req
+	mReq.Name = objectName
+	mReq.Generation = generation
+
+	rd, err = b.wrapped.NewReaderWithReadHandle(ctx, mReq)
+	return
+}
+
+func (b *versionsBucket) NewMultiRangeDownloader(
+	ctx context.Context, req *gcs.MultiRangeDownloaderRequest) (mrd gcs.MultiRangeDownloader, err error) {
+	objectName, generation, ok := b.wrappedVersion(req.Name)
+	if !ok {
+		err = &gcs.NotFoundError{Err: fmt.Errorf("object %q not found", req.Name)}
+		return
+	}
+
+	// Modify the request and call through.
+	mReq := new(gcs.MultiRangeDownloaderRequest)
+	*mReq = *req
+	mReq.Name = objectName
+	mReq.Generation = generation
+
+	mrd, err = b.wrapped.NewMultiRangeDownloader(ctx, mReq)
+	return
+}
+
+func (b *versionsBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	name, ok := strings.CutPrefix(req.Name, b.root)
+	switch {
+	case !ok:
+	case name == "":
+		m = &gcs.MinObject{Name: req.Name}
+	case strings.HasSuffix(name, "/"):
+		if m, err = b.statDir(ctx, name); err != nil {
+			return
+		}
+	default:
+		objectName, generation, isVersion := parseVersionName(name)
+		if !isVersion {
+			break
+		}
+
+		var generations []*gcs.MinObject
+		if generations, err = b.listGenerations(ctx, objectName); err != nil {
+			return
+		}
+		for _, g := range generations {
+			if g.Generation == generation {
+				m = b.versionObject(g)
+				break
+			}
+		}
+	}
+
+	if m == nil {
+		err = &gcs.NotFoundError{Err: fmt.Errorf("object %q not found", req.Name)}
+		return
+	}
+
+	if req.ReturnExtendedObjectAttributes {
+		e = &gcs.ExtendedObjectAttributes{}
+	}
+	return
+}
+
+// ListObjects lists one page of the wrapped listing of the names under the
+// prefix, with all their generations, at a time. The generations of the
+// wrapped object the prefix belongs to are returned with the first page.
+// Prefixes outside of the root list nothing.
+func (b *versionsBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	listing = new(gcs.Listing)
+	prefix, ok := strings.CutPrefix(req.Prefix, b.root)
+	if !ok {
+		return
+	}
+
+	var state versionsListingState
+	var versions []*gcs.MinObject
+	if req.ContinuationToken != "" {
+		if state, err = decodeVersionsListingState(req.ContinuationToken); err != nil {
+			return
+		}
+	} else if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		var generations []*gcs.MinObject
+		if generations, err = b.listGenerations(ctx, prefix[:i]); err != nil {
+			return
+		}
+		for _, g := range generations {
+			if v := b.versionObject(g); strings.HasPrefix(v.Name, req.Prefix) {
+				versions = append(versions, v)
+			}
+		}
+	}
+
+	wrappedListing, err := b.wrapped.ListObjects(ctx, &gcs.ListObjectsRequest{
+		Prefix:            prefix,
+		Delimiter:         req.Delimiter,
+		ContinuationToken: state.Token,
+		MaxResults:        req.MaxResults,
+		ProjectionVal:     req.ProjectionVal,
+		Versions:          true,
+	})
+	if err != nil {
+		return
+	}
+
+	for _, o := range wrappedListing.MinObjects {
+		// Skip the placeholder objects of directories, whose directories are
+		// already part of the view.
+		if !strings.HasSuffix(o.Name, "/") {
+			versions = append(versions, b.versionObject(o))
+		}
+	}
+
+	if req.Delimiter == "" {
+		listing.MinObjects = versions
+	} else {
+		b.collapseRuns(req, versions, wrappedListing, &state, listing)
+	}
+
+	sort.Slice(listing.MinObjects, func(i, j int) bool {
+		return listing.MinObjects[i].Name < listing.MinObjects[j].Name
+	})
+	sort.Strings(listing.CollapsedRuns)
+
+	if wrappedListing.ContinuationToken != "" {
+		state.Token = wrappedListing.ContinuationToken
+		listing.ContinuationToken = state.encode()
+	}
+	return
+}
+
+// Fill in the listing for a request with a delimiter, collapsing the
+// generations under the same directory, which is returned as a placeholder
+// object as well if requested. The wrapped runs are directories of the view as
+// they are. Directories already returned by the previous pages are skipped,
+// and the ones the next pages may return again are recorded in state.
+func (b *versionsBucket) collapseRuns(
+	req *gcs.ListObjectsRequest,
+	versions []*gcs.MinObject,
+	wrappedListing *gcs.Listing,
+	state *versionsListingState,
+	listing *gcs.Listing) {
+	returned := make(map[string]bool)
+	for _, run := range state.Runs {
+		returned[run] = true
+	}
+
+	placeholders := make(map[string]*gcs.MinObject)
+	addRun := func(run string) *gcs.MinObject {
+		if returned[run] {
+			return placeholders[run]
+		}
+		returned[run] = true
+
+		listing.CollapsedRuns = append(listing.CollapsedRuns, run)
+		if !req.IncludeTrailingDelimiter {
+			return nil
+		}
+		placeholders[run] = &gcs.MinObject{Name: run}
+		listing.MinObjects = append(listing.MinObjects, placeholders[run])
+		return placeholders[run]
+	}
+
+	for _, v := range versions {
+		rest := v.Name[len(req.Prefix):]
+		delimiterIndex := strings.Index(rest, req.Delimiter)
+		if delimiterIndex < 0 {
+			listing.MinObjects = append(listing.MinObjects, v)
+			continue
+		}
+
+		run := v.Name[:len(req.Prefix)+delimiterIndex+len(req.Delimiter)]
+		if p := addRun(run); p != nil && v.Updated.After(p.Updated) {
+			p.Updated = v.Updated
+		}
+	}
+	for _, run := range wrappedListing.CollapsedRuns {
+		addRun(b.root + run)
+	}
+
+	// The last wrapped name of the page, in the view.
+	var last string
+	if n := len(wrappedListing.MinObjects); n > 0 {
+		last = wrappedListing.MinObjects[n-1].Name
+	}
+	if n := len(wrappedListing.CollapsedRuns); n > 0 {
+		last = max(last, wrappedListing.CollapsedRuns[n-1])
+	}
+	last = b.root + last
+
+	state.Runs = nil
+	for run := range returned {
+		if run > last {
+			state.Runs = append(state.Runs, run)
+		}
+	}
+	sort.Strings(state.Runs)
+}
+
+func (b *versionsBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	return errVersionsReadOnly
+}
+
+func (b *versionsBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	return errVersionsReadOnly
+}
+
+func (b *versionsBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return nil, &gcs.NotFoundError{Err: fmt.Errorf("folder %q not found", folderName)}
+}
+
+func (b *versionsBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return nil, errVersionsReadOnly
+}
+
+func (b *versionsBucket) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (*gcs.Folder, error) {
+	return nil, errVersionsReadOnly
+}
@@ -0,0 +1,258 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcsx
+
+import (
+	"context"
+	"strconv"
+	"syscall"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+const versionsRoot = ".versions/"
+
+type VersionsBucketTest struct {
+	suite.Suite
+	ctx     context.Context
+	wrapped gcs.Bucket
+	bucket  gcs.Bucket
+
+	// The generations of "dir/foo", oldest first.
+	generations []string
+}
+
+func TestVersionsBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(VersionsBucketTest))
+}
+
+func (t *VersionsBucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.wrapped = fake.NewFakeBucketWithVersioning(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	t.bucket = NewVersionsBucket(t.wrapped, versionsRoot)
+
+	t.generations = nil
+	for _, contents := range []string{"taco", "burrito"} {
+		o, err := storageutil.CreateObject(t.ctx, t.wrapped, "dir/foo", []byte(contents))
+		require.NoError(t.T(), err)
+		t.generations = append(t.generations, strconv.FormatInt(o.Generation, 10))
+	}
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped, "bar", []byte("enchilada"))
+	require.NoError(t.T(), err)
+}
+
+func (t *VersionsBucketTest) TestParseVersionName() {
+	testCases := []struct {
+		name           string
+		wantObjectName string
+		wantGeneration int64
+		wantOk         bool
+	}{
+		{"dir/foo/12", "dir/foo", 12, true},
+		{"foo/1", "foo", 1, true},
+		{"foo", "", 0, false},
+		{"/12", "", 0, false},
+		{"foo/", "", 0, false},
+		{"foo/bar", "", 0, false},
+		{"foo/+12", "", 0, false},
+		{"foo/012", "", 0, false},
+	}
+
+	for _, tc := range testCases {
+		objectName, generation, ok := parseVersionName(tc.name)
+
+		assert.Equal(t.T(), tc.wantOk, ok, tc.name)
+		assert.Equal(t.T(), tc.wantObjectName, objectName, tc.name)
+		assert.Equal(t.T(), tc.wantGeneration, generation, tc.name)
+	}
+}
+
+func (t *VersionsBucketTest) TestListObjectsWithDelimiter() {
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{
+		Prefix:                   versionsRoot + "dir/foo/",
+		Delimiter:                "/",
+		IncludeTrailingDelimiter: true,
+	})
+
+	require.NoError(t.T(), err)
+	require.Len(t.T(), listing.MinObjects, 2)
+	assert.Equal(t.T(), versionsRoot+"dir/foo/"+t.generations[0], listing.MinObjects[0].Name)
+	assert.Equal(t.T(), versionsRoot+"dir/foo/"+t.generations[1], listing.MinObjects[1].Name)
+	assert.Empty(t.T(), listing.CollapsedRuns)
+	assert.Empty(t.T(), listing.ContinuationToken)
+}
+
+func (t *VersionsBucketTest) TestListObjectsCollapsesDirectories() {
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{
+		Prefix:                   versionsRoot,
+		Delimiter:                "/",
+		IncludeTrailingDelimiter: true,
+	})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), []string{versionsRoot + "bar/", versionsRoot + "dir/"}, listing.CollapsedRuns)
+	require.Len(t.T(), listing.MinObjects, 2)
+	assert.Equal(t.T(), versionsRoot+"bar/", listing.MinObjects[0].Name)
+	assert.Equal(t.T(), versionsRoot+"dir/", listing.MinObjects[1].Name)
+}
+
+func (t *VersionsBucketTest) TestStatObjectReturnsGeneration() {
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: versionsRoot + "dir/foo/" + t.generations[0]})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), versionsRoot+"dir/foo/"+t.generations[0], m.Name)
+	assert.Equal(t.T(), t.generations[0], strconv.FormatInt(m.Generation, 10))
+	assert.EqualValues(t.T(), len("taco"), m.Size)
+}
+
+func (t *VersionsBucketTest) TestStatObjectReturnsPlaceholderForDirectories() {
+	for _, name := range []string{versionsRoot, versionsRoot + "dir/", versionsRoot + "dir/foo/", versionsRoot + "bar/"} {
+		m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+
+		require.NoError(t.T(), err, name)
+		assert.Equal(t.T(), name, m.Name)
+	}
+}
+
+func (t *VersionsBucketTest) TestStatObjectReturnsNotFound() {
+	for _, name := range []string{versionsRoot + "dir/foo", versionsRoot + "dir/foo/1234", versionsRoot + "baz/", versionsRoot + "bar/notageneration", "dir/"} {
+		_, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+
+		var notFoundErr *gcs.NotFoundError
+		assert.ErrorAs(t.T(), err, &notFoundErr, name)
+	}
+}
+
+func (t *VersionsBucketTest) TestNewReaderReadsGeneration() {
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, versionsRoot+"dir/foo/"+t.generations[0])
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+}
+
+func (t *VersionsBucketTest) TestMutationsFailAsReadOnly() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "dir/foo/1", []byte("taco"))
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+
+	err = t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: versionsRoot + "dir/foo/" + t.generations[0]})
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+
+	_, err = t.bucket.CopyObject(t.ctx, &gcs.CopyObjectRequest{SrcName: versionsRoot + "dir/foo/" + t.generations[0], DstName: "baz"})
+	assert.ErrorIs(t.T(), err, syscall.EROFS)
+}
+
+func (t *VersionsBucketTest) TestListObjectsMergesObjectAndDirectoryAcrossPages() {
+	// "dir/foo" and "dir/foo/bar" are both under "dir/foo/" in the view, and
+	// "dir/foo.txt" sorts between them in the wrapped listing.
+	for _, name := range []string{"dir/foo.txt", "dir/foo/bar", "dir/qux"} {
+		_, err := storageutil.CreateObject(t.ctx, t.wrapped, name, []byte("taco"))
+		require.NoError(t.T(), err)
+	}
+	t.bucket = NewVersionsBucket(&pagingBucket{Bucket: t.wrapped}, versionsRoot)
+
+	var runs []string
+	req := &gcs.ListObjectsRequest{Prefix: versionsRoot + "dir/", Delimiter: "/", MaxResults: 1}
+	for pages := 1; ; pages++ {
+		listing, err := t.bucket.ListObjects(t.ctx, req)
+		require.NoError(t.T(), err)
+		assert.Empty(t.T(), listing.MinObjects)
+		runs = append(runs, listing.CollapsedRuns...)
+		if listing.ContinuationToken == "" {
+			assert.Greater(t.T(), pages, 3)
+			break
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+
+	assert.Equal(t.T(), []string{versionsRoot + "dir/foo/", versionsRoot + "dir/foo.txt/", versionsRoot + "dir/qux/"}, runs)
+}
+
+func (t *VersionsBucketTest) TestListObjectsReturnsGenerationsWithSubdirectories() {
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped, "dir/foo/bar/baz", []byte("taco"))
+	require.NoError(t.T(), err)
+
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Prefix: versionsRoot + "dir/foo/", Delimiter: "/"})
+
+	require.NoError(t.T(), err)
+	require.Len(t.T(), listing.MinObjects, 2)
+	assert.Equal(t.T(), versionsRoot+"dir/foo/"+t.generations[0], listing.MinObjects[0].Name)
+	assert.Equal(t.T(), []string{versionsRoot + "dir/foo/bar/"}, listing.CollapsedRuns)
+}
+
+// pagingBucket paginates the listings of the wrapped bucket, which must return
+// them in full, so that each page holds at most MaxResults objects and
+// collapsed runs in order.
+type pagingBucket struct {
+	gcs.Bucket
+}
+
+func (b *pagingBucket) ListObjects(ctx context.Context, req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	fullReq := *req
+	fullReq.ContinuationToken = ""
+	fullReq.MaxResults = 0
+	full, err := b.Bucket.ListObjects(ctx, &fullReq)
+	if err != nil {
+		return nil, err
+	}
+
+	// Merge the objects and runs by name, the objects first.
+	type entry struct {
+		o   *gcs.MinObject
+		run string
+	}
+	var entries []entry
+	runs := full.CollapsedRuns
+	for _, o := range full.MinObjects {
+		for len(runs) > 0 && runs[0] < o.Name {
+			entries = append(entries, entry{run: runs[0]})
+			runs = runs[1:]
+		}
+		entries = append(entries, entry{o: o})
+	}
+	for _, run := range runs {
+		entries = append(entries, entry{run: run})
+	}
+
+	start := 0
+	if req.ContinuationToken != "" {
+		if start, err = strconv.Atoi(req.ContinuationToken); err != nil {
+			return nil, err
+		}
+	}
+	end := len(entries)
+	if req.MaxResults > 0 && start+req.MaxResults < end {
+		end = start + req.MaxResults
+	}
+
+	listing := &gcs.Listing{Lifetimes: full.Lifetimes}
+	for _, e := range entries[start:end] {
+		if e.o != nil {
+			listing.MinObjects = append(listing.MinObjects, e.o)
+		} else {
+			listing.CollapsedRuns = append(listing.CollapsedRuns, e.run)
+		}
+	}
+	if end < len(entries) {
+		listing.ContinuationToken = strconv.Itoa(end)
+	}
+	return listing, nil
+}
@@ -0,0 +1,424 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package journal keeps the contents of dirty files in a durable directory
+// until they are synced to GCS, so that the writes can be recovered after a
+// crash or restart.
+//
+// Each entry in the journal directory is a pair of files: the contents of the
+// file and a JSON manifest recording the object it is to be synced to. Entries
+// whose contents were never modified have a zero mtime in their manifest, and
+// are discarded on recovery. The contents are made durable before each attempt
+// to sync them, see Journal.Sync.
+//
+// A journal directory is used by a single mount at a time, which holds a lock
+// on it.
+package journal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/gcsx"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"golang.org/x/net/context"
+)
+
+const (
+	entryPrefix      = "gcsfuse-journal-"
+	manifestSuffix   = ".json"
+	quarantineSubdir = "quarantine"
+	lockFileName     = "lock"
+)
+
+// Manifest records the object to which the contents of a journal entry are to
+// be synced.
+type Manifest struct {
+	BucketName string
+	ObjectName string
+
+	// Generation and meta generation of the object the contents were derived
+	// from. Zero generation means the object didn't exist, i.e. a local file.
+	Generation     int64
+	MetaGeneration int64
+
+	// Mtime of the contents at the time the manifest was last written. Zero if
+	// the contents haven't been modified.
+	Mtime time.Time
+}
+
+// Journal is a directory on local disk holding the contents of dirty files.
+// Journal is safe for concurrent access.
+type Journal struct {
+	dir   string
+	clock timeutil.Clock
+
+	// Holds the lock on the directory until Close.
+	lock *os.File
+}
+
+// New creates a Journal in the given directory, creating the directory if
+// required. It fails if the directory is in use by another Journal, in this or
+// any other process.
+func New(dir string, clock timeutil.Clock) (*Journal, error) {
+	if err := os.MkdirAll(filepath.Join(dir, quarantineSubdir), 0700); err != nil {
+		return nil, fmt.Errorf("error while creating write journal directory: %w", err)
+	}
+	lock, err := lockDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Journal{dir: dir, clock: clock, lock: lock}, nil
+}
+
+// lockDir takes an exclusive lock on the given journal directory, released
+// when the returned file is closed, including when the process exits.
+func lockDir(dir string) (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(dir, lockFileName), os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error while opening write journal lock: %w", err)
+	}
+	if err = syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, fmt.Errorf("write journal directory %s is in use by another mount", dir)
+		}
+		return nil, fmt.Errorf("error while locking write journal directory: %w", err)
+	}
+	return f, nil
+}
+
+// Close releases the lock on the journal directory. The journal must not be
+// used afterwards.
+func (j *Journal) Close() error {
+	return j.lock.Close()
+}
+
+// NewTempFile returns a temp file whose initial contents are given by the
+// supplied reader, kept in the journal until Destroy is called on it. The
+// manifest is persisted again with the current mtime on the first
+// modification.
+func (j *Journal) NewTempFile(source io.ReadCloser, manifest Manifest) (gcsx.TempFile, error) {
+	f, err := os.CreateTemp(j.dir, entryPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("error while creating journal entry: %w", err)
+	}
+	manifest.Mtime = time.Time{}
+	if err = writeManifest(f.Name(), &manifest); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return nil, fmt.Errorf("error while writing journal manifest: %w", err)
+	}
+
+	return &tempFile{
+		TempFile: gcsx.NewCacheFile(source, f, j.dir, j.clock),
+		journal:  j,
+		path:     f.Name(),
+		manifest: manifest,
+	}, nil
+}
+
+// tempFile is a gcsx.TempFile which persists its manifest alongside the
+// contents.
+type tempFile struct {
+	gcsx.TempFile
+	journal *Journal
+
+	// Path of the contents on disk.
+	path string
+
+	manifest Manifest
+	modified bool
+}
+
+func (tf *tempFile) WriteAt(p []byte, offset int64) (int, error) {
+	if err := tf.ensureManifest(); err != nil {
+		return 0, err
+	}
+	return tf.TempFile.WriteAt(p, offset)
+}
+
+func (tf *tempFile) Truncate(n int64) error {
+	if err := tf.ensureManifest(); err != nil {
+		return err
+	}
+	return tf.TempFile.Truncate(n)
+}
+
+func (tf *tempFile) SetMtime(mtime time.Time) {
+	tf.TempFile.SetMtime(mtime)
+	tf.manifest.Mtime = mtime
+	if err := writeManifest(tf.path, &tf.manifest); err != nil {
+		logger.Errorf("Failed to update journal manifest of %q: %v", tf.manifest.ObjectName, err)
+		return
+	}
+	tf.modified = true
+}
+
+// Destroy removes the entry from the journal, which is done once the contents
+// have been synced or are no longer required.
+func (tf *tempFile) Destroy() {
+	tf.TempFile.Destroy()
+	if err := os.Remove(manifestPath(tf.path)); err != nil && !os.IsNotExist(err) {
+		logger.Warnf("Failed to remove journal manifest of %q: %v", tf.manifest.ObjectName, err)
+	}
+	if err := os.Remove(tf.path); err != nil {
+		logger.Warnf("Failed to remove journal entry of %q: %v", tf.manifest.ObjectName, err)
+	}
+}
+
+// Sync makes the contents of the given temp file durable along with its
+// manifest, so that they survive a restart of the machine, if it's a modified
+// journal entry. It's called before each attempt to sync the contents to GCS,
+// which stay journaled until that succeeds.
+func (j *Journal) Sync(tf gcsx.TempFile) error {
+	jtf, ok := tf.(*tempFile)
+	if !ok || !jtf.modified {
+		return nil
+	}
+
+	f, err := os.Open(jtf.path)
+	if err != nil {
+		return fmt.Errorf("error while opening journal entry: %w", err)
+	}
+	err = f.Sync()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("error while syncing journal entry: %w", err)
+	}
+
+	// Persist the creation of the entry and the renames of its manifest.
+	if err = syncDir(j.dir); err != nil {
+		return fmt.Errorf("error while syncing write journal directory: %w", err)
+	}
+	return nil
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	err = d.Sync()
+	if closeErr := d.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// ensureManifest marks the entry as modified in the manifest, if not done
+// already.
+func (tf *tempFile) ensureManifest() error {
+	if tf.modified {
+		return nil
+	}
+	tf.manifest.Mtime = tf.journal.clock.Now()
+	if err := writeManifest(tf.path, &tf.manifest); err != nil {
+		return fmt.Errorf("error while writing journal manifest: %w", err)
+	}
+	tf.modified = true
+	return nil
+}
+
+func manifestPath(entryPath string) string {
+	return entryPath + manifestSuffix
+}
+
+// writeManifest atomically replaces the manifest of the given entry.
+func writeManifest(entryPath string, manifest *Manifest) error {
+	content, err := json.Marshal(manifest)
+	if err != nil {
+		return fmt.Errorf("json.Marshal: %w", err)
+	}
+	tmpPath := manifestPath(entryPath) + ".tmp"
+	f, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(content); err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return os.Rename(tmpPath, manifestPath(entryPath))
+}
+
+// Recover syncs the entries of the given bucket left behind by a previous
+// mount. An entry is uploaded if the object is still at the generation it was
+// derived from, and is moved to the quarantine subdirectory otherwise so that
+// the writes aren't lost. Entries failing with other errors are kept for the
+// next attempt.
+func (j *Journal) Recover(ctx context.Context, bucket gcs.Bucket) error {
+	dirEntries, err := os.ReadDir(j.dir)
+	if err != nil {
+		return fmt.Errorf("error while reading write journal directory: %w", err)
+	}
+
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if dirEntry.IsDir() || !strings.HasPrefix(name, entryPrefix) || strings.Contains(name, ".") {
+			continue
+		}
+		entryPath := filepath.Join(j.dir, name)
+		if err = j.recoverEntry(ctx, bucket, entryPath); err != nil {
+			logger.Errorf("Failed to recover write journal entry %s: %v", entryPath, err)
+		}
+	}
+	return nil
+}
+
+func (j *Journal) recoverEntry(ctx context.Context, bucket gcs.Bucket, entryPath string) error {
+	content, err := os.ReadFile(manifestPath(entryPath))
+	if err != nil {
+		return err
+	}
+	var manifest Manifest
+	if err = json.Unmarshal(content, &manifest); err != nil {
+		return fmt.Errorf("corrupt manifest: %w", err)
+	}
+	if manifest.BucketName != bucket.Name() {
+		return nil
+	}
+	if manifest.Mtime.IsZero() {
+		// The contents were never modified.
+		os.Remove(manifestPath(entryPath))
+		return os.Remove(entryPath)
+	}
+
+	srcObject, err := currentObject(ctx, bucket, manifest.ObjectName)
+	if err != nil {
+		return err
+	}
+	var currentGeneration int64
+	if srcObject != nil {
+		currentGeneration = srcObject.Generation
+	}
+	if currentGeneration != manifest.Generation {
+		return j.quarantine(entryPath, &manifest, fmt.Sprintf("object generation changed from %d to %d", manifest.Generation, currentGeneration))
+	}
+
+	f, err := os.Open(entryPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mtime, err := entryMtime(entryPath, &manifest)
+	if err != nil {
+		return err
+	}
+
+	req := gcs.NewCreateObjectRequest(srcObject, manifest.ObjectName, &mtime, 0)
+	req.Contents = f
+	_, err = bucket.CreateObject(ctx, req)
+	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &preconditionErr) {
+		return j.quarantine(entryPath, &manifest, err.Error())
+	}
+	if err != nil {
+		return fmt.Errorf("CreateObject: %w", err)
+	}
+
+	logger.Infof("Recovered unsynced writes to %q from write journal.", manifest.ObjectName)
+	os.Remove(manifestPath(entryPath))
+	return os.Remove(entryPath)
+}
+
+// currentObject returns the current object with the given name, or nil if it
+// doesn't exist.
+func currentObject(ctx context.Context, bucket gcs.Bucket, name string) (*gcs.Object, error) {
+	m, attrs, err := bucket.StatObject(ctx, &gcs.StatObjectRequest{Name: name, ForceFetchFromGcs: true, ReturnExtendedObjectAttributes: true})
+	var notFoundErr *gcs.NotFoundError
+	if errors.As(err, &notFoundErr) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("StatObject: %w", err)
+	}
+	return storageutil.ConvertMinObjectAndExtendedObjectAttributesToObject(m, attrs), nil
+}
+
+// entryMtime returns the mtime of the contents of an entry. The contents
+// modified after the manifest was last written carry a later mtime.
+func entryMtime(entryPath string, manifest *Manifest) (time.Time, error) {
+	entryStat, err := os.Stat(entryPath)
+	if err != nil {
+		return time.Time{}, err
+	}
+	manifestStat, err := os.Stat(manifestPath(entryPath))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if entryStat.ModTime().After(manifestStat.ModTime()) {
+		return entryStat.ModTime(), nil
+	}
+	return manifest.Mtime, nil
+}
+
+func (j *Journal) quarantine(entryPath string, manifest *Manifest, reason string) error {
+	dst := filepath.Join(j.dir, quarantineSubdir, filepath.Base(entryPath))
+	if err := os.Rename(entryPath, dst); err != nil {
+		return fmt.Errorf("error while quarantining: %w", err)
+	}
+	if err := os.Rename(manifestPath(entryPath), manifestPath(dst)); err != nil {
+		return fmt.Errorf("error while quarantining manifest: %w", err)
+	}
+	logger.Warnf("Quarantined unsynced writes to %q at %s: %s", manifest.ObjectName, dst, reason)
+	return nil
+}
+
+// recoveringBucketManager recovers the journal entries of each bucket as it's
+// set up.
+type recoveringBucketManager struct {
+	gcsx.BucketManager
+	journal *Journal
+}
+
+// WrapBucketManager returns a BucketManager which recovers the journal entries
+// of each bucket before handing it out.
+func (j *Journal) WrapBucketManager(bm gcsx.BucketManager) gcsx.BucketManager {
+	return &recoveringBucketManager{BucketManager: bm, journal: j}
+}
+
+func (bm *recoveringBucketManager) SetUpBucket(
+	ctx context.Context,
+	name string, isMultibucketMount bool, metricHandle common.MetricHandle) (b gcsx.SyncerBucket, err error) {
+	b, err = bm.BucketManager.SetUpBucket(ctx, name, isMultibucketMount, metricHandle)
+	if err != nil {
+		return
+	}
+
+	if err = bm.journal.Recover(ctx, b); err != nil {
+		err = fmt.Errorf("Recover: %w", err)
+		return
+	}
+	return
+}
@@ -0,0 +1,253 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type JournalTest struct {
+	suite.Suite
+	ctx     context.Context
+	dir     string
+	clock   timeutil.SimulatedClock
+	bucket  gcs.Bucket
+	journal *Journal
+}
+
+func TestJournalTestSuite(t *testing.T) {
+	suite.Run(t, new(JournalTest))
+}
+
+func (t *JournalTest) SetupTest() {
+	var err error
+	t.ctx = context.Background()
+	t.dir = t.T().TempDir()
+	t.clock.SetTime(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	t.bucket = fake.NewFakeBucket(&t.clock, "some_bucket", gcs.BucketType{})
+	t.journal, err = New(t.dir, &t.clock)
+	require.NoError(t.T(), err)
+}
+
+// entries returns the names of the files in the given directory.
+func (t *JournalTest) entries(dir string) []string {
+	dirEntries, err := os.ReadDir(dir)
+	require.NoError(t.T(), err)
+	var names []string
+	for _, e := range dirEntries {
+		if !e.IsDir() && e.Name() != lockFileName {
+			names = append(names, e.Name())
+		}
+	}
+	return names
+}
+
+func (t *JournalTest) readManifest(entryPath string) Manifest {
+	var m Manifest
+	content, err := os.ReadFile(manifestPath(entryPath))
+	require.NoError(t.T(), err)
+	require.NoError(t.T(), json.Unmarshal(content, &m))
+	return m
+}
+
+// writeEntry leaves behind a modified journal entry for the given object with
+// the given contents.
+func (t *JournalTest) writeEntry(objectName string, generation int64, contents string) {
+	tf, err := t.journal.NewTempFile(io.NopCloser(strings.NewReader("")), Manifest{
+		BucketName: t.bucket.Name(),
+		ObjectName: objectName,
+		Generation: generation,
+	})
+	require.NoError(t.T(), err)
+	_, err = tf.WriteAt([]byte(contents), 0)
+	require.NoError(t.T(), err)
+}
+
+func (t *JournalTest) createObject(name, contents string) *gcs.Object {
+	o, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     name,
+		Contents: strings.NewReader(contents),
+	})
+	require.NoError(t.T(), err)
+	return o
+}
+
+func (t *JournalTest) readObject(name string) string {
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, name)
+	require.NoError(t.T(), err)
+	return string(contents)
+}
+
+func (t *JournalTest) TestNewTempFileWritesManifestWithoutMtime() {
+	tf, err := t.journal.NewTempFile(io.NopCloser(strings.NewReader("taco")), Manifest{
+		BucketName: "some_bucket",
+		ObjectName: "foo",
+		Generation: 17,
+	})
+
+	require.NoError(t.T(), err)
+	m := t.readManifest(filepath.Join(t.dir, filepath.Base(tf.Name())))
+	assert.Equal(t.T(), "foo", m.ObjectName)
+	assert.Equal(t.T(), int64(17), m.Generation)
+	assert.True(t.T(), m.Mtime.IsZero())
+}
+
+func (t *JournalTest) TestWriteAtRecordsMtimeInManifest() {
+	tf, err := t.journal.NewTempFile(io.NopCloser(strings.NewReader("taco")), Manifest{ObjectName: "foo"})
+	require.NoError(t.T(), err)
+
+	_, err = tf.WriteAt([]byte("burrito"), 0)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), t.clock.Now().UTC(), t.readManifest(tf.Name()).Mtime.UTC())
+	contents, err := os.ReadFile(tf.Name())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+}
+
+func (t *JournalTest) TestSetMtimeRecordsMtimeInManifest() {
+	tf, err := t.journal.NewTempFile(io.NopCloser(strings.NewReader("")), Manifest{ObjectName: "foo"})
+	require.NoError(t.T(), err)
+	mtime := t.clock.Now().Add(-time.Hour)
+
+	tf.SetMtime(mtime)
+
+	assert.Equal(t.T(), mtime.UTC(), t.readManifest(tf.Name()).Mtime.UTC())
+}
+
+func (t *JournalTest) TestDestroyRemovesEntry() {
+	tf, err := t.journal.NewTempFile(io.NopCloser(strings.NewReader("taco")), Manifest{ObjectName: "foo"})
+	require.NoError(t.T(), err)
+	_, err = tf.WriteAt([]byte("burrito"), 0)
+	require.NoError(t.T(), err)
+
+	tf.Destroy()
+
+	assert.Empty(t.T(), t.entries(t.dir))
+}
+
+func (t *JournalTest) TestRecoverCreatesNewObject() {
+	t.writeEntry("foo", 0, "taco")
+
+	err := t.journal.Recover(t.ctx, t.bucket)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", t.readObject("foo"))
+	assert.Empty(t.T(), t.entries(t.dir))
+}
+
+func (t *JournalTest) TestRecoverOverwritesObjectAtBaseGeneration() {
+	o := t.createObject("foo", "taco")
+	t.writeEntry("foo", o.Generation, "burrito")
+
+	err := t.journal.Recover(t.ctx, t.bucket)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", t.readObject("foo"))
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), m.Generation, o.Generation)
+	assert.Contains(t.T(), m.Metadata, gcs.MtimeMetadataKey)
+	assert.Empty(t.T(), t.entries(t.dir))
+}
+
+func (t *JournalTest) TestRecoverQuarantinesEntryOnGenerationConflict() {
+	o := t.createObject("foo", "taco")
+	t.writeEntry("foo", o.Generation, "burrito")
+	t.createObject("foo", "enchilada")
+
+	err := t.journal.Recover(t.ctx, t.bucket)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "enchilada", t.readObject("foo"))
+	assert.Empty(t.T(), t.entries(t.dir))
+	assert.Len(t.T(), t.entries(filepath.Join(t.dir, quarantineSubdir)), 2)
+}
+
+func (t *JournalTest) TestRecoverQuarantinesNewFileWhenObjectWasCreated() {
+	t.writeEntry("foo", 0, "burrito")
+	t.createObject("foo", "taco")
+
+	err := t.journal.Recover(t.ctx, t.bucket)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", t.readObject("foo"))
+	assert.Len(t.T(), t.entries(filepath.Join(t.dir, quarantineSubdir)), 2)
+}
+
+func (t *JournalTest) TestRecoverDiscardsUnmodifiedEntry() {
+	_, err := t.journal.NewTempFile(io.NopCloser(strings.NewReader("taco")), Manifest{
+		BucketName: t.bucket.Name(),
+		ObjectName: "foo",
+	})
+	require.NoError(t.T(), err)
+
+	err = t.journal.Recover(t.ctx, t.bucket)
+
+	require.NoError(t.T(), err)
+	assert.Empty(t.T(), t.entries(t.dir))
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	assert.Error(t.T(), err)
+}
+
+func (t *JournalTest) TestRecoverSkipsEntriesOfOtherBuckets() {
+	t.writeEntry("foo", 0, "taco")
+	otherBucket := fake.NewFakeBucket(&t.clock, "other_bucket", gcs.BucketType{})
+
+	err := t.journal.Recover(t.ctx, otherBucket)
+
+	require.NoError(t.T(), err)
+	assert.Len(t.T(), t.entries(t.dir), 2)
+}
+
+func (t *JournalTest) TestDirectoryIsLockedUntilClose() {
+	_, err := New(t.dir, &t.clock)
+	assert.ErrorContains(t.T(), err, "in use by another mount")
+
+	require.NoError(t.T(), t.journal.Close())
+	t.journal, err = New(t.dir, &t.clock)
+	assert.NoError(t.T(), err)
+}
+
+func (t *JournalTest) TestSync() {
+	tf, err := t.journal.NewTempFile(io.NopCloser(strings.NewReader("taco")), Manifest{ObjectName: "foo"})
+	require.NoError(t.T(), err)
+	// Unmodified contents aren't recovered, so there's nothing to persist.
+	require.NoError(t.T(), t.journal.Sync(tf))
+	_, err = tf.WriteAt([]byte("burrito"), 0)
+	require.NoError(t.T(), err)
+
+	err = t.journal.Sync(tf)
+
+	require.NoError(t.T(), err)
+	contents, err := os.ReadFile(tf.Name())
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+}
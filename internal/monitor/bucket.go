@@ -38,17 +38,21 @@ func CaptureMultiRangeDownloaderMetrics(ctx context.Context, metricHandle common
 	recordRequest(ctx, metricHandle, method, start)
 }
 
-// NewMonitoringBucket returns a gcs.Bucket that exports metrics for monitoring
-func NewMonitoringBucket(b gcs.Bucket, m common.MetricHandle) gcs.Bucket {
+// NewMonitoringBucket returns a gcs.Bucket that exports metrics for monitoring,
+// and accounts for the cost of its operations if the given accountant isn't
+// nil.
+func NewMonitoringBucket(b gcs.Bucket, m common.MetricHandle, costs *CostAccountant) gcs.Bucket {
 	return &monitoringBucket{
 		wrapped:      b,
 		metricHandle: m,
+		costs:        costs,
 	}
 }
 
 type monitoringBucket struct {
 	wrapped      gcs.Bucket
 	metricHandle common.MetricHandle
+	costs        *CostAccountant
 }
 
 func (mb *monitoringBucket) Name() string {
@@ -64,10 +68,11 @@ func setupReader(ctx context.Context, mb *monitoringBucket, req *gcs.ReadObjectR
 
 	rc, err := mb.wrapped.NewReaderWithReadHandle(ctx, req)
 	if err == nil {
-		rc = newMonitoringReadCloser(ctx, req.Name, rc, mb.metricHandle)
+		rc = newMonitoringReadCloser(ctx, req.Name, rc, mb.metricHandle, mb.costs)
 	}
 
 	recordRequest(ctx, mb.metricHandle, method, startTime)
+	mb.costs.recordOperation(ctx, method, req.Name)
 	return rc, err
 }
 
@@ -90,22 +95,47 @@ func (mb *monitoringBucket) CreateObject(
 	ctx context.Context,
 	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
 	startTime := time.Now()
+	if mb.costs != nil && req.Contents != nil {
+		counted := *req
+		counted.Contents = &ingressReader{ctx: ctx, object: req.Name, wrapped: req.Contents, costs: mb.costs}
+		req = &counted
+	}
 	o, err := mb.wrapped.CreateObject(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "CreateObject", startTime)
+	mb.costs.recordOperation(ctx, "CreateObject", req.Name)
 	return o, err
 }
 
 func (mb *monitoringBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
 	startTime := time.Now()
 	wc, err := mb.wrapped.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+	if err == nil && mb.costs != nil {
+		wc = &ingressWriter{ctx: ctx, Writer: wc, costs: mb.costs}
+	}
 	recordRequest(ctx, mb.metricHandle, "CreateObjectChunkWriter", startTime)
+	mb.costs.recordOperation(ctx, "CreateObjectChunkWriter", req.Name)
+	return wc, err
+}
+
+func (mb *monitoringBucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	startTime := time.Now()
+	wc, err := mb.wrapped.CreateAppendableObjectWriter(ctx, req, chunkSize, callBack)
+	if err == nil && mb.costs != nil {
+		wc = &ingressWriter{ctx: ctx, Writer: wc, costs: mb.costs}
+	}
+	recordRequest(ctx, mb.metricHandle, "CreateAppendableObjectWriter", startTime)
+	mb.costs.recordOperation(ctx, "CreateAppendableObjectWriter", req.Name)
 	return wc, err
 }
 
 func (mb *monitoringBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
 	startTime := time.Now()
+	if iw, ok := w.(*ingressWriter); ok {
+		w = iw.Writer
+	}
 	o, err := mb.wrapped.FinalizeUpload(ctx, w)
 	recordRequest(ctx, mb.metricHandle, "FinalizeUpload", startTime)
+	mb.costs.recordOperation(ctx, "FinalizeUpload", w.ObjectName())
 	return o, err
 }
 
@@ -115,6 +145,7 @@ func (mb *monitoringBucket) CopyObject(
 	startTime := time.Now()
 	o, err := mb.wrapped.CopyObject(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "CopyObject", startTime)
+	mb.costs.recordOperation(ctx, "CopyObject", req.DstName)
 	return o, err
 }
 
@@ -124,6 +155,7 @@ func (mb *monitoringBucket) ComposeObjects(
 	startTime := time.Now()
 	o, err := mb.wrapped.ComposeObjects(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "ComposeObjects", startTime)
+	mb.costs.recordOperation(ctx, "ComposeObjects", req.DstName)
 	return o, err
 }
 
@@ -133,6 +165,7 @@ func (mb *monitoringBucket) StatObject(
 	startTime := time.Now()
 	m, e, err := mb.wrapped.StatObject(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "StatObject", startTime)
+	mb.costs.recordOperation(ctx, "StatObject", req.Name)
 	return m, e, err
 }
 
@@ -142,6 +175,7 @@ func (mb *monitoringBucket) ListObjects(
 	startTime := time.Now()
 	listing, err := mb.wrapped.ListObjects(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "ListObjects", startTime)
+	mb.costs.recordOperation(ctx, "ListObjects", req.Prefix)
 	return listing, err
 }
 
@@ -151,6 +185,7 @@ func (mb *monitoringBucket) UpdateObject(
 	startTime := time.Now()
 	o, err := mb.wrapped.UpdateObject(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "UpdateObject", startTime)
+	mb.costs.recordOperation(ctx, "UpdateObject", req.Name)
 	return o, err
 }
 
@@ -160,6 +195,7 @@ func (mb *monitoringBucket) DeleteObject(
 	startTime := time.Now()
 	err := mb.wrapped.DeleteObject(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "DeleteObject", startTime)
+	mb.costs.recordOperation(ctx, "DeleteObject", req.Name)
 	return err
 }
 
@@ -167,6 +203,7 @@ func (mb *monitoringBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectR
 	startTime := time.Now()
 	o, err := mb.wrapped.MoveObject(ctx, req)
 	recordRequest(ctx, mb.metricHandle, "MoveObject", startTime)
+	mb.costs.recordOperation(ctx, "MoveObject", req.DstName)
 	return o, err
 }
 
@@ -174,6 +211,7 @@ func (mb *monitoringBucket) DeleteFolder(ctx context.Context, folderName string)
 	startTime := time.Now()
 	err := mb.wrapped.DeleteFolder(ctx, folderName)
 	recordRequest(ctx, mb.metricHandle, "DeleteFolder", startTime)
+	mb.costs.recordOperation(ctx, "DeleteFolder", folderName)
 	return err
 }
 
@@ -181,6 +219,7 @@ func (mb *monitoringBucket) GetFolder(ctx context.Context, folderName string) (*
 	startTime := time.Now()
 	folder, err := mb.wrapped.GetFolder(ctx, folderName)
 	recordRequest(ctx, mb.metricHandle, "GetFolder", startTime)
+	mb.costs.recordOperation(ctx, "GetFolder", folderName)
 	return folder, err
 }
 
@@ -188,6 +227,7 @@ func (mb *monitoringBucket) CreateFolder(ctx context.Context, folderName string)
 	startTime := time.Now()
 	folder, err := mb.wrapped.CreateFolder(ctx, folderName)
 	recordRequest(ctx, mb.metricHandle, "CreateFolder", startTime)
+	mb.costs.recordOperation(ctx, "CreateFolder", folderName)
 	return folder, err
 }
 
@@ -195,6 +235,7 @@ func (mb *monitoringBucket) RenameFolder(ctx context.Context, folderName string,
 	startTime := time.Now()
 	o, err = mb.wrapped.RenameFolder(ctx, folderName, destinationFolderId)
 	recordRequest(ctx, mb.metricHandle, "RenameFolder", startTime)
+	mb.costs.recordOperation(ctx, "RenameFolder", destinationFolderId)
 	return
 }
 
@@ -202,7 +243,11 @@ func (mb *monitoringBucket) NewMultiRangeDownloader(
 	ctx context.Context, req *gcs.MultiRangeDownloaderRequest) (mrd gcs.MultiRangeDownloader, err error) {
 	startTime := time.Now()
 	mrd, err = mb.wrapped.NewMultiRangeDownloader(ctx, req)
+	if err == nil && mb.costs != nil {
+		mrd = &egressMultiRangeDownloader{ctx: ctx, object: req.Name, MultiRangeDownloader: mrd, costs: mb.costs}
+	}
 	recordRequest(ctx, mb.metricHandle, "NewMultiRangeDownloader", startTime)
+	mb.costs.recordOperation(ctx, "NewMultiRangeDownloader", req.Name)
 	return
 }
 
@@ -212,13 +257,14 @@ func recordReader(ctx context.Context, metricHandle common.MetricHandle, ioMetho
 }
 
 // Monitoring on the object reader
-func newMonitoringReadCloser(ctx context.Context, object string, rc gcs.StorageReader, metricHandle common.MetricHandle) gcs.StorageReader {
+func newMonitoringReadCloser(ctx context.Context, object string, rc gcs.StorageReader, metricHandle common.MetricHandle, costs *CostAccountant) gcs.StorageReader {
 	recordReader(ctx, metricHandle, "opened")
 	return &monitoringReadCloser{
 		ctx:          ctx,
 		object:       object,
 		wrapped:      rc,
 		metricHandle: metricHandle,
+		costs:        costs,
 	}
 }
 
@@ -227,6 +273,7 @@ type monitoringReadCloser struct {
 	object       string
 	wrapped      gcs.StorageReader
 	metricHandle common.MetricHandle
+	costs        *CostAccountant
 }
 
 func (mrc *monitoringReadCloser) Read(p []byte) (n int, err error) {
@@ -234,6 +281,7 @@ func (mrc *monitoringReadCloser) Read(p []byte) (n int, err error) {
 	if err == nil || err == io.EOF {
 		mrc.metricHandle.GCSReadBytesCount(mrc.ctx, int64(n), nil)
 	}
+	mrc.costs.recordTransfer(mrc.ctx, egress, mrc.object, int64(n))
 	return
 }
 
@@ -251,3 +299,54 @@ func (mrc *monitoringReadCloser) ReadHandle() (rh storagev2.ReadHandle) {
 	recordReader(mrc.ctx, mrc.metricHandle, "ReadHandle")
 	return
 }
+
+// ingressReader accounts for the contents of objects created as they're read.
+type ingressReader struct {
+	ctx     context.Context
+	object  string
+	wrapped io.Reader
+	costs   *CostAccountant
+}
+
+func (r *ingressReader) Read(p []byte) (n int, err error) {
+	n, err = r.wrapped.Read(p)
+	r.costs.recordTransfer(r.ctx, ingress, r.object, int64(n))
+	return
+}
+
+// ingressWriter accounts for the contents of resumable uploads as they're
+// written.
+type ingressWriter struct {
+	gcs.Writer
+	ctx   context.Context
+	costs *CostAccountant
+}
+
+func (w *ingressWriter) Write(p []byte) (n int, err error) {
+	n, err = w.Writer.Write(p)
+	w.costs.recordTransfer(w.ctx, ingress, w.ObjectName(), int64(n))
+	return
+}
+
+// egressMultiRangeDownloader accounts for the ranges downloaded.
+type egressMultiRangeDownloader struct {
+	gcs.MultiRangeDownloader
+	ctx    context.Context
+	object string
+	costs  *CostAccountant
+}
+
+func (d *egressMultiRangeDownloader) Add(output io.Writer, offset, length int64, callback func(int64, int64, error)) {
+	d.MultiRangeDownloader.Add(&egressWriter{d: d, wrapped: output}, offset, length, callback)
+}
+
+type egressWriter struct {
+	d       *egressMultiRangeDownloader
+	wrapped io.Writer
+}
+
+func (w *egressWriter) Write(p []byte) (n int, err error) {
+	n, err = w.wrapped.Write(p)
+	w.d.costs.recordTransfer(w.d.ctx, egress, w.d.object, int64(n))
+	return
+}
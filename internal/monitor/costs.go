@@ -0,0 +1,243 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+)
+
+// The pricing classes of GCS operations.
+const (
+	classA    = "class_a"
+	classB    = "class_b"
+	classFree = "free"
+)
+
+// The prefix operations are attributed to once the maximum number of distinct
+// prefixes is reached. Prefixes otherwise end in "/" or are empty, so it
+// can't be confused with one.
+const otherPrefix = "other"
+
+// The directions of bytes transferred.
+const (
+	egress  = "egress"
+	ingress = "ingress"
+)
+
+// The pricing classes of the methods of gcs.Bucket, as named in metrics.
+// Resumable uploads are priced once, when started.
+var operationClasses = map[string]string{
+	"NewReader":                    classB,
+	"NewMultiRangeDownloader":      classB,
+	"StatObject":                   classB,
+	"GetFolder":                    classB,
+	"CreateObject":                 classA,
+	"CreateObjectChunkWriter":      classA,
+	"CreateAppendableObjectWriter": classA,
+	"CopyObject":                   classA,
+	"ComposeObjects":               classA,
+	"ListObjects":                  classA,
+	"UpdateObject":                 classA,
+	"MoveObject":                   classA,
+	"CreateFolder":                 classA,
+	"RenameFolder":                 classA,
+	"FinalizeUpload":               classFree,
+	"DeleteObject":                 classFree,
+	"DeleteFolder":                 classFree,
+}
+
+// CostConfig configures the accounting of the cost of the GCS operations of a
+// mount.
+type CostConfig struct {
+	// The label the operations of the mount are attributed to.
+	MountLabel string
+
+	// The number of leading directories of object names operations are also
+	// attributed to, none if zero.
+	PrefixDepth int
+
+	// The maximum number of distinct prefixes operations are attributed to,
+	// bounding the cardinality of the metrics. Operations on further prefixes
+	// are attributed to otherPrefix.
+	MaxPrefixes int
+
+	// The prices, in dollars, of 10,000 class A and class B operations, and of
+	// a GiB of egress, for estimating the cost.
+	ClassAPrice       float64
+	ClassBPrice       float64
+	EgressPricePerGiB float64
+
+	// How often the estimated cost is logged, never if zero.
+	LogInterval time.Duration
+}
+
+// CostAccountant counts the GCS operations of a mount by pricing class, and
+// the bytes transferred by direction, exporting them as metrics attributed to
+// the mount label and the prefix of the object names, and logging the
+// estimated cost of the totals periodically.
+//
+// Operations are counted as the bucket it wraps sees them. In particular, a
+// request which a failover bucket below sends to both the primary and the
+// secondary bucket is counted once, though both operations are billed.
+type CostAccountant struct {
+	config       CostConfig
+	metricHandle common.MetricHandle
+
+	operations map[string]*atomic.Int64
+	transfers  map[string]*atomic.Int64
+
+	mu       sync.Mutex
+	prefixes map[string]struct{} // GUARDED_BY(mu)
+}
+
+func NewCostAccountant(config CostConfig, metricHandle common.MetricHandle) *CostAccountant {
+	c := &CostAccountant{
+		config:       config,
+		metricHandle: metricHandle,
+		operations:   make(map[string]*atomic.Int64),
+		transfers:    make(map[string]*atomic.Int64),
+		prefixes:     make(map[string]struct{}),
+	}
+	for _, class := range []string{classA, classB, classFree} {
+		c.operations[class] = new(atomic.Int64)
+	}
+	for _, direction := range []string{egress, ingress} {
+		c.transfers[direction] = new(atomic.Int64)
+	}
+	return c
+}
+
+// Return the prefix of the given object name operations on it are attributed
+// to: its leading directories, up to the configured depth.
+func (c *CostAccountant) prefix(name string) string {
+	end := 0
+	for range c.config.PrefixDepth {
+		i := strings.IndexByte(name[end:], '/')
+		if i < 0 {
+			break
+		}
+		end += i + 1
+	}
+	return name[:end]
+}
+
+// Return the value of the prefix label of operations on the given object name:
+// its prefix, unless the maximum number of distinct prefixes has been reached
+// without it, in which case otherPrefix.
+func (c *CostAccountant) prefixLabel(name string) string {
+	prefix := c.prefix(name)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.prefixes[prefix]; ok {
+		return prefix
+	}
+	if len(c.prefixes) >= c.config.MaxPrefixes {
+		return otherPrefix
+	}
+	c.prefixes[prefix] = struct{}{}
+	return prefix
+}
+
+func (c *CostAccountant) attrs(key string, value string, name string) []common.MetricAttr {
+	return []common.MetricAttr{
+		{Key: key, Value: value},
+		{Key: common.MountLabel, Value: c.config.MountLabel},
+		{Key: common.ObjectPrefix, Value: c.prefixLabel(name)},
+	}
+}
+
+// Record a call to the given method on the given object name.
+func (c *CostAccountant) recordOperation(ctx context.Context, method string, name string) {
+	if c == nil {
+		return
+	}
+
+	class, ok := operationClasses[method]
+	if !ok {
+		return
+	}
+	c.operations[class].Add(1)
+	c.metricHandle.GCSOperationCount(ctx, 1, c.attrs(common.GCSOperationClass, class, name))
+}
+
+// Record bytes transferred in the given direction for the given object name.
+func (c *CostAccountant) recordTransfer(ctx context.Context, direction string, name string, n int64) {
+	if c == nil || n <= 0 {
+		return
+	}
+
+	c.transfers[direction].Add(n)
+	c.metricHandle.GCSTransferBytesCount(ctx, n, c.attrs(common.TransferDirection, direction, name))
+}
+
+// CostSummary holds the totals of a mount so far.
+type CostSummary struct {
+	ClassAOperations int64
+	ClassBOperations int64
+	FreeOperations   int64
+	EgressBytes      int64
+	IngressBytes     int64
+
+	// In dollars.
+	EstimatedCost float64
+}
+
+func (c *CostAccountant) Summary() CostSummary {
+	s := CostSummary{
+		ClassAOperations: c.operations[classA].Load(),
+		ClassBOperations: c.operations[classB].Load(),
+		FreeOperations:   c.operations[classFree].Load(),
+		EgressBytes:      c.transfers[egress].Load(),
+		IngressBytes:     c.transfers[ingress].Load(),
+	}
+	s.EstimatedCost = float64(s.ClassAOperations)/10000*c.config.ClassAPrice +
+		float64(s.ClassBOperations)/10000*c.config.ClassBPrice +
+		float64(s.EgressBytes)/(1<<30)*c.config.EgressPricePerGiB
+	return s
+}
+
+func (c *CostAccountant) logSummary() {
+	s := c.Summary()
+	logger.Infof("GCS operations of mount %q so far: %d class A, %d class B, %d free; %d bytes egress, %d bytes ingress; estimated cost $%.4f",
+		c.config.MountLabel, s.ClassAOperations, s.ClassBOperations, s.FreeOperations, s.EgressBytes, s.IngressBytes, s.EstimatedCost)
+}
+
+// LogPeriodically logs the summary at the configured interval, if any, until
+// the context is cancelled, and once more then.
+func (c *CostAccountant) LogPeriodically(ctx context.Context) {
+	if c.config.LogInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(c.config.LogInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			c.logSummary()
+		case <-ctx.Done():
+			c.logSummary()
+			return
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/common"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// costMetrics sums the cost metrics by their attributes.
+type costMetrics struct {
+	common.MetricHandle
+	mu     sync.Mutex
+	counts map[[3]string]int64
+}
+
+func (m *costMetrics) add(inc int64, attrs []common.MetricAttr) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.counts[[3]string{attrs[0].Value, attrs[1].Value, attrs[2].Value}] += inc
+}
+
+func (m *costMetrics) GCSOperationCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	m.add(inc, attrs)
+}
+
+func (m *costMetrics) GCSTransferBytesCount(_ context.Context, inc int64, attrs []common.MetricAttr) {
+	m.add(inc, attrs)
+}
+
+type CostAccountingTest struct {
+	suite.Suite
+	ctx     context.Context
+	metrics *costMetrics
+	costs   *CostAccountant
+	bucket  gcs.Bucket
+}
+
+func TestCostAccountingTestSuite(t *testing.T) {
+	suite.Run(t, new(CostAccountingTest))
+}
+
+func (t *CostAccountingTest) SetupTest() {
+	t.ctx = context.Background()
+	t.metrics = &costMetrics{MetricHandle: common.NewNoopMetrics(), counts: make(map[[3]string]int64)}
+	t.costs = NewCostAccountant(CostConfig{
+		MountLabel:        "some_mount",
+		PrefixDepth:       1,
+		MaxPrefixes:       10,
+		ClassAPrice:       0.05,
+		ClassBPrice:       0.004,
+		EgressPricePerGiB: 0.12,
+	}, t.metrics)
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	t.bucket = NewMonitoringBucket(wrapped, t.metrics, t.costs)
+}
+
+func (t *CostAccountingTest) TestOperationsByClassAndPrefix() {
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "logs/a/foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Prefix: "logs/"})
+	require.NoError(t.T(), err)
+	for _, name := range []string{"logs/a/foo", "bar"} {
+		_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+	}
+	require.Error(t.T(), err)
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "logs/a/foo"}))
+
+	s := t.costs.Summary()
+
+	// Failed requests are charged too.
+	assert.Equal(t.T(), int64(2), s.ClassAOperations)
+	assert.Equal(t.T(), int64(2), s.ClassBOperations)
+	assert.Equal(t.T(), int64(1), s.FreeOperations)
+	assert.InDelta(t.T(), 2*0.05/10000+2*0.004/10000, s.EstimatedCost, 1e-12)
+	assert.Equal(t.T(), map[[3]string]int64{
+		{classA, "some_mount", "logs/"}:    2,
+		{classB, "some_mount", "logs/"}:    1,
+		{classB, "some_mount", ""}:         1,
+		{classFree, "some_mount", "logs/"}: 1,
+		{ingress, "some_mount", "logs/"}:   4,
+	}, t.metrics.counts)
+}
+
+func (t *CostAccountingTest) TestBytesTransferred() {
+	contents := strings.Repeat("x", 1024)
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte(contents))
+	require.NoError(t.T(), err)
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: "bar"}, 0, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = t.bucket.FinalizeUpload(t.ctx, w)
+	require.NoError(t.T(), err)
+
+	_, err = storageutil.ReadObject(t.ctx, t.bucket, "foo")
+	require.NoError(t.T(), err)
+	mrd, err := t.bucket.NewMultiRangeDownloader(t.ctx, &gcs.MultiRangeDownloaderRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	var buf bytes.Buffer
+	mrd.Add(&buf, 0, 100, func(int64, int64, error) {})
+	mrd.Wait()
+	require.NoError(t.T(), mrd.Close())
+
+	s := t.costs.Summary()
+	assert.Equal(t.T(), int64(1024+4), s.IngressBytes)
+	assert.Equal(t.T(), int64(1024+100), s.EgressBytes)
+	assert.InDelta(t.T(), 2*0.05/10000+2*0.004/10000+float64(1124)/(1<<30)*0.12, s.EstimatedCost, 1e-12)
+}
+
+func (t *CostAccountingTest) TestPrefix() {
+	var testCases = []struct {
+		depth int
+		name  string
+		want  string
+	}{
+		{0, "a/b/c", ""},
+		{1, "a/b/c", "a/"},
+		{2, "a/b/c", "a/b/"},
+		{3, "a/b/c", "a/b/"},
+		{2, "a/", "a/"},
+		{2, "a", ""},
+	}
+
+	for _, tc := range testCases {
+		c := NewCostAccountant(CostConfig{PrefixDepth: tc.depth}, t.metrics)
+		assert.Equal(t.T(), tc.want, c.prefix(tc.name), "%d %q", tc.depth, tc.name)
+	}
+}
+
+func (t *CostAccountingTest) TestPrefixesBeyondMaxAreOther() {
+	c := NewCostAccountant(CostConfig{PrefixDepth: 1, MaxPrefixes: 2}, t.metrics)
+
+	var labels []string
+	for _, name := range []string{"a/foo", "b/foo", "c/foo", "a/bar", "d"} {
+		labels = append(labels, c.prefixLabel(name))
+	}
+
+	assert.Equal(t.T(), []string{"a/", "b/", otherPrefix, "a/", otherPrefix}, labels)
+}
+
+func (t *CostAccountingTest) TestWithoutAccountant() {
+	wrapped := fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	t.bucket = NewMonitoringBucket(wrapped, t.metrics, nil)
+
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.ReadObject(t.ctx, t.bucket, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Empty(t.T(), t.metrics.counts)
+}
@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"cloud.google.com/go/storage"
@@ -49,6 +50,36 @@ type bucketHandle struct {
 	bucketName    string
 	bucketType    *gcs.BucketType
 	controlClient StorageControlClient
+
+	// The customer-supplied encryption key attached to all object requests, if
+	// any.
+	encryptionKey []byte
+}
+
+// Return a handle to the named object, using the customer-supplied encryption
+// key if any.
+func (bh *bucketHandle) object(name string) *storage.ObjectHandle {
+	obj := bh.bucket.Object(name)
+	if bh.encryptionKey != nil {
+		obj = obj.Key(bh.encryptionKey)
+	}
+	return obj
+}
+
+// Whether the error is GCS rejecting the customer-supplied encryption key of a
+// request, either because the object is encrypted with another key, or
+// because only one of them has a key.
+func isEncryptionKeyError(err error) bool {
+	var gErr *googleapi.Error
+	if errors.As(err, &gErr) {
+		return gErr.Code == http.StatusBadRequest && strings.Contains(gErr.Error(), "encryption key")
+	}
+	if rpcErr, ok := status.FromError(err); ok {
+		code := rpcErr.Code()
+		return (code == codes.InvalidArgument || code == codes.FailedPrecondition) &&
+			strings.Contains(rpcErr.Message(), "encryption key")
+	}
+	return false
 }
 
 func (bh *bucketHandle) Name() string {
@@ -80,7 +111,7 @@ func (bh *bucketHandle) NewReaderWithReadHandle(
 		length = end - start
 	}
 
-	obj := bh.bucket.Object(req.Name)
+	obj := bh.object(req.Name)
 
 	// Switching to the requested generation of object.
 	if req.Generation != 0 {
@@ -102,15 +133,17 @@ func (bh *bucketHandle) NewReaderWithReadHandle(
 	// NewRangeReader creates a "storage.Reader" object which is also io.ReadCloser since it contains both Read() and Close() methods present in io.ReadCloser interface.
 	r, err := obj.NewRangeReader(ctx, start, length)
 
-	if err == storage.ErrObjectNotExist {
+	if errors.Is(err, storage.ErrObjectNotExist) {
 		err = &gcs.NotFoundError{Err: storage.ErrObjectNotExist}
+	} else if isEncryptionKeyError(err) {
+		err = &gcs.EncryptionKeyError{Err: err}
 	}
 
 	return r, err
 }
 
 func (bh *bucketHandle) DeleteObject(ctx context.Context, req *gcs.DeleteObjectRequest) error {
-	obj := bh.bucket.Object(req.Name)
+	obj := bh.object(req.Name)
 
 	// Switching to the requested generation of the object. By default, generation
 	// is 0 which signifies the latest generation. Note: GCS will delete the
@@ -133,7 +166,7 @@ func (bh *bucketHandle) DeleteObject(ctx context.Context, req *gcs.DeleteObjectR
 				err = &gcs.PreconditionError{Err: ee}
 			}
 		default:
-			if err == storage.ErrObjectNotExist {
+			if errors.Is(err, storage.ErrObjectNotExist) {
 				err = &gcs.NotFoundError{Err: storage.ErrObjectNotExist}
 			} else {
 				err = fmt.Errorf("error in deleting object: %w", err)
@@ -148,13 +181,17 @@ func (bh *bucketHandle) StatObject(ctx context.Context,
 	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
 	var attrs *storage.ObjectAttrs
 	// Retrieving object attrs through Go Storage Client.
-	attrs, err = bh.bucket.Object(req.Name).Attrs(ctx)
+	attrs, err = bh.object(req.Name).Attrs(ctx)
 
 	// If error is of type storage.ErrObjectNotExist
-	if err == storage.ErrObjectNotExist {
+	if errors.Is(err, storage.ErrObjectNotExist) {
 		err = &gcs.NotFoundError{Err: err} // Special case error that object not found in the bucket.
 		return
 	}
+	if isEncryptionKeyError(err) {
+		err = &gcs.EncryptionKeyError{Err: err}
+		return
+	}
 	if err != nil {
 		err = fmt.Errorf("error in fetching object attributes: %w", err)
 		return
@@ -171,7 +208,7 @@ func (bh *bucketHandle) StatObject(ctx context.Context,
 }
 
 func (bh *bucketHandle) getObjectHandleWithPreconditionsSet(req *gcs.CreateObjectRequest) *storage.ObjectHandle {
-	obj := bh.bucket.Object(req.Name)
+	obj := bh.object(req.Name)
 
 	// GenerationPrecondition - If non-nil, the object will be created/overwritten
 	// only if the current generation for the object name is equal to the given value.
@@ -214,6 +251,7 @@ func (bh *bucketHandle) CreateObject(ctx context.Context, req *gcs.CreateObjectR
 	}
 	// All objects in zonal buckets must be appendable.
 	wc.Append = bh.BucketType().Zonal
+	wc.FinalizeOnClose = true
 
 	// Copy the contents to the writer.
 	if _, err = io.Copy(wc, req.Contents); err != nil {
@@ -224,6 +262,10 @@ func (bh *bucketHandle) CreateObject(ctx context.Context, req *gcs.CreateObjectR
 	// We can't use defer to close the writer, because we need to close the
 	// writer successfully before calling Attrs() method of writer.
 	if err = wc.Close(); err != nil {
+		if isEncryptionKeyError(err) {
+			err = &gcs.EncryptionKeyError{Err: err}
+			return
+		}
 		// This checks if the error returned from the RPC call is a gRPC status error.
 		// If it is, and the error code is `codes.FailedPrecondition`, it means the
 		// operation failed due to a precondition not being met.The generic gRPC error
@@ -254,7 +296,7 @@ func (bh *bucketHandle) CreateObject(ctx context.Context, req *gcs.CreateObjectR
 func (bh *bucketHandle) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
 	obj := bh.getObjectHandleWithPreconditionsSet(req)
 
-	wc := &ObjectWriter{obj.NewWriter(ctx)}
+	wc := &ObjectWriter{Writer: obj.NewWriter(ctx)}
 	wc.ChunkSize = chunkSize
 	wc.Writer = storageutil.SetAttrsInWriter(wc.Writer, req)
 	if callBack == nil {
@@ -263,14 +305,70 @@ func (bh *bucketHandle) CreateObjectChunkWriter(ctx context.Context, req *gcs.Cr
 		}
 	}
 	wc.ProgressFunc = callBack
-	// All objects in zonal buckets must be appendable.
+	// All objects in zonal buckets must be appendable. They can be flushed
+	// before they are finalized on close.
 	wc.Append = bh.BucketType().Zonal
+	wc.FinalizeOnClose = true
 
 	return wc, nil
 }
 
+func (bh *bucketHandle) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if !bh.BucketType().Zonal {
+		return nil, fmt.Errorf("appending to object %q: appendable objects are only supported in zonal buckets", req.Name)
+	}
+
+	// The client library doesn't send the customer-supplied encryption key when
+	// taking over an appendable object, so GCS would reject the takeover of
+	// objects encrypted with it.
+	if bh.encryptionKey != nil {
+		return nil, fmt.Errorf("appending to object %q: appendable objects can't be taken over with a customer-supplied encryption key: %w", req.Name, errors.ErrUnsupported)
+	}
+
+	if callBack == nil {
+		callBack = func(bytesUploadedSoFar int64) {
+			logger.Tracef("gcs: Req %#16x: -- UploadBlock(%q): %20v bytes uploaded so far", ctx.Value(gcs.ReqIdField), req.Name, bytesUploadedSoFar)
+		}
+	}
+
+	// The stream is abandoned by cancelling its context, so that the object
+	// isn't finalized if it can't be appended to.
+	ctx, cancel := context.WithCancel(ctx)
+	obj := bh.object(req.Name).Generation(req.Generation)
+	w, offset, err := obj.NewWriterFromAppendableObject(ctx, &storage.AppendableWriterOpts{
+		ChunkSize:       chunkSize,
+		ProgressFunc:    callBack,
+		FinalizeOnClose: true,
+	})
+	switch {
+	case err != nil:
+		if rpcErr, ok := status.FromError(err); ok {
+			switch rpcErr.Code() {
+			case codes.NotFound:
+				err = &gcs.NotFoundError{Err: err}
+			case codes.FailedPrecondition:
+				err = &gcs.PreconditionError{Err: err}
+			}
+		}
+		err = fmt.Errorf("error in taking over object %q: %w", req.Name, err)
+	case offset != req.Offset:
+		err = &gcs.PreconditionError{Err: fmt.Errorf("object %q has size %d, expected %d", req.Name, offset, req.Offset)}
+	}
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	w.ChunkTransferTimeout = time.Duration(req.ChunkTransferTimeoutSecs) * time.Second
+	return &ObjectWriter{Writer: w, cancel: cancel}, nil
+}
+
 func (bh *bucketHandle) FinalizeUpload(ctx context.Context, w gcs.Writer) (o *gcs.MinObject, err error) {
 	if err = w.Close(); err != nil {
+		if isEncryptionKeyError(err) {
+			err = &gcs.EncryptionKeyError{Err: err}
+			return
+		}
 		var gErr *googleapi.Error
 		if errors.As(err, &gErr) {
 			if gErr.Code == http.StatusPreconditionFailed {
@@ -289,8 +387,8 @@ func (bh *bucketHandle) FinalizeUpload(ctx context.Context, w gcs.Writer) (o *gc
 }
 
 func (bh *bucketHandle) CopyObject(ctx context.Context, req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
-	srcObj := bh.bucket.Object(req.SrcName)
-	dstObj := bh.bucket.Object(req.DstName)
+	srcObj := bh.object(req.SrcName)
+	dstObj := bh.object(req.DstName)
 
 	// Switching to the requested generation of source object.
 	if req.SrcGeneration != 0 {
@@ -304,15 +402,18 @@ func (bh *bucketHandle) CopyObject(ctx context.Context, req *gcs.CopyObjectReque
 
 	objAttrs, err := dstObj.CopierFrom(srcObj).Run(ctx)
 
+	if isEncryptionKeyError(err) {
+		err = &gcs.EncryptionKeyError{Err: err}
+		return
+	}
 	if err != nil {
-		switch ee := err.(type) {
-		case *googleapi.Error:
-			if ee.Code == http.StatusPreconditionFailed {
-				err = &gcs.PreconditionError{Err: ee}
-			}
-			if ee.Code == http.StatusNotFound {
-				err = &gcs.NotFoundError{Err: storage.ErrObjectNotExist}
-			}
+		var ee *googleapi.Error
+		switch {
+		case errors.Is(err, storage.ErrObjectNotExist),
+			errors.As(err, &ee) && ee.Code == http.StatusNotFound:
+			err = &gcs.NotFoundError{Err: storage.ErrObjectNotExist}
+		case errors.As(err, &ee) && ee.Code == http.StatusPreconditionFailed:
+			err = &gcs.PreconditionError{Err: ee}
 		default:
 			err = fmt.Errorf("error in copying object: %w", err)
 		}
@@ -348,9 +449,14 @@ func (bh *bucketHandle) ListObjects(ctx context.Context, req *gcs.ListObjectsReq
 		Projection:               getProjectionValue(req.ProjectionVal),
 		IncludeTrailingDelimiter: req.IncludeTrailingDelimiter,
 		IncludeFoldersAsPrefixes: req.IncludeFoldersAsPrefixes,
+		Versions:                 req.Versions,
 		//MaxResults: , (Field not present in storage.Query of Go Storage Library but present in ListObjectsQuery in Jacobsa code.)
 	}
-	err = query.SetAttrSelection([]string{"Name", "Size", "Generation", "Metageneration", "Updated", "Metadata", "ContentEncoding", "CRC32C"})
+	attrSelection := []string{"Name", "Size", "Generation", "Metageneration", "Updated", "Metadata", "ContentEncoding", "CRC32C"}
+	if req.Versions {
+		attrSelection = append(attrSelection, "Created", "Deleted")
+	}
+	err = query.SetAttrSelection(attrSelection)
 	if err != nil {
 		err = fmt.Errorf("error while setting attribute selection for List Object query :%w", err)
 		return
@@ -385,6 +491,14 @@ func (bh *bucketHandle) ListObjects(ctx context.Context, req *gcs.ListObjectsReq
 			// Converting attrs to *Object type.
 			currMinObject := storageutil.ObjectAttrsToMinObject(attrs)
 			list.MinObjects = append(list.MinObjects, currMinObject)
+			if req.Versions {
+				list.Lifetimes = append(list.Lifetimes, gcs.GenerationLifetime{
+					Name:       attrs.Name,
+					Generation: attrs.Generation,
+					Created:    attrs.Created,
+					Deleted:    attrs.Deleted,
+				})
+			}
 		}
 
 		// itr.next returns all the objects present in the bucket. Hence adding a
@@ -405,7 +519,7 @@ func (bh *bucketHandle) ListObjects(ctx context.Context, req *gcs.ListObjectsReq
 }
 
 func (bh *bucketHandle) UpdateObject(ctx context.Context, req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
-	obj := bh.bucket.Object(req.Name)
+	obj := bh.object(req.Name)
 
 	if req.Generation != 0 {
 		obj = obj.Generation(req.Generation)
@@ -450,6 +564,11 @@ func (bh *bucketHandle) UpdateObject(ctx context.Context, req *gcs.UpdateObjectR
 		return
 	}
 
+	if isEncryptionKeyError(err) {
+		err = &gcs.EncryptionKeyError{Err: err}
+		return
+	}
+
 	// If storage object does not exist, httpclient is returning ErrObjectNotExist error instead of googleapi error
 	// https://github.com/GoogleCloudPlatform/gcsfuse/blob/master/vendor/cloud.google.com/go/storage/http_client.go#L516
 	switch ee := err.(type) {
@@ -458,7 +577,7 @@ func (bh *bucketHandle) UpdateObject(ctx context.Context, req *gcs.UpdateObjectR
 			err = &gcs.PreconditionError{Err: ee}
 		}
 	default:
-		if err == storage.ErrObjectNotExist {
+		if errors.Is(err, storage.ErrObjectNotExist) {
 			err = &gcs.NotFoundError{Err: storage.ErrObjectNotExist}
 		} else {
 			err = fmt.Errorf("error in updating object: %w", err)
@@ -469,7 +588,7 @@ func (bh *bucketHandle) UpdateObject(ctx context.Context, req *gcs.UpdateObjectR
 }
 
 func (bh *bucketHandle) ComposeObjects(ctx context.Context, req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
-	dstObj := bh.bucket.Object(req.DstName)
+	dstObj := bh.object(req.DstName)
 
 	dstObjConds := storage.Conditions{}
 	if req.DstMetaGenerationPrecondition != nil {
@@ -495,7 +614,7 @@ func (bh *bucketHandle) ComposeObjects(ctx context.Context, req *gcs.ComposeObje
 	// Converting the req.Sources list to a list of storage.ObjectHandle as expected by the Go Storage Client.
 	var srcObjList []*storage.ObjectHandle
 	for _, src := range req.Sources {
-		currSrcObj := bh.bucket.Object(src.Name)
+		currSrcObj := bh.object(src.Name)
 		// Switching to requested Generation of the object.
 		// Zero src generation is the latest generation, we are skipping it because by default it will take the latest one
 		if src.Generation != 0 {
@@ -505,7 +624,12 @@ func (bh *bucketHandle) ComposeObjects(ctx context.Context, req *gcs.ComposeObje
 	}
 
 	// Composing Source Objects to Destination Object using Composer created through Go Storage Client.
-	attrs, err := dstObj.ComposerFrom(srcObjList...).Run(ctx)
+	composer := storageutil.SetAttrsInComposer(dstObj.ComposerFrom(srcObjList...), req)
+	attrs, err := composer.Run(ctx)
+	if isEncryptionKeyError(err) {
+		err = &gcs.EncryptionKeyError{Err: err}
+		return
+	}
 	if err != nil {
 		switch ee := err.(type) {
 		case *googleapi.Error:
@@ -555,7 +679,7 @@ func (bh *bucketHandle) MoveObject(ctx context.Context, req *gcs.MoveObjectReque
 	var o *gcs.Object
 	var err error
 
-	obj := bh.bucket.Object(req.SrcName)
+	obj := bh.object(req.SrcName)
 
 	// Switching to the requested generation of source object.
 	if req.SrcGeneration != 0 {
@@ -581,7 +705,9 @@ func (bh *bucketHandle) MoveObject(ctx context.Context, req *gcs.MoveObjectReque
 
 	// If storage object does not exist, httpclient is returning ErrObjectNotExist error instead of googleapi error
 	// https://github.com/GoogleCloudPlatform/gcsfuse/blob/master/vendor/cloud.google.com/go/storage/http_client.go#L516
-	if ok, preCondErr := isPreconditionFailed(err); ok {
+	if isEncryptionKeyError(err) {
+		err = &gcs.EncryptionKeyError{Err: err}
+	} else if ok, preCondErr := isPreconditionFailed(err); ok {
 		err = preCondErr
 	} else if errors.Is(err, storage.ErrObjectNotExist) {
 		err = &gcs.NotFoundError{Err: storage.ErrObjectNotExist}
@@ -664,7 +790,7 @@ func (bh *bucketHandle) CreateFolder(ctx context.Context, folderName string) (*g
 
 func (bh *bucketHandle) NewMultiRangeDownloader(
 	ctx context.Context, req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
-	obj := bh.bucket.Object(req.Name)
+	obj := bh.object(req.Name)
 
 	// Switching to the requested generation of object.
 	if req.Generation != 0 {
@@ -675,7 +801,11 @@ func (bh *bucketHandle) NewMultiRangeDownloader(
 		obj = obj.ReadCompressed(true)
 	}
 
-	return obj.NewMultiRangeDownloader(ctx)
+	mrd, err := obj.NewMultiRangeDownloader(ctx)
+	if isEncryptionKeyError(err) {
+		err = &gcs.EncryptionKeyError{Err: err}
+	}
+	return mrd, err
 }
 
 func isStorageConditionsNotEmpty(conditions storage.Conditions) bool {
@@ -76,10 +76,10 @@ func minObjectsToMinObjectNames(minObjects []*gcs.MinObject) (objectNames []stri
 }
 
 func createBucketHandle(testSuite *BucketHandleTest, resp *controlpb.StorageLayout, err1 error) {
-	var err error
 	testSuite.mockClient.On("GetStorageLayout", mock.Anything, mock.Anything, mock.Anything).
 		Return(resp, err1)
-	testSuite.bucketHandle, err = testSuite.storageHandle.BucketHandle(context.Background(), TestBucketName, "")
+	b, err := testSuite.storageHandle.BucketHandle(context.Background(), TestBucketName, "")
+	testSuite.bucketHandle = b.(*bucketHandle)
 	testSuite.bucketHandle.controlClient = testSuite.mockClient
 
 	assert.NotNil(testSuite.T(), testSuite.bucketHandle)
@@ -607,6 +607,32 @@ func (testSuite *BucketHandleTest) TestBucketHandle_CreateObjectChunkWriterWithN
 	assert.NotNil(testSuite.T(), objWr.ProgressFunc)
 }
 
+func (testSuite *BucketHandleTest) TestBucketHandle_CreateObjectChunkWriterFinalizesOnClose() {
+	createBucketHandle(testSuite, &controlpb.StorageLayout{}, nil)
+
+	w, err := testSuite.bucketHandle.CreateObjectChunkWriter(context.Background(), &gcs.CreateObjectRequest{Name: "test_object_1"}, 1024, nil)
+
+	require.NoError(testSuite.T(), err)
+	assert.True(testSuite.T(), w.(*ObjectWriter).FinalizeOnClose)
+}
+
+func (testSuite *BucketHandleTest) TestBucketHandle_CreateAppendableObjectWriterInNonZonalBucket() {
+	createBucketHandle(testSuite, &controlpb.StorageLayout{}, nil)
+
+	_, err := testSuite.bucketHandle.CreateAppendableObjectWriter(context.Background(), &gcs.AppendObjectRequest{Name: "test_object_1", Generation: 1}, 1024, nil)
+
+	assert.ErrorContains(testSuite.T(), err, "only supported in zonal buckets")
+}
+
+func (testSuite *BucketHandleTest) TestBucketHandle_CreateAppendableObjectWriterWithEncryptionKey() {
+	createBucketHandle(testSuite, &controlpb.StorageLayout{LocationType: "zone"}, nil)
+	testSuite.bucketHandle.encryptionKey = make([]byte, 32)
+
+	_, err := testSuite.bucketHandle.CreateAppendableObjectWriter(context.Background(), &gcs.AppendObjectRequest{Name: "test_object_1", Generation: 1}, 1024, nil)
+
+	assert.ErrorIs(testSuite.T(), err, errors.ErrUnsupported)
+}
+
 func (testSuite *BucketHandleTest) TestBucketHandle_FinalizeUploadSuccess() {
 	createBucketHandle(testSuite, &controlpb.StorageLayout{}, nil)
 
@@ -1492,12 +1518,11 @@ func (testSuite *BucketHandleTest) TestBucketTypeForHierarchicalNameSpaceFalse()
 
 func (testSuite *BucketHandleTest) TestBucketHandleWithError() {
 	var x *controlpb.StorageLayout
-	var err error
 	// Test when the client returns an error.
 	testSuite.mockClient.On("GetStorageLayout", mock.Anything, mock.Anything, mock.Anything).Return(x, errors.New("mocked error"))
-	testSuite.bucketHandle, err = testSuite.storageHandle.BucketHandle(context.Background(), TestBucketName, "")
+	b, err := testSuite.storageHandle.BucketHandle(context.Background(), TestBucketName, "")
 
-	assert.Nil(testSuite.T(), testSuite.bucketHandle)
+	assert.Nil(testSuite.T(), b)
 	assert.Contains(testSuite.T(), err.Error(), "mocked error")
 }
 
@@ -1689,3 +1714,56 @@ func TestIsPreconditionFailed(t *testing.T) {
 		})
 	}
 }
+
+func TestIsEncryptionKeyError(t *testing.T) {
+	wrongKeyApiError, _ := apierror.FromError(status.New(codes.InvalidArgument, "The provided encryption key is incorrect").Err())
+	invalidArgumentApiError, _ := apierror.FromError(status.New(codes.InvalidArgument, "Invalid argument").Err())
+
+	tests := []struct {
+		name      string
+		err       error
+		expectKey bool
+	}{
+		{
+			name:      "googleapi.Error with missing key",
+			err:       &googleapi.Error{Code: http.StatusBadRequest, Message: "The target object is encrypted by a customer-supplied encryption key."},
+			expectKey: true,
+		},
+		{
+			name:      "googleapi.Error with other bad request",
+			err:       &googleapi.Error{Code: http.StatusBadRequest, Message: "Invalid argument."},
+			expectKey: false,
+		},
+		{
+			name:      "googleapi.Error with other code",
+			err:       &googleapi.Error{Code: http.StatusForbidden, Message: "No access to the encryption key."},
+			expectKey: false,
+		},
+		{
+			name:      "apierror.APIError with wrong key",
+			err:       wrongKeyApiError,
+			expectKey: true,
+		},
+		{
+			name:      "apierror.APIError with other invalid argument",
+			err:       invalidArgumentApiError,
+			expectKey: false,
+		},
+		{
+			name:      "nil error",
+			err:       nil,
+			expectKey: false,
+		},
+		{
+			name:      "generic error",
+			err:       errors.New("bad encryption key"),
+			expectKey: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expectKey, isEncryptionKeyError(tt.err))
+		})
+	}
+}
@@ -0,0 +1,240 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package caching_test
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/cfg"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/lru"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/metadata"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/caching"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// How long concurrent callers are given to join a call in flight.
+const joinDelay = 20 * time.Millisecond
+
+// gatedBucket holds stat, folder and list calls until its gate is opened,
+// counting them.
+type gatedBucket struct {
+	gcs.Bucket
+	gate  chan struct{}
+	calls atomic.Int32
+}
+
+func (b *gatedBucket) wait() {
+	b.calls.Add(1)
+	<-b.gate
+}
+
+func (b *gatedBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	b.wait()
+	return b.Bucket.StatObject(ctx, req)
+}
+
+func (b *gatedBucket) GetFolder(ctx context.Context, prefix string) (*gcs.Folder, error) {
+	b.wait()
+	return b.Bucket.GetFolder(ctx, prefix)
+}
+
+func (b *gatedBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	b.wait()
+	return b.Bucket.ListObjects(ctx, req)
+}
+
+type CoalescingTest struct {
+	suite.Suite
+	ctx     context.Context
+	wrapped *gatedBucket
+	bucket  gcs.Bucket
+}
+
+func TestCoalescingTestSuite(t *testing.T) {
+	suite.Run(t, new(CoalescingTest))
+}
+
+func (t *CoalescingTest) SetupTest() {
+	t.ctx = context.Background()
+	t.wrapped = &gatedBucket{
+		Bucket: fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{Hierarchical: true}),
+		gate:   make(chan struct{}),
+	}
+	cache := metadata.NewStatCacheBucketView(lru.NewCache(cfg.AverageSizeOfPositiveStatCacheEntry*100), "")
+	t.bucket = caching.NewFastStatBucket(primaryCacheTTL, cache, timeutil.RealClock(), t.wrapped, negativeCacheTTL)
+
+	_, err := storageutil.CreateObject(t.ctx, t.wrapped.Bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = t.wrapped.Bucket.CreateFolder(t.ctx, "dir/")
+	require.NoError(t.T(), err)
+}
+
+// Run f in n goroutines, opening the gate once they had time to join the
+// first call.
+func (t *CoalescingTest) runConcurrently(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			f(i)
+		}()
+	}
+	time.Sleep(joinDelay)
+	close(t.wrapped.gate)
+	wg.Wait()
+}
+
+func (t *CoalescingTest) TestConcurrentStatsShareOneCall() {
+	_, err := t.wrapped.Bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "bar",
+		Contents: strings.NewReader("taco"),
+		Metadata: map[string]string{"key": "value"},
+	})
+	require.NoError(t.T(), err)
+	results := make([]*gcs.MinObject, 4)
+
+	t.runConcurrently(len(results), func(i int) {
+		m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "bar"})
+		assert.NoError(t.T(), err)
+		results[i] = m
+	})
+
+	assert.EqualValues(t.T(), 1, t.wrapped.calls.Load())
+	for _, m := range results[1:] {
+		assert.Equal(t.T(), *results[0], *m)
+		assert.NotSame(t.T(), results[0], m)
+	}
+	// Modifying the metadata of one record leaves the others alone.
+	results[0].Metadata["key"] = "other"
+	assert.Equal(t.T(), "value", results[1].Metadata["key"])
+}
+
+func (t *CoalescingTest) TestConcurrentForcedStatsDontShareCalls() {
+	t.runConcurrently(4, func(i int) {
+		_, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo", ForceFetchFromGcs: true})
+		assert.NoError(t.T(), err)
+	})
+
+	assert.EqualValues(t.T(), 4, t.wrapped.calls.Load())
+}
+
+func (t *CoalescingTest) TestConcurrentGetFoldersShareOneCall() {
+	results := make([]*gcs.Folder, 4)
+
+	t.runConcurrently(len(results), func(i int) {
+		f, err := t.bucket.GetFolder(t.ctx, "dir/")
+		assert.NoError(t.T(), err)
+		results[i] = f
+	})
+
+	assert.EqualValues(t.T(), 1, t.wrapped.calls.Load())
+	for _, f := range results {
+		assert.Equal(t.T(), "dir/", f.Name)
+	}
+}
+
+func (t *CoalescingTest) TestConcurrentListingsShareOneCall() {
+	results := make([]*gcs.Listing, 4)
+
+	t.runConcurrently(len(results), func(i int) {
+		listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Delimiter: "/"})
+		assert.NoError(t.T(), err)
+		results[i] = listing
+	})
+
+	assert.EqualValues(t.T(), 1, t.wrapped.calls.Load())
+	for _, listing := range results {
+		require.Len(t.T(), listing.MinObjects, 1)
+		assert.Equal(t.T(), "foo", listing.MinObjects[0].Name)
+	}
+	// Modifying one listing, or the objects in it, leaves the others alone.
+	results[0].MinObjects[0].Name = "bar"
+	results[0].MinObjects[0] = nil
+	assert.Equal(t.T(), "foo", results[1].MinObjects[0].Name)
+}
+
+func (t *CoalescingTest) TestDifferentListingsDontShareCalls() {
+	t.runConcurrently(2, func(i int) {
+		_, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Delimiter: "/", MaxResults: i + 1})
+		assert.NoError(t.T(), err)
+	})
+
+	assert.EqualValues(t.T(), 2, t.wrapped.calls.Load())
+}
+
+func (t *CoalescingTest) TestCallsAfterModificationDontShareEarlierCall() {
+	var wg sync.WaitGroup
+	list := func() {
+		defer wg.Done()
+		_, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{})
+		assert.NoError(t.T(), err)
+	}
+	wg.Add(1)
+	go list()
+	require.Eventually(t.T(), func() bool { return t.wrapped.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("burrito"))
+	require.NoError(t.T(), err)
+	wg.Add(1)
+	go list()
+	time.Sleep(joinDelay)
+	close(t.wrapped.gate)
+	wg.Wait()
+
+	assert.EqualValues(t.T(), 2, t.wrapped.calls.Load())
+}
+
+func (t *CoalescingTest) TestCancelledCallerStopsWaiting() {
+	ctx, cancel := context.WithCancel(t.ctx)
+	errs := make(chan error, 1)
+	go func() {
+		_, err := t.bucket.ListObjects(ctx, &gcs.ListObjectsRequest{})
+		errs <- err
+	}()
+	require.Eventually(t.T(), func() bool { return t.wrapped.calls.Load() == 1 }, time.Second, time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t.T(), <-errs, context.Canceled)
+
+	// The call goes on for the callers that joined it.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{})
+		assert.NoError(t.T(), err)
+		assert.NotNil(t.T(), listing)
+	}()
+	time.Sleep(joinDelay)
+	close(t.wrapped.gate)
+	<-done
+
+	assert.EqualValues(t.T(), 1, t.wrapped.calls.Load())
+}
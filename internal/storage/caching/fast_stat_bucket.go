@@ -15,17 +15,21 @@
 package caching
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"maps"
+	"slices"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/cache/metadata"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
 	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
-	"golang.org/x/net/context"
+	"golang.org/x/sync/singleflight"
 
 	"github.com/jacobsa/timeutil"
 )
@@ -33,6 +37,9 @@ import (
 // Create a bucket that caches object records returned by the supplied wrapped
 // bucket. Records are invalidated when modifications are made through this
 // bucket, and after the supplied TTL.
+//
+// Concurrent identical calls to StatObject, GetFolder and ListObjects that miss
+// the cache share a single call to the wrapped bucket and its result.
 func NewFastStatBucket(
 	primaryCacheTTL time.Duration,
 	cache metadata.StatCache,
@@ -73,6 +80,18 @@ type fastStatBucket struct {
 	primaryCacheTTL time.Duration
 	// TTL for entries for non-existing files and folders in the cache.
 	negativeCacheTTL time.Duration
+
+	/////////////////////////
+	// Mutable state
+	/////////////////////////
+
+	// The calls to the wrapped bucket in flight, keyed by the epoch in which
+	// they started and their request.
+	flights singleflight.Group
+
+	// Advanced after every modification made through this bucket, so that
+	// calls made after it don't share the result of a call started before it.
+	epoch atomic.Uint64
 }
 
 ////////////////////////////////////////////////////////////////////////
@@ -199,6 +218,36 @@ func (b *fastStatBucket) lookUpFolder(name string) (bool, *gcs.Folder) {
 	return hit, f
 }
 
+// Called once a modification made through this bucket is done, successful or
+// not.
+func (b *fastStatBucket) modified() {
+	b.epoch.Add(1)
+}
+
+// Call f, unless an identical call started since the last modification is in
+// flight, in which case wait for its result instead. The result is shared
+// when the call is.
+//
+// As it may be shared, the call isn't cancelled along with the context of the
+// caller that started it; callers whose context is cancelled stop waiting
+// instead.
+func (b *fastStatBucket) coalesce(
+	ctx context.Context,
+	key string,
+	f func(ctx context.Context) (any, error)) (v any, shared bool, err error) {
+	key = fmt.Sprintf("%d/%s", b.epoch.Load(), key)
+	ch := b.flights.DoChan(key, func() (any, error) {
+		return f(context.WithoutCancel(ctx))
+	})
+
+	select {
+	case r := <-ch:
+		return r.Val, r.Shared, r.Err
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Bucket interface
 ////////////////////////////////////////////////////////////////////////
@@ -229,6 +278,8 @@ func (b *fastStatBucket) NewReaderWithReadHandle(
 func (b *fastStatBucket) CreateObject(
 	ctx context.Context,
 	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	defer b.modified()
+
 	// Throw away any existing record for this object.
 	b.invalidate(req.Name)
 
@@ -248,7 +299,18 @@ func (b *fastStatBucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.C
 	return b.wrapped.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
 }
 
+func (b *fastStatBucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	defer b.modified()
+
+	// Flushes grow the object behind our back, so forget what we know about it.
+	b.invalidate(req.Name)
+
+	return b.wrapped.CreateAppendableObjectWriter(ctx, req, chunkSize, callBack)
+}
+
 func (b *fastStatBucket) FinalizeUpload(ctx context.Context, writer gcs.Writer) (*gcs.MinObject, error) {
+	defer b.modified()
+
 	name := writer.ObjectName()
 	// Throw away any existing record for this object.
 	b.invalidate(name)
@@ -267,6 +329,8 @@ func (b *fastStatBucket) FinalizeUpload(ctx context.Context, writer gcs.Writer)
 func (b *fastStatBucket) CopyObject(
 	ctx context.Context,
 	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	defer b.modified()
+
 	// Throw away any existing record for the destination name.
 	b.invalidate(req.DstName)
 
@@ -286,6 +350,8 @@ func (b *fastStatBucket) CopyObject(
 func (b *fastStatBucket) ComposeObjects(
 	ctx context.Context,
 	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	defer b.modified()
+
 	// Throw away any existing record for the destination name.
 	b.invalidate(req.DstName)
 
@@ -339,6 +405,33 @@ func (b *fastStatBucket) StatObject(
 
 // LOCKS_EXCLUDED(b.mu)
 func (b *fastStatBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	v, shared, err := b.coalesce(ctx, fmt.Sprintf("list/%#v", *req), func(ctx context.Context) (any, error) {
+		return b.listObjectsFromGcs(ctx, req)
+	})
+	if err != nil {
+		return
+	}
+
+	listing = v.(*gcs.Listing)
+	if shared {
+		// Callers may modify the listing they get, and the objects in it.
+		minObjects := make([]*gcs.MinObject, len(listing.MinObjects))
+		for i, m := range listing.MinObjects {
+			minObjects[i] = cloneMinObject(m)
+		}
+		listing = &gcs.Listing{
+			MinObjects:        minObjects,
+			CollapsedRuns:     slices.Clone(listing.CollapsedRuns),
+			ContinuationToken: listing.ContinuationToken,
+			Lifetimes:         slices.Clone(listing.Lifetimes),
+		}
+	}
+	return
+}
+
+func (b *fastStatBucket) listObjectsFromGcs(
 	ctx context.Context,
 	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
 	// Fetch the listing.
@@ -347,6 +440,11 @@ func (b *fastStatBucket) ListObjects(
 		return
 	}
 
+	// Noncurrent generations must not shadow the live ones.
+	if req.Versions {
+		return
+	}
+
 	if b.BucketType().Hierarchical {
 		b.insertHierarchicalListing(listing)
 		return
@@ -361,6 +459,8 @@ func (b *fastStatBucket) ListObjects(
 func (b *fastStatBucket) UpdateObject(
 	ctx context.Context,
 	req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
+	defer b.modified()
+
 	// Throw away any existing record for this object.
 	b.invalidate(req.Name)
 
@@ -380,6 +480,8 @@ func (b *fastStatBucket) UpdateObject(
 func (b *fastStatBucket) DeleteObject(
 	ctx context.Context,
 	req *gcs.DeleteObjectRequest) (err error) {
+	defer b.modified()
+
 	err = b.wrapped.DeleteObject(ctx, req)
 	if err != nil {
 		b.invalidate(req.Name)
@@ -390,6 +492,8 @@ func (b *fastStatBucket) DeleteObject(
 }
 
 func (b *fastStatBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	defer b.modified()
+
 	// Throw away any existing record for the source and destination name.
 	b.invalidate(req.SrcName)
 	b.invalidate(req.DstName)
@@ -407,6 +511,8 @@ func (b *fastStatBucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequ
 }
 
 func (b *fastStatBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	defer b.modified()
+
 	err := b.wrapped.DeleteFolder(ctx, folderName)
 	// In case of an error; invalidate the cached entry. This will make sure that
 	// gcsfuse is not caching possibly erroneous status of the folder and next
@@ -419,23 +525,80 @@ func (b *fastStatBucket) DeleteFolder(ctx context.Context, folderName string) er
 	return err
 }
 
+// statResult is the result of a call to StatObject of the wrapped bucket.
+type statResult struct {
+	m *gcs.MinObject
+	e *gcs.ExtendedObjectAttributes
+}
+
 func (b *fastStatBucket) StatObjectFromGcs(ctx context.Context,
 	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
-	m, e, err = b.wrapped.StatObject(ctx, req)
+	// Forced fetches must see the object as it is once they start, which a
+	// call already in flight may not, so they aren't coalesced.
+	if req.ForceFetchFromGcs {
+		var r statResult
+		r, err = b.statObjectFromGcs(ctx, req)
+		return r.m, r.e, err
+	}
+
+	v, shared, err := b.coalesce(ctx, "stat/"+req.Name, func(ctx context.Context) (any, error) {
+		return b.statObjectFromGcs(ctx, req)
+	})
+	if err != nil {
+		return
+	}
+
+	// Only forced fetches return extended attributes.
+	m = v.(statResult).m
+	if shared {
+		// Callers may modify the records they get.
+		m = cloneMinObject(m)
+	}
+	return
+}
+
+func (b *fastStatBucket) statObjectFromGcs(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (statResult, error) {
+	m, e, err := b.wrapped.StatObject(ctx, req)
 	if err != nil {
 		// Special case: NotFoundError -> negative entry.
 		if _, ok := err.(*gcs.NotFoundError); ok {
 			b.addNegativeEntry(req.Name)
 		}
 
-		return
+		return statResult{}, err
 	}
 
 	// Put the object in cache.
 	o := storageutil.ConvertMinObjectToObject(m)
 	b.insert(o)
 
-	return
+	return statResult{m: m, e: e}, nil
+}
+
+// Return a copy of the record which shares no memory with it, or nil if it is.
+func cloneMinObject(m *gcs.MinObject) *gcs.MinObject {
+	if m == nil {
+		return nil
+	}
+
+	c := *m
+	c.Metadata = maps.Clone(m.Metadata)
+	if m.CRC32C != nil {
+		crc := *m.CRC32C
+		c.CRC32C = &crc
+	}
+	return &c
+}
+
+// Return a pointer to a shallow copy of *p, or nil if p is.
+func ptrToCopy[T any](p *T) *T {
+	if p == nil {
+		return nil
+	}
+	c := *p
+	return &c
 }
 
 func (b *fastStatBucket) GetFolder(ctx context.Context, prefix string) (*gcs.Folder, error) {
@@ -457,21 +620,34 @@ func (b *fastStatBucket) GetFolder(ctx context.Context, prefix string) (*gcs.Fol
 }
 
 func (b *fastStatBucket) getFolderFromGCS(ctx context.Context, prefix string) (*gcs.Folder, error) {
-	f, err := b.wrapped.GetFolder(ctx, prefix)
+	v, shared, err := b.coalesce(ctx, "folder/"+prefix, func(ctx context.Context) (any, error) {
+		f, err := b.wrapped.GetFolder(ctx, prefix)
 
-	if err == nil {
-		b.insertFolder(f)
-		return f, nil
+		if err == nil {
+			b.insertFolder(f)
+			return f, nil
+		}
+
+		// Special case: NotFoundError -> negative entry.
+		if _, ok := err.(*gcs.NotFoundError); ok {
+			b.addNegativeEntryForFolder(prefix)
+		}
+		return nil, err
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	// Special case: NotFoundError -> negative entry.
-	if _, ok := err.(*gcs.NotFoundError); ok {
-		b.addNegativeEntryForFolder(prefix)
+	f := v.(*gcs.Folder)
+	if shared {
+		f = ptrToCopy(f)
 	}
-	return nil, err
+	return f, nil
 }
 
 func (b *fastStatBucket) CreateFolder(ctx context.Context, folderName string) (f *gcs.Folder, err error) {
+	defer b.modified()
+
 	// Throw away any existing record for this folder.
 	b.invalidate(folderName)
 
@@ -487,6 +663,8 @@ func (b *fastStatBucket) CreateFolder(ctx context.Context, folderName string) (f
 }
 
 func (b *fastStatBucket) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (*gcs.Folder, error) {
+	defer b.modified()
+
 	f, err := b.wrapped.RenameFolder(ctx, folderName, destinationFolderId)
 	if err != nil {
 		return nil, err
@@ -16,6 +16,7 @@ package fake
 
 import (
 	"bytes"
+	"cloud.google.com/go/storage"
 	"context"
 	"crypto/md5"
 	"encoding/hex"
@@ -44,6 +45,22 @@ func NewFakeBucket(clock timeutil.Clock, name string, bucketType gcs.BucketType)
 	return b
 }
 
+// NewFakeBucketWithVersioning is like NewFakeBucket, but the returned bucket
+// has object versioning enabled: replaced and deleted generations are kept as
+// noncurrent generations, which can still be read and are listed by versioned
+// listings.
+func NewFakeBucketWithVersioning(clock timeutil.Clock, name string, bucketType gcs.BucketType) gcs.Bucket {
+	b := &bucket{
+		clock:      clock,
+		name:       name,
+		bucketType: bucketType,
+		versioning: true,
+		noncurrent: make(map[string]fakeObjectSlice),
+	}
+	b.mu = syncutil.NewInvariantMutex(b.checkInvariants)
+	return b
+}
+
 ////////////////////////////////////////////////////////////////////////
 // Helper types
 ////////////////////////////////////////////////////////////////////////
@@ -51,6 +68,12 @@ func NewFakeBucket(clock timeutil.Clock, name string, bucketType gcs.BucketType)
 type fakeObject struct {
 	metadata gcs.Object
 	data     []byte
+
+	// The creation time of the generation.
+	created time.Time
+
+	// True for appendable objects which were flushed but not finalized yet.
+	unfinalized bool
 }
 
 // A slice of objects compared by name.
@@ -178,6 +201,13 @@ type bucket struct {
 	//
 	// INVARIANT: This is an upper bound for generation numbers in objects.
 	prevGeneration int64 // GUARDED_BY(mu)
+
+	// Whether object versioning is enabled.
+	versioning bool
+
+	// The noncurrent generations of objects by name, in increasing order of
+	// generation. Only used if versioning is enabled.
+	noncurrent map[string]fakeObjectSlice // GUARDED_BY(mu)
 }
 
 func checkName(name string) (err error) {
@@ -228,6 +258,35 @@ func (b *bucket) checkInvariants() {
 	}
 }
 
+// Keep the object at the given index within b.objects as a noncurrent
+// generation, if versioning is enabled. Must be called before the object is
+// replaced or removed.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) archiveLocked(index int) {
+	if !b.versioning {
+		return
+	}
+
+	o := b.objects[index]
+	o.metadata.Deleted = b.clock.Now()
+	b.noncurrent[o.metadata.Name] = append(b.noncurrent[o.metadata.Name], o)
+}
+
+// Find the noncurrent generation of the named object, returning false if
+// there is no such generation.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) findNoncurrentLocked(name string, generation int64) (o fakeObject, ok bool) {
+	for _, nc := range b.noncurrent[name] {
+		if nc.metadata.Generation == generation {
+			return nc, true
+		}
+	}
+
+	return
+}
+
 // Create an object struct for the given attributes and contents.
 //
 // LOCKS_REQUIRED(b.mu)
@@ -237,6 +296,11 @@ func (b *bucket) mintObject(
 	md5Sum := md5.Sum(contents)
 	crc32c := crc32.Checksum(contents, crc32cTable)
 
+	storageClass := req.StorageClass
+	if storageClass == "" {
+		storageClass = "STANDARD"
+	}
+
 	// Set up basic info.
 	b.prevGeneration++
 	o.metadata = gcs.Object{
@@ -254,12 +318,13 @@ func (b *bucket) mintObject(
 		Metadata:        copyMetadata(req.Metadata),
 		Generation:      b.prevGeneration,
 		MetaGeneration:  1,
-		StorageClass:    "STANDARD",
+		StorageClass:    storageClass,
 		Updated:         b.clock.Now(),
 	}
 
 	// Set up data.
 	o.data = contents
+	o.created = o.metadata.Updated
 
 	return
 }
@@ -399,6 +464,7 @@ func createOrUpdateFakeObject(b *bucket, req *gcs.CreateObjectRequest, contents
 	// Replace an entry in or add an entry to our list of objects.
 	existingIndex := b.objects.find(req.Name)
 	if existingIndex < len(b.objects) {
+		b.archiveLocked(existingIndex)
 		b.objects[existingIndex] = fo
 	} else {
 		b.objects = append(b.objects, fo)
@@ -432,29 +498,33 @@ func (b *bucket) createObjectLocked(
 	return createOrUpdateFakeObject(b, req, contents)
 }
 
-// Create a reader based on the supplied request, also returning the index
-// within b.objects of the entry for the requested generation.
+// Create a reader based on the supplied request, also returning the entry for
+// the requested generation.
 //
 // LOCKS_REQUIRED(b.mu)
 func (b *bucket) newReaderLocked(
-	req *gcs.ReadObjectRequest) (r io.Reader, index int, err error) {
+	req *gcs.ReadObjectRequest) (r io.Reader, o fakeObject, err error) {
 	// Find the object with the requested name.
-	index = b.objects.find(req.Name)
-	if index == len(b.objects) {
-		err = &gcs.NotFoundError{
-			Err: fmt.Errorf("object %s not found", req.Name),
-		}
-
-		return
+	index := b.objects.find(req.Name)
+	live := index < len(b.objects)
+	if live {
+		o = b.objects[index]
 	}
 
-	o := b.objects[index]
+	// Does the generation match? If not, it may be a noncurrent generation.
+	if req.Generation != 0 && (!live || req.Generation != o.metadata.Generation) {
+		var ok bool
+		if o, ok = b.findNoncurrentLocked(req.Name, req.Generation); !ok {
+			err = &gcs.NotFoundError{
+				Err: fmt.Errorf(
+					"object %s generation %v not found", req.Name, req.Generation),
+			}
 
-	// Does the generation match?
-	if req.Generation != 0 && req.Generation != o.metadata.Generation {
+			return
+		}
+	} else if !live {
 		err = &gcs.NotFoundError{
-			Err: fmt.Errorf(
-				"object %s generation %v not found", req.Name, req.Generation),
+			Err: fmt.Errorf("object %s not found", req.Name),
 		}
 
 		return
@@ -558,6 +628,14 @@ func (b *bucket) ListObjects(
 		maxResults = 1000
 	}
 
+	// Versioned listings include the noncurrent generations, and aren't
+	// paginated.
+	objects := b.objects
+	if req.Versions {
+		objects = b.versionedObjectsLocked()
+		maxResults = len(objects) + 1
+	}
+
 	// Find where in the space of object names to start.
 	nameStart := req.Prefix
 	if req.ContinuationToken != "" && req.ContinuationToken > nameStart {
@@ -565,14 +643,14 @@ func (b *bucket) ListObjects(
 	}
 
 	// Find the range of indexes within the array to scan.
-	indexStart := b.objects.lowerBound(nameStart)
-	prefixLimit := b.objects.prefixUpperBound(req.Prefix)
+	indexStart := objects.lowerBound(nameStart)
+	prefixLimit := objects.prefixUpperBound(req.Prefix)
 	indexLimit := minInt(indexStart+maxResults, prefixLimit)
 
 	// Scan the array.
 	var lastResultWasPrefix bool
 	for i := indexStart; i < indexLimit; i++ {
-		var o fakeObject = b.objects[i]
+		var o fakeObject = objects[i]
 		name := o.metadata.Name
 
 		// Search for a delimiter if necessary.
@@ -628,6 +706,14 @@ func (b *bucket) ListObjects(
 		// Otherwise, return as an object result. Make a copy to avoid handing back
 		// internal state.
 		listing.MinObjects = append(listing.MinObjects, copyMinObject(&o.metadata))
+		if req.Versions {
+			listing.Lifetimes = append(listing.Lifetimes, gcs.GenerationLifetime{
+				Name:       name,
+				Generation: o.metadata.Generation,
+				Created:    o.created,
+				Deleted:    o.metadata.Deleted,
+			})
+		}
 	}
 
 	// Set up a cursor for where to start the next scan if we didn't exhaust the
@@ -651,13 +737,33 @@ func (b *bucket) ListObjects(
 			}
 		} else {
 			// Otherwise, we'll start scanning at the next object.
-			listing.ContinuationToken = b.objects[indexLimit].metadata.Name
+			listing.ContinuationToken = objects[indexLimit].metadata.Name
 		}
 	}
 
 	return
 }
 
+// Return all the generations of all objects, sorted by name and then by
+// generation.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) versionedObjectsLocked() (objects fakeObjectSlice) {
+	objects = append(objects, b.objects...)
+	for _, generations := range b.noncurrent {
+		objects = append(objects, generations...)
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		x, y := objects[i].metadata, objects[j].metadata
+		if x.Name != y.Name {
+			return x.Name < y.Name
+		}
+		return x.Generation < y.Generation
+	})
+	return
+}
+
 // LOCKS_EXCLUDED(b.mu)
 func (b *bucket) NewReader(
 	ctx context.Context,
@@ -696,10 +802,20 @@ func (b *bucket) NewReaderWithReadHandle(ctx context.Context, req *gcs.ReadObjec
 func (b *bucket) CreateObject(
 	ctx context.Context,
 	req *gcs.CreateObjectRequest) (o *gcs.Object, err error) {
+	// Read the contents before locking, since they may be produced by reading
+	// other objects from the bucket.
+	contents, err := io.ReadAll(req.Contents)
+	if err != nil {
+		err = fmt.Errorf("ReadAll: %v", err)
+		return
+	}
+	mReq := *req
+	mReq.Contents = bytes.NewReader(contents)
+
 	b.mu.Lock()
 	defer b.mu.Unlock()
 
-	o, err = b.createObjectLocked(req)
+	o, err = b.createObjectLocked(&mReq)
 	return
 }
 
@@ -707,6 +823,42 @@ func (b *bucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObj
 	return NewFakeObjectWriter(b, req)
 }
 
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, _ int, _ func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if !b.BucketType().Zonal {
+		return nil, fmt.Errorf("appending to object %q: appendable objects are only supported in zonal buckets", req.Name)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	index := b.objects.find(req.Name)
+	if index == len(b.objects) || b.objects[index].metadata.Generation != req.Generation {
+		return nil, &gcs.NotFoundError{
+			Err: fmt.Errorf("object %q generation %d not found", req.Name, req.Generation),
+		}
+	}
+
+	o := b.objects[index]
+	if !o.unfinalized {
+		return nil, &gcs.PreconditionError{Err: fmt.Errorf("object %q is finalized", req.Name)}
+	}
+	if int64(len(o.data)) != req.Offset {
+		return nil, &gcs.PreconditionError{
+			Err: fmt.Errorf("object %q has size %d, expected %d", req.Name, len(o.data), req.Offset),
+		}
+	}
+
+	w := &FakeObjectWriter{
+		bkt:         b,
+		req:         &gcs.CreateObjectRequest{Name: req.Name},
+		ObjectAttrs: storage.ObjectAttrs{Name: req.Name},
+		generation:  req.Generation,
+	}
+	w.buf.Write(o.data)
+	return w, nil
+}
+
 func (b *bucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
@@ -777,6 +929,7 @@ func (b *bucket) CopyObject(
 	dst := b.objects[srcIndex]
 	dst.metadata.Name = req.DstName
 	dst.metadata.MediaLink = "http://localhost/download/storage/fake/" + req.DstName
+	dst.created = b.clock.Now()
 
 	b.prevGeneration++
 	dst.metadata.Generation = b.prevGeneration
@@ -784,6 +937,7 @@ func (b *bucket) CopyObject(
 	// Insert into our array.
 	existingIndex := b.objects.find(req.DstName)
 	if existingIndex < len(b.objects) {
+		b.archiveLocked(existingIndex)
 		b.objects[existingIndex] = dst
 	} else {
 		b.objects = append(b.objects, dst)
@@ -819,9 +973,9 @@ func (b *bucket) ComposeObjects(
 
 	for _, src := range req.Sources {
 		var r io.Reader
-		var srcIndex int
+		var srcObject fakeObject
 
-		r, srcIndex, err = b.newReaderLocked(&gcs.ReadObjectRequest{
+		r, srcObject, err = b.newReaderLocked(&gcs.ReadObjectRequest{
 			Name:       src.Name,
 			Generation: src.Generation,
 		})
@@ -831,7 +985,7 @@ func (b *bucket) ComposeObjects(
 		}
 
 		srcReaders = append(srcReaders, r)
-		dstComponentCount += b.objects[srcIndex].metadata.ComponentCount
+		dstComponentCount += srcObject.metadata.ComponentCount
 	}
 
 	// GCS doesn't like the component count to go too high.
@@ -848,6 +1002,14 @@ func (b *bucket) ComposeObjects(
 		Contents:                   io.MultiReader(srcReaders...),
 		ContentType:                req.ContentType,
 		Metadata:                   req.Metadata,
+		ContentLanguage:            req.ContentLanguage,
+		ContentEncoding:            req.ContentEncoding,
+		CacheControl:               req.CacheControl,
+		ContentDisposition:         req.ContentDisposition,
+		CustomTime:                 req.CustomTime,
+		EventBasedHold:             req.EventBasedHold,
+		StorageClass:               req.StorageClass,
+		Acl:                        req.Acl,
 	}
 
 	_, err = b.createObjectLocked(createReq)
@@ -994,13 +1156,17 @@ func (b *bucket) DeleteObject(
 
 	// Do we possess the object with the given name?
 	index := b.objects.find(req.Name)
-	if index == len(b.objects) {
+	live := index < len(b.objects)
+
+	// A specific generation other than the live one can only be a noncurrent
+	// generation, which is deleted permanently.
+	if req.Generation != 0 &&
+		(!live || b.objects[index].metadata.Generation != req.Generation) {
+		b.deleteNoncurrentLocked(req.Name, req.Generation)
 		return
 	}
 
-	// Don't do anything if the generation is wrong.
-	if req.Generation != 0 &&
-		b.objects[index].metadata.Generation != req.Generation {
+	if !live {
 		return
 	}
 
@@ -1019,12 +1185,27 @@ func (b *bucket) DeleteObject(
 		}
 	}
 
-	// Remove the object.
+	// Remove the object. Deleting the live generation by its generation number
+	// deletes it permanently, as in GCS.
+	if req.Generation == 0 {
+		b.archiveLocked(index)
+	}
 	b.objects = append(b.objects[:index], b.objects[index+1:]...)
 
 	return
 }
 
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) deleteNoncurrentLocked(name string, generation int64) {
+	generations := b.noncurrent[name]
+	for i, o := range generations {
+		if o.metadata.Generation == generation {
+			b.noncurrent[name] = append(generations[:i:i], generations[i+1:]...)
+			return
+		}
+	}
+}
+
 func (b *bucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
 	// Check that the destination name is legal.
 	err := checkName(req.DstName)
@@ -1066,15 +1247,18 @@ func (b *bucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*g
 	dst := b.objects[srcIndex]
 	dst.metadata.Name = req.DstName
 	dst.metadata.MediaLink = "http://localhost/download/storage/fake/" + req.DstName
+	dst.created = b.clock.Now()
 
 	b.prevGeneration++
 	dst.metadata.Generation = b.prevGeneration
 
 	// Remove the source object.
+	b.archiveLocked(srcIndex)
 	b.objects = append(b.objects[:srcIndex], b.objects[srcIndex+1:]...)
 	// Insert dest object into our array.
 	existingIndex := b.objects.find(req.DstName)
 	if existingIndex < len(b.objects) {
+		b.archiveLocked(existingIndex)
 		b.objects[existingIndex] = dst
 	} else {
 		b.objects = append(b.objects, dst)
@@ -21,6 +21,10 @@ package fake
 
 import (
 	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash/crc32"
 	"io"
 
 	"cloud.google.com/go/storage"
@@ -36,26 +40,85 @@ type FakeObjectWriter struct {
 	bkt    *bucket
 	req    *gcs.CreateObjectRequest
 	Object *gcs.MinObject // Object created by writer
+
+	// The generation of the appendable object the writer appends to, once it
+	// was created by a flush or taken over, or zero.
+	generation int64
 }
 
 func (w *FakeObjectWriter) Write(p []byte) (n int, err error) {
 	return w.buf.Write(p)
 }
 
+// LOCKS_REQUIRED(w.bkt.mu)
 func (w *FakeObjectWriter) Close() error {
-	contents := w.buf.Bytes()
+	return w.storeLocked(true)
+}
+
+// Flush stores the contents written so far as an unfinalized appendable
+// object.
+//
+// LOCKS_EXCLUDED(w.bkt.mu)
+func (w *FakeObjectWriter) Flush() (int64, error) {
+	if !w.bkt.BucketType().Zonal {
+		return 0, errors.New("flush is only supported for appendable objects in zonal buckets")
+	}
 
-	// Validate for preconditions.
-	if err := preconditionChecks(w.bkt, w.req, contents); err != nil {
-		return err
+	w.bkt.mu.Lock()
+	defer w.bkt.mu.Unlock()
+	if err := w.storeLocked(false); err != nil {
+		return 0, err
 	}
+	return int64(w.buf.Len()), nil
+}
 
-	o, err := createOrUpdateFakeObject(w.bkt, w.req, contents)
-	if err == nil {
+// Store the contents written so far, creating the object unless it was
+// already created by a flush or taken over, in which case the contents of
+// that generation are replaced. The object is left unfinalized unless
+// finalize is set.
+//
+// LOCKS_REQUIRED(w.bkt.mu)
+func (w *FakeObjectWriter) storeLocked(finalize bool) error {
+	contents := bytes.Clone(w.buf.Bytes())
+
+	if w.generation == 0 {
+		// Validate for preconditions.
+		if err := preconditionChecks(w.bkt, w.req, contents); err != nil {
+			return err
+		}
+
+		o, err := createOrUpdateFakeObject(w.bkt, w.req, contents)
+		if err != nil {
+			return err
+		}
 		w.Object = storageutil.ConvertObjToMinObject(o)
+		if !finalize {
+			w.generation = o.Generation
+			w.bkt.objects[w.bkt.objects.find(o.Name)].unfinalized = true
+		}
+		return nil
+	}
+
+	index := w.bkt.objects.find(w.req.Name)
+	if index == len(w.bkt.objects) ||
+		w.bkt.objects[index].metadata.Generation != w.generation ||
+		!w.bkt.objects[index].unfinalized {
+		return &gcs.PreconditionError{
+			Err: fmt.Errorf("object %q generation %d was replaced or finalized", w.req.Name, w.generation),
+		}
 	}
 
-	return err
+	o := &w.bkt.objects[index]
+	md5Sum := md5.Sum(contents)
+	crc32c := crc32.Checksum(contents, crc32cTable)
+	o.data = contents
+	o.metadata.Size = uint64(len(contents))
+	o.metadata.MD5 = &md5Sum
+	o.metadata.CRC32C = &crc32c
+	o.metadata.Updated = w.bkt.clock.Now()
+	o.unfinalized = !finalize
+	w.Object = storageutil.ConvertObjToMinObject(copyObject(&o.metadata))
+	return nil
 }
 
 func (w *FakeObjectWriter) ObjectName() string {
@@ -0,0 +1,142 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fake
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type VersioningTest struct {
+	suite.Suite
+	ctx    context.Context
+	bucket gcs.Bucket
+}
+
+func TestVersioningTestSuite(t *testing.T) {
+	suite.Run(t, new(VersioningTest))
+}
+
+func (t *VersioningTest) SetupTest() {
+	t.ctx = context.Background()
+	t.bucket = NewFakeBucketWithVersioning(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+}
+
+func (t *VersioningTest) readGeneration(bucket gcs.Bucket, name string, generation int64) (string, error) {
+	rc, err := bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: name, Generation: generation})
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	contents, err := io.ReadAll(rc)
+	return string(contents), err
+}
+
+func (t *VersioningTest) listVersions() (names []string, generations []int64) {
+	objects, _, err := storageutil.ListAll(t.ctx, t.bucket, &gcs.ListObjectsRequest{Versions: true})
+	require.NoError(t.T(), err)
+	for _, o := range objects {
+		names = append(names, o.Name)
+		generations = append(generations, o.Generation)
+	}
+	return
+}
+
+func (t *VersioningTest) TestReplacedGenerationIsNoncurrent() {
+	old, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	live, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	contents, err := t.readGeneration(t.bucket, "foo", old.Generation)
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", contents)
+	names, generations := t.listVersions()
+	assert.Equal(t.T(), []string{"foo", "foo"}, names)
+	assert.Equal(t.T(), []int64{old.Generation, live.Generation}, generations)
+	// Listings without versions only include the live generation.
+	objects, _, err := storageutil.ListAll(t.ctx, t.bucket, &gcs.ListObjectsRequest{})
+	require.NoError(t.T(), err)
+	require.Len(t.T(), objects, 1)
+	assert.Equal(t.T(), live.Generation, objects[0].Generation)
+}
+
+func (t *VersioningTest) TestDeletedObjectIsNoncurrent() {
+	old, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+
+	err = t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo"})
+
+	require.NoError(t.T(), err)
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	var notFoundErr *gcs.NotFoundError
+	assert.ErrorAs(t.T(), err, &notFoundErr)
+	contents, err := t.readGeneration(t.bucket, "foo", old.Generation)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", contents)
+}
+
+func (t *VersioningTest) TestDeleteGenerationIsPermanent() {
+	old, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	live, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo", Generation: old.Generation}))
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo", Generation: live.Generation}))
+
+	names, _ := t.listVersions()
+	assert.Empty(t.T(), names)
+}
+
+func (t *VersioningTest) TestCopyAndMoveKeepReplacedGenerations() {
+	old, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "bar", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	_, err = t.bucket.CopyObject(t.ctx, &gcs.CopyObjectRequest{SrcName: "bar", DstName: "foo"})
+	require.NoError(t.T(), err)
+	_, err = t.bucket.MoveObject(t.ctx, &gcs.MoveObjectRequest{SrcName: "foo", DstName: "baz"})
+	require.NoError(t.T(), err)
+
+	names, _ := t.listVersions()
+	assert.Equal(t.T(), []string{"bar", "baz", "foo", "foo"}, names)
+	contents, err := t.readGeneration(t.bucket, "foo", old.Generation)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", contents)
+}
+
+func (t *VersioningTest) TestBucketWithoutVersioningForgetsReplacedGenerations() {
+	bucket := NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	old, err := storageutil.CreateObject(t.ctx, bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, bucket, "foo", []byte("burrito"))
+	require.NoError(t.T(), err)
+
+	_, err = t.readGeneration(bucket, "foo", old.Generation)
+
+	var notFoundErr *gcs.NotFoundError
+	assert.ErrorAs(t.T(), err, &notFoundErr)
+}
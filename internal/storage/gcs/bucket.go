@@ -43,6 +43,11 @@ type Writer interface {
 	io.WriteCloser
 	ObjectName() string
 	Attrs() *storage.ObjectAttrs
+
+	// Flush makes the contents written so far durable and readable without
+	// finalizing the object, returning its size. Only writers of appendable
+	// objects, in zonal buckets, support it.
+	Flush() (int64, error)
 }
 
 // Bucket represents a GCS bucket, pre-bound with a bucket name and necessary
@@ -105,6 +110,12 @@ type Bucket interface {
 	// writer is closed (object is finalised).
 	CreateObjectChunkWriter(ctx context.Context, req *CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (Writer, error)
 
+	// CreateAppendableObjectWriter takes over an unfinalized appendable object,
+	// returning a writer which appends to it from its current size. It fails
+	// with *PreconditionError if the size isn't req.Offset. Only zonal buckets
+	// support it. The object is finalized when the writer is.
+	CreateAppendableObjectWriter(ctx context.Context, req *AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (Writer, error)
+
 	// FinalizeUpload closes the storage.Writer which completes the write
 	// operation and creates an object on GCS.
 	FinalizeUpload(ctx context.Context, writer Writer) (*MinObject, error)
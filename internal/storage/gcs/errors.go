@@ -35,3 +35,14 @@ type PreconditionError struct {
 func (pe *PreconditionError) Error() string {
 	return fmt.Sprintf("gcs.PreconditionError: %v", pe.Err)
 }
+
+// An *EncryptionKeyError value is an error that indicates the object is
+// encrypted with a customer-supplied encryption key other than the one in use,
+// or with none when one is in use, or the other way around.
+type EncryptionKeyError struct {
+	Err error
+}
+
+func (eke *EncryptionKeyError) Error() string {
+	return fmt.Sprintf("gcs.EncryptionKeyError: %v", eke.Err)
+}
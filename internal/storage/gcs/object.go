@@ -91,6 +91,16 @@ type MinObject struct {
 	CRC32C          *uint32 // Missing for CMEK buckets
 }
 
+// GenerationLifetime holds when a generation of an object was created, and when
+// it became noncurrent, if it did. It is returned by listings with
+// ListObjectsRequest.Versions only, to keep MinObject small.
+type GenerationLifetime struct {
+	Name       string
+	Generation int64
+	Created    time.Time
+	Deleted    time.Time
+}
+
 // ExtendedObjectAttributes contains the missing attributes of Object which are not present in MinObject.
 type ExtendedObjectAttributes struct {
 	ContentType        string
@@ -55,6 +55,10 @@ type CreateObjectRequest struct {
 	StorageClass       string
 	Acl                []*storagev1.ObjectAccessControl
 
+	// If non-empty, the name of the Cloud KMS key used to encrypt the object
+	// instead of the bucket's default key.
+	KmsKeyName string
+
 	// ChunkTransferTimeout sets a per-chunk request timeout for resumable uploads.
 	//
 	// For resumable uploads, the Writer will terminate the request and attempt a retry
@@ -86,6 +90,21 @@ type CreateObjectRequest struct {
 	MetaGenerationPrecondition *int64
 }
 
+// A request to append to an unfinalized appendable object, accepted by
+// Bucket.CreateAppendableObjectWriter.
+type AppendObjectRequest struct {
+	// The name and generation of the object to append to. Both must be set.
+	Name       string
+	Generation int64
+
+	// The size of the object, from which the appends continue. Taking over the
+	// object fails if its size differs.
+	Offset int64
+
+	// See CreateObjectRequest.ChunkTransferTimeoutSecs.
+	ChunkTransferTimeoutSecs int64
+}
+
 // A request to copy an object to a new name, preserving all metadata.
 type CopyObjectRequest struct {
 	SrcName string
@@ -211,6 +230,12 @@ type ReadObjectRequest struct {
 
 	// ReadHandle associated with the object. This would be periodically refreshed.
 	ReadHandle []byte
+
+	// The record of the object, as returned by the bucket, if the caller has
+	// it. Wrappers which need its attributes to serve the read use it rather
+	// than looking the object up, if it is of the generation read. It isn't
+	// part of recorded requests.
+	Object *MinObject `json:"-"`
 }
 
 // A request to read the contents of an object at a particular generation.
@@ -224,6 +249,9 @@ type MultiRangeDownloaderRequest struct {
 	// If present, read the contents of the GCS object as it is on GCS.
 	// This might not be honoured by all the implementations.
 	ReadCompressed bool
+
+	// See ReadObjectRequest.Object.
+	Object *MinObject `json:"-"`
 }
 
 type StatObjectRequest struct {
@@ -314,6 +342,10 @@ type ListObjectsRequest struct {
 	// the current flow, default value will be full and callers can override it
 	// using this param.
 	ProjectionVal Projection
+
+	// List all the generations of the matching objects, including the
+	// noncurrent ones in buckets with object versioning enabled.
+	Versions bool
 }
 
 // Listing contains a set of objects and delimter-based collapsed runs returned
@@ -351,6 +383,10 @@ type Listing struct {
 	// and deleted concurrently with a single or multiple listing requests may or
 	// may not be returned.
 	ContinuationToken string
+
+	// The lifetimes of the generations in MinObjects, one for each. Only set by
+	// listings with ListObjectsRequest.Versions.
+	Lifetimes []GenerationLifetime
 }
 
 // A request to update the metadata of an object, accepted by
@@ -0,0 +1,947 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package local provides a gcs.Bucket backed by a directory of the local file
+// system, for running gcsfuse without GCS.
+//
+// The contents of each object are kept in the file at its name within the
+// directory, so that the tree can be inspected and modified with ordinary
+// tools, and every directory is a folder, as in a bucket with hierarchical
+// namespace. The attributes of objects, including their generations, are kept
+// in sidecar files under a hidden directory. Files without a sidecar, or
+// modified since it was written, are presented as objects with a generation
+// derived from their modification time.
+//
+// Names that can't be paths, such as those with empty or "." components,
+// can't be used. Noncurrent generations aren't kept. Only one process should
+// use a directory at a time.
+package local
+
+import (
+	"crypto/md5"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/net/context"
+)
+
+const (
+	// The directory within the bucket directory holding everything but the
+	// contents of objects. Object names within it are reserved.
+	metadataDirName = ".gcsfuse-local"
+
+	// The subdirectories of the metadata directory holding the sidecar files,
+	// at the names of their objects, and temporary files.
+	attrsDirName = "attrs"
+	tmpDirName   = "tmp"
+
+	// The suffixes of the sidecar files, and of the files holding the contents
+	// of objects whose names end in "/", which are folders in the bucket
+	// directory.
+	attrsSuffix    = ".attrs"
+	contentsSuffix = ".contents"
+
+	// The number of results of a listing when the request doesn't limit it, and
+	// the maximum, as in GCS.
+	maxListResults = 1000
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// DirFromEndpoint returns the directory of a custom endpoint of the form
+// file:///path, whose subdirectories are buckets.
+func DirFromEndpoint(endpoint string) (dir string, ok bool) {
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Scheme != "file" || u.Path == "" {
+		return "", false
+	}
+	return u.Path, true
+}
+
+// NewBucket returns a bucket with the given name backed by the directory,
+// which must exist.
+func NewBucket(dir string, name string) (gcs.Bucket, error) {
+	fi, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("bucket directory: %w", err)
+	}
+	if !fi.IsDir() {
+		return nil, fmt.Errorf("bucket directory %q is not a directory", dir)
+	}
+
+	b := &bucket{
+		name: name,
+		root: dir,
+	}
+
+	// Drop temporary files left behind by an earlier process.
+	if err = os.RemoveAll(b.tmpDir()); err != nil {
+		return nil, fmt.Errorf("removing temporary files: %w", err)
+	}
+	if err = os.MkdirAll(b.tmpDir(), 0755); err != nil {
+		return nil, fmt.Errorf("creating metadata directory: %w", err)
+	}
+
+	return b, nil
+}
+
+type bucket struct {
+	name string
+	root string
+
+	// Held while looking up and changing objects and folders. Reads of the
+	// contents of objects go on without it, from files opened while held,
+	// which aren't modified in place.
+	mu sync.Mutex
+
+	// The most recent generation number that was minted.
+	prevGeneration int64 // GUARDED_BY(mu)
+}
+
+// The sidecar of an object.
+type record struct {
+	Object gcs.Object
+
+	// The modification time of the contents when the sidecar was written, to
+	// detect contents modified since.
+	ModTime time.Time
+}
+
+func (b *bucket) tmpDir() string {
+	return filepath.Join(b.root, metadataDirName, tmpDirName)
+}
+
+// Return the path of the contents of the named object.
+func (b *bucket) contentsPath(name string) string {
+	if strings.HasSuffix(name, "/") {
+		return filepath.Join(b.root, metadataDirName, attrsDirName, filepath.FromSlash(name)+contentsSuffix)
+	}
+	return filepath.Join(b.root, filepath.FromSlash(name))
+}
+
+// Return the path of the sidecar of the named object.
+func (b *bucket) attrsPath(name string) string {
+	return filepath.Join(b.root, metadataDirName, attrsDirName, filepath.FromSlash(name)+attrsSuffix)
+}
+
+// Return the path of the named folder, which ends in "/".
+func (b *bucket) folderPath(name string) string {
+	return filepath.Join(b.root, filepath.FromSlash(name))
+}
+
+// Check that the name is valid for GCS and can be a path within the bucket
+// directory.
+func checkName(name string) error {
+	if len(name) == 0 || len(name) > 1024 {
+		return errors.New("invalid object name: length must be in [1, 1024]")
+	}
+	if !utf8.ValidString(name) {
+		return errors.New("invalid object name: not valid UTF-8")
+	}
+	if strings.ContainsAny(name, "\r\n\x00") {
+		return errors.New("invalid object name: must not contain CR, LF or NUL")
+	}
+
+	components := strings.Split(strings.TrimSuffix(name, "/"), "/")
+	if components[0] == metadataDirName {
+		return fmt.Errorf("invalid object name %q: %s is reserved", name, metadataDirName)
+	}
+	for _, c := range components {
+		if c == "" || c == "." || c == ".." || (filepath.Separator != '/' && strings.ContainsRune(c, filepath.Separator)) {
+			return fmt.Errorf("invalid object name %q: not a local path", name)
+		}
+	}
+	return nil
+}
+
+func isNotFound(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// Return a new generation number in microseconds, like those of GCS, greater
+// than any minted before, and usually than those of files modified earlier.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) mintGeneration() int64 {
+	b.prevGeneration = max(time.Now().UnixMicro(), b.prevGeneration+1)
+	return b.prevGeneration
+}
+
+// Return the current generation of the named object, or nil if there is none.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) lookUpLocked(name string) (*gcs.Object, error) {
+	fi, err := os.Stat(b.contentsPath(name))
+	if isNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !fi.Mode().IsRegular() {
+		return nil, nil
+	}
+
+	var r record
+	data, err := os.ReadFile(b.attrsPath(name))
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err = json.Unmarshal(data, &r); err != nil {
+			return nil, fmt.Errorf("sidecar of %q: %w", name, err)
+		}
+		if r.ModTime.Equal(fi.ModTime()) && r.Object.Size == uint64(fi.Size()) {
+			// Folders may have been renamed along with the sidecar.
+			r.Object.Name = name
+			return &r.Object, nil
+		}
+	}
+
+	// The contents were written or modified outside of the bucket.
+	return &gcs.Object{
+		Name:           name,
+		Size:           uint64(fi.Size()),
+		ComponentCount: 1,
+		Generation:     max(fi.ModTime().UnixMicro(), r.Object.Generation+1),
+		MetaGeneration: 1,
+		StorageClass:   "STANDARD",
+		Updated:        fi.ModTime(),
+	}, nil
+}
+
+// Return the current generation of the named object, which must exist and
+// have the given generation unless zero.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) findLocked(name string, generation int64) (*gcs.Object, error) {
+	o, err := b.lookUpLocked(name)
+	if err != nil {
+		return nil, err
+	}
+	if o == nil {
+		return nil, &gcs.NotFoundError{Err: fmt.Errorf("object %q not found", name)}
+	}
+	if generation != 0 && o.Generation != generation {
+		return nil, &gcs.NotFoundError{
+			Err: fmt.Errorf("object %q generation %d not found", name, generation),
+		}
+	}
+	return o, nil
+}
+
+// Check the preconditions of a request to create the named object.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) checkPreconditionsLocked(name string, generation *int64, metaGeneration *int64) error {
+	if generation == nil && metaGeneration == nil {
+		return nil
+	}
+
+	existing, err := b.lookUpLocked(name)
+	if err != nil {
+		return err
+	}
+
+	if generation != nil {
+		if *generation == 0 && existing != nil {
+			return &gcs.PreconditionError{Err: errors.New("precondition failed: object exists")}
+		}
+		if *generation > 0 {
+			if existing == nil {
+				return &gcs.PreconditionError{Err: errors.New("precondition failed: object doesn't exist")}
+			}
+			if existing.Generation != *generation {
+				return &gcs.PreconditionError{
+					Err: fmt.Errorf("precondition failed: object has generation %v", existing.Generation),
+				}
+			}
+		}
+	}
+
+	if metaGeneration != nil {
+		if existing == nil {
+			return &gcs.PreconditionError{Err: errors.New("precondition failed: object doesn't exist")}
+		}
+		if existing.MetaGeneration != *metaGeneration {
+			return &gcs.PreconditionError{
+				Err: fmt.Errorf("precondition failed: object has meta-generation %v", existing.MetaGeneration),
+			}
+		}
+	}
+
+	return nil
+}
+
+// Write the sidecar of the object, whose contents are in place.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) writeRecordLocked(o *gcs.Object) error {
+	fi, err := os.Stat(b.contentsPath(o.Name))
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(&record{Object: *o, ModTime: fi.ModTime()})
+	if err != nil {
+		return err
+	}
+	return b.installFileLocked(data, b.attrsPath(o.Name))
+}
+
+// Atomically replace the file at the path with the data.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) installFileLocked(data []byte, path string) error {
+	f, err := os.CreateTemp(b.tmpDir(), "attrs")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = b.renameLocked(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// Rename the file, creating the parent directories of the new path.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) renameLocked(oldPath string, newPath string) error {
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return err
+	}
+	return os.Rename(oldPath, newPath)
+}
+
+// Make the temporary file the contents of the object, and write its sidecar.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) installLocked(tmpPath string, o *gcs.Object) error {
+	contentsPath := b.contentsPath(o.Name)
+	if fi, err := os.Stat(contentsPath); err == nil && fi.IsDir() {
+		return fmt.Errorf("object %q conflicts with folder %q", o.Name, o.Name+"/")
+	}
+
+	// Objects whose names end in "/" are also folders.
+	if strings.HasSuffix(o.Name, "/") {
+		if err := os.MkdirAll(b.folderPath(o.Name), 0755); err != nil {
+			return err
+		}
+	}
+
+	if err := b.renameLocked(tmpPath, contentsPath); err != nil {
+		return fmt.Errorf("installing contents of %q: %w", o.Name, err)
+	}
+	return b.writeRecordLocked(o)
+}
+
+// Remove the contents and the sidecar of the object.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) removeLocked(name string) error {
+	if err := os.Remove(b.contentsPath(name)); err != nil && !isNotFound(err) {
+		return err
+	}
+	if err := os.Remove(b.attrsPath(name)); err != nil && !isNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Create an object for the given attributes and contents.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) mintObjectLocked(req *gcs.CreateObjectRequest, c *tmpContents) *gcs.Object {
+	storageClass := req.StorageClass
+	if storageClass == "" {
+		storageClass = "STANDARD"
+	}
+
+	return &gcs.Object{
+		Name:               req.Name,
+		ContentType:        req.ContentType,
+		ContentLanguage:    req.ContentLanguage,
+		CacheControl:       req.CacheControl,
+		Size:               c.size,
+		ContentEncoding:    req.ContentEncoding,
+		ComponentCount:     1,
+		MD5:                &c.md5,
+		CRC32C:             &c.crc32c,
+		Metadata:           copyMetadata(req.Metadata),
+		Generation:         b.mintGeneration(),
+		MetaGeneration:     1,
+		StorageClass:       storageClass,
+		Updated:            time.Now(),
+		ContentDisposition: req.ContentDisposition,
+		CustomTime:         req.CustomTime,
+		EventBasedHold:     req.EventBasedHold,
+		Acl:                req.Acl,
+	}
+}
+
+// Create the object of the request with the contents, which are removed if
+// that fails.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) createObject(req *gcs.CreateObjectRequest, c *tmpContents) (o *gcs.Object, err error) {
+	defer func() {
+		if err != nil {
+			os.Remove(c.path)
+		}
+	}()
+
+	if err = c.check(req); err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err = b.checkPreconditionsLocked(req.Name, req.GenerationPrecondition, req.MetaGenerationPrecondition); err != nil {
+		return
+	}
+
+	o = b.mintObjectLocked(req, c)
+	if err = b.installLocked(c.path, o); err != nil {
+		return
+	}
+	o = copyObject(o)
+	return
+}
+
+func copyMetadata(in map[string]string) (out map[string]string) {
+	if in == nil {
+		return
+	}
+
+	out = make(map[string]string)
+	for k, v := range in {
+		out[k] = v
+	}
+
+	return
+}
+
+func copyObject(o *gcs.Object) *gcs.Object {
+	var copy gcs.Object = *o
+	copy.Metadata = copyMetadata(o.Metadata)
+	return &copy
+}
+
+// tmpContents is a temporary file holding the contents of a new object.
+type tmpContents struct {
+	path   string
+	size   uint64
+	crc32c uint32
+	md5    [md5.Size]byte
+}
+
+// Check the checksums requested for the contents, if any.
+func (c *tmpContents) check(req *gcs.CreateObjectRequest) error {
+	if req.CRC32C != nil && *req.CRC32C != c.crc32c {
+		return fmt.Errorf("CRC32C mismatch: got 0x%08x, expected 0x%08x", c.crc32c, *req.CRC32C)
+	}
+	if req.MD5 != nil && *req.MD5 != c.md5 {
+		return fmt.Errorf("MD5 mismatch: got %x, expected %x", c.md5, *req.MD5)
+	}
+	return nil
+}
+
+// Copy the contents into a temporary file.
+func (b *bucket) writeTmpContents(r io.Reader) (*tmpContents, error) {
+	w, err := b.newTmpWriter()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = io.Copy(w, r); err != nil {
+		w.abort()
+		return nil, err
+	}
+	return w.finish()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+func (b *bucket) Name() string {
+	return b.name
+}
+
+func (b *bucket) BucketType() gcs.BucketType {
+	return gcs.BucketType{Hierarchical: true}
+}
+
+func (b *bucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	f, o, err := b.open(req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clip the range to the contents.
+	start, limit := uint64(0), o.Size
+	if req.Range != nil {
+		limit = min(req.Range.Limit, o.Size)
+		start = min(req.Range.Start, limit)
+	}
+
+	return &reader{
+		SectionReader: io.NewSectionReader(f, int64(start), int64(limit-start)),
+		f:             f,
+	}, nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	f, o, err := b.open(req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &multiRangeDownloader{f: f, name: o.Name, size: int64(o.Size)}, nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	if err := checkName(req.Name); err != nil {
+		return nil, err
+	}
+
+	c, err := b.writeTmpContents(req.Contents)
+	if err != nil {
+		return nil, fmt.Errorf("reading contents: %w", err)
+	}
+	return b.createObject(req, c)
+}
+
+func (b *bucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, _ int, _ func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if err := checkName(req.Name); err != nil {
+		return nil, err
+	}
+
+	w, err := b.newTmpWriter()
+	if err != nil {
+		return nil, err
+	}
+	return newObjectWriter(b, req, w), nil
+}
+
+func (b *bucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, _ int, _ func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, fmt.Errorf("appending to %q: appendable objects are not supported", req.Name)
+}
+
+func (b *bucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	ow, ok := w.(*objectWriter)
+	if !ok {
+		return nil, fmt.Errorf("writer of type %T wasn't created by this bucket", w)
+	}
+
+	if err := ow.Close(); err != nil {
+		return nil, err
+	}
+	return storageutil.ConvertObjToMinObject(ow.object), nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (o *gcs.Object, err error) {
+	if err = checkName(req.DstName); err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	src, err := b.findLocked(req.SrcName, req.SrcGeneration)
+	if err != nil {
+		return
+	}
+	if req.SrcMetaGenerationPrecondition != nil && src.MetaGeneration != *req.SrcMetaGenerationPrecondition {
+		err = &gcs.PreconditionError{
+			Err: fmt.Errorf("object %q has meta-generation %d", req.SrcName, src.MetaGeneration),
+		}
+		return
+	}
+
+	f, err := os.Open(b.contentsPath(src.Name))
+	if err != nil {
+		return
+	}
+	c, err := b.writeTmpContents(f)
+	f.Close()
+	if err != nil {
+		return
+	}
+
+	// Copy it with a new generation number.
+	dst := copyObject(src)
+	dst.Name = req.DstName
+	dst.Generation = b.mintGeneration()
+	dst.MetaGeneration = 1
+	dst.Updated = time.Now()
+	if err = b.installLocked(c.path, dst); err != nil {
+		os.Remove(c.path)
+		return
+	}
+
+	o = dst
+	return
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	// GCS doesn't like too few or too many sources.
+	if len(req.Sources) < 1 {
+		err = errors.New("you must provide at least one source component")
+		return
+	}
+	if len(req.Sources) > gcs.MaxSourcesPerComposeRequest {
+		err = errors.New("you have provided too many source components")
+		return
+	}
+	if err = checkName(req.DstName); err != nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	w, err := b.newTmpWriter()
+	if err != nil {
+		return
+	}
+	defer func() {
+		if err != nil {
+			w.abort()
+		}
+	}()
+
+	// Concatenate the sources, also computing the sum of their component
+	// counts.
+	var componentCount int64
+	for _, s := range req.Sources {
+		var src *gcs.Object
+		if src, err = b.findLocked(s.Name, s.Generation); err != nil {
+			return
+		}
+		componentCount += src.ComponentCount
+
+		var f *os.File
+		if f, err = os.Open(b.contentsPath(src.Name)); err != nil {
+			return
+		}
+		_, err = io.Copy(w, f)
+		f.Close()
+		if err != nil {
+			return
+		}
+	}
+
+	// GCS doesn't like the component count to go too high.
+	if componentCount > gcs.MaxComponentCount {
+		err = errors.New("result would have too many components")
+		return
+	}
+
+	if err = b.checkPreconditionsLocked(req.DstName, req.DstGenerationPrecondition, req.DstMetaGenerationPrecondition); err != nil {
+		return
+	}
+
+	c, err := w.finish()
+	if err != nil {
+		return
+	}
+	dst := b.mintObjectLocked(&gcs.CreateObjectRequest{
+		Name:               req.DstName,
+		ContentType:        req.ContentType,
+		ContentEncoding:    req.ContentEncoding,
+		ContentLanguage:    req.ContentLanguage,
+		CacheControl:       req.CacheControl,
+		ContentDisposition: req.ContentDisposition,
+		CustomTime:         req.CustomTime,
+		EventBasedHold:     req.EventBasedHold,
+		StorageClass:       req.StorageClass,
+		Acl:                req.Acl,
+		Metadata:           req.Metadata,
+	}, c)
+
+	// As in GCS, composite objects have no MD5 hash.
+	dst.ComponentCount = componentCount
+	dst.MD5 = nil
+	if err = b.installLocked(c.path, dst); err != nil {
+		os.Remove(c.path)
+		return
+	}
+
+	o = copyObject(dst)
+	return
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o, err := b.findLocked(req.Name, 0)
+	if err != nil {
+		return
+	}
+
+	m = storageutil.ConvertObjToMinObject(o)
+	if req.ReturnExtendedObjectAttributes {
+		e = storageutil.ConvertObjToExtendedObjectAttributes(o)
+	}
+	return
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (o *gcs.Object, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	obj, err := b.findLocked(req.Name, req.Generation)
+	if err != nil {
+		return
+	}
+
+	if req.MetaGenerationPrecondition != nil && obj.MetaGeneration != *req.MetaGenerationPrecondition {
+		err = &gcs.PreconditionError{
+			Err: fmt.Errorf("object %q has meta-generation %d", obj.Name, obj.MetaGeneration),
+		}
+		return
+	}
+
+	if req.ContentType != nil {
+		obj.ContentType = *req.ContentType
+	}
+	if req.ContentEncoding != nil {
+		obj.ContentEncoding = *req.ContentEncoding
+	}
+	if req.ContentLanguage != nil {
+		obj.ContentLanguage = *req.ContentLanguage
+	}
+	if req.CacheControl != nil {
+		obj.CacheControl = *req.CacheControl
+	}
+
+	for k, v := range req.Metadata {
+		if obj.Metadata == nil {
+			obj.Metadata = make(map[string]string)
+		}
+		if v == nil {
+			delete(obj.Metadata, k)
+			continue
+		}
+		obj.Metadata[k] = *v
+	}
+
+	obj.MetaGeneration++
+	obj.Updated = time.Now()
+	if err = b.writeRecordLocked(obj); err != nil {
+		return
+	}
+
+	o = obj
+	return
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o, err := b.findLocked(req.Name, req.Generation)
+	if err != nil {
+		return err
+	}
+
+	if req.MetaGenerationPrecondition != nil && o.MetaGeneration != *req.MetaGenerationPrecondition {
+		return &gcs.PreconditionError{
+			Err: fmt.Errorf("object %q has meta-generation %d", req.Name, o.MetaGeneration),
+		}
+	}
+
+	// Parent directories are folders, and stay.
+	return b.removeLocked(req.Name)
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	if err := checkName(req.DstName); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	src, err := b.findLocked(req.SrcName, req.SrcGeneration)
+	if err != nil {
+		return nil, err
+	}
+	if req.SrcMetaGenerationPrecondition != nil && src.MetaGeneration != *req.SrcMetaGenerationPrecondition {
+		return nil, &gcs.PreconditionError{
+			Err: fmt.Errorf("object %q has meta-generation %d", req.SrcName, src.MetaGeneration),
+		}
+	}
+
+	// Move it with a new generation number, to ensure that the generation
+	// number for the destination name is strictly increasing.
+	dst := copyObject(src)
+	dst.Name = req.DstName
+	dst.Generation = b.mintGeneration()
+	dst.Updated = time.Now()
+	if err = b.installLocked(b.contentsPath(src.Name), dst); err != nil {
+		return nil, err
+	}
+	if src.Name != dst.Name {
+		if err = os.Remove(b.attrsPath(src.Name)); err != nil && !isNotFound(err) {
+			return nil, err
+		}
+	}
+
+	return copyObject(dst), nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) DeleteFolder(ctx context.Context, folderName string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := os.Remove(b.folderPath(folderName)); err != nil {
+		if isNotFound(err) {
+			return &gcs.NotFoundError{Err: fmt.Errorf("folder %q not found", folderName)}
+		}
+		return fmt.Errorf("deleting folder %q: %w", folderName, err)
+	}
+
+	// As in GCS, delete the object named like the folder too. Its contents and
+	// sidecar live under the metadata directory, which is removed if empty.
+	if err := b.removeLocked(folderName); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Dir(b.attrsPath(folderName))); err != nil && !isNotFound(err) && !errors.Is(err, syscall.ENOTEMPTY) && !errors.Is(err, syscall.EEXIST) {
+		return err
+	}
+	return nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	fi, err := os.Stat(b.folderPath(folderName))
+	if isNotFound(err) || (err == nil && !fi.IsDir()) {
+		return nil, &gcs.NotFoundError{Err: fmt.Errorf("folder %q not found", folderName)}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcs.Folder{Name: folderName, UpdateTime: fi.ModTime()}, nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	if err := checkName(folderName); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	path := b.folderPath(folderName)
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, fmt.Errorf("creating folder %q: %w", folderName, err)
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gcs.Folder{Name: folderName, UpdateTime: fi.ModTime()}, nil
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (*gcs.Folder, error) {
+	if err := checkName(destinationFolderId); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	srcPath, dstPath := b.folderPath(folderName), b.folderPath(destinationFolderId)
+	if fi, err := os.Stat(srcPath); err != nil || !fi.IsDir() {
+		return nil, &gcs.NotFoundError{Err: fmt.Errorf("folder %q not found", folderName)}
+	}
+	if _, err := os.Lstat(dstPath); err == nil {
+		return nil, fmt.Errorf("renaming folder %q: %q already exists", folderName, destinationFolderId)
+	}
+
+	// Objects keep their generations, and their sidecars move along with them.
+	if err := b.renameLocked(srcPath, dstPath); err != nil {
+		return nil, fmt.Errorf("renaming folder %q: %w", folderName, err)
+	}
+	srcAttrs := filepath.Join(b.root, metadataDirName, attrsDirName, filepath.FromSlash(folderName))
+	dstAttrs := filepath.Join(b.root, metadataDirName, attrsDirName, filepath.FromSlash(destinationFolderId))
+	if err := b.renameLocked(srcAttrs, dstAttrs); err != nil && !isNotFound(err) {
+		return nil, fmt.Errorf("renaming sidecars of folder %q: %w", folderName, err)
+	}
+
+	return &gcs.Folder{Name: destinationFolderId, UpdateTime: time.Now()}, nil
+}
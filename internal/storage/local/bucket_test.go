@@ -0,0 +1,428 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LocalBucketTest struct {
+	suite.Suite
+	ctx    context.Context
+	dir    string
+	bucket gcs.Bucket
+}
+
+func TestLocalBucketTestSuite(t *testing.T) {
+	suite.Run(t, new(LocalBucketTest))
+}
+
+func (t *LocalBucketTest) SetupTest() {
+	var err error
+	t.ctx = context.Background()
+	t.dir = t.T().TempDir()
+	t.bucket, err = NewBucket(t.dir, "some_bucket")
+	require.NoError(t.T(), err)
+}
+
+func (t *LocalBucketTest) create(name string, contents string) *gcs.Object {
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, name, []byte(contents))
+	require.NoError(t.T(), err)
+	return o
+}
+
+func (t *LocalBucketTest) read(name string) string {
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, name)
+	require.NoError(t.T(), err)
+	return string(contents)
+}
+
+func (t *LocalBucketTest) list(req *gcs.ListObjectsRequest) (names []string, runs []string) {
+	listing, err := t.bucket.ListObjects(t.ctx, req)
+	require.NoError(t.T(), err)
+	for _, m := range listing.MinObjects {
+		names = append(names, m.Name)
+	}
+	return names, listing.CollapsedRuns
+}
+
+func (t *LocalBucketTest) TestDirFromEndpoint() {
+	dir, ok := DirFromEndpoint("file:///var/buckets")
+	assert.True(t.T(), ok)
+	assert.Equal(t.T(), "/var/buckets", dir)
+
+	_, ok = DirFromEndpoint("https://localhost:9000")
+	assert.False(t.T(), ok)
+}
+
+func (t *LocalBucketTest) TestNewBucketRequiresDirectory() {
+	_, err := NewBucket(filepath.Join(t.dir, "missing"), "missing")
+
+	assert.Error(t.T(), err)
+}
+
+func (t *LocalBucketTest) TestCreateObjectStoresContentsAtName() {
+	o := t.create("foo/bar", "taco")
+
+	assert.EqualValues(t.T(), 4, o.Size)
+	assert.EqualValues(t.T(), 1, o.MetaGeneration)
+	assert.NotNil(t.T(), o.CRC32C)
+	contents, err := os.ReadFile(filepath.Join(t.dir, "foo", "bar"))
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "taco", string(contents))
+	assert.Equal(t.T(), "taco", t.read("foo/bar"))
+}
+
+func (t *LocalBucketTest) TestCreateObjectRejectsInvalidNames() {
+	for _, name := range []string{"a//b", "a/./b", "../a", ".gcsfuse-local/a", "a\nb"} {
+		_, err := storageutil.CreateObject(t.ctx, t.bucket, name, []byte("taco"))
+
+		assert.Error(t.T(), err, name)
+	}
+}
+
+func (t *LocalBucketTest) TestCreateObjectChecksPreconditions() {
+	o := t.create("foo", "taco")
+	zero := int64(0)
+	stale := o.Generation - 1
+
+	_, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		Contents:               strings.NewReader("burrito"),
+		GenerationPrecondition: &zero,
+	})
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr))
+	_, err = t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		Contents:               strings.NewReader("burrito"),
+		GenerationPrecondition: &stale,
+	})
+	assert.True(t.T(), errors.As(err, &preconditionErr))
+	newO, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		Contents:               strings.NewReader("burrito"),
+		GenerationPrecondition: &o.Generation,
+	})
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), newO.Generation, o.Generation)
+	assert.Equal(t.T(), "burrito", t.read("foo"))
+}
+
+func (t *LocalBucketTest) TestCreateObjectChecksCRC32C() {
+	crc := uint32(17)
+
+	_, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "foo",
+		Contents: strings.NewReader("taco"),
+		CRC32C:   &crc,
+	})
+
+	assert.ErrorContains(t.T(), err, "CRC32C mismatch")
+	_, err = os.Stat(filepath.Join(t.dir, "foo"))
+	assert.True(t.T(), os.IsNotExist(err))
+}
+
+func (t *LocalBucketTest) TestGenerationsAreMicroseconds() {
+	before := time.Now().UnixMicro()
+	o := t.create("foo", "taco")
+	require.NoError(t.T(), os.WriteFile(filepath.Join(t.dir, "bar"), []byte("taco"), 0644))
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "bar"})
+	after := time.Now().UnixMicro()
+
+	// Like those of GCS, leaving the high bits clear.
+	require.NoError(t.T(), err)
+	assert.GreaterOrEqual(t.T(), o.Generation, before)
+	assert.LessOrEqual(t.T(), o.Generation, after)
+	assert.LessOrEqual(t.T(), m.Generation, after)
+}
+
+func (t *LocalBucketTest) TestAttributesPersistAcrossBuckets() {
+	o, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:        "foo",
+		Contents:    strings.NewReader("taco"),
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"a": "b"},
+	})
+	require.NoError(t.T(), err)
+
+	bucket, err := NewBucket(t.dir, "some_bucket")
+	require.NoError(t.T(), err)
+	m, e, err := bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo", ReturnExtendedObjectAttributes: true})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), o.Generation, m.Generation)
+	assert.Equal(t.T(), map[string]string{"a": "b"}, m.Metadata)
+	assert.Equal(t.T(), "text/plain", e.ContentType)
+}
+
+func (t *LocalBucketTest) TestFilesWrittenOutsideAreObjects() {
+	require.NoError(t.T(), os.MkdirAll(filepath.Join(t.dir, "dir"), 0755))
+	require.NoError(t.T(), os.WriteFile(filepath.Join(t.dir, "dir", "foo"), []byte("taco"), 0644))
+
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "dir/foo"})
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 4, m.Size)
+	assert.NotZero(t.T(), m.Generation)
+	assert.Equal(t.T(), "taco", t.read("dir/foo"))
+}
+
+func (t *LocalBucketTest) TestContentsModifiedOutsideGetNewGeneration() {
+	o := t.create("foo", "taco")
+	m, err := t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+		Name:     "foo",
+		Metadata: map[string]*string{"a": nil},
+	})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), o.Generation, m.Generation)
+
+	require.NoError(t.T(), os.WriteFile(filepath.Join(t.dir, "foo"), []byte("burrito"), 0644))
+	stat, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), stat.Generation, o.Generation)
+	assert.EqualValues(t.T(), len("burrito"), stat.Size)
+	assert.Nil(t.T(), stat.CRC32C)
+}
+
+func (t *LocalBucketTest) TestNewReaderReadsRangeOfGeneration() {
+	o := t.create("foo", "taco burrito")
+
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:       "foo",
+		Generation: o.Generation,
+		Range:      &gcs.ByteRange{Start: 5, Limit: 100},
+	})
+
+	require.NoError(t.T(), err)
+	contents, err := io.ReadAll(rc)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+	assert.NoError(t.T(), rc.Close())
+	_, err = t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo", Generation: o.Generation + 1})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
+
+func (t *LocalBucketTest) TestMultiRangeDownloader() {
+	t.create("foo", "taco burrito enchilada")
+	mrd, err := t.bucket.NewMultiRangeDownloader(t.ctx, &gcs.MultiRangeDownloaderRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	var first, second bytes.Buffer
+
+	mrd.Add(&first, 0, 4, func(int64, int64, error) {})
+	mrd.Add(&second, 13, 100, func(int64, int64, error) {})
+	mrd.Wait()
+
+	assert.Equal(t.T(), "taco", first.String())
+	assert.Equal(t.T(), "enchilada", second.String())
+	assert.NoError(t.T(), mrd.Close())
+}
+
+func (t *LocalBucketTest) TestChunkWriter() {
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: "foo"}, 1024, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte("taco"))
+	require.NoError(t.T(), err)
+
+	m, err := t.bucket.FinalizeUpload(t.ctx, w)
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 4, m.Size)
+	assert.Equal(t.T(), m.Generation, w.Attrs().Generation)
+	assert.Equal(t.T(), "taco", t.read("foo"))
+}
+
+func (t *LocalBucketTest) TestObjectNamedLikeFolder() {
+	t.create("dir/", "")
+
+	fi, err := os.Stat(filepath.Join(t.dir, "dir"))
+	require.NoError(t.T(), err)
+	assert.True(t.T(), fi.IsDir())
+	assert.Equal(t.T(), "", t.read("dir/"))
+	_, err = t.bucket.GetFolder(t.ctx, "dir/")
+	assert.NoError(t.T(), err)
+}
+
+func (t *LocalBucketTest) TestListObjectsWithDelimiter() {
+	t.create("a", "")
+	t.create("b/", "")
+	t.create("b/c", "")
+	t.create("d/e/f", "")
+	_, err := t.bucket.CreateFolder(t.ctx, "empty/")
+	require.NoError(t.T(), err)
+
+	names, runs := t.list(&gcs.ListObjectsRequest{Delimiter: "/"})
+	assert.Equal(t.T(), []string{"a"}, names)
+	assert.Equal(t.T(), []string{"b/", "d/"}, runs)
+
+	names, runs = t.list(&gcs.ListObjectsRequest{Delimiter: "/", IncludeTrailingDelimiter: true, IncludeFoldersAsPrefixes: true})
+	assert.Equal(t.T(), []string{"a", "b/"}, names)
+	assert.Equal(t.T(), []string{"b/", "d/", "empty/"}, runs)
+
+	names, runs = t.list(&gcs.ListObjectsRequest{Prefix: "b/", Delimiter: "/"})
+	assert.Equal(t.T(), []string{"b/", "b/c"}, names)
+	assert.Empty(t.T(), runs)
+}
+
+func (t *LocalBucketTest) TestListObjectsWithoutDelimiter() {
+	t.create("a-b", "")
+	t.create("a/b", "")
+	t.create("a/", "")
+	t.create("c", "")
+
+	names, runs := t.list(&gcs.ListObjectsRequest{Prefix: "a"})
+
+	// Sorted as names, rather than as paths.
+	assert.Equal(t.T(), []string{"a-b", "a/", "a/b"}, names)
+	assert.Empty(t.T(), runs)
+}
+
+func (t *LocalBucketTest) TestListObjectsPaginates() {
+	for _, name := range []string{"a", "b/c", "b/d", "e", "f"} {
+		t.create(name, "")
+	}
+	var names, runs []string
+	req := &gcs.ListObjectsRequest{Delimiter: "/", MaxResults: 2}
+
+	for {
+		listing, err := t.bucket.ListObjects(t.ctx, req)
+		require.NoError(t.T(), err)
+		assert.LessOrEqual(t.T(), len(listing.MinObjects)+len(listing.CollapsedRuns), 2)
+		for _, m := range listing.MinObjects {
+			names = append(names, m.Name)
+		}
+		runs = append(runs, listing.CollapsedRuns...)
+		if listing.ContinuationToken == "" {
+			break
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+
+	assert.Equal(t.T(), []string{"a", "e", "f"}, names)
+	assert.Equal(t.T(), []string{"b/"}, runs)
+}
+
+func (t *LocalBucketTest) TestCopyObject() {
+	src := t.create("foo", "taco")
+
+	o, err := t.bucket.CopyObject(t.ctx, &gcs.CopyObjectRequest{SrcName: "foo", DstName: "dir/bar"})
+
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), o.Generation, src.Generation)
+	assert.Equal(t.T(), "taco", t.read("dir/bar"))
+	assert.Equal(t.T(), "taco", t.read("foo"))
+}
+
+func (t *LocalBucketTest) TestComposeObjects() {
+	t.create("foo", "taco")
+	t.create("bar", "burrito")
+
+	o, err := t.bucket.ComposeObjects(t.ctx, &gcs.ComposeObjectsRequest{
+		DstName: "foo",
+		Sources: []gcs.ComposeSource{{Name: "foo"}, {Name: "bar"}},
+	})
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 2, o.ComponentCount)
+	assert.Nil(t.T(), o.MD5)
+	assert.Equal(t.T(), "tacoburrito", t.read("foo"))
+}
+
+func (t *LocalBucketTest) TestMoveObject() {
+	src := t.create("foo", "taco")
+
+	o, err := t.bucket.MoveObject(t.ctx, &gcs.MoveObjectRequest{SrcName: "foo", DstName: "bar"})
+
+	require.NoError(t.T(), err)
+	assert.Greater(t.T(), o.Generation, src.Generation)
+	assert.Equal(t.T(), "taco", t.read("bar"))
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
+
+func (t *LocalBucketTest) TestUpdateObject() {
+	t.create("foo", "taco")
+	contentType := "text/plain"
+	value := "b"
+	metaGeneration := int64(1)
+
+	o, err := t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+		Name:                       "foo",
+		ContentType:                &contentType,
+		Metadata:                   map[string]*string{"a": &value},
+		MetaGenerationPrecondition: &metaGeneration,
+	})
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 2, o.MetaGeneration)
+	assert.Equal(t.T(), "text/plain", o.ContentType)
+	m, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), map[string]string{"a": "b"}, m.Metadata)
+	assert.EqualValues(t.T(), 2, m.MetaGeneration)
+}
+
+func (t *LocalBucketTest) TestDeleteObjectKeepsFolder() {
+	t.create("dir/foo", "taco")
+
+	err := t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "dir/foo"})
+
+	require.NoError(t.T(), err)
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "dir/foo"})
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+	_, err = t.bucket.GetFolder(t.ctx, "dir/")
+	assert.NoError(t.T(), err)
+	err = t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "dir/foo"})
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
+
+func (t *LocalBucketTest) TestFolders() {
+	_, err := t.bucket.CreateFolder(t.ctx, "a/b/")
+	require.NoError(t.T(), err)
+	t.create("a/b/foo", "taco")
+
+	// Non-empty folders can't be deleted.
+	assert.Error(t.T(), t.bucket.DeleteFolder(t.ctx, "a/b/"))
+	f, err := t.bucket.RenameFolder(t.ctx, "a/", "c/")
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "c/", f.Name)
+
+	assert.Equal(t.T(), "taco", t.read("c/b/foo"))
+	_, err = t.bucket.GetFolder(t.ctx, "a/")
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+	require.NoError(t.T(), t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "c/b/foo"}))
+	assert.NoError(t.T(), t.bucket.DeleteFolder(t.ctx, "c/b/"))
+	_, err = t.bucket.GetFolder(t.ctx, "c/b/")
+	assert.True(t.T(), errors.As(err, &notFoundErr))
+}
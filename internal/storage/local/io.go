@@ -0,0 +1,259 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"os"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// tmpWriter writes the contents of a new object to a temporary file,
+// computing their checksums.
+type tmpWriter struct {
+	f      *os.File
+	size   uint64
+	crc32c hash.Hash32
+	md5    hash.Hash
+}
+
+func (b *bucket) newTmpWriter() (*tmpWriter, error) {
+	f, err := os.CreateTemp(b.tmpDir(), "contents")
+	if err != nil {
+		return nil, err
+	}
+
+	return &tmpWriter{
+		f:      f,
+		crc32c: crc32.New(crc32cTable),
+		md5:    md5.New(),
+	}, nil
+}
+
+func (w *tmpWriter) Write(p []byte) (n int, err error) {
+	n, err = w.f.Write(p)
+	w.crc32c.Write(p[:n])
+	w.md5.Write(p[:n])
+	w.size += uint64(n)
+	return
+}
+
+func (w *tmpWriter) finish() (*tmpContents, error) {
+	if err := w.f.Close(); err != nil {
+		os.Remove(w.f.Name())
+		return nil, err
+	}
+
+	c := &tmpContents{
+		path:   w.f.Name(),
+		size:   w.size,
+		crc32c: w.crc32c.Sum32(),
+	}
+	w.md5.Sum(c.md5[:0])
+	return c, nil
+}
+
+func (w *tmpWriter) abort() {
+	w.f.Close()
+	os.Remove(w.f.Name())
+}
+
+// objectWriter creates an object with the contents written to it when
+// closed.
+type objectWriter struct {
+	b     *bucket
+	req   *gcs.CreateObjectRequest
+	w     *tmpWriter
+	attrs storage.ObjectAttrs
+
+	// Set when closed.
+	closed bool
+	err    error
+	object *gcs.Object
+}
+
+func newObjectWriter(b *bucket, req *gcs.CreateObjectRequest, w *tmpWriter) *objectWriter {
+	return &objectWriter{
+		b:   b,
+		req: req,
+		w:   w,
+		attrs: storage.ObjectAttrs{
+			Name:        req.Name,
+			ContentType: req.ContentType,
+		},
+	}
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("writer for %q is closed", w.req.Name)
+	}
+	return w.w.Write(p)
+}
+
+func (w *objectWriter) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+
+	c, err := w.w.finish()
+	if err == nil {
+		w.object, err = w.b.createObject(w.req, c)
+	}
+	if err != nil {
+		w.err = err
+		return err
+	}
+
+	w.attrs.Size = int64(w.object.Size)
+	w.attrs.Generation = w.object.Generation
+	w.attrs.Metageneration = w.object.MetaGeneration
+	w.attrs.CRC32C = *w.object.CRC32C
+	w.attrs.MD5 = w.object.MD5[:]
+	w.attrs.Updated = w.object.Updated
+	return nil
+}
+
+// Flush fails: objects are only created when the writer is closed.
+func (w *objectWriter) Flush() (int64, error) {
+	return 0, fmt.Errorf("flushing %q: %w", w.req.Name, errors.ErrUnsupported)
+}
+
+func (w *objectWriter) ObjectName() string {
+	return w.req.Name
+}
+
+func (w *objectWriter) Attrs() *storage.ObjectAttrs {
+	return &w.attrs
+}
+
+// Open the contents of the named object, which must have the given generation
+// unless zero.
+//
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) open(name string, generation int64) (*os.File, *gcs.Object, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	o, err := b.findLocked(name, generation)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The file stays as it is while open, since contents are replaced by
+	// renaming.
+	f, err := os.Open(b.contentsPath(name))
+	if err != nil {
+		return nil, nil, err
+	}
+	return f, o, nil
+}
+
+// reader reads a range of the contents of an object.
+type reader struct {
+	*io.SectionReader
+	f *os.File
+}
+
+func (r *reader) Close() error {
+	return r.f.Close()
+}
+
+func (r *reader) ReadHandle() storage.ReadHandle {
+	return nil
+}
+
+// multiRangeDownloader reads ranges of the contents of an object
+// concurrently.
+type multiRangeDownloader struct {
+	f    *os.File
+	name string
+	size int64
+	wg   sync.WaitGroup
+
+	mu  sync.Mutex
+	err error // GUARDED_BY(mu)
+}
+
+func (d *multiRangeDownloader) setErr(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *multiRangeDownloader) Add(output io.Writer, offset, length int64, callback func(int64, int64, error)) {
+	// Apply the input checks of the storage client.
+	var err error
+	if length < 0 {
+		err = fmt.Errorf("length < 0")
+	} else if offset > d.size {
+		err = fmt.Errorf("out of range. offset (%v) > size of content (%v) of %s", offset, d.size, d.name)
+	} else if offset <= -d.size {
+		offset = 0
+		length = d.size
+	} else if offset < 0 {
+		offset = d.size + offset
+		length = min(length, d.size-offset)
+	} else {
+		length = min(length, d.size-offset)
+	}
+	if err != nil {
+		d.setErr(err)
+		if callback != nil {
+			callback(offset, length, err)
+		}
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		n, err := io.Copy(output, io.NewSectionReader(d.f, offset, length))
+		if err == nil && n != length {
+			err = fmt.Errorf("read %d bytes of %s at offset %d, expected %d", n, d.name, offset, length)
+		}
+		if err != nil {
+			d.setErr(err)
+		}
+		if callback != nil {
+			callback(offset, n, err)
+		}
+	}()
+}
+
+func (d *multiRangeDownloader) Close() error {
+	d.Wait()
+	d.f.Close()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *multiRangeDownloader) Wait() {
+	d.wg.Wait()
+}
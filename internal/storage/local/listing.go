@@ -0,0 +1,249 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package local
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/net/context"
+)
+
+// listEntry is an object or a folder within the bucket directory, or both if
+// the name of the object ends in "/".
+type listEntry struct {
+	name   string
+	object *gcs.Object
+	folder bool
+}
+
+// Return the objects and folders that may be in the listing of the prefix,
+// sorted by name. With "/" as delimiter, only the directory holding the prefix
+// is read, since deeper entries collapse into its folders.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) entriesLocked(prefix string, delimiter string) (entries []listEntry, err error) {
+	dirName := prefix[:strings.LastIndex(prefix, "/")+1]
+
+	// Add the entry of the named object or folder, whose path is known to be a
+	// directory if isDir.
+	add := func(name string, isDir bool) (err error) {
+		e := listEntry{name: name}
+		if isDir {
+			e.name += "/"
+			e.folder = true
+		}
+		if e.object, err = b.lookUpLocked(e.name); err != nil {
+			return
+		}
+		if e.object != nil || e.folder {
+			entries = append(entries, e)
+		}
+		return
+	}
+
+	// The object named like the directory holding the prefix.
+	if dirName != "" && dirName == prefix {
+		if err = add(dirName, false); err != nil {
+			return
+		}
+	}
+
+	dirPath := filepath.Join(b.root, filepath.FromSlash(dirName))
+	if delimiter == "/" {
+		var dirEntries []os.DirEntry
+		dirEntries, err = os.ReadDir(dirPath)
+		if isNotFound(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return
+		}
+
+		for _, d := range dirEntries {
+			name := dirName + d.Name()
+			if !strings.HasPrefix(name, prefix) || name == metadataDirName {
+				continue
+			}
+
+			// Follow symbolic links.
+			var fi os.FileInfo
+			if fi, err = os.Stat(filepath.Join(dirPath, d.Name())); err != nil {
+				if isNotFound(err) {
+					err = nil
+					continue
+				}
+				return
+			}
+			if err = add(name, fi.IsDir()); err != nil {
+				return
+			}
+		}
+	} else {
+		err = filepath.WalkDir(dirPath, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == dirPath && isNotFound(err) {
+					return filepath.SkipAll
+				}
+				return err
+			}
+			if p == dirPath {
+				return nil
+			}
+
+			rel, err := filepath.Rel(b.root, p)
+			if err != nil {
+				return err
+			}
+			name := filepath.ToSlash(rel)
+			if name == metadataDirName {
+				return filepath.SkipDir
+			}
+
+			// Skip directories holding nothing with the prefix.
+			if d.IsDir() && !strings.HasPrefix(name+"/", prefix) && !strings.HasPrefix(prefix, name+"/") {
+				return filepath.SkipDir
+			}
+			if !strings.HasPrefix(name, prefix) {
+				return nil
+			}
+
+			// Folders aren't results without a delimiter.
+			if err := add(name, d.IsDir()); err != nil {
+				return err
+			}
+			if d.IsDir() && entries[len(entries)-1].object == nil {
+				entries = entries[:len(entries)-1]
+			} else if d.IsDir() {
+				entries[len(entries)-1].folder = false
+			}
+			return nil
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	return
+}
+
+// Whether the folder holds any object, at any depth.
+//
+// LOCKS_REQUIRED(b.mu)
+func (b *bucket) holdsObjectsLocked(folderName string) (found bool, err error) {
+	// Objects whose names end in "/" have their contents with the sidecars.
+	dirs := []string{
+		b.folderPath(folderName),
+		filepath.Join(b.root, metadataDirName, attrsDirName, filepath.FromSlash(folderName)),
+	}
+	for i, dir := range dirs {
+		err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == dir && isNotFound(err) {
+					return filepath.SkipAll
+				}
+				return err
+			}
+			if !d.IsDir() && (i == 0 || strings.HasSuffix(p, contentsSuffix)) {
+				found = true
+				return filepath.SkipAll
+			}
+			return nil
+		})
+		if found || err != nil {
+			return
+		}
+	}
+	return
+}
+
+// LOCKS_EXCLUDED(b.mu)
+func (b *bucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (listing *gcs.Listing, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	entries, err := b.entriesLocked(req.Prefix, req.Delimiter)
+	if err != nil {
+		return
+	}
+
+	maxResults := req.MaxResults
+	if maxResults <= 0 || maxResults > maxListResults {
+		maxResults = maxListResults
+	}
+
+	// Results are sorted, and the continuation token is the last result
+	// returned.
+	listing = new(gcs.Listing)
+	var results int
+	var lastKey string
+	for _, e := range entries {
+		var run string
+		returnObject := e.object != nil
+
+		if req.Delimiter != "" {
+			rest := e.name[len(req.Prefix):]
+			if i := strings.Index(rest, req.Delimiter); i >= 0 {
+				run = e.name[:len(req.Prefix)+i+len(req.Delimiter)]
+
+				// As in GCS, objects whose names end in the delimiter are returned
+				// along with their prefix if requested.
+				isTrailingDelimiter := i == len(rest)-len(req.Delimiter)
+				returnObject = returnObject && isTrailingDelimiter && req.IncludeTrailingDelimiter
+
+				// Empty folders are prefixes only if folders are requested.
+				if e.object == nil && !req.IncludeFoldersAsPrefixes {
+					var found bool
+					if found, err = b.holdsObjectsLocked(e.name); err != nil {
+						return
+					}
+					if !found {
+						continue
+					}
+				}
+			}
+		}
+
+		newRun := run != "" && run > req.ContinuationToken &&
+			(len(listing.CollapsedRuns) == 0 || listing.CollapsedRuns[len(listing.CollapsedRuns)-1] != run)
+		returnObject = returnObject && e.name > req.ContinuationToken
+		if !newRun && !returnObject {
+			continue
+		}
+
+		if results >= maxResults {
+			listing.ContinuationToken = lastKey
+			break
+		}
+
+		if newRun {
+			listing.CollapsedRuns = append(listing.CollapsedRuns, run)
+			results++
+			lastKey = run
+		}
+		if returnObject {
+			listing.MinObjects = append(listing.MinObjects, storageutil.ConvertObjToMinObject(e.object))
+			results++
+			lastKey = max(lastKey, e.name)
+		}
+	}
+
+	return
+}
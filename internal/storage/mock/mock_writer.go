@@ -45,6 +45,11 @@ func (mw *Writer) Close() error {
 	return args.Error(0)
 }
 
+func (mw *Writer) Flush() (int64, error) {
+	args := mw.Called()
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (mw *Writer) ObjectName() string {
 	args := mw.Called()
 	return args.String(0)
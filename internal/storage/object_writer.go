@@ -20,6 +20,8 @@
 package storage
 
 import (
+	"context"
+
 	"cloud.google.com/go/storage"
 )
 
@@ -28,6 +30,17 @@ import (
 // It is used to write content to GCS object via resumable upload API.
 type ObjectWriter struct {
 	*storage.Writer
+
+	// Cancels the context of the writer once it is closed, if set.
+	cancel context.CancelFunc
+}
+
+func (e *ObjectWriter) Close() error {
+	err := e.Writer.Close()
+	if e.cancel != nil {
+		e.cancel()
+	}
+	return err
 }
 
 func (e *ObjectWriter) ObjectName() string {
@@ -0,0 +1,416 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// NewRecordingBucket wraps the supplied bucket in a layer recording every
+// request to it, and its outcome, to a trace written to w, including the
+// contents read and written if payloads is set. Failures to record are
+// logged, and don't fail requests.
+func NewRecordingBucket(wrapped gcs.Bucket, w io.Writer, payloads bool) (gcs.Bucket, error) {
+	b := &recordingBucket{
+		wrapped:  wrapped,
+		payloads: payloads,
+		start:    time.Now(),
+		enc:      json.NewEncoder(w),
+	}
+
+	h := header{
+		Bucket:     wrapped.Name(),
+		BucketType: wrapped.BucketType(),
+		Payloads:   payloads,
+	}
+	if err := b.enc.Encode(h); err != nil {
+		return nil, fmt.Errorf("writing trace header: %w", err)
+	}
+	return b, nil
+}
+
+type recordingBucket struct {
+	wrapped  gcs.Bucket
+	payloads bool
+	start    time.Time
+
+	nextSeq atomic.Uint64
+
+	// GUARDED_BY(mu)
+	enc *json.Encoder
+	mu  sync.Mutex
+}
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// An entry being recorded, and the time its request started.
+type pendingEntry struct {
+	*entry
+	began time.Time
+}
+
+func (b *recordingBucket) marshal(v any) json.RawMessage {
+	m, err := json.Marshal(v)
+	if err != nil {
+		logger.Errorf("recording bucket: marshaling %T: %v", v, err)
+		return nil
+	}
+	return m
+}
+
+func (b *recordingBucket) startEntry(op string, req any) pendingEntry {
+	now := time.Now()
+	return pendingEntry{
+		entry: &entry{
+			Seq:     b.nextSeq.Add(1) - 1,
+			Op:      op,
+			Start:   now.Sub(b.start),
+			Request: b.marshal(req),
+		},
+		began: now,
+	}
+}
+
+// Record the latency of the request of e, and its response, if any.
+func (b *recordingBucket) respond(e pendingEntry, resp any, err error) {
+	e.Duration = time.Since(e.began)
+	if err == nil && resp != nil {
+		e.Response = b.marshal(resp)
+	}
+	e.Error = newTraceError(err)
+}
+
+func (b *recordingBucket) write(e pendingEntry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.enc.Encode(e.entry); err != nil {
+		logger.Errorf("recording bucket: writing %s #%d: %v", e.Op, e.Seq, err)
+	}
+}
+
+// Send a request to the wrapped bucket and record it.
+func record[T any](b *recordingBucket, op string, req any, f func() (T, error)) (T, error) {
+	e := b.startEntry(op, req)
+	resp, err := f()
+	b.respond(e, resp, err)
+	b.write(e)
+	return resp, err
+}
+
+////////////////////////////////////////////////////////////////////////
+// Reader
+////////////////////////////////////////////////////////////////////////
+
+// recordingReader records its request once closed, with the contents read.
+type recordingReader struct {
+	gcs.StorageReader
+	bucket  *recordingBucket
+	entry   pendingEntry
+	payload *payload
+	once    sync.Once
+}
+
+func (r *recordingReader) Read(p []byte) (n int, err error) {
+	n, err = r.StorageReader.Read(p)
+	r.payload.Write(p[:n])
+	if err != nil && err != io.EOF && r.entry.PayloadError == nil {
+		r.entry.PayloadError = newTraceError(err)
+	}
+	return
+}
+
+func (r *recordingReader) Close() error {
+	err := r.StorageReader.Close()
+	r.once.Do(func() {
+		r.entry.Response = r.bucket.marshal(readerResponse{ReadHandle: r.ReadHandle()})
+		r.payload.record(r.entry.entry)
+		r.bucket.write(r.entry)
+	})
+	return err
+}
+
+////////////////////////////////////////////////////////////////////////
+// Writer
+////////////////////////////////////////////////////////////////////////
+
+// recordingWriter hashes the contents written, recorded once the upload is
+// finalized, and records each flush.
+type recordingWriter struct {
+	gcs.Writer
+	bucket  *recordingBucket
+	payload *payload
+}
+
+func (w *recordingWriter) Write(p []byte) (int, error) {
+	n, err := w.Writer.Write(p)
+	w.payload.Write(p[:n])
+	return n, err
+}
+
+func (w *recordingWriter) Flush() (int64, error) {
+	return record(w.bucket, "Flush", flushRequest{Name: w.ObjectName()}, w.Writer.Flush)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Multi-range downloader
+////////////////////////////////////////////////////////////////////////
+
+// recordingMRD records each range downloaded once its callback is called.
+type recordingMRD struct {
+	gcs.MultiRangeDownloader
+	bucket *recordingBucket
+	req    gcs.MultiRangeDownloaderRequest
+}
+
+func (d *recordingMRD) Add(
+	output io.Writer,
+	offset, length int64,
+	callback func(int64, int64, error)) {
+	e := d.bucket.startEntry(opMRDAdd, mrdAddRequest{
+		Name:       d.req.Name,
+		Generation: d.req.Generation,
+		Offset:     offset,
+		Length:     length,
+	})
+	p := newPayload(d.bucket.payloads)
+
+	d.MultiRangeDownloader.Add(io.MultiWriter(output, p), offset, length, func(offset, n int64, err error) {
+		d.bucket.respond(e, nil, err)
+		p.record(e.entry)
+		d.bucket.write(e)
+		if callback != nil {
+			callback(offset, n, err)
+		}
+	})
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+func (b *recordingBucket) Name() string {
+	return b.wrapped.Name()
+}
+
+func (b *recordingBucket) BucketType() gcs.BucketType {
+	return b.wrapped.BucketType()
+}
+
+func (b *recordingBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *recordingBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	e := b.startEntry(opNewReader, req)
+	rd, err := b.wrapped.NewReaderWithReadHandle(ctx, req)
+	b.respond(e, nil, err)
+	if err != nil {
+		b.write(e)
+		return nil, err
+	}
+
+	return &recordingReader{
+		StorageReader: rd,
+		bucket:        b,
+		entry:         e,
+		payload:       newPayload(b.payloads),
+	}, nil
+}
+
+func (b *recordingBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	e := b.startEntry("NewMultiRangeDownloader", req)
+	mrd, err := b.wrapped.NewMultiRangeDownloader(ctx, req)
+	b.respond(e, nil, err)
+	b.write(e)
+	if err != nil {
+		return nil, err
+	}
+	return &recordingMRD{MultiRangeDownloader: mrd, bucket: b, req: *req}, nil
+}
+
+func (b *recordingBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	// Contents are recorded as the payload.
+	recorded := *req
+	recorded.Contents = nil
+	e := b.startEntry("CreateObject", &recorded)
+
+	p := newPayload(b.payloads)
+	wReq := *req
+	wReq.Contents = io.TeeReader(req.Contents, p)
+	o, err := b.wrapped.CreateObject(ctx, &wReq)
+
+	b.respond(e, o, err)
+	p.record(e.entry)
+	b.write(e)
+	return o, err
+}
+
+func (b *recordingBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	recorded := *req
+	recorded.Contents = nil
+	return record(b, "CreateObjectChunkWriter", &recorded, func() (gcs.Writer, error) {
+		w, err := b.wrapped.CreateObjectChunkWriter(ctx, req, chunkSize, callBack)
+		if err != nil {
+			return nil, err
+		}
+		return &recordingWriter{Writer: w, bucket: b, payload: newPayload(b.payloads)}, nil
+	})
+}
+
+func (b *recordingBucket) CreateAppendableObjectWriter(
+	ctx context.Context,
+	req *gcs.AppendObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return record(b, "CreateAppendableObjectWriter", req, func() (gcs.Writer, error) {
+		w, err := b.wrapped.CreateAppendableObjectWriter(ctx, req, chunkSize, callBack)
+		if err != nil {
+			return nil, err
+		}
+		return &recordingWriter{Writer: w, bucket: b, payload: newPayload(b.payloads)}, nil
+	})
+}
+
+func (b *recordingBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	rw, ok := w.(*recordingWriter)
+	if !ok {
+		return b.wrapped.FinalizeUpload(ctx, w)
+	}
+
+	e := b.startEntry("FinalizeUpload", finalizeUploadRequest{Name: w.ObjectName()})
+	m, err := b.wrapped.FinalizeUpload(ctx, rw.Writer)
+	b.respond(e, m, err)
+	rw.payload.record(e.entry)
+	b.write(e)
+	return m, err
+}
+
+func (b *recordingBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	return record(b, "CopyObject", req, func() (*gcs.Object, error) {
+		return b.wrapped.CopyObject(ctx, req)
+	})
+}
+
+func (b *recordingBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	return record(b, "ComposeObjects", req, func() (*gcs.Object, error) {
+		return b.wrapped.ComposeObjects(ctx, req)
+	})
+}
+
+func (b *recordingBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	resp, err := record(b, "StatObject", req, func() (*statResponse, error) {
+		m, e, err := b.wrapped.StatObject(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return &statResponse{MinObject: m, Extended: e}, nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.MinObject, resp.Extended, nil
+}
+
+func (b *recordingBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	return record(b, "ListObjects", req, func() (*gcs.Listing, error) {
+		return b.wrapped.ListObjects(ctx, req)
+	})
+}
+
+func (b *recordingBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	return record(b, "UpdateObject", req, func() (*gcs.Object, error) {
+		return b.wrapped.UpdateObject(ctx, req)
+	})
+}
+
+func (b *recordingBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	_, err := record(b, "DeleteObject", req, func() (*struct{}, error) {
+		return nil, b.wrapped.DeleteObject(ctx, req)
+	})
+	return err
+}
+
+func (b *recordingBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	return record(b, "MoveObject", req, func() (*gcs.Object, error) {
+		return b.wrapped.MoveObject(ctx, req)
+	})
+}
+
+func (b *recordingBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	_, err := record(b, "DeleteFolder", folderName, func() (*struct{}, error) {
+		return nil, b.wrapped.DeleteFolder(ctx, folderName)
+	})
+	return err
+}
+
+func (b *recordingBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return record(b, "GetFolder", folderName, func() (*gcs.Folder, error) {
+		return b.wrapped.GetFolder(ctx, folderName)
+	})
+}
+
+func (b *recordingBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return record(b, "CreateFolder", folderName, func() (*gcs.Folder, error) {
+		return b.wrapped.CreateFolder(ctx, folderName)
+	})
+}
+
+func (b *recordingBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (*gcs.Folder, error) {
+	req := renameFolderRequest{FolderName: folderName, DestinationFolderID: destinationFolderId}
+	return record(b, "RenameFolder", req, func() (*gcs.Folder, error) {
+		return b.wrapped.RenameFolder(ctx, folderName, destinationFolderId)
+	})
+}
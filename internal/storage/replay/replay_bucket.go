@@ -0,0 +1,574 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/storage"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/logger"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// errDiverged is returned for requests that weren't recorded.
+var errDiverged = errors.New("request diverged from the trace")
+
+type ReplayConfig struct {
+	// If set, each request is served the response to the same request, in the
+	// order they were recorded, rather than the next response of the trace.
+	MatchByRequest bool
+
+	// If set, responses are delayed by their recorded latencies.
+	Timings bool
+
+	// The prefix of the names of temporary objects, whose random parts are
+	// ignored when matching requests to the trace.
+	TmpObjectPrefix string
+}
+
+// NewReplayBucket returns a bucket serving the requests recorded in the trace
+// read from r by a bucket of NewRecordingBucket, with the recorded responses.
+//
+// Reads are served the recorded contents if the trace has them, and zeros of
+// the recorded size otherwise; they end where the recorded readers were
+// closed. The contents written are hashed, and a warning is logged if they
+// differ from the recorded ones.
+//
+// Requests match recorded ones regardless of the parts that differ from run
+// to run of a workload: the mtimes recorded in metadata, and the random parts
+// of the names of temporary objects.
+func NewReplayBucket(r io.Reader, config ReplayConfig) (gcs.Bucket, error) {
+	dec := json.NewDecoder(r)
+	var h header
+	if err := dec.Decode(&h); err != nil {
+		return nil, fmt.Errorf("reading trace header: %w", err)
+	}
+
+	b := &replayBucket{
+		header:    h,
+		config:    config,
+		byRequest: make(map[string][]*entry),
+	}
+	for {
+		e := new(entry)
+		err := dec.Decode(e)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading trace entry %d: %w", len(b.entries), err)
+		}
+		b.entries = append(b.entries, e)
+	}
+
+	sort.Slice(b.entries, func(i, j int) bool { return b.entries[i].Seq < b.entries[j].Seq })
+	for _, e := range b.entries {
+		key := b.requestKey(e.Op, e.Request)
+		b.byRequest[key] = append(b.byRequest[key], e)
+	}
+	return b, nil
+}
+
+type replayBucket struct {
+	header header
+	config ReplayConfig
+
+	mu sync.Mutex
+
+	// The entries of the trace, in the order of their requests, and the next
+	// one to be served.
+	//
+	// GUARDED_BY(mu)
+	entries []*entry
+	next    int
+
+	// The entries not served yet, by request.
+	//
+	// GUARDED_BY(mu)
+	byRequest map[string][]*entry
+}
+
+////////////////////////////////////////////////////////////////////////
+// Helpers
+////////////////////////////////////////////////////////////////////////
+
+// The placeholder for the parts of requests that differ from run to run.
+const normalized = "*"
+
+// The number of hex digits of the random parts of the names of temporary
+// objects.
+const tmpObjectNameDigits = 16
+
+func (b *replayBucket) requestKey(op string, req json.RawMessage) string {
+	dec := json.NewDecoder(bytes.NewReader(req))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return op + " " + string(req)
+	}
+	r, _ := json.Marshal(b.normalize(v))
+	return op + " " + string(r)
+}
+
+// Replace the parts of the decoded request that differ from run to run.
+func (b *replayBucket) normalize(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, x := range v {
+			if k == gcs.MtimeMetadataKey && x != nil {
+				v[k] = normalized
+			} else {
+				v[k] = b.normalize(x)
+			}
+		}
+	case []any:
+		for i, x := range v {
+			v[i] = b.normalize(x)
+		}
+	case string:
+		return b.normalizeName(v)
+	}
+	return v
+}
+
+// Replace the random part of the name of a temporary object, which is
+// followed by the suffixes of its parts, if any.
+func (b *replayBucket) normalizeName(name string) string {
+	prefix := b.config.TmpObjectPrefix
+	if prefix == "" || !strings.HasPrefix(name, prefix) || len(name) < len(prefix)+tmpObjectNameDigits {
+		return name
+	}
+	random := name[len(prefix) : len(prefix)+tmpObjectNameDigits]
+	if _, err := strconv.ParseUint(random, 16, 64); err != nil {
+		return name
+	}
+	return prefix + normalized + name[len(prefix)+tmpObjectNameDigits:]
+}
+
+// Return the entry recorded for the given request.
+func (b *replayBucket) take(op string, req any) (*entry, error) {
+	r, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling %T: %w", req, err)
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := b.requestKey(op, r)
+	if b.config.MatchByRequest {
+		queue := b.byRequest[key]
+		if len(queue) == 0 {
+			return nil, fmt.Errorf("%s %s: %w", op, r, errDiverged)
+		}
+		b.byRequest[key] = queue[1:]
+		return queue[0], nil
+	}
+
+	if b.next == len(b.entries) {
+		return nil, fmt.Errorf("%s %s past the end of the trace: %w", op, r, errDiverged)
+	}
+	e := b.entries[b.next]
+	if b.requestKey(e.Op, e.Request) != key {
+		return nil, fmt.Errorf("%s %s instead of #%d %s %s: %w", op, r, e.Seq, e.Op, e.Request, errDiverged)
+	}
+	b.next++
+	return e, nil
+}
+
+// Wait for the recorded latency of e, if timings are replayed.
+func (b *replayBucket) delay(ctx context.Context, e *entry) error {
+	if !b.config.Timings || e.Duration <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(e.Duration)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Serve a request the recorded response.
+func replay[T any](ctx context.Context, b *replayBucket, op string, req any) (resp T, err error) {
+	e, err := b.take(op, req)
+	if err != nil {
+		return
+	}
+	if err = b.delay(ctx, e); err != nil {
+		return
+	}
+	if err = e.Error.err(); err != nil {
+		return
+	}
+	if len(e.Response) > 0 {
+		if err = json.Unmarshal(e.Response, &resp); err != nil {
+			err = fmt.Errorf("unmarshaling response of %s #%d: %w", e.Op, e.Seq, err)
+		}
+	}
+	return
+}
+
+// Return a reader of the contents recorded by e.
+func payloadReader(e *entry) io.Reader {
+	if int64(len(e.Payload)) == e.PayloadSize {
+		return bytes.NewReader(e.Payload)
+	}
+	return io.LimitReader(zeros{}, e.PayloadSize)
+}
+
+// zeros reads as an infinite run of zeros.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// Log a warning if the contents written differ from those recorded by e.
+func checkWritten(e *entry, p *payload) {
+	hash := hex.EncodeToString(p.hash.Sum(nil))
+	if hash != e.PayloadHash {
+		logger.Warnf("replay bucket: contents written by %s #%d differ from the trace: %d bytes with SHA-256 %s instead of %d bytes with SHA-256 %s",
+			e.Op, e.Seq, p.size, hash, e.PayloadSize, e.PayloadHash)
+	}
+}
+
+////////////////////////////////////////////////////////////////////////
+// Reader
+////////////////////////////////////////////////////////////////////////
+
+// replayReader serves the recorded contents, then the recorded error, if any.
+type replayReader struct {
+	r      io.Reader
+	err    error
+	handle []byte
+}
+
+func (r *replayReader) Read(p []byte) (n int, err error) {
+	n, err = r.r.Read(p)
+	if err == io.EOF && r.err != nil {
+		err = r.err
+	}
+	return
+}
+
+func (r *replayReader) Close() error {
+	return nil
+}
+
+func (r *replayReader) ReadHandle() storage.ReadHandle {
+	return r.handle
+}
+
+////////////////////////////////////////////////////////////////////////
+// Writer
+////////////////////////////////////////////////////////////////////////
+
+// replayWriter hashes the contents written, checked once the upload is
+// finalized, and replays each flush.
+type replayWriter struct {
+	ctx     context.Context
+	bucket  *replayBucket
+	name    string
+	payload *payload
+}
+
+func (w *replayWriter) Write(p []byte) (int, error) {
+	return w.payload.Write(p)
+}
+
+func (w *replayWriter) Close() error {
+	return nil
+}
+
+func (w *replayWriter) Flush() (int64, error) {
+	return replay[int64](w.ctx, w.bucket, "Flush", flushRequest{Name: w.name})
+}
+
+func (w *replayWriter) ObjectName() string {
+	return w.name
+}
+
+func (w *replayWriter) Attrs() *storage.ObjectAttrs {
+	return nil
+}
+
+////////////////////////////////////////////////////////////////////////
+// Multi-range downloader
+////////////////////////////////////////////////////////////////////////
+
+// replayMRD serves each range the recorded contents, in the background.
+type replayMRD struct {
+	bucket *replayBucket
+	ctx    context.Context
+	req    gcs.MultiRangeDownloaderRequest
+	wg     sync.WaitGroup
+}
+
+func (d *replayMRD) Add(
+	output io.Writer,
+	offset, length int64,
+	callback func(int64, int64, error)) {
+	// Take the entry right away, so that ranges are served in the order
+	// they're added.
+	e, err := d.bucket.take(opMRDAdd, mrdAddRequest{
+		Name:       d.req.Name,
+		Generation: d.req.Generation,
+		Offset:     offset,
+		Length:     length,
+	})
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		var n int64
+		if err == nil {
+			err = d.bucket.delay(d.ctx, e)
+		}
+		if err == nil {
+			n, err = io.Copy(output, payloadReader(e))
+		}
+		if err == nil {
+			err = e.Error.err()
+		}
+		if callback != nil {
+			callback(offset, n, err)
+		}
+	}()
+}
+
+func (d *replayMRD) Close() error {
+	d.wg.Wait()
+	return nil
+}
+
+func (d *replayMRD) Wait() {
+	d.wg.Wait()
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+func (b *replayBucket) Name() string {
+	return b.header.Bucket
+}
+
+func (b *replayBucket) BucketType() gcs.BucketType {
+	return b.header.BucketType
+}
+
+func (b *replayBucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *replayBucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	e, err := b.take(opNewReader, req)
+	if err != nil {
+		return nil, err
+	}
+	if err = b.delay(ctx, e); err != nil {
+		return nil, err
+	}
+	if err = e.Error.err(); err != nil {
+		return nil, err
+	}
+
+	var resp readerResponse
+	if len(e.Response) > 0 {
+		if err = json.Unmarshal(e.Response, &resp); err != nil {
+			return nil, fmt.Errorf("unmarshaling response of %s #%d: %w", e.Op, e.Seq, err)
+		}
+	}
+	return &replayReader{
+		r:      payloadReader(e),
+		err:    e.PayloadError.err(),
+		handle: resp.ReadHandle,
+	}, nil
+}
+
+func (b *replayBucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	if _, err := replay[*struct{}](ctx, b, "NewMultiRangeDownloader", req); err != nil {
+		return nil, err
+	}
+	return &replayMRD{bucket: b, ctx: ctx, req: *req}, nil
+}
+
+func (b *replayBucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	recorded := *req
+	recorded.Contents = nil
+	e, err := b.take("CreateObject", &recorded)
+	if err != nil {
+		return nil, err
+	}
+
+	p := newPayload(false)
+	if _, err = io.Copy(p, req.Contents); err != nil {
+		return nil, err
+	}
+	checkWritten(e, p)
+
+	if err = b.delay(ctx, e); err != nil {
+		return nil, err
+	}
+	if err = e.Error.err(); err != nil {
+		return nil, err
+	}
+	o := new(gcs.Object)
+	if err = json.Unmarshal(e.Response, o); err != nil {
+		return nil, fmt.Errorf("unmarshaling response of %s #%d: %w", e.Op, e.Seq, err)
+	}
+	return o, nil
+}
+
+func (b *replayBucket) CreateObjectChunkWriter(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	recorded := *req
+	recorded.Contents = nil
+	if _, err := replay[*struct{}](ctx, b, "CreateObjectChunkWriter", &recorded); err != nil {
+		return nil, err
+	}
+	return &replayWriter{ctx: ctx, bucket: b, name: req.Name, payload: newPayload(false)}, nil
+}
+
+func (b *replayBucket) CreateAppendableObjectWriter(
+	ctx context.Context,
+	req *gcs.AppendObjectRequest,
+	chunkSize int,
+	callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	if _, err := replay[*struct{}](ctx, b, "CreateAppendableObjectWriter", req); err != nil {
+		return nil, err
+	}
+	return &replayWriter{ctx: ctx, bucket: b, name: req.Name, payload: newPayload(false)}, nil
+}
+
+func (b *replayBucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	rw, ok := w.(*replayWriter)
+	if !ok {
+		return nil, fmt.Errorf("FinalizeUpload of a writer of another bucket: %T", w)
+	}
+
+	e, err := b.take("FinalizeUpload", finalizeUploadRequest{Name: rw.name})
+	if err != nil {
+		return nil, err
+	}
+	checkWritten(e, rw.payload)
+	if err = b.delay(ctx, e); err != nil {
+		return nil, err
+	}
+	if err = e.Error.err(); err != nil {
+		return nil, err
+	}
+	m := new(gcs.MinObject)
+	if err = json.Unmarshal(e.Response, m); err != nil {
+		return nil, fmt.Errorf("unmarshaling response of %s #%d: %w", e.Op, e.Seq, err)
+	}
+	return m, nil
+}
+
+func (b *replayBucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	return replay[*gcs.Object](ctx, b, "CopyObject", req)
+}
+
+func (b *replayBucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (*gcs.Object, error) {
+	return replay[*gcs.Object](ctx, b, "ComposeObjects", req)
+}
+
+func (b *replayBucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (*gcs.MinObject, *gcs.ExtendedObjectAttributes, error) {
+	resp, err := replay[statResponse](ctx, b, "StatObject", req)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.MinObject, resp.Extended, nil
+}
+
+func (b *replayBucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	return replay[*gcs.Listing](ctx, b, "ListObjects", req)
+}
+
+func (b *replayBucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	return replay[*gcs.Object](ctx, b, "UpdateObject", req)
+}
+
+func (b *replayBucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	_, err := replay[*struct{}](ctx, b, "DeleteObject", req)
+	return err
+}
+
+func (b *replayBucket) MoveObject(
+	ctx context.Context,
+	req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	return replay[*gcs.Object](ctx, b, "MoveObject", req)
+}
+
+func (b *replayBucket) DeleteFolder(ctx context.Context, folderName string) error {
+	_, err := replay[*struct{}](ctx, b, "DeleteFolder", folderName)
+	return err
+}
+
+func (b *replayBucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return replay[*gcs.Folder](ctx, b, "GetFolder", folderName)
+}
+
+func (b *replayBucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return replay[*gcs.Folder](ctx, b, "CreateFolder", folderName)
+}
+
+func (b *replayBucket) RenameFolder(
+	ctx context.Context,
+	folderName string,
+	destinationFolderId string) (*gcs.Folder, error) {
+	req := renameFolderRequest{FolderName: folderName, DestinationFolderID: destinationFolderId}
+	return replay[*gcs.Folder](ctx, b, "RenameFolder", req)
+}
@@ -0,0 +1,237 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/fake"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/jacobsa/timeutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// outcome is what a workload observed.
+type outcome struct {
+	stat       *gcs.MinObject
+	missingErr error
+	contents   string
+	listing    *gcs.Listing
+	created    *gcs.Object
+	finalized  *gcs.MinObject
+	ranges     string
+}
+
+type ReplayTest struct {
+	suite.Suite
+	ctx    context.Context
+	bucket gcs.Bucket
+	trace  bytes.Buffer
+}
+
+func TestReplayTestSuite(t *testing.T) {
+	suite.Run(t, new(ReplayTest))
+}
+
+func (t *ReplayTest) SetupTest() {
+	t.ctx = context.Background()
+	t.bucket = fake.NewFakeBucket(timeutil.RealClock(), "some_bucket", gcs.BucketType{})
+	t.trace.Reset()
+
+	_, err := storageutil.CreateObject(t.ctx, t.bucket, "foo", []byte("taco"))
+	require.NoError(t.T(), err)
+	_, err = storageutil.CreateObject(t.ctx, t.bucket, "dir/bar", []byte("burrito"))
+	require.NoError(t.T(), err)
+}
+
+func (t *ReplayTest) record(payloads bool) gcs.Bucket {
+	b, err := NewRecordingBucket(t.bucket, &t.trace, payloads)
+	require.NoError(t.T(), err)
+	return b
+}
+
+func (t *ReplayTest) replay(config ReplayConfig) gcs.Bucket {
+	b, err := NewReplayBucket(bytes.NewReader(t.trace.Bytes()), config)
+	require.NoError(t.T(), err)
+	return b
+}
+
+func (t *ReplayTest) stat(b gcs.Bucket, name string) (*gcs.MinObject, error) {
+	m, _, err := b.StatObject(t.ctx, &gcs.StatObjectRequest{Name: name})
+	return m, err
+}
+
+func (t *ReplayTest) workload(b gcs.Bucket) (o outcome) {
+	var err error
+	o.stat, err = t.stat(b, "foo")
+	require.NoError(t.T(), err)
+	_, o.missingErr = t.stat(b, "missing")
+
+	contents, err := storageutil.ReadObject(t.ctx, b, "foo")
+	require.NoError(t.T(), err)
+	o.contents = string(contents)
+
+	o.listing, err = b.ListObjects(t.ctx, &gcs.ListObjectsRequest{Delimiter: "/"})
+	require.NoError(t.T(), err)
+
+	o.created, err = b.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "baz",
+		Contents: strings.NewReader("enchilada"),
+	})
+	require.NoError(t.T(), err)
+
+	w, err := b.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: "qux"}, 0, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write([]byte("quesadilla"))
+	require.NoError(t.T(), err)
+	o.finalized, err = b.FinalizeUpload(t.ctx, w)
+	require.NoError(t.T(), err)
+
+	mrd, err := b.NewMultiRangeDownloader(t.ctx, &gcs.MultiRangeDownloaderRequest{Name: "dir/bar"})
+	require.NoError(t.T(), err)
+	var buf bytes.Buffer
+	mrd.Add(&buf, 1, 3, func(int64, int64, error) {})
+	mrd.Wait()
+	require.NoError(t.T(), mrd.Close())
+	o.ranges = buf.String()
+
+	require.NoError(t.T(), b.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo"}))
+	return
+}
+
+func (t *ReplayTest) TestReplaysRecordedResponses() {
+	recorded := t.workload(t.record(true))
+
+	replayed := t.workload(t.replay(ReplayConfig{}))
+
+	assert.Equal(t.T(), "taco", replayed.contents)
+	assert.Equal(t.T(), "urr", replayed.ranges)
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(replayed.missingErr, &notFoundErr))
+	assert.Equal(t.T(), recorded.missingErr.Error(), replayed.missingErr.Error())
+
+	// Responses are as recorded, but for the monotonic clock readings and
+	// locations of times.
+	assertSameJSON := func(expected, actual any) {
+		e, err := json.Marshal(expected)
+		require.NoError(t.T(), err)
+		a, err := json.Marshal(actual)
+		require.NoError(t.T(), err)
+		assert.JSONEq(t.T(), string(e), string(a))
+	}
+	assertSameJSON(recorded.stat, replayed.stat)
+	assertSameJSON(recorded.listing, replayed.listing)
+	assertSameJSON(recorded.created, replayed.created)
+	assertSameJSON(recorded.finalized, replayed.finalized)
+}
+
+func (t *ReplayTest) TestBucketIsDescribedByTrace() {
+	t.record(false)
+
+	b := t.replay(ReplayConfig{})
+
+	assert.Equal(t.T(), "some_bucket", b.Name())
+	assert.Equal(t.T(), gcs.BucketType{}, b.BucketType())
+}
+
+func (t *ReplayTest) TestReadsWithoutPayloadsServeZeros() {
+	_, err := storageutil.ReadObject(t.ctx, t.record(false), "foo")
+	require.NoError(t.T(), err)
+
+	contents, err := storageutil.ReadObject(t.ctx, t.replay(ReplayConfig{}), "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), make([]byte, 4), contents)
+}
+
+func (t *ReplayTest) TestDivergenceFromOrder() {
+	b := t.record(false)
+	_, err := t.stat(b, "foo")
+	require.NoError(t.T(), err)
+	_, err = t.stat(b, "dir/bar")
+	require.NoError(t.T(), err)
+	b = t.replay(ReplayConfig{})
+
+	_, err = t.stat(b, "dir/bar")
+	assert.ErrorIs(t.T(), err, errDiverged)
+
+	// The trace isn't advanced by diverging requests.
+	_, err = t.stat(b, "foo")
+	assert.NoError(t.T(), err)
+	_, err = t.stat(b, "dir/bar")
+	assert.NoError(t.T(), err)
+	_, err = t.stat(b, "foo")
+	assert.ErrorIs(t.T(), err, errDiverged)
+}
+
+func (t *ReplayTest) TestMatchByRequest() {
+	b := t.record(false)
+	for _, name := range []string{"foo", "dir/bar", "foo"} {
+		_, err := t.stat(b, name)
+		require.NoError(t.T(), err)
+	}
+	b = t.replay(ReplayConfig{MatchByRequest: true})
+
+	for _, name := range []string{"dir/bar", "foo", "foo"} {
+		m, err := t.stat(b, name)
+		require.NoError(t.T(), err)
+		assert.Equal(t.T(), name, m.Name)
+	}
+	_, err := t.stat(b, "foo")
+	assert.ErrorIs(t.T(), err, errDiverged)
+}
+
+func (t *ReplayTest) TestTimings() {
+	trace := `{"bucket":"some_bucket","bucketType":{},"payloads":false}
+{"seq":0,"op":"StatObject","start":0,"duration":50000000,"request":{"Name":"foo","ForceFetchFromGcs":false,"ReturnExtendedObjectAttributes":false},"response":{"MinObject":{"Name":"foo"}}}
+`
+	b, err := NewReplayBucket(strings.NewReader(trace), ReplayConfig{Timings: true})
+	require.NoError(t.T(), err)
+
+	start := time.Now()
+	m, err := t.stat(b, "foo")
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "foo", m.Name)
+	assert.GreaterOrEqual(t.T(), time.Since(start), 50*time.Millisecond)
+}
+
+func (t *ReplayTest) TestMtimesAndTmpObjectNamesAreIgnored() {
+	create := func(b gcs.Bucket, name string, mtime string) error {
+		_, err := b.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+			Name:     name,
+			Contents: strings.NewReader(""),
+			Metadata: map[string]string{gcs.MtimeMetadataKey: mtime, "key": "value"},
+		})
+		return err
+	}
+	require.NoError(t.T(), create(t.record(false), ".gcsfuse_tmp/0123456789abcdef-part-0001", "2025-01-01T00:00:00Z"))
+	b := t.replay(ReplayConfig{TmpObjectPrefix: ".gcsfuse_tmp/"})
+
+	// Other parts of the request are still matched.
+	err := create(b, ".gcsfuse_tmp/fedcba9876543210-part-0002", "2025-01-02T00:00:00Z")
+	assert.ErrorIs(t.T(), err, errDiverged)
+	err = create(b, ".gcsfuse_tmp/fedcba9876543210-part-0001", "2025-01-02T00:00:00Z")
+	assert.NoError(t.T(), err)
+}
@@ -0,0 +1,197 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay records the traffic of a gcs.Bucket to a trace, and serves a
+// bucket from such a trace, so that a workload can be reproduced offline
+// against the layers above the bucket.
+//
+// A trace is a file of JSON values, one per line: a header describing the
+// bucket, followed by an entry per request, with its response or error, its
+// start and latency, and the size and SHA-256 of the contents read or written,
+// and optionally the contents themselves. Entries are numbered in the order
+// requests are made, but written once they complete, so they may be out of
+// order in the file.
+package replay
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+)
+
+// The names of operations in traces, other than bucket methods.
+const (
+	opNewReader = "NewReader"
+	opMRDAdd    = "MultiRangeDownloader.Add"
+)
+
+// header is the first line of a trace.
+type header struct {
+	Bucket     string         `json:"bucket"`
+	BucketType gcs.BucketType `json:"bucketType"`
+	Payloads   bool           `json:"payloads"`
+}
+
+// entry records a request and its outcome.
+type entry struct {
+	// The number of the request, in the order requests were made.
+	Seq uint64 `json:"seq"`
+	Op  string `json:"op"`
+
+	// The start of the request, relative to the start of the trace, and the
+	// time it took to respond. The latencies of readers are those of opening
+	// them.
+	Start    time.Duration `json:"start"`
+	Duration time.Duration `json:"duration"`
+
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response,omitempty"`
+	Error    *traceError     `json:"error,omitempty"`
+
+	// The contents read or written, up to the point readers were closed.
+	PayloadSize int64  `json:"payloadSize,omitempty"`
+	PayloadHash string `json:"payloadHash,omitempty"`
+	Payload     []byte `json:"payload,omitempty"`
+
+	// The error that ended reading, other than io.EOF.
+	PayloadError *traceError `json:"payloadError,omitempty"`
+}
+
+// The kinds of errors reproduced with their types on replay.
+const (
+	errorKindNotFound         = "not_found"
+	errorKindPrecondition     = "precondition"
+	errorKindCanceled         = "canceled"
+	errorKindDeadlineExceeded = "deadline_exceeded"
+)
+
+// traceError records an error, with its kind if callers check for it.
+type traceError struct {
+	Kind    string `json:"kind,omitempty"`
+	Message string `json:"message"`
+}
+
+func newTraceError(err error) *traceError {
+	if err == nil {
+		return nil
+	}
+
+	e := &traceError{Message: err.Error()}
+	var notFoundErr *gcs.NotFoundError
+	var preconditionErr *gcs.PreconditionError
+	switch {
+	case errors.As(err, &notFoundErr):
+		e.Kind = errorKindNotFound
+	case errors.As(err, &preconditionErr):
+		e.Kind = errorKindPrecondition
+	case errors.Is(err, context.Canceled):
+		e.Kind = errorKindCanceled
+	case errors.Is(err, context.DeadlineExceeded):
+		e.Kind = errorKindDeadlineExceeded
+	}
+	return e
+}
+
+// Return the error recorded by e.
+func (e *traceError) err() error {
+	if e == nil {
+		return nil
+	}
+
+	// Typed errors prefix the messages of the errors they wrap.
+	switch e.Kind {
+	case errorKindNotFound:
+		return &gcs.NotFoundError{Err: errors.New(strings.TrimPrefix(e.Message, "gcs.NotFoundError: "))}
+	case errorKindPrecondition:
+		return &gcs.PreconditionError{Err: errors.New(strings.TrimPrefix(e.Message, "gcs.PreconditionError: "))}
+	case errorKindCanceled:
+		return fmt.Errorf("%s: %w", e.Message, context.Canceled)
+	case errorKindDeadlineExceeded:
+		return fmt.Errorf("%s: %w", e.Message, context.DeadlineExceeded)
+	}
+	return errors.New(e.Message)
+}
+
+// statResponse is the response of StatObject.
+type statResponse struct {
+	MinObject *gcs.MinObject
+	Extended  *gcs.ExtendedObjectAttributes
+}
+
+// readerResponse is the response of NewReader.
+type readerResponse struct {
+	ReadHandle []byte
+}
+
+// finalizeUploadRequest is the request of FinalizeUpload.
+type finalizeUploadRequest struct {
+	Name string
+}
+
+// flushRequest is the request of a flush of a writer.
+type flushRequest struct {
+	Name string
+}
+
+// renameFolderRequest is the request of RenameFolder.
+type renameFolderRequest struct {
+	FolderName          string
+	DestinationFolderID string
+}
+
+// mrdAddRequest is the request of a range of a multi-range downloader.
+type mrdAddRequest struct {
+	Name       string
+	Generation int64
+	Offset     int64
+	Length     int64
+}
+
+// payload hashes, and optionally keeps, the contents read or written.
+type payload struct {
+	size int64
+	hash hash.Hash
+	keep bool
+	data []byte
+}
+
+func newPayload(keep bool) *payload {
+	return &payload{hash: sha256.New(), keep: keep}
+}
+
+func (p *payload) Write(b []byte) (int, error) {
+	p.size += int64(len(b))
+	p.hash.Write(b)
+	if p.keep {
+		p.data = append(p.data, b...)
+	}
+	return len(b), nil
+}
+
+// Record the payload in e.
+func (p *payload) record(e *entry) {
+	e.PayloadSize = p.size
+	e.PayloadHash = hex.EncodeToString(p.hash.Sum(nil))
+	if p.keep {
+		e.Payload = p.data
+	}
+}
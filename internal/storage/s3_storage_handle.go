@@ -0,0 +1,44 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package storage
+
+import (
+	"fmt"
+
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/s3compat"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/net/context"
+)
+
+// s3StorageHandle serves the buckets of an S3-compatible object store in place
+// of GCS buckets. See s3compat.NewBucket.
+type s3StorageHandle struct {
+	client s3compat.Client
+}
+
+func newS3StorageHandle(ctx context.Context, clientConfig storageutil.StorageClientConfig) (StorageHandle, error) {
+	client, err := s3compat.NewClient(ctx, clientConfig.S3Endpoint, clientConfig.S3Region)
+	if err != nil {
+		return nil, fmt.Errorf("s3compat.NewClient: %w", err)
+	}
+
+	return &s3StorageHandle{client: client}, nil
+}
+
+// Billing projects are specific to GCS, and ignored.
+func (sh *s3StorageHandle) BucketHandle(ctx context.Context, bucketName string, billingProject string) (gcs.Bucket, error) {
+	return s3compat.NewBucket(sh.client, bucketName), nil
+}
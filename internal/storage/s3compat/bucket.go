@@ -0,0 +1,687 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package s3compat provides a gcs.Bucket backed by a bucket of an
+// S3-compatible object store, for running gcsfuse over on-premises stores.
+//
+// S3 has no generation numbers, so the generation of an object is derived from
+// its modification time, to the second, and its ETag, which both listings and
+// metadata requests return. Generations thus change whenever the contents or
+// metadata of an object are replaced, but two versions of an object written
+// within the same second aren't ordered by their generations. The
+// meta-generation of every object is 1.
+//
+// Preconditions on writes are checked against the object as it is just before
+// the write, which is then made conditional on the ETag of the object, or on
+// its absence, so that it fails rather than replace an object written in
+// between. Stores that ignore conditional writes leave that window open, as do
+// copies, which can't be conditional on their destination. Reads of a
+// particular generation and deletes of the source of moves are pinned to its
+// ETag in the same way, and fail as in GCS if the object has been replaced
+// since.
+//
+// Listings don't carry the user metadata of objects, which is only returned
+// when objects are looked up. Composing objects and updating their metadata
+// rewrite them, and the bucket has no folders.
+package s3compat
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"hash/fnv"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/net/context"
+)
+
+const (
+	// The number of low bits of generations derived from ETags, below the
+	// modification time in seconds.
+	etagGenerationBits = 20
+
+	// The minimum size of the parts of a multipart upload, but for the last.
+	minPartSize = 5 << 20
+
+	// The maximum number of results of a listing, as in GCS and S3.
+	maxListResults = 1000
+
+	// The number of objects looked up concurrently while listing.
+	listLookUpConcurrency = 16
+)
+
+var crc32cTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Client is the subset of the requests of the S3 client made by the bucket.
+type Client interface {
+	HeadObject(ctx context.Context, in *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, in *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	CopyObject(ctx context.Context, in *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+	ListObjectsV2(ctx context.Context, in *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	CreateMultipartUpload(ctx context.Context, in *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error)
+	UploadPart(ctx context.Context, in *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error)
+	CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error)
+	AbortMultipartUpload(ctx context.Context, in *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error)
+}
+
+// NewClient returns a client of the S3-compatible store at the endpoint,
+// authenticated with the credentials found in the environment as by the AWS
+// CLI.
+func NewClient(ctx context.Context, endpoint string, region string) (Client, error) {
+	cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(region))
+	if err != nil {
+		return nil, fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	return s3.NewFromConfig(cfg, func(o *s3.Options) {
+		o.BaseEndpoint = aws.String(endpoint)
+
+		// On-premises stores rarely serve buckets at subdomains of theirs, nor
+		// accept the checksums the client sends by default.
+		o.UsePathStyle = true
+		o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+		o.ResponseChecksumValidation = aws.ResponseChecksumValidationWhenRequired
+	}), nil
+}
+
+// NewBucket returns a bucket backed by the S3 bucket with the given name.
+func NewBucket(client Client, name string) gcs.Bucket {
+	return &bucket{
+		client: client,
+		name:   name,
+	}
+}
+
+type bucket struct {
+	client Client
+	name   string
+}
+
+// Convert the errors of the client into the errors of package gcs, where
+// they have a counterpart.
+func convertErr(err error) error {
+	var re interface{ HTTPStatusCode() int }
+	if errors.As(err, &re) {
+		switch re.HTTPStatusCode() {
+		case http.StatusNotFound:
+			return &gcs.NotFoundError{Err: err}
+		case http.StatusPreconditionFailed:
+			return &gcs.PreconditionError{Err: err}
+		}
+	}
+	return err
+}
+
+// Like convertErr, for the errors of a write conditional on the given ETag
+// unless nil, which fails as not found if the object has been deleted since.
+func convertWriteErr(err error, ifMatch *string) error {
+	err = convertErr(err)
+	var notFoundErr *gcs.NotFoundError
+	if ifMatch != nil && errors.As(err, &notFoundErr) {
+		err = &gcs.PreconditionError{Err: err}
+	}
+	return err
+}
+
+// Return the generation of an object with the given modification time and
+// ETag.
+func generation(modTime time.Time, etag string) int64 {
+	h := fnv.New32a()
+	h.Write([]byte(strings.Trim(etag, `"`)))
+	return modTime.Unix()<<etagGenerationBits | int64(h.Sum32()&(1<<etagGenerationBits-1))
+}
+
+// Return the MD5 of the contents of an object if the ETag is one, as it is for
+// objects not uploaded in parts nor encrypted with KMS keys.
+func etagMD5(etag string) *[md5.Size]byte {
+	b, err := hex.DecodeString(strings.Trim(etag, `"`))
+	if err != nil || len(b) != md5.Size {
+		return nil
+	}
+
+	var sum [md5.Size]byte
+	copy(sum[:], b)
+	return &sum
+}
+
+// The user metadata of an object. Keys are case-insensitive in S3, and
+// lowercased, as gcsfuse writes them.
+func convertMetadata(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[strings.ToLower(k)] = v
+	}
+	return out
+}
+
+// An object as returned by a HEAD request.
+type head struct {
+	object *gcs.Object
+	etag   string
+}
+
+// Look up the named object, returning a nil object if it doesn't exist.
+func (b *bucket) lookUp(ctx context.Context, name string) (h head, err error) {
+	out, err := b.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(b.name),
+		Key:    aws.String(name),
+	})
+	if err != nil {
+		err = convertErr(err)
+		var notFoundErr *gcs.NotFoundError
+		if errors.As(err, &notFoundErr) {
+			err = nil
+		}
+		return
+	}
+
+	etag := aws.ToString(out.ETag)
+	modTime := aws.ToTime(out.LastModified)
+	h = head{
+		etag: etag,
+		object: &gcs.Object{
+			Name:               name,
+			ContentType:        aws.ToString(out.ContentType),
+			ContentLanguage:    aws.ToString(out.ContentLanguage),
+			CacheControl:       aws.ToString(out.CacheControl),
+			ContentEncoding:    aws.ToString(out.ContentEncoding),
+			ContentDisposition: aws.ToString(out.ContentDisposition),
+			Size:               uint64(aws.ToInt64(out.ContentLength)),
+			MD5:                etagMD5(etag),
+			Metadata:           convertMetadata(out.Metadata),
+			Generation:         generation(modTime, etag),
+			MetaGeneration:     1,
+			StorageClass:       string(out.StorageClass),
+			Updated:            modTime,
+			ComponentCount:     1,
+		},
+	}
+	return
+}
+
+// Look up the named object, which must have the given generation unless zero.
+func (b *bucket) find(ctx context.Context, name string, generation int64) (h head, err error) {
+	h, err = b.lookUp(ctx, name)
+	if err != nil {
+		return
+	}
+
+	if h.object == nil {
+		err = &gcs.NotFoundError{Err: fmt.Errorf("object %q not found", name)}
+		return
+	}
+	if generation != 0 && h.object.Generation != generation {
+		err = &gcs.NotFoundError{
+			Err: fmt.Errorf("object %q generation %v not found", name, generation),
+		}
+		return
+	}
+	return
+}
+
+// Check the preconditions of a request to write the named object against it
+// as it is, returning it if it exists.
+func (b *bucket) checkPreconditions(
+	ctx context.Context,
+	name string,
+	generation *int64,
+	metaGeneration *int64) (h head, err error) {
+	if generation == nil && metaGeneration == nil {
+		return
+	}
+
+	h, err = b.lookUp(ctx, name)
+	if err != nil {
+		return
+	}
+
+	if generation != nil {
+		if *generation == 0 && h.object != nil {
+			err = &gcs.PreconditionError{Err: errors.New("precondition failed: object exists")}
+			return
+		}
+		if *generation > 0 {
+			if h.object == nil {
+				err = &gcs.PreconditionError{Err: errors.New("precondition failed: object doesn't exist")}
+				return
+			}
+			if h.object.Generation != *generation {
+				err = &gcs.PreconditionError{
+					Err: fmt.Errorf("precondition failed: object has generation %v", h.object.Generation),
+				}
+				return
+			}
+		}
+	}
+
+	if metaGeneration != nil {
+		if h.object == nil {
+			err = &gcs.PreconditionError{Err: errors.New("precondition failed: object doesn't exist")}
+			return
+		}
+		if h.object.MetaGeneration != *metaGeneration {
+			err = &gcs.PreconditionError{
+				Err: fmt.Errorf("precondition failed: object has meta-generation %v", h.object.MetaGeneration),
+			}
+			return
+		}
+	}
+
+	return
+}
+
+// Return the conditions of a write to an object whose preconditions were
+// checked against it as h, making the write fail if the object has changed
+// since.
+func writeConditions(h head, generation *int64, metaGeneration *int64) (ifMatch *string, ifNoneMatch *string) {
+	switch {
+	case generation == nil && metaGeneration == nil:
+	case h.object == nil:
+		ifNoneMatch = aws.String("*")
+	default:
+		ifMatch = aws.String(h.etag)
+	}
+	return
+}
+
+// Delete the named object, only if it has the given ETag unless nil.
+func (b *bucket) delete(ctx context.Context, name string, etag *string) error {
+	in := &s3.DeleteObjectInput{
+		Bucket:  aws.String(b.name),
+		Key:     aws.String(name),
+		IfMatch: etag,
+	}
+	_, err := b.client.DeleteObject(ctx, in)
+
+	// Some stores don't implement conditional deletes.
+	var re interface{ HTTPStatusCode() int }
+	if etag != nil && errors.As(err, &re) && re.HTTPStatusCode() == http.StatusNotImplemented {
+		in.IfMatch = nil
+		_, err = b.client.DeleteObject(ctx, in)
+	}
+	return convertErr(err)
+}
+
+// Return the copy source of the named object, for copy requests.
+func (b *bucket) copySource(name string) string {
+	segments := strings.Split(name, "/")
+	for i, s := range segments {
+		segments[i] = url.PathEscape(s)
+	}
+	return url.PathEscape(b.name) + "/" + strings.Join(segments, "/")
+}
+
+// Copy the object with the given ETag to the named object, replacing its
+// attributes with those of o unless nil, and return the new object.
+func (b *bucket) copy(ctx context.Context, src head, dstName string, o *gcs.Object) (*gcs.Object, error) {
+	in := &s3.CopyObjectInput{
+		Bucket:            aws.String(b.name),
+		Key:               aws.String(dstName),
+		CopySource:        aws.String(b.copySource(src.object.Name)),
+		CopySourceIfMatch: aws.String(src.etag),
+	}
+	if o != nil {
+		in.MetadataDirective = types.MetadataDirectiveReplace
+		in.Metadata = o.Metadata
+		in.ContentType = aws.String(o.ContentType)
+		in.ContentLanguage = nonEmpty(o.ContentLanguage)
+		in.ContentEncoding = nonEmpty(o.ContentEncoding)
+		in.CacheControl = nonEmpty(o.CacheControl)
+		in.ContentDisposition = nonEmpty(o.ContentDisposition)
+	}
+
+	if _, err := b.client.CopyObject(ctx, in); err != nil {
+		err = convertErr(err)
+
+		// The source was replaced after it was looked up.
+		var preconditionErr *gcs.PreconditionError
+		if errors.As(err, &preconditionErr) {
+			err = &gcs.NotFoundError{Err: err}
+		}
+		return nil, err
+	}
+
+	dst, err := b.find(ctx, dstName, 0)
+	if err != nil {
+		return nil, err
+	}
+	return dst.object, nil
+}
+
+func nonEmpty(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return aws.String(s)
+}
+
+////////////////////////////////////////////////////////////////////////
+// Bucket interface
+////////////////////////////////////////////////////////////////////////
+
+func (b *bucket) Name() string {
+	return b.name
+}
+
+func (b *bucket) BucketType() gcs.BucketType {
+	return gcs.BucketType{}
+}
+
+func (b *bucket) NewReader(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (io.ReadCloser, error) {
+	return b.NewReaderWithReadHandle(ctx, req)
+}
+
+func (b *bucket) NewReaderWithReadHandle(
+	ctx context.Context,
+	req *gcs.ReadObjectRequest) (gcs.StorageReader, error) {
+	h, err := b.find(ctx, req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	// Clip the range to the contents.
+	start, limit := uint64(0), h.object.Size
+	if req.Range != nil {
+		limit = min(req.Range.Limit, h.object.Size)
+		start = min(req.Range.Start, limit)
+	}
+
+	rc, err := b.getRange(ctx, h, int64(start), int64(limit-start))
+	if err != nil {
+		return nil, err
+	}
+	return &reader{ReadCloser: rc}, nil
+}
+
+func (b *bucket) NewMultiRangeDownloader(
+	ctx context.Context,
+	req *gcs.MultiRangeDownloaderRequest) (gcs.MultiRangeDownloader, error) {
+	h, err := b.find(ctx, req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &multiRangeDownloader{ctx: ctx, b: b, h: h}, nil
+}
+
+func (b *bucket) CreateObject(
+	ctx context.Context,
+	req *gcs.CreateObjectRequest) (*gcs.Object, error) {
+	w := newObjectWriter(ctx, b, req, minPartSize, nil)
+	if _, err := io.Copy(w, req.Contents); err != nil {
+		w.abort()
+		return nil, fmt.Errorf("reading contents: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return w.object, nil
+}
+
+func (b *bucket) CreateObjectChunkWriter(ctx context.Context, req *gcs.CreateObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return newObjectWriter(ctx, b, req, max(chunkSize, minPartSize), callBack), nil
+}
+
+func (b *bucket) CreateAppendableObjectWriter(ctx context.Context, req *gcs.AppendObjectRequest, chunkSize int, callBack func(bytesUploadedSoFar int64)) (gcs.Writer, error) {
+	return nil, fmt.Errorf("appending to %q: appendable objects are not supported", req.Name)
+}
+
+func (b *bucket) FinalizeUpload(ctx context.Context, w gcs.Writer) (*gcs.MinObject, error) {
+	ow, ok := w.(*objectWriter)
+	if !ok {
+		return nil, fmt.Errorf("writer of type %T wasn't created by this bucket", w)
+	}
+
+	if err := ow.Close(); err != nil {
+		return nil, err
+	}
+	return storageutil.ConvertObjToMinObject(ow.object), nil
+}
+
+func (b *bucket) CopyObject(
+	ctx context.Context,
+	req *gcs.CopyObjectRequest) (*gcs.Object, error) {
+	src, err := b.find(ctx, req.SrcName, req.SrcGeneration)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.SrcMetaGenerationPrecondition != nil && *req.SrcMetaGenerationPrecondition != src.object.MetaGeneration {
+		return nil, &gcs.PreconditionError{
+			Err: fmt.Errorf("precondition failed: source has meta-generation %v", src.object.MetaGeneration),
+		}
+	}
+	if _, err = b.checkPreconditions(ctx, req.DstName, req.DstGenerationPrecondition, nil); err != nil {
+		return nil, err
+	}
+
+	return b.copy(ctx, src, req.DstName, nil)
+}
+
+// Objects are composed by writing their contents anew, as composing with S3
+// multipart copies requires all but the last of them to be at least 5 MiB.
+func (b *bucket) ComposeObjects(
+	ctx context.Context,
+	req *gcs.ComposeObjectsRequest) (o *gcs.Object, err error) {
+	if len(req.Sources) == 0 {
+		err = errors.New("at least one source is required")
+		return
+	}
+
+	// Pin the sources to their ETags before reading any of them, since the
+	// destination may be among them.
+	sources := make([]head, len(req.Sources))
+	for i, s := range req.Sources {
+		if sources[i], err = b.find(ctx, s.Name, s.Generation); err != nil {
+			return
+		}
+	}
+
+	w := newObjectWriter(ctx, b, &gcs.CreateObjectRequest{
+		Name:                       req.DstName,
+		ContentType:                req.ContentType,
+		Metadata:                   req.Metadata,
+		ContentLanguage:            req.ContentLanguage,
+		ContentEncoding:            req.ContentEncoding,
+		CacheControl:               req.CacheControl,
+		ContentDisposition:         req.ContentDisposition,
+		GenerationPrecondition:     req.DstGenerationPrecondition,
+		MetaGenerationPrecondition: req.DstMetaGenerationPrecondition,
+	}, minPartSize, nil)
+	defer func() {
+		if err != nil {
+			w.abort()
+		}
+	}()
+
+	for _, s := range sources {
+		var rc io.ReadCloser
+		if rc, err = b.getRange(ctx, s, 0, int64(s.object.Size)); err != nil {
+			return
+		}
+		_, err = io.Copy(w, rc)
+		rc.Close()
+		if err != nil {
+			err = fmt.Errorf("copying %q: %w", s.object.Name, err)
+			return
+		}
+	}
+
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	o = w.object
+	o.ComponentCount = int64(len(sources))
+	o.MD5 = nil
+	return
+}
+
+func (b *bucket) StatObject(
+	ctx context.Context,
+	req *gcs.StatObjectRequest) (m *gcs.MinObject, e *gcs.ExtendedObjectAttributes, err error) {
+	h, err := b.find(ctx, req.Name, 0)
+	if err != nil {
+		return
+	}
+
+	m = storageutil.ConvertObjToMinObject(h.object)
+	if req.ReturnExtendedObjectAttributes {
+		e = storageutil.ConvertObjToExtendedObjectAttributes(h.object)
+	}
+	return
+}
+
+// Metadata is updated by copying the object over itself with the new
+// attributes, which gives it a new generation.
+func (b *bucket) UpdateObject(
+	ctx context.Context,
+	req *gcs.UpdateObjectRequest) (*gcs.Object, error) {
+	h, err := b.find(ctx, req.Name, req.Generation)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.MetaGenerationPrecondition != nil && *req.MetaGenerationPrecondition != h.object.MetaGeneration {
+		return nil, &gcs.PreconditionError{
+			Err: fmt.Errorf("precondition failed: object has meta-generation %v", h.object.MetaGeneration),
+		}
+	}
+
+	o := *h.object
+	if req.ContentType != nil {
+		o.ContentType = *req.ContentType
+	}
+	if req.ContentEncoding != nil {
+		o.ContentEncoding = *req.ContentEncoding
+	}
+	if req.ContentLanguage != nil {
+		o.ContentLanguage = *req.ContentLanguage
+	}
+	if req.CacheControl != nil {
+		o.CacheControl = *req.CacheControl
+	}
+
+	o.Metadata = make(map[string]string)
+	for k, v := range h.object.Metadata {
+		o.Metadata[k] = v
+	}
+	for k, v := range req.Metadata {
+		if v == nil {
+			delete(o.Metadata, k)
+		} else {
+			o.Metadata[k] = *v
+		}
+	}
+
+	return b.copy(ctx, h, req.Name, &o)
+}
+
+func (b *bucket) DeleteObject(
+	ctx context.Context,
+	req *gcs.DeleteObjectRequest) error {
+	if req.Generation == 0 && req.MetaGenerationPrecondition == nil {
+		// Deleting a missing object succeeds in S3.
+		return b.delete(ctx, req.Name, nil)
+	}
+
+	h, err := b.find(ctx, req.Name, req.Generation)
+	if err != nil {
+		return err
+	}
+	if req.MetaGenerationPrecondition != nil && *req.MetaGenerationPrecondition != h.object.MetaGeneration {
+		return &gcs.PreconditionError{
+			Err: fmt.Errorf("precondition failed: object has meta-generation %v", h.object.MetaGeneration),
+		}
+	}
+
+	err = b.delete(ctx, req.Name, aws.String(h.etag))
+
+	// The object was replaced after it was looked up.
+	var preconditionErr *gcs.PreconditionError
+	if errors.As(err, &preconditionErr) {
+		err = &gcs.NotFoundError{Err: err}
+	}
+	return err
+}
+
+// Objects are moved by copying and deleting them. The source is only deleted
+// if it is still the object copied, so that a source replaced in between isn't
+// lost.
+func (b *bucket) MoveObject(ctx context.Context, req *gcs.MoveObjectRequest) (*gcs.Object, error) {
+	src, err := b.find(ctx, req.SrcName, req.SrcGeneration)
+	if err != nil {
+		return nil, err
+	}
+	if req.SrcMetaGenerationPrecondition != nil && *req.SrcMetaGenerationPrecondition != src.object.MetaGeneration {
+		return nil, &gcs.PreconditionError{
+			Err: fmt.Errorf("precondition failed: source has meta-generation %v", src.object.MetaGeneration),
+		}
+	}
+
+	// S3 refuses to copy objects over themselves unchanged.
+	if req.SrcName == req.DstName {
+		return src.object, nil
+	}
+
+	o, err := b.copy(ctx, src, req.DstName, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// A source deleted in between is gone all the same.
+	err = b.delete(ctx, req.SrcName, aws.String(src.etag))
+	var notFoundErr *gcs.NotFoundError
+	if err != nil && !errors.As(err, &notFoundErr) {
+		return nil, fmt.Errorf("deleting source: %w", err)
+	}
+	return o, nil
+}
+
+func (b *bucket) DeleteFolder(ctx context.Context, folderName string) error {
+	return errors.New("folders are not supported by S3-compatible buckets")
+}
+
+func (b *bucket) GetFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return nil, errors.New("folders are not supported by S3-compatible buckets")
+}
+
+func (b *bucket) RenameFolder(ctx context.Context, folderName string, destinationFolderId string) (*gcs.Folder, error) {
+	return nil, errors.New("folders are not supported by S3-compatible buckets")
+}
+
+func (b *bucket) CreateFolder(ctx context.Context, folderName string) (*gcs.Folder, error) {
+	return nil, errors.New("folders are not supported by S3-compatible buckets")
+}
@@ -0,0 +1,430 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// racingS3 runs race once, as a concurrent writer would, just before the first
+// write or delete, after the bucket has checked its preconditions.
+type racingS3 struct {
+	*fakeS3
+	race func()
+}
+
+func (r *racingS3) runRace() {
+	if r.race != nil {
+		race := r.race
+		r.race = nil
+		race()
+	}
+}
+
+func (r *racingS3) PutObject(ctx context.Context, in *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	r.runRace()
+	return r.fakeS3.PutObject(ctx, in, optFns...)
+}
+
+func (r *racingS3) CompleteMultipartUpload(ctx context.Context, in *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	r.runRace()
+	return r.fakeS3.CompleteMultipartUpload(ctx, in, optFns...)
+}
+
+func (r *racingS3) DeleteObject(ctx context.Context, in *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	r.runRace()
+	return r.fakeS3.DeleteObject(ctx, in, optFns...)
+}
+
+type S3BucketTest struct {
+	suite.Suite
+	ctx    context.Context
+	s3     *fakeS3
+	bucket gcs.Bucket
+}
+
+func TestS3BucketTestSuite(t *testing.T) {
+	suite.Run(t, new(S3BucketTest))
+}
+
+func (t *S3BucketTest) SetupTest() {
+	t.ctx = context.Background()
+	t.s3 = newFakeS3()
+	t.bucket = NewBucket(t.s3, "some_bucket")
+}
+
+func (t *S3BucketTest) create(name string, contents string) *gcs.Object {
+	o, err := storageutil.CreateObject(t.ctx, t.bucket, name, []byte(contents))
+	require.NoError(t.T(), err)
+	return o
+}
+
+func (t *S3BucketTest) read(name string) string {
+	contents, err := storageutil.ReadObject(t.ctx, t.bucket, name)
+	require.NoError(t.T(), err)
+	return string(contents)
+}
+
+func (t *S3BucketTest) assertNotFound(err error) {
+	var notFoundErr *gcs.NotFoundError
+	assert.True(t.T(), errors.As(err, &notFoundErr), "%v", err)
+}
+
+func (t *S3BucketTest) TestGenerationDependsOnSecondAndETag() {
+	modTime := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	gen := generation(modTime, `"abc"`)
+
+	assert.Equal(t.T(), gen, generation(modTime.Add(time.Millisecond), "abc"))
+	assert.NotEqual(t.T(), gen, generation(modTime, `"abd"`))
+	assert.Greater(t.T(), generation(modTime.Add(time.Second), `"abc"`), gen)
+}
+
+func (t *S3BucketTest) TestCreateObject() {
+	o, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:        "foo",
+		Contents:    strings.NewReader("taco"),
+		ContentType: "text/plain",
+		Metadata:    map[string]string{"gcsfuse_mtime": "some time"},
+	})
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 4, o.Size)
+	assert.EqualValues(t.T(), 1, o.MetaGeneration)
+	assert.Equal(t.T(), storageutil.MD5([]byte("taco")), o.MD5)
+	m, e, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo", ReturnExtendedObjectAttributes: true})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), o.Generation, m.Generation)
+	assert.Equal(t.T(), map[string]string{"gcsfuse_mtime": "some time"}, m.Metadata)
+	assert.Equal(t.T(), "text/plain", e.ContentType)
+}
+
+func (t *S3BucketTest) TestCreateObjectChecksPreconditions() {
+	o := t.create("foo", "taco")
+	zero := int64(0)
+	stale := o.Generation + 1
+
+	_, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		Contents:               strings.NewReader("burrito"),
+		GenerationPrecondition: &zero,
+	})
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr))
+	_, err = t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		Contents:               strings.NewReader("burrito"),
+		GenerationPrecondition: &stale,
+	})
+	assert.True(t.T(), errors.As(err, &preconditionErr))
+	newO, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		Contents:               strings.NewReader("burrito"),
+		GenerationPrecondition: &o.Generation,
+	})
+	require.NoError(t.T(), err)
+	assert.NotEqual(t.T(), o.Generation, newO.Generation)
+	assert.Equal(t.T(), "burrito", t.read("foo"))
+}
+
+// Make the bucket race with a write of the named object with the given
+// contents.
+func (t *S3BucketTest) raceWithWrite(name string, contents string) {
+	plain := t.bucket
+	t.bucket = NewBucket(&racingS3{fakeS3: t.s3, race: func() {
+		_, err := storageutil.CreateObject(t.ctx, plain, name, []byte(contents))
+		require.NoError(t.T(), err)
+	}}, "some_bucket")
+}
+
+func (t *S3BucketTest) TestCreateObjectFailsIfReplacedAfterPreconditionCheck() {
+	o := t.create("foo", "taco")
+	t.raceWithWrite("foo", "enchilada")
+
+	_, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		Contents:               strings.NewReader("burrito"),
+		GenerationPrecondition: &o.Generation,
+	})
+
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr), "%v", err)
+	assert.Equal(t.T(), "enchilada", t.read("foo"))
+}
+
+func (t *S3BucketTest) TestChunkWriterFailsIfCreatedAfterPreconditionCheck() {
+	zero := int64(0)
+	t.raceWithWrite("foo", "enchilada")
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{
+		Name:                   "foo",
+		GenerationPrecondition: &zero,
+	}, minPartSize, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write(make([]byte, minPartSize+1))
+	require.NoError(t.T(), err)
+
+	_, err = t.bucket.FinalizeUpload(t.ctx, w)
+
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr), "%v", err)
+	assert.Empty(t.T(), t.s3.uploads)
+	assert.Equal(t.T(), "enchilada", t.read("foo"))
+}
+
+func (t *S3BucketTest) TestCreateObjectChecksCRC32C() {
+	crc := uint32(17)
+
+	_, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "foo",
+		Contents: strings.NewReader("taco"),
+		CRC32C:   &crc,
+	})
+
+	assert.ErrorContains(t.T(), err, "CRC32C mismatch")
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	t.assertNotFound(err)
+}
+
+func (t *S3BucketTest) TestNewReaderReadsRangeOfGeneration() {
+	o := t.create("foo", "taco burrito")
+
+	rc, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:       "foo",
+		Generation: o.Generation,
+		Range:      &gcs.ByteRange{Start: 5, Limit: 100},
+	})
+
+	require.NoError(t.T(), err)
+	contents, err := io.ReadAll(rc)
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "burrito", string(contents))
+	assert.NoError(t.T(), rc.Close())
+	rc, err = t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{
+		Name:  "foo",
+		Range: &gcs.ByteRange{Start: 12, Limit: 12},
+	})
+	require.NoError(t.T(), err)
+	contents, err = io.ReadAll(rc)
+	require.NoError(t.T(), err)
+	assert.Empty(t.T(), contents)
+}
+
+func (t *S3BucketTest) TestNewReaderOfReplacedGeneration() {
+	o := t.create("foo", "taco")
+	t.create("foo", "burrito")
+
+	_, err := t.bucket.NewReader(t.ctx, &gcs.ReadObjectRequest{Name: "foo", Generation: o.Generation})
+
+	t.assertNotFound(err)
+}
+
+func (t *S3BucketTest) TestChunkWriterUploadsParts() {
+	contents := bytes.Repeat([]byte("x"), 2*minPartSize+10)
+	var uploaded []int64
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "foo",
+		Metadata: map[string]string{"a": "b"},
+	}, 1024, func(n int64) { uploaded = append(uploaded, n) })
+	require.NoError(t.T(), err)
+	_, err = w.Write(contents)
+	require.NoError(t.T(), err)
+
+	m, err := t.bucket.FinalizeUpload(t.ctx, w)
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), len(contents), m.Size)
+	assert.Equal(t.T(), m.Generation, w.Attrs().Generation)
+	assert.Equal(t.T(), []int64{minPartSize, 2 * minPartSize, int64(len(contents))}, uploaded)
+	assert.Equal(t.T(), string(contents), t.read("foo"))
+	stat, _, err := t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo", ReturnExtendedObjectAttributes: true})
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), map[string]string{"a": "b"}, stat.Metadata)
+}
+
+func (t *S3BucketTest) TestFailedChunkWriterAbortsUpload() {
+	md5 := storageutil.MD5([]byte("taco"))
+	w, err := t.bucket.CreateObjectChunkWriter(t.ctx, &gcs.CreateObjectRequest{Name: "foo", MD5: md5}, minPartSize, nil)
+	require.NoError(t.T(), err)
+	_, err = w.Write(make([]byte, minPartSize+1))
+	require.NoError(t.T(), err)
+	require.Len(t.T(), t.s3.uploads, 1)
+
+	_, err = t.bucket.FinalizeUpload(t.ctx, w)
+
+	assert.ErrorContains(t.T(), err, "MD5 mismatch")
+	assert.Empty(t.T(), t.s3.uploads)
+	assert.Empty(t.T(), t.s3.objects)
+}
+
+func (t *S3BucketTest) TestMultiRangeDownloader() {
+	t.create("foo", "taco burrito enchilada")
+	mrd, err := t.bucket.NewMultiRangeDownloader(t.ctx, &gcs.MultiRangeDownloaderRequest{Name: "foo"})
+	require.NoError(t.T(), err)
+	var first, second bytes.Buffer
+
+	mrd.Add(&first, 0, 4, func(int64, int64, error) {})
+	mrd.Add(&second, 13, 100, func(int64, int64, error) {})
+	mrd.Wait()
+
+	assert.Equal(t.T(), "taco", first.String())
+	assert.Equal(t.T(), "enchilada", second.String())
+	assert.NoError(t.T(), mrd.Close())
+}
+
+func (t *S3BucketTest) TestListObjectsWithDelimiter() {
+	a := t.create("a", "taco")
+	t.create("b/", "")
+	t.create("b/c", "")
+	t.create("d/e", "")
+
+	listing, err := t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Delimiter: "/", IncludeTrailingDelimiter: true})
+
+	require.NoError(t.T(), err)
+	require.Len(t.T(), listing.MinObjects, 2)
+	assert.Equal(t.T(), "a", listing.MinObjects[0].Name)
+	assert.Equal(t.T(), a.Generation, listing.MinObjects[0].Generation)
+	assert.EqualValues(t.T(), 4, listing.MinObjects[0].Size)
+	assert.Equal(t.T(), "b/", listing.MinObjects[1].Name)
+	assert.Equal(t.T(), []string{"b/", "d/"}, listing.CollapsedRuns)
+	listing, err = t.bucket.ListObjects(t.ctx, &gcs.ListObjectsRequest{Delimiter: "/"})
+	require.NoError(t.T(), err)
+	assert.Len(t.T(), listing.MinObjects, 1)
+}
+
+func (t *S3BucketTest) TestListObjectsPaginates() {
+	for _, name := range []string{"a", "b/c", "b/d", "e", "f"} {
+		t.create(name, "")
+	}
+	var names, runs []string
+	req := &gcs.ListObjectsRequest{Delimiter: "/", MaxResults: 2}
+
+	for {
+		listing, err := t.bucket.ListObjects(t.ctx, req)
+		require.NoError(t.T(), err)
+		for _, m := range listing.MinObjects {
+			names = append(names, m.Name)
+		}
+		runs = append(runs, listing.CollapsedRuns...)
+		if listing.ContinuationToken == "" {
+			break
+		}
+		req.ContinuationToken = listing.ContinuationToken
+	}
+
+	assert.Equal(t.T(), []string{"a", "e", "f"}, names)
+	assert.Equal(t.T(), []string{"b/"}, runs)
+}
+
+func (t *S3BucketTest) TestCopyObject() {
+	src := t.create("foo", "taco")
+
+	o, err := t.bucket.CopyObject(t.ctx, &gcs.CopyObjectRequest{
+		SrcName:       "foo",
+		SrcGeneration: src.Generation,
+		DstName:       "dir/bar baz",
+	})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "dir/bar baz", o.Name)
+	assert.Equal(t.T(), "taco", t.read("dir/bar baz"))
+	assert.Equal(t.T(), "taco", t.read("foo"))
+}
+
+func (t *S3BucketTest) TestComposeObjects() {
+	src := t.create("foo", "taco")
+	t.create("bar", "burrito")
+
+	o, err := t.bucket.ComposeObjects(t.ctx, &gcs.ComposeObjectsRequest{
+		DstName:                   "foo",
+		DstGenerationPrecondition: &src.Generation,
+		Sources:                   []gcs.ComposeSource{{Name: "foo", Generation: src.Generation}, {Name: "bar"}},
+	})
+
+	require.NoError(t.T(), err)
+	assert.EqualValues(t.T(), 2, o.ComponentCount)
+	assert.EqualValues(t.T(), len("tacoburrito"), o.Size)
+	assert.Equal(t.T(), "tacoburrito", t.read("foo"))
+}
+
+func (t *S3BucketTest) TestMoveObject() {
+	t.create("foo", "taco")
+
+	o, err := t.bucket.MoveObject(t.ctx, &gcs.MoveObjectRequest{SrcName: "foo", DstName: "bar"})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "bar", o.Name)
+	assert.Equal(t.T(), "taco", t.read("bar"))
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	t.assertNotFound(err)
+}
+
+func (t *S3BucketTest) TestMoveObjectKeepsSourceReplacedAfterCopy() {
+	t.create("foo", "taco")
+	t.raceWithWrite("foo", "burrito")
+
+	_, err := t.bucket.MoveObject(t.ctx, &gcs.MoveObjectRequest{SrcName: "foo", DstName: "bar"})
+
+	var preconditionErr *gcs.PreconditionError
+	assert.True(t.T(), errors.As(err, &preconditionErr), "%v", err)
+	assert.Equal(t.T(), "taco", t.read("bar"))
+	assert.Equal(t.T(), "burrito", t.read("foo"))
+}
+
+func (t *S3BucketTest) TestUpdateObject() {
+	_, err := t.bucket.CreateObject(t.ctx, &gcs.CreateObjectRequest{
+		Name:     "foo",
+		Contents: strings.NewReader("taco"),
+		Metadata: map[string]string{"a": "b", "c": "d"},
+	})
+	require.NoError(t.T(), err)
+	contentType := "text/plain"
+	value := "e"
+
+	o, err := t.bucket.UpdateObject(t.ctx, &gcs.UpdateObjectRequest{
+		Name:        "foo",
+		ContentType: &contentType,
+		Metadata:    map[string]*string{"a": nil, "c": &value},
+	})
+
+	require.NoError(t.T(), err)
+	assert.Equal(t.T(), "text/plain", o.ContentType)
+	assert.Equal(t.T(), map[string]string{"c": "e"}, o.Metadata)
+	assert.Equal(t.T(), "taco", t.read("foo"))
+}
+
+func (t *S3BucketTest) TestDeleteObject() {
+	o := t.create("foo", "taco")
+
+	err := t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo", Generation: o.Generation + 1})
+	t.assertNotFound(err)
+	err = t.bucket.DeleteObject(t.ctx, &gcs.DeleteObjectRequest{Name: "foo", Generation: o.Generation})
+
+	require.NoError(t.T(), err)
+	_, _, err = t.bucket.StatObject(t.ctx, &gcs.StatObjectRequest{Name: "foo"})
+	t.assertNotFound(err)
+}
@@ -0,0 +1,341 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3compat
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+type fakeS3Upload struct {
+	parts       map[int32][]byte
+	contentType string
+	metadata    map[string]string
+}
+
+type fakeS3Object struct {
+	contents    []byte
+	etag        string
+	modTime     time.Time
+	contentType string
+	metadata    map[string]string
+}
+
+// fakeS3 is an in-memory stand-in for a single bucket of an S3-compatible
+// store, implementing the requests the bucket makes. Each write happens a
+// second after the previous one.
+type fakeS3 struct {
+	mu      sync.Mutex
+	now     time.Time
+	objects map[string]*fakeS3Object
+	uploads map[string]*fakeS3Upload
+	nextID  int
+}
+
+func newFakeS3() *fakeS3 {
+	return &fakeS3{
+		now:     time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		objects: make(map[string]*fakeS3Object),
+		uploads: make(map[string]*fakeS3Upload),
+	}
+}
+
+// Return an error as the client does for a response with the status.
+func fakeS3Error(code string, status int) error {
+	return &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: status}},
+			Err:      errors.New(code),
+		},
+		RequestID: "request-id",
+	}
+}
+
+func (f *fakeS3) putLocked(key string, o *fakeS3Object) {
+	f.now = f.now.Add(time.Second)
+	o.modTime = f.now
+	if o.etag == "" {
+		o.etag = fmt.Sprintf(`"%x"`, md5.Sum(o.contents))
+	}
+	f.objects[key] = o
+}
+
+func (f *fakeS3) findLocked(key string, ifMatch *string) (*fakeS3Object, error) {
+	o, ok := f.objects[key]
+	if !ok {
+		return nil, fakeS3Error("NoSuchKey", http.StatusNotFound)
+	}
+	if ifMatch != nil && *ifMatch != o.etag {
+		return nil, fakeS3Error("PreconditionFailed", http.StatusPreconditionFailed)
+	}
+	return o, nil
+}
+
+// Check the conditions of a write to the key.
+func (f *fakeS3) checkWriteLocked(key string, ifMatch *string, ifNoneMatch *string) error {
+	if ifNoneMatch != nil {
+		if _, ok := f.objects[key]; ok {
+			return fakeS3Error("PreconditionFailed", http.StatusPreconditionFailed)
+		}
+	}
+	if ifMatch != nil {
+		if _, err := f.findLocked(key, ifMatch); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *fakeS3) HeadObject(_ context.Context, in *s3.HeadObjectInput, _ ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o, err := f.findLocked(*in.Key, in.IfMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	// Like some stores, canonicalize the keys of the metadata headers.
+	metadata := make(map[string]string)
+	for k, v := range o.metadata {
+		metadata[http.CanonicalHeaderKey(k)] = v
+	}
+	return &s3.HeadObjectOutput{
+		ContentLength: aws.Int64(int64(len(o.contents))),
+		ContentType:   aws.String(o.contentType),
+		ETag:          aws.String(o.etag),
+		LastModified:  aws.Time(o.modTime),
+		Metadata:      metadata,
+	}, nil
+}
+
+func (f *fakeS3) GetObject(_ context.Context, in *s3.GetObjectInput, _ ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	o, err := f.findLocked(*in.Key, in.IfMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := o.contents
+	if in.Range != nil {
+		var start, end int
+		if _, err := fmt.Sscanf(*in.Range, "bytes=%d-%d", &start, &end); err != nil || start > end || start >= len(contents) {
+			return nil, fakeS3Error("InvalidRange", http.StatusRequestedRangeNotSatisfiable)
+		}
+		contents = contents[start:min(end+1, len(contents))]
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(contents))}, nil
+}
+
+func (f *fakeS3) PutObject(_ context.Context, in *s3.PutObjectInput, _ ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	contents, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if err = f.checkWriteLocked(*in.Key, in.IfMatch, in.IfNoneMatch); err != nil {
+		return nil, err
+	}
+	f.putLocked(*in.Key, &fakeS3Object{
+		contents:    contents,
+		contentType: aws.ToString(in.ContentType),
+		metadata:    in.Metadata,
+	})
+	return &s3.PutObjectOutput{ETag: aws.String(f.objects[*in.Key].etag)}, nil
+}
+
+func (f *fakeS3) CopyObject(_ context.Context, in *s3.CopyObjectInput, _ ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	source, err := url.PathUnescape(*in.CopySource)
+	if err != nil {
+		return nil, err
+	}
+	_, key, _ := strings.Cut(source, "/")
+	src, err := f.findLocked(key, in.CopySourceIfMatch)
+	if err != nil {
+		return nil, err
+	}
+
+	dst := &fakeS3Object{
+		contents:    src.contents,
+		etag:        src.etag,
+		contentType: src.contentType,
+		metadata:    src.metadata,
+	}
+	if in.MetadataDirective == types.MetadataDirectiveReplace {
+		dst.contentType = aws.ToString(in.ContentType)
+		dst.metadata = in.Metadata
+	} else if key == *in.Key {
+		return nil, fakeS3Error("InvalidRequest", http.StatusBadRequest)
+	}
+	f.putLocked(*in.Key, dst)
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (f *fakeS3) DeleteObject(_ context.Context, in *s3.DeleteObjectInput, _ ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.checkWriteLocked(*in.Key, in.IfMatch, nil); err != nil {
+		return nil, err
+	}
+	delete(f.objects, *in.Key)
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+// Continuation tokens are the last key or common prefix returned.
+func (f *fakeS3) ListObjectsV2(_ context.Context, in *s3.ListObjectsV2Input, _ ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	prefix := aws.ToString(in.Prefix)
+	delimiter := aws.ToString(in.Delimiter)
+	after := aws.ToString(in.ContinuationToken)
+	var keys []string
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	out := &s3.ListObjectsV2Output{}
+	var results int32
+	var last string
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) {
+			continue
+		}
+		entry := k
+		isPrefix := false
+		if delimiter != "" {
+			if i := strings.Index(k[len(prefix):], delimiter); i >= 0 {
+				entry = k[:len(prefix)+i+len(delimiter)]
+				isPrefix = true
+			}
+		}
+		if entry <= after || entry == last {
+			continue
+		}
+		if results == aws.ToInt32(in.MaxKeys) {
+			out.IsTruncated = aws.Bool(true)
+			out.NextContinuationToken = aws.String(last)
+			break
+		}
+
+		if isPrefix {
+			out.CommonPrefixes = append(out.CommonPrefixes, types.CommonPrefix{Prefix: aws.String(entry)})
+		} else {
+			o := f.objects[k]
+			out.Contents = append(out.Contents, types.Object{
+				Key:          aws.String(k),
+				Size:         aws.Int64(int64(len(o.contents))),
+				ETag:         aws.String(o.etag),
+				LastModified: aws.Time(o.modTime),
+			})
+		}
+		results++
+		last = entry
+	}
+	return out, nil
+}
+
+func (f *fakeS3) CreateMultipartUpload(_ context.Context, in *s3.CreateMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	id := fmt.Sprint(f.nextID)
+	f.uploads[id] = &fakeS3Upload{
+		parts:       make(map[int32][]byte),
+		contentType: aws.ToString(in.ContentType),
+		metadata:    in.Metadata,
+	}
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (f *fakeS3) UploadPart(_ context.Context, in *s3.UploadPartInput, _ ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	contents, err := io.ReadAll(in.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	upload, ok := f.uploads[*in.UploadId]
+	if !ok {
+		return nil, fakeS3Error("NoSuchUpload", http.StatusNotFound)
+	}
+	upload.parts[*in.PartNumber] = contents
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf(`"%x"`, md5.Sum(contents)))}, nil
+}
+
+func (f *fakeS3) CompleteMultipartUpload(_ context.Context, in *s3.CompleteMultipartUploadInput, _ ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	upload, ok := f.uploads[*in.UploadId]
+	if !ok {
+		return nil, fakeS3Error("NoSuchUpload", http.StatusNotFound)
+	}
+	if err := f.checkWriteLocked(*in.Key, in.IfMatch, in.IfNoneMatch); err != nil {
+		return nil, err
+	}
+	delete(f.uploads, *in.UploadId)
+
+	var contents []byte
+	for i, p := range in.MultipartUpload.Parts {
+		part := upload.parts[*p.PartNumber]
+		if i < len(in.MultipartUpload.Parts)-1 && len(part) < minPartSize {
+			return nil, fakeS3Error("EntityTooSmall", http.StatusBadRequest)
+		}
+		contents = append(contents, part...)
+	}
+	f.putLocked(*in.Key, &fakeS3Object{
+		contents:    contents,
+		etag:        fmt.Sprintf(`"%x-%d"`, md5.Sum(contents), len(in.MultipartUpload.Parts)),
+		contentType: upload.contentType,
+		metadata:    upload.metadata,
+	})
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (f *fakeS3) AbortMultipartUpload(_ context.Context, in *s3.AbortMultipartUploadInput, _ ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	delete(f.uploads, *in.UploadId)
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
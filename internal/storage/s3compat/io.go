@@ -0,0 +1,373 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3compat
+
+import (
+	"bytes"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"golang.org/x/net/context"
+)
+
+// objectWriter uploads the contents written to it in parts of partSize bytes,
+// or in a single request if they fit in one, and creates the object when
+// closed.
+type objectWriter struct {
+	ctx      context.Context
+	b        *bucket
+	req      *gcs.CreateObjectRequest
+	partSize int
+	callBack func(bytesUploadedSoFar int64)
+
+	// The contents not yet uploaded, and the multipart upload once started.
+	buf      []byte
+	uploadID *string
+	parts    []types.CompletedPart
+	uploaded int64
+
+	crc32c hash.Hash32
+	md5    hash.Hash
+	attrs  storage.ObjectAttrs
+
+	// The first error, after which nothing more is uploaded.
+	err error
+
+	// Set when closed.
+	closed bool
+	object *gcs.Object
+}
+
+func newObjectWriter(
+	ctx context.Context,
+	b *bucket,
+	req *gcs.CreateObjectRequest,
+	partSize int,
+	callBack func(int64)) *objectWriter {
+	return &objectWriter{
+		ctx:      ctx,
+		b:        b,
+		req:      req,
+		partSize: partSize,
+		callBack: callBack,
+		crc32c:   crc32.New(crc32cTable),
+		md5:      md5.New(),
+		attrs: storage.ObjectAttrs{
+			Name:        req.Name,
+			ContentType: req.ContentType,
+		},
+	}
+}
+
+func (w *objectWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, fmt.Errorf("writer for %q is closed", w.req.Name)
+	}
+	if w.err != nil {
+		return 0, w.err
+	}
+
+	w.crc32c.Write(p)
+	w.md5.Write(p)
+	w.buf = append(w.buf, p...)
+	for len(w.buf) >= w.partSize {
+		if w.err = w.uploadPart(w.buf[:w.partSize]); w.err != nil {
+			return 0, w.err
+		}
+		w.buf = append(w.buf[:0], w.buf[w.partSize:]...)
+	}
+
+	return len(p), nil
+}
+
+// Upload the next part, starting the multipart upload if need be.
+func (w *objectWriter) uploadPart(data []byte) error {
+	if w.uploadID == nil {
+		out, err := w.b.client.CreateMultipartUpload(w.ctx, &s3.CreateMultipartUploadInput{
+			Bucket:             aws.String(w.b.name),
+			Key:                aws.String(w.req.Name),
+			ContentType:        nonEmpty(w.req.ContentType),
+			ContentLanguage:    nonEmpty(w.req.ContentLanguage),
+			ContentEncoding:    nonEmpty(w.req.ContentEncoding),
+			CacheControl:       nonEmpty(w.req.CacheControl),
+			ContentDisposition: nonEmpty(w.req.ContentDisposition),
+			Metadata:           w.req.Metadata,
+		})
+		if err != nil {
+			return fmt.Errorf("CreateMultipartUpload: %w", convertErr(err))
+		}
+		w.uploadID = out.UploadId
+	}
+
+	partNumber := int32(len(w.parts) + 1)
+	out, err := w.b.client.UploadPart(w.ctx, &s3.UploadPartInput{
+		Bucket:        aws.String(w.b.name),
+		Key:           aws.String(w.req.Name),
+		UploadId:      w.uploadID,
+		PartNumber:    aws.Int32(partNumber),
+		Body:          bytes.NewReader(data),
+		ContentLength: aws.Int64(int64(len(data))),
+	})
+	if err != nil {
+		return fmt.Errorf("UploadPart: %w", convertErr(err))
+	}
+
+	w.parts = append(w.parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(partNumber)})
+	w.uploaded += int64(len(data))
+	if w.callBack != nil {
+		w.callBack(w.uploaded)
+	}
+	return nil
+}
+
+// Check the contents and preconditions, and upload what's left.
+func (w *objectWriter) finish() error {
+	var sum [md5.Size]byte
+	w.md5.Sum(sum[:0])
+	if w.req.CRC32C != nil && *w.req.CRC32C != w.crc32c.Sum32() {
+		return fmt.Errorf("CRC32C mismatch: got 0x%08x, expected 0x%08x", w.crc32c.Sum32(), *w.req.CRC32C)
+	}
+	if w.req.MD5 != nil && *w.req.MD5 != sum {
+		return fmt.Errorf("MD5 mismatch: got %x, expected %x", sum, *w.req.MD5)
+	}
+
+	h, err := w.b.checkPreconditions(w.ctx, w.req.Name, w.req.GenerationPrecondition, w.req.MetaGenerationPrecondition)
+	if err != nil {
+		return err
+	}
+	ifMatch, ifNoneMatch := writeConditions(h, w.req.GenerationPrecondition, w.req.MetaGenerationPrecondition)
+
+	if w.uploadID == nil {
+		_, err = w.b.client.PutObject(w.ctx, &s3.PutObjectInput{
+			Bucket:             aws.String(w.b.name),
+			Key:                aws.String(w.req.Name),
+			Body:               bytes.NewReader(w.buf),
+			ContentLength:      aws.Int64(int64(len(w.buf))),
+			ContentType:        nonEmpty(w.req.ContentType),
+			ContentLanguage:    nonEmpty(w.req.ContentLanguage),
+			ContentEncoding:    nonEmpty(w.req.ContentEncoding),
+			CacheControl:       nonEmpty(w.req.CacheControl),
+			ContentDisposition: nonEmpty(w.req.ContentDisposition),
+			Metadata:           w.req.Metadata,
+			IfMatch:            ifMatch,
+			IfNoneMatch:        ifNoneMatch,
+		})
+		if err != nil {
+			return fmt.Errorf("PutObject: %w", convertWriteErr(err, ifMatch))
+		}
+	} else {
+		if len(w.buf) > 0 {
+			if err = w.uploadPart(w.buf); err != nil {
+				return err
+			}
+			w.buf = nil
+		}
+
+		_, err = w.b.client.CompleteMultipartUpload(w.ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(w.b.name),
+			Key:             aws.String(w.req.Name),
+			UploadId:        w.uploadID,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: w.parts},
+			IfMatch:         ifMatch,
+			IfNoneMatch:     ifNoneMatch,
+		})
+		if err != nil {
+			return fmt.Errorf("CompleteMultipartUpload: %w", convertWriteErr(err, ifMatch))
+		}
+		w.uploadID = nil
+	}
+
+	if h, err = w.b.find(w.ctx, w.req.Name, 0); err != nil {
+		return err
+	}
+	w.object = h.object
+	return nil
+}
+
+func (w *objectWriter) Close() error {
+	if w.closed {
+		return w.err
+	}
+	w.closed = true
+
+	if w.err == nil {
+		w.err = w.finish()
+	}
+	if w.err != nil {
+		w.abort()
+		return w.err
+	}
+
+	w.attrs.Size = int64(w.object.Size)
+	w.attrs.Generation = w.object.Generation
+	w.attrs.Metageneration = w.object.MetaGeneration
+	if w.object.MD5 != nil {
+		w.attrs.MD5 = w.object.MD5[:]
+	}
+	w.attrs.Updated = w.object.Updated
+	return nil
+}
+
+// Abort the multipart upload, if started, so that the store drops its parts.
+func (w *objectWriter) abort() {
+	if w.uploadID == nil {
+		return
+	}
+
+	// The upload may have been abandoned because the context was cancelled.
+	w.b.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+		Bucket:   aws.String(w.b.name),
+		Key:      aws.String(w.req.Name),
+		UploadId: w.uploadID,
+	})
+	w.uploadID = nil
+}
+
+// Flush fails: objects are only created when the writer is closed.
+func (w *objectWriter) Flush() (int64, error) {
+	return 0, fmt.Errorf("flushing %q: %w", w.req.Name, errors.ErrUnsupported)
+}
+
+func (w *objectWriter) ObjectName() string {
+	return w.req.Name
+}
+
+func (w *objectWriter) Attrs() *storage.ObjectAttrs {
+	return &w.attrs
+}
+
+// Read length bytes of the contents of the object at the offset, failing with
+// a not found error if the object has been replaced.
+func (b *bucket) getRange(ctx context.Context, h head, offset int64, length int64) (io.ReadCloser, error) {
+	// S3 has no empty ranges.
+	if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	}
+
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket:  aws.String(b.name),
+		Key:     aws.String(h.object.Name),
+		Range:   aws.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+		IfMatch: aws.String(h.etag),
+	})
+	if err != nil {
+		err = convertErr(err)
+		var preconditionErr *gcs.PreconditionError
+		if errors.As(err, &preconditionErr) {
+			err = &gcs.NotFoundError{Err: err}
+		}
+		return nil, err
+	}
+	return out.Body, nil
+}
+
+// reader reads a range of the contents of an object.
+type reader struct {
+	io.ReadCloser
+}
+
+func (r *reader) ReadHandle() storage.ReadHandle {
+	return nil
+}
+
+// multiRangeDownloader reads ranges of the contents of an object with
+// concurrent requests.
+type multiRangeDownloader struct {
+	ctx context.Context
+	b   *bucket
+	h   head
+	wg  sync.WaitGroup
+
+	mu  sync.Mutex
+	err error // GUARDED_BY(mu)
+}
+
+func (d *multiRangeDownloader) setErr(err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.err == nil {
+		d.err = err
+	}
+}
+
+func (d *multiRangeDownloader) Add(output io.Writer, offset, length int64, callback func(int64, int64, error)) {
+	// Apply the input checks of the storage client.
+	size := int64(d.h.object.Size)
+	var err error
+	if length < 0 {
+		err = fmt.Errorf("length < 0")
+	} else if offset > size {
+		err = fmt.Errorf("out of range. offset (%v) > size of content (%v) of %s", offset, size, d.h.object.Name)
+	} else if offset <= -size {
+		offset = 0
+		length = size
+	} else if offset < 0 {
+		offset = size + offset
+		length = min(length, size-offset)
+	} else {
+		length = min(length, size-offset)
+	}
+	if err != nil {
+		d.setErr(err)
+		if callback != nil {
+			callback(offset, length, err)
+		}
+		return
+	}
+
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+
+		var n int64
+		rc, err := d.b.getRange(d.ctx, d.h, offset, length)
+		if err == nil {
+			n, err = io.Copy(output, rc)
+			rc.Close()
+		}
+		if err == nil && n != length {
+			err = fmt.Errorf("read %d bytes of %s at offset %d, expected %d", n, d.h.object.Name, offset, length)
+		}
+		if err != nil {
+			d.setErr(err)
+		}
+		if callback != nil {
+			callback(offset, n, err)
+		}
+	}()
+}
+
+func (d *multiRangeDownloader) Close() error {
+	d.Wait()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.err
+}
+
+func (d *multiRangeDownloader) Wait() {
+	d.wg.Wait()
+}
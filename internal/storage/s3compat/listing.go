@@ -0,0 +1,127 @@
+// Copyright 2025 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package s3compat
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/gcs"
+	"github.com/googlecloudplatform/gcsfuse/v2/internal/storage/storageutil"
+	"golang.org/x/net/context"
+	"golang.org/x/sync/errgroup"
+)
+
+// Convert an object of a listing, which lacks user metadata.
+func convertListedObject(o types.Object) *gcs.MinObject {
+	etag := aws.ToString(o.ETag)
+	modTime := aws.ToTime(o.LastModified)
+	return &gcs.MinObject{
+		Name:           aws.ToString(o.Key),
+		Size:           uint64(aws.ToInt64(o.Size)),
+		Generation:     generation(modTime, etag),
+		MetaGeneration: 1,
+		Updated:        modTime,
+	}
+}
+
+// Look up the objects named like the collapsed runs, which GCS returns along
+// with them if asked to, but S3 never does.
+func (b *bucket) lookUpRuns(ctx context.Context, runs []string) ([]*gcs.MinObject, error) {
+	objects := make([]*gcs.MinObject, len(runs))
+	group, ctx := errgroup.WithContext(ctx)
+	group.SetLimit(listLookUpConcurrency)
+	for i, run := range runs {
+		group.Go(func() error {
+			h, err := b.lookUp(ctx, run)
+			if err != nil {
+				return fmt.Errorf("looking up %q: %w", run, err)
+			}
+			if h.object != nil {
+				objects[i] = storageutil.ConvertObjToMinObject(h.object)
+			}
+			return nil
+		})
+	}
+	if err := group.Wait(); err != nil {
+		return nil, err
+	}
+
+	var found []*gcs.MinObject
+	for _, o := range objects {
+		if o != nil {
+			found = append(found, o)
+		}
+	}
+	return found, nil
+}
+
+func (b *bucket) ListObjects(
+	ctx context.Context,
+	req *gcs.ListObjectsRequest) (*gcs.Listing, error) {
+	if req.Versions {
+		return nil, errors.New("listing noncurrent versions is not supported by S3-compatible buckets")
+	}
+
+	maxResults := req.MaxResults
+	if maxResults <= 0 || maxResults > maxListResults {
+		maxResults = maxListResults
+	}
+
+	in := &s3.ListObjectsV2Input{
+		Bucket:  aws.String(b.name),
+		Prefix:  nonEmpty(req.Prefix),
+		MaxKeys: aws.Int32(int32(maxResults)),
+	}
+	if req.Delimiter != "" {
+		in.Delimiter = aws.String(req.Delimiter)
+	}
+	if req.ContinuationToken != "" {
+		in.ContinuationToken = aws.String(req.ContinuationToken)
+	}
+
+	out, err := b.client.ListObjectsV2(ctx, in)
+	if err != nil {
+		return nil, convertErr(err)
+	}
+
+	listing := new(gcs.Listing)
+	for _, o := range out.Contents {
+		listing.MinObjects = append(listing.MinObjects, convertListedObject(o))
+	}
+	for _, p := range out.CommonPrefixes {
+		listing.CollapsedRuns = append(listing.CollapsedRuns, aws.ToString(p.Prefix))
+	}
+	if aws.ToBool(out.IsTruncated) {
+		listing.ContinuationToken = aws.ToString(out.NextContinuationToken)
+	}
+
+	if req.Delimiter != "" && req.IncludeTrailingDelimiter && len(listing.CollapsedRuns) > 0 {
+		found, err := b.lookUpRuns(ctx, listing.CollapsedRuns)
+		if err != nil {
+			return nil, err
+		}
+		listing.MinObjects = append(listing.MinObjects, found...)
+		sort.Slice(listing.MinObjects, func(i, j int) bool {
+			return listing.MinObjects[i].Name < listing.MinObjects[j].Name
+		})
+	}
+
+	return listing, nil
+}
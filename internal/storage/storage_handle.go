@@ -55,7 +55,7 @@ type StorageHandle interface {
 	// to that project rather than to the bucket's owning project.
 	//
 	// A user-project is required for all operations on Requester Pays buckets.
-	BucketHandle(ctx context.Context, bucketName string, billingProject string) (bh *bucketHandle, err error)
+	BucketHandle(ctx context.Context, bucketName string, billingProject string) (b gcs.Bucket, err error)
 }
 
 type storageClient struct {
@@ -263,6 +263,10 @@ func (sh *storageClient) getStorageLayout(bucketName string) (*controlpb.Storage
 // NewStorageHandle creates control client and stores client config to allow dynamic
 // creation of http or grpc client.
 func NewStorageHandle(ctx context.Context, clientConfig storageutil.StorageClientConfig) (sh StorageHandle, err error) {
+	if clientConfig.S3Endpoint != "" {
+		return newS3StorageHandle(ctx, clientConfig)
+	}
+
 	// The default protocol for the Go Storage control client's folders API is gRPC.
 	// gcsfuse will initially mirror this behavior due to the client's lack of HTTP support.
 	var controlClient *control.StorageControlClient
@@ -318,7 +322,7 @@ func (sh *storageClient) getClient(ctx context.Context, isbucketZonal bool) (*st
 	return nil, fmt.Errorf("invalid client-protocol requested: %s", sh.clientConfig.ClientProtocol)
 }
 
-func (sh *storageClient) BucketHandle(ctx context.Context, bucketName string, billingProject string) (bh *bucketHandle, err error) {
+func (sh *storageClient) BucketHandle(ctx context.Context, bucketName string, billingProject string) (gcs.Bucket, error) {
 	var client *storage.Client
 	bucketType, err := sh.lookupBucketType(bucketName)
 	if err != nil {
@@ -344,12 +348,13 @@ func (sh *storageClient) BucketHandle(ctx context.Context, bucketName string, bi
 		storageBucketHandle = storageBucketHandle.UserProject(billingProject)
 	}
 
-	bh = &bucketHandle{
+	bh := &bucketHandle{
 		bucket:        storageBucketHandle,
 		bucketName:    bucketName,
 		controlClient: sh.storageControlClient,
 		bucketType:    bucketType,
+		encryptionKey: sh.clientConfig.EncryptionKey,
 	}
 
-	return
+	return bh, nil
 }
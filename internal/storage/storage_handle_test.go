@@ -77,15 +77,29 @@ func (testSuite *StorageHandleTest) mockStorageLayout(bucketType gcs.BucketType)
 func (testSuite *StorageHandleTest) TestBucketHandleWhenBucketExistsWithEmptyBillingProject() {
 	storageHandle := testSuite.fakeStorage.CreateStorageHandle()
 	testSuite.mockStorageLayout(gcs.BucketType{})
-	bucketHandle, err := storageHandle.BucketHandle(testSuite.ctx, TestBucketName, "")
+	b, err := storageHandle.BucketHandle(testSuite.ctx, TestBucketName, "")
 
-	assert.NotNil(testSuite.T(), bucketHandle)
+	assert.NotNil(testSuite.T(), b)
 	assert.Nil(testSuite.T(), err)
+	bucketHandle := b.(*bucketHandle)
 	assert.Equal(testSuite.T(), TestBucketName, bucketHandle.bucketName)
 	assert.False(testSuite.T(), bucketHandle.bucketType.Zonal)
 	assert.False(testSuite.T(), bucketHandle.bucketType.Hierarchical)
 }
 
+func (testSuite *StorageHandleTest) TestBucketHandleWithEncryptionKey() {
+	key := []byte("0123456789abcdef0123456789abcdef")
+	storageHandle := testSuite.fakeStorage.CreateStorageHandle()
+	storageHandle.(*storageClient).clientConfig.EncryptionKey = key
+	testSuite.mockStorageLayout(gcs.BucketType{})
+
+	b, err := storageHandle.BucketHandle(testSuite.ctx, TestBucketName, "")
+
+	assert.Nil(testSuite.T(), err)
+	bucketHandle := b.(*bucketHandle)
+	assert.Equal(testSuite.T(), key, bucketHandle.encryptionKey)
+}
+
 func (testSuite *StorageHandleTest) TestBucketHandleWhenBucketDoesNotExistWithEmptyBillingProject() {
 	storageHandle := testSuite.fakeStorage.CreateStorageHandle()
 	testSuite.mockClient.On("GetStorageLayout", mock.Anything, mock.Anything, mock.Anything).
@@ -100,10 +114,11 @@ func (testSuite *StorageHandleTest) TestBucketHandleWhenBucketExistsWithNonEmpty
 	storageHandle := testSuite.fakeStorage.CreateStorageHandle()
 	testSuite.mockStorageLayout(gcs.BucketType{Hierarchical: true})
 
-	bucketHandle, err := storageHandle.BucketHandle(testSuite.ctx, TestBucketName, projectID)
+	b, err := storageHandle.BucketHandle(testSuite.ctx, TestBucketName, projectID)
 
-	assert.NotNil(testSuite.T(), bucketHandle)
+	assert.NotNil(testSuite.T(), b)
 	assert.Nil(testSuite.T(), err)
+	bucketHandle := b.(*bucketHandle)
 	assert.Equal(testSuite.T(), TestBucketName, bucketHandle.bucketName)
 	assert.False(testSuite.T(), bucketHandle.bucketType.Zonal)
 	assert.True(testSuite.T(), bucketHandle.bucketType.Hierarchical)
@@ -217,6 +232,20 @@ func (testSuite *StorageHandleTest) TestNewStorageHandleWhenTokenUrlIsSet() {
 	assert.NotNil(testSuite.T(), handleCreated)
 }
 
+func (testSuite *StorageHandleTest) TestNewStorageHandleWithS3Endpoint() {
+	sc := storageutil.GetDefaultStorageClientConfig()
+	sc.S3Endpoint = "http://localhost:9000"
+	sc.S3Region = "us-east-1"
+
+	handleCreated, err := NewStorageHandle(testSuite.ctx, sc)
+
+	require.NoError(testSuite.T(), err)
+	assert.IsType(testSuite.T(), &s3StorageHandle{}, handleCreated)
+	b, err := handleCreated.BucketHandle(testSuite.ctx, TestBucketName, projectID)
+	require.NoError(testSuite.T(), err)
+	assert.Equal(testSuite.T(), TestBucketName, b.Name())
+}
+
 func (testSuite *StorageHandleTest) TestNewStorageHandleWhenJsonReadEnabled() {
 	sc := storageutil.GetDefaultStorageClientConfig()
 	sc.ExperimentalEnableJsonRead = true
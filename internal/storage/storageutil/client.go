@@ -42,6 +42,10 @@ type StorageClientConfig struct {
 	MaxRetrySleep     time.Duration
 	RetryMultiplier   float64
 
+	// If set, the customer-supplied encryption key with which objects are
+	// read and written.
+	EncryptionKey []byte
+
 	/** HTTP client parameters. */
 	MaxConnsPerHost            int
 	MaxIdleConnsPerHost        int
@@ -56,6 +60,13 @@ type StorageClientConfig struct {
 	// Enabling new API flow for HNS bucket.
 	EnableHNS bool
 
+	/** S3-compatible store parameters. */
+
+	// If set, buckets are those of the S3-compatible object store at this
+	// endpoint, in S3Region, instead of GCS buckets.
+	S3Endpoint string
+	S3Region   string
+
 	ReadStallRetryConfig cfg.ReadStallGcsRetriesConfig
 }
 
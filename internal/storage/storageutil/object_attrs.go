@@ -138,6 +138,7 @@ func SetAttrsInWriter(wc *storage.Writer, req *gcs.CreateObjectRequest) *storage
 	wc.CustomTime, _ = time.Parse(time.RFC3339, req.CustomTime)
 	wc.EventBasedHold = req.EventBasedHold
 	wc.StorageClass = req.StorageClass
+	wc.KMSKeyName = req.KmsKeyName
 
 	// Converting []*storagev1.ObjectAccessControl to []ACLRule for writer object.
 	var aclRules []storage.ACLRule
@@ -158,6 +159,28 @@ func SetAttrsInWriter(wc *storage.Writer, req *gcs.CreateObjectRequest) *storage
 	return wc
 }
 
+// SetAttrsInComposer sets the attributes of the destination of the compose
+// request in the composer.
+func SetAttrsInComposer(c *storage.Composer, req *gcs.ComposeObjectsRequest) *storage.Composer {
+	c.ContentType = req.ContentType
+	c.ContentLanguage = req.ContentLanguage
+	c.ContentEncoding = req.ContentEncoding
+	c.CacheControl = req.CacheControl
+	c.Metadata = req.Metadata
+	c.ContentDisposition = req.ContentDisposition
+	c.CustomTime, _ = time.Parse(time.RFC3339, req.CustomTime)
+	c.EventBasedHold = req.EventBasedHold
+	c.StorageClass = req.StorageClass
+
+	var aclRules []storage.ACLRule
+	for _, element := range req.Acl {
+		aclRules = append(aclRules, convertObjectAccessControlToACLRule(element))
+	}
+	c.ACL = aclRules
+
+	return c
+}
+
 func ConvertObjToMinObject(o *gcs.Object) *gcs.MinObject {
 	if o == nil {
 		return nil
@@ -192,6 +192,7 @@ func (t objectAttrsTest) TestSetAttrsInWriterMethod() {
 		EventBasedHold:             true,
 		StorageClass:               "High Accessibility",
 		Acl:                        nil,
+		KmsKeyName:                 "projects/p/locations/l/keyRings/r/cryptoKeys/k",
 		Contents:                   strings.NewReader("Creating new object"),
 		CRC32C:                     &crc32c,
 		MD5:                        &md5Hash,
@@ -213,11 +214,38 @@ func (t objectAttrsTest) TestSetAttrsInWriterMethod() {
 	ExpectTrue(parsedTime.Equal(writer.CustomTime))
 	ExpectEq(writer.EventBasedHold, createObjectRequest.EventBasedHold)
 	ExpectEq(writer.StorageClass, createObjectRequest.StorageClass)
+	ExpectEq(writer.KMSKeyName, createObjectRequest.KmsKeyName)
 	ExpectEq(writer.CRC32C, *createObjectRequest.CRC32C)
 	ExpectTrue(writer.SendCRC32C)
 	ExpectEq(string(writer.MD5[:]), string(createObjectRequest.MD5[:]))
 }
 
+func (t objectAttrsTest) TestSetAttrsInComposerMethod() {
+	composeObjectsRequest := gcs.ComposeObjectsRequest{
+		DstName:            "test_object",
+		ContentType:        "json",
+		ContentEncoding:    "universal",
+		CacheControl:       "Medium",
+		Metadata:           map[string]string{"file_name": "test.txt"},
+		ContentDisposition: "Test content disposition",
+		CustomTime:         "2024-01-02T15:04:05Z",
+		EventBasedHold:     true,
+		StorageClass:       "ARCHIVE",
+	}
+	composer := &storage.Composer{}
+
+	composer = SetAttrsInComposer(composer, &composeObjectsRequest)
+
+	ExpectEq(composeObjectsRequest.ContentType, composer.ContentType)
+	ExpectEq(composeObjectsRequest.ContentEncoding, composer.ContentEncoding)
+	ExpectEq(composeObjectsRequest.CacheControl, composer.CacheControl)
+	ExpectEq(composeObjectsRequest.Metadata, composer.Metadata)
+	ExpectEq(composeObjectsRequest.ContentDisposition, composer.ContentDisposition)
+	ExpectTrue(time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC).Equal(composer.CustomTime))
+	ExpectTrue(composer.EventBasedHold)
+	ExpectEq(composeObjectsRequest.StorageClass, composer.StorageClass)
+}
+
 func (t objectAttrsTest) Test_ConvertObjToMinObject_WithNilObject() {
 	var gcsObject *gcs.Object
 